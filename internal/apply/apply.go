@@ -0,0 +1,307 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+)
+
+// Applier applies DNS profiles to network services.
+// It is shared by the TUI and the command-line subcommands so both
+// change DNS in exactly the same way.
+type Applier struct {
+	Client   dns.Client
+	Settings config.Settings
+
+	// Checker probes servers after a change. When nil, a probe.Prober
+	// built from Settings is used.
+	Checker probe.Checker
+}
+
+// New creates a new Applier for the given client and settings.
+func New(client dns.Client, settings config.Settings) *Applier {
+	return &Applier{
+		Client:   client,
+		Settings: settings,
+	}
+}
+
+// Result describes a successfully applied profile.
+type Result struct {
+	// Probes holds one result per new server when the servers were
+	// verified, either for Settings.Verify or for rollback.
+	Probes []probe.Result
+}
+
+// RollbackError is returned when none of a profile's servers answered
+// after applying it, and the previous DNS was restored.
+type RollbackError struct {
+	// Probes are the failed verification queries.
+	Probes []probe.Result
+	// Err is set if restoring the previous DNS failed.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *RollbackError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("no server answered (%s) and restoring the previous DNS failed: %v", probe.Summary(e.Probes), e.Err)
+	}
+	return fmt.Sprintf("no server answered (%s); rolled back to previous DNS", probe.Summary(e.Probes))
+}
+
+// Unwrap returns the restore error, if any.
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// Profile applies a profile to a network service.
+// It is Apply without the verification results.
+func (a *Applier) Profile(ctx context.Context, service string, profile config.Profile) error {
+	_, err := a.Apply(ctx, service, profile)
+	return err
+}
+
+// Apply applies a profile to a network service.
+// DHCP profiles clear the service's DNS servers; all others set them.
+// Domains are only replaced when the profile lists some, so a profile
+// without domains leaves the service's own domains alone.
+// The change is bounded by the apply timeout and aborted if ctx is cancelled.
+//
+// Unless the profile opts out, the previous state is captured first and
+// the new servers are probed afterwards. If none of them answer, the
+// previous state is restored and a *RollbackError is returned. The
+// previous state is also restored, even though ctx is done, if the apply
+// is cancelled or times out after changing DNS, or fails after changing
+// the servers but not the domains, or part way through a combined change.
+//
+// The change is made with the profile's persistence, or the global one,
+// and fails if the backend cannot make such changes.
+func (a *Applier) Apply(ctx context.Context, service string, profile config.Profile) (Result, error) {
+	a, err := a.withPersistence(profile)
+	if err != nil {
+		return Result{}, err
+	}
+
+	rollback := profile.RollbackEnabled()
+
+	var previous State
+	if rollback {
+		var err error
+		if previous, err = a.Capture(ctx, service); err != nil {
+			return Result{}, fmt.Errorf("failed to read current DNS: %w", err)
+		}
+	}
+
+	if changed, err := a.change(ctx, service, profile); err != nil {
+		if rollback && (changed || interrupted(ctx, err)) {
+			return Result{}, a.abandon(ctx, service, previous, err)
+		}
+		return Result{}, err
+	}
+	a.flush(ctx)
+
+	var result Result
+	if rollback || a.Settings.Verify {
+		servers, err := a.newServers(ctx, service, profile)
+		if err != nil {
+			return Result{}, err
+		}
+		if len(servers) > 0 {
+			result.Probes = a.checker().ProbeAll(ctx, servers)
+		}
+	}
+
+	// A cancelled apply is not evidence that the servers are broken, but
+	// it should not leave the change behind either
+	if err := ctx.Err(); err != nil {
+		if rollback {
+			return Result{}, a.abandon(ctx, service, previous, err)
+		}
+		return Result{}, err
+	}
+
+	if rollback && len(result.Probes) > 0 && !probe.AnyOK(result.Probes) {
+		return Result{}, &RollbackError{
+			Probes: result.Probes,
+			Err:    a.Restore(ctx, service, previous),
+		}
+	}
+
+	return result, nil
+}
+
+// abandon restores the previous state after an apply failed with err
+// part way, and returns err. The restore runs even if ctx is done, bounded
+// by the apply timeout.
+func (a *Applier) abandon(ctx context.Context, service string, previous State, err error) error {
+	if restoreErr := a.Restore(context.WithoutCancel(ctx), service, previous); restoreErr != nil {
+		return fmt.Errorf("%w; restoring the previous DNS failed: %v", err, restoreErr)
+	}
+	return err
+}
+
+// interrupted reports whether err means a change was cut short, so it may
+// have been made in part.
+func interrupted(ctx context.Context, err error) bool {
+	var timeout *dns.TimeoutError
+	return ctx.Err() != nil || errors.As(err, &timeout)
+}
+
+// withPersistence returns an Applier whose client makes changes with the
+// persistence chosen for a profile. Capturing and restoring go through
+// the same client, so a rollback undoes the change where it was made.
+func (a *Applier) withPersistence(profile config.Profile) (*Applier, error) {
+	persistence := a.Settings.PersistenceFor(profile)
+	if persistence == "" {
+		return a, nil
+	}
+
+	client, err := dns.WithPersistence(a.Client, dns.Persistence(persistence))
+	if err != nil {
+		return nil, err
+	}
+	scoped := *a
+	scoped.Client = client
+	return &scoped, nil
+}
+
+// ServiceResult is the outcome of applying a profile to one service.
+type ServiceResult struct {
+	Service string
+	Result
+	Err error
+}
+
+// ApplyAll applies a profile to each service in turn, so that one
+// service's rollback never races another's change. Every service is
+// attempted even if an earlier one fails; only cancellation stops early,
+// and the remaining services then report the context's error.
+func (a *Applier) ApplyAll(ctx context.Context, services []string, profile config.Profile) []ServiceResult {
+	results := make([]ServiceResult, 0, len(services))
+
+	for _, service := range services {
+		result := ServiceResult{Service: service}
+		if err := ctx.Err(); err != nil {
+			result.Err = err
+		} else {
+			result.Result, result.Err = a.Apply(ctx, service, profile)
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Failed returns the results that have an error.
+func Failed(results []ServiceResult) []ServiceResult {
+	var failed []ServiceResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// change sets or clears the service's DNS for a profile. It reports
+// whether DNS may have changed, even if the change then failed: once the
+// servers are set, or once a combined change has started.
+func (a *Applier) change(ctx context.Context, service string, profile config.Profile) (changed bool, err error) {
+	client := dns.WithContext(a.Client)
+
+	err = dns.WithTimeout(ctx, a.Settings.Timeouts.ApplyTimeout(), func(ctx context.Context) error {
+		// Backends that reactivate a connection per change get one change
+		if combined, ok := a.Client.(dns.CombinedClient); ok && profile.HasDomains() {
+			var servers []string
+			if !profile.IsDHCP() {
+				servers = profile.Servers
+			}
+			// The backend may fail after saving part of the change
+			changed = true
+			return combined.SetDNSAndDomains(ctx, service, servers, profileDomains(profile))
+		}
+
+		if profile.IsDHCP() {
+			// Clear DNS to use DHCP
+			if err := client.ClearDNSServersContext(ctx, service); err != nil {
+				return err
+			}
+		} else {
+			// Set specific DNS servers
+			if err := client.SetDNSServersContext(ctx, service, profile.Servers); err != nil {
+				return err
+			}
+		}
+		changed = true
+
+		if !profile.HasDomains() {
+			return nil
+		}
+		return a.setDomains(ctx, service, profile)
+	})
+	return changed, err
+}
+
+// newServers returns the servers a profile put in place. For DHCP
+// profiles these are read back from the backend, which may report none.
+func (a *Applier) newServers(ctx context.Context, service string, profile config.Profile) ([]string, error) {
+	if !profile.IsDHCP() {
+		return profile.Servers, nil
+	}
+
+	state, err := a.Capture(ctx, service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read new DNS: %w", err)
+	}
+	return state.Servers, nil
+}
+
+// checker returns the configured Checker, or a Prober built from Settings.
+func (a *Applier) checker() probe.Checker {
+	if a.Checker != nil {
+		return a.Checker
+	}
+	return probe.Prober{
+		Name:    a.Settings.VerifyProbeName(),
+		Timeout: a.Settings.Timeouts.ProbeTimeout(),
+	}
+}
+
+// Clear clears the DNS servers of a network service to use DHCP defaults.
+// It behaves like applying a DHCP profile, including rollback.
+func (a *Applier) Clear(ctx context.Context, service string) error {
+	_, err := a.Apply(ctx, service, config.Profile{DHCP: true})
+	return err
+}
+
+// setDomains replaces the domains of a service with the profile's.
+func (a *Applier) setDomains(ctx context.Context, service string, profile config.Profile) error {
+	client, ok := a.Client.(dns.DomainClient)
+	if !ok {
+		return fmt.Errorf("%s does not support search domains", a.Client.Name())
+	}
+
+	return client.SetDomains(ctx, service, profileDomains(profile))
+}
+
+// profileDomains returns the domains a profile lists.
+func profileDomains(profile config.Profile) dns.Domains {
+	return dns.Domains{
+		Search:    profile.Domains,
+		RouteOnly: profile.RouteOnlyDomains,
+	}
+}
+
+// flush flushes the DNS cache if configured.
+// Flush failures are ignored since the DNS change itself succeeded.
+func (a *Applier) flush(ctx context.Context) {
+	if a.Settings.FlushCache {
+		client := dns.WithContext(a.Client)
+		_ = dns.WithTimeout(ctx, a.Settings.Timeouts.FlushTimeout(), client.FlushCacheContext)
+	}
+}
@@ -0,0 +1,603 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+	"github.com/nycjv321/dnsctl/internal/probe/probetest"
+)
+
+// newTestApplier creates an Applier whose servers always answer probes.
+func newTestApplier(client dns.Client, settings config.Settings) *Applier {
+	applier := New(client, settings)
+	applier.Checker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+		results := make([]probe.Result, len(servers))
+		for i, server := range servers {
+			results[i] = probe.Result{Server: server, Latency: time.Millisecond}
+		}
+		return results
+	})
+	return applier
+}
+
+// TestProfile_SetsServers tests that a profile with servers sets DNS.
+func TestProfile_SetsServers(t *testing.T) {
+	mock := dns.NewMockClient()
+	applier := newTestApplier(mock, config.Settings{FlushCache: true})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"9.9.9.9"}})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(mock.SetCalls) != 1 {
+		t.Fatalf("expected 1 set call, got %d", len(mock.SetCalls))
+	}
+	if mock.SetCalls[0].Service != "Wi-Fi" {
+		t.Errorf("expected Wi-Fi, got %s", mock.SetCalls[0].Service)
+	}
+	if mock.FlushCalls != 1 {
+		t.Errorf("expected 1 flush call, got %d", mock.FlushCalls)
+	}
+}
+
+// TestProfile_DHCP_ClearsServers tests that DHCP profiles clear DNS.
+func TestProfile_DHCP_ClearsServers(t *testing.T) {
+	mock := dns.NewMockClient()
+	applier := newTestApplier(mock, config.Settings{})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{DHCP: true})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(mock.ClearCalls) != 1 {
+		t.Fatalf("expected 1 clear call, got %d", len(mock.ClearCalls))
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no set calls, got %d", len(mock.SetCalls))
+	}
+	if mock.FlushCalls != 0 {
+		t.Errorf("expected no flush calls when disabled, got %d", mock.FlushCalls)
+	}
+}
+
+// TestProfile_Error tests that backend errors are returned unchanged.
+func TestProfile_Error(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.SetError = errors.New("permission denied")
+	applier := newTestApplier(mock, config.Settings{FlushCache: true})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"9.9.9.9"}})
+
+	if err == nil || err.Error() != "permission denied" {
+		t.Errorf("expected permission denied, got: %v", err)
+	}
+	if mock.FlushCalls != 0 {
+		t.Errorf("expected no flush after failure, got %d", mock.FlushCalls)
+	}
+}
+
+// TestClear_ClearsServers tests clearing DNS for a service.
+func TestClear_ClearsServers(t *testing.T) {
+	mock := dns.NewMockClient()
+	applier := newTestApplier(mock, config.Settings{FlushCache: true})
+
+	if err := applier.Clear(context.Background(), "Ethernet"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(mock.ClearCalls) != 1 || mock.ClearCalls[0] != "Ethernet" {
+		t.Errorf("expected clear call for Ethernet, got %v", mock.ClearCalls)
+	}
+	if mock.FlushCalls != 1 {
+		t.Errorf("expected 1 flush call, got %d", mock.FlushCalls)
+	}
+}
+
+// TestProfile_Timeout tests that a hung backend is reported as a timeout.
+func TestProfile_Timeout(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.Delay = time.Hour
+	settings := config.Settings{
+		FlushCache: true,
+		Timeouts:   config.Timeouts{Apply: 20 * time.Millisecond},
+	}
+	applier := newTestApplier(mock, settings)
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"9.9.9.9"}})
+
+	var timeoutErr *dns.TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected TimeoutError, got: %v", err)
+	}
+	if mock.FlushCalls != 0 {
+		t.Errorf("expected no flush after timeout, got %d", mock.FlushCalls)
+	}
+}
+
+// TestClear_Cancelled tests that a cancelled context aborts clearing.
+func TestClear_Cancelled(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.Delay = time.Hour
+	applier := newTestApplier(mock, config.Settings{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := applier.Clear(ctx, "Wi-Fi")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
+
+// TestApplyAll_ContinuesAfterError tests that one failing service
+// doesn't stop the rest and is reported on its own.
+func TestApplyAll_ContinuesAfterError(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.Services = []string{"Wi-Fi", "Ethernet", "Thunderbolt"}
+	mock.ServiceErrors = map[string]error{"Ethernet": errors.New("device not managed")}
+	applier := newTestApplier(mock, config.Settings{})
+
+	results := applier.ApplyAll(context.Background(), mock.Services, config.Profile{Servers: []string{"9.9.9.9"}})
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	failed := Failed(results)
+	if len(failed) != 1 || failed[0].Service != "Ethernet" {
+		t.Fatalf("expected only Ethernet to fail, got %+v", failed)
+	}
+	for _, service := range []string{"Wi-Fi", "Thunderbolt"} {
+		if got := mock.DNSServers[service]; len(got) != 1 || got[0] != "9.9.9.9" {
+			t.Errorf("expected 9.9.9.9 on %s, got %v", service, got)
+		}
+	}
+}
+
+// TestApplyAll_Cancelled tests that cancellation stops the remaining services.
+func TestApplyAll_Cancelled(t *testing.T) {
+	mock := dns.NewMockClient()
+	applier := newTestApplier(mock, config.Settings{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := applier.ApplyAll(ctx, []string{"Wi-Fi", "Ethernet"}, config.Profile{Servers: []string{"9.9.9.9"}})
+
+	for _, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("expected %s to be cancelled, got: %v", r.Service, r.Err)
+		}
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no set calls, got %v", mock.SetCalls)
+	}
+}
+
+// TestProfile_SetsDomains tests that search and routing domains are applied with servers.
+func TestProfile_SetsDomains(t *testing.T) {
+	mock := dns.NewMockClient()
+	applier := newTestApplier(mock, config.Settings{})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{
+		Servers:          []string{"10.0.0.53"},
+		Domains:          []string{"corp.example.com"},
+		RouteOnlyDomains: []string{"internal.example.com"},
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(mock.SetDomainsCalls) != 1 {
+		t.Fatalf("expected 1 set domains call, got %d", len(mock.SetDomainsCalls))
+	}
+	domains := mock.SetDomainsCalls[0].Domains
+	if len(domains.Search) != 1 || domains.Search[0] != "corp.example.com" {
+		t.Errorf("expected search domain corp.example.com, got %v", domains.Search)
+	}
+	if len(domains.RouteOnly) != 1 || domains.RouteOnly[0] != "internal.example.com" {
+		t.Errorf("expected routing domain internal.example.com, got %v", domains.RouteOnly)
+	}
+}
+
+// combinedClient is a MockClient that also sets servers and domains at
+// once, recording each such change.
+type combinedClient struct {
+	*dns.MockClient
+	calls []string
+
+	// fail makes the next change set the servers, then fail.
+	fail error
+}
+
+func (c *combinedClient) SetDNSAndDomains(ctx context.Context, service string, servers []string, domains dns.Domains) error {
+	c.calls = append(c.calls, service+": "+strings.Join(servers, ",")+" "+strings.Join(domains.Entries(), ","))
+	if err := c.fail; err != nil {
+		c.fail = nil
+		c.DNSServers[service] = servers
+		return err
+	}
+	return nil
+}
+
+// TestProfile_SetsServersAndDomainsAtOnce tests that backends that can
+// change servers and domains together get one change.
+func TestProfile_SetsServersAndDomainsAtOnce(t *testing.T) {
+	client := &combinedClient{MockClient: dns.NewMockClient()}
+	applier := newTestApplier(client, config.Settings{})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{
+		Servers:          []string{"10.0.0.53"},
+		Domains:          []string{"corp.example.com"},
+		RouteOnlyDomains: []string{"internal.example.com"},
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := strings.Join(client.calls, "; "); got != "Wi-Fi: 10.0.0.53 corp.example.com,~internal.example.com" {
+		t.Errorf("expected one combined change, got %q", got)
+	}
+	if len(client.SetCalls) != 0 || len(client.SetDomainsCalls) != 0 {
+		t.Errorf("expected no separate changes, got %v and %v", client.SetCalls, client.SetDomainsCalls)
+	}
+}
+
+// TestApply_RestoresAfterCombinedChangeFails tests that a combined change
+// that fails part way is rolled back.
+func TestApply_RestoresAfterCombinedChangeFails(t *testing.T) {
+	client := &combinedClient{MockClient: dns.NewMockClient(), fail: errors.New("reapply failed")}
+	client.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	applier := newTestApplier(client, config.Settings{})
+
+	_, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{
+		Servers: []string{"10.0.0.53"},
+		Domains: []string{"corp.example.com"},
+	})
+
+	if err == nil || err.Error() != "reapply failed" {
+		t.Fatalf("expected the change to fail, got: %v", err)
+	}
+	if got := strings.Join(client.calls, "; "); got != "Wi-Fi: 10.0.0.53 corp.example.com; Wi-Fi: 9.9.9.9 " {
+		t.Errorf("expected the previous servers to be restored, got %q", got)
+	}
+}
+
+// TestProfile_KeepsDomains tests that a profile without domains leaves the
+// service's domains alone.
+func TestProfile_KeepsDomains(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.Domains = map[string]dns.Domains{"Wi-Fi": {Search: []string{"corp.example.com"}}}
+	applier := newTestApplier(mock, config.Settings{})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"1.1.1.1"}})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(mock.SetDomainsCalls) != 0 {
+		t.Errorf("expected no set domains calls, got %+v", mock.SetDomainsCalls)
+	}
+	if got := mock.Domains["Wi-Fi"].Search; len(got) != 1 || got[0] != "corp.example.com" {
+		t.Errorf("expected corp.example.com to be kept, got %v", got)
+	}
+}
+
+// TestProfile_NoDomainsWithoutSupport tests that profiles without domains
+// work on backends without domain support.
+func TestProfile_NoDomainsWithoutSupport(t *testing.T) {
+	client := struct{ dns.Client }{dns.NewMockClient()}
+	applier := newTestApplier(client, config.Settings{})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"1.1.1.1"}})
+
+	if err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+// TestProfile_DHCP_KeepsAutomaticDomains tests that DHCP profiles without domains leave them to DHCP.
+func TestProfile_DHCP_KeepsAutomaticDomains(t *testing.T) {
+	mock := dns.NewMockClient()
+	applier := newTestApplier(mock, config.Settings{})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{DHCP: true})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(mock.SetDomainsCalls) != 0 {
+		t.Errorf("expected no set domains calls, got %d", len(mock.SetDomainsCalls))
+	}
+}
+
+// TestProfile_DomainsUnsupported tests that domains fail on backends without domain support.
+func TestProfile_DomainsUnsupported(t *testing.T) {
+	client := struct{ dns.Client }{dns.NewMockClient()}
+	applier := newTestApplier(client, config.Settings{})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{
+		Servers: []string{"10.0.0.53"},
+		Domains: []string{"corp.example.com"},
+	})
+
+	if err == nil || err.Error() != "mock does not support search domains" {
+		t.Errorf("expected unsupported error, got: %v", err)
+	}
+}
+
+// TestApply_Verify tests that each server is probed with the configured name.
+func TestApply_Verify(t *testing.T) {
+	good := probetest.Start(t, probetest.Options{})
+	bad := probetest.Start(t, probetest.Options{Silent: true})
+	applier := New(dns.NewMockClient(), config.Settings{
+		Verify:    true,
+		ProbeName: "probe.example.com",
+		Timeouts:  config.Timeouts{Probe: 100 * time.Millisecond},
+	})
+
+	result, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{Servers: []string{good.Addr, bad.Addr}})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(result.Probes) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(result.Probes))
+	}
+	if !result.Probes[0].OK() {
+		t.Errorf("expected first server to answer, got: %v", result.Probes[0].Err)
+	}
+	if result.Probes[1].OK() {
+		t.Error("expected second server to time out")
+	}
+	if query := <-good.UDPQueries; !strings.Contains(string(query), "\x05probe\x07example\x03com") {
+		t.Errorf("expected query for probe name, got %x", query)
+	}
+}
+
+// silentChecker reports every server as timed out.
+var silentChecker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+	results := make([]probe.Result, len(servers))
+	for i, server := range servers {
+		results[i] = probe.Result{Server: server, Err: context.DeadlineExceeded}
+	}
+	return results
+})
+
+// TestApply_RollsBack tests that the previous servers and domains are restored.
+func TestApply_RollsBack(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	mock.Domains = map[string]dns.Domains{"Wi-Fi": {Search: []string{"home.arpa"}}}
+	applier := New(mock, config.Settings{})
+	applier.Checker = silentChecker
+
+	_, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"192.0.2.1"}})
+
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) {
+		t.Fatalf("expected RollbackError, got: %v", err)
+	}
+	if err.Error() != "no server answered (192.0.2.1 timed out); rolled back to previous DNS" {
+		t.Errorf("unexpected message: %v", err)
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 1 || got[0] != "9.9.9.9" {
+		t.Errorf("expected 9.9.9.9 to be restored, got %v", got)
+	}
+	if got := mock.Domains["Wi-Fi"].Search; len(got) != 1 || got[0] != "home.arpa" {
+		t.Errorf("expected home.arpa to be restored, got %v", got)
+	}
+}
+
+// TestApply_RollsBackToDHCP tests that a previous DHCP state is restored by clearing.
+func TestApply_RollsBackToDHCP(t *testing.T) {
+	mock := dns.NewMockClient()
+	applier := New(mock, config.Settings{})
+	applier.Checker = silentChecker
+
+	_, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"192.0.2.1"}})
+
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) {
+		t.Fatalf("expected RollbackError, got: %v", err)
+	}
+	if len(mock.ClearCalls) != 1 {
+		t.Errorf("expected 1 clear call, got %d", len(mock.ClearCalls))
+	}
+	if len(mock.DNSServers["Wi-Fi"]) != 0 {
+		t.Errorf("expected no servers, got %v", mock.DNSServers["Wi-Fi"])
+	}
+}
+
+// TestApply_RollsBackDomainsToDHCP tests that domains which clearing does
+// not bring back are restored as captured.
+func TestApply_RollsBackDomainsToDHCP(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.Domains = map[string]dns.Domains{"Wi-Fi": {Search: []string{"home.arpa"}}}
+	applier := New(mock, config.Settings{})
+	applier.Checker = silentChecker
+
+	_, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{
+		Servers: []string{"192.0.2.1"},
+		Domains: []string{"corp.example.com"},
+	})
+
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) {
+		t.Fatalf("expected RollbackError, got: %v", err)
+	}
+	if len(mock.ClearCalls) != 1 {
+		t.Errorf("expected 1 clear call, got %d", len(mock.ClearCalls))
+	}
+	if got := mock.Domains["Wi-Fi"].Search; len(got) != 1 || got[0] != "home.arpa" {
+		t.Errorf("expected home.arpa to be restored, got %v", got)
+	}
+}
+
+// dhcpClient is a MockClient whose servers were all learned from DHCP.
+type dhcpClient struct {
+	*dns.MockClient
+}
+
+func (c dhcpClient) HasManualDNS(ctx context.Context, service string) (bool, error) {
+	return false, nil
+}
+
+// TestApply_RollsBackDHCPServers tests that servers learned from DHCP are
+// restored by clearing rather than set manually.
+func TestApply_RollsBackDHCPServers(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.1"}
+	applier := New(dhcpClient{mock}, config.Settings{})
+	applier.Checker = silentChecker
+
+	_, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"192.0.2.1"}})
+
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || rollbackErr.Err != nil {
+		t.Fatalf("expected a successful rollback, got: %v", err)
+	}
+	if len(mock.ClearCalls) != 1 {
+		t.Errorf("expected DHCP to be restored by clearing, got %v", mock.ClearCalls)
+	}
+	if len(mock.SetCalls) != 1 {
+		t.Errorf("expected only the profile's servers to be set, got %v", mock.SetCalls)
+	}
+}
+
+// TestApply_RollbackDisabled tests that profiles can opt out of rollback.
+func TestApply_RollbackDisabled(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	applier := New(mock, config.Settings{})
+	applier.Checker = silentChecker
+	disabled := false
+
+	_, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"192.0.2.1"}, Rollback: &disabled})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 1 || got[0] != "192.0.2.1" {
+		t.Errorf("expected new servers to stay, got %v", got)
+	}
+}
+
+// TestApply_RestoreFails tests that a failed rollback is reported.
+func TestApply_RestoreFails(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	applier := New(mock, config.Settings{})
+	applier.Checker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+		// Fail the restore that follows
+		mock.SetError = errors.New("permission denied")
+		return silentChecker(ctx, servers)
+	})
+
+	_, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"192.0.2.1"}})
+
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) || rollbackErr.Err == nil {
+		t.Fatalf("expected RollbackError with restore failure, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "restoring the previous DNS failed: permission denied") {
+		t.Errorf("unexpected message: %v", err)
+	}
+}
+
+// TestApply_RestoresAfterDomainsFail tests that servers set before the
+// domains failed are restored.
+func TestApply_RestoresAfterDomainsFail(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	domainErr := errors.New("permission denied")
+	mock.DomainError = domainErr
+	applier := newTestApplier(mock, config.Settings{})
+
+	_, err := applier.Apply(context.Background(), "Wi-Fi", config.Profile{
+		Servers: []string{"10.0.0.53"},
+		Domains: []string{"corp.example.com"},
+	})
+
+	if !errors.Is(err, domainErr) {
+		t.Fatalf("expected domain error, got: %v", err)
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 1 || got[0] != "9.9.9.9" {
+		t.Errorf("expected 9.9.9.9 to be restored, got %v", got)
+	}
+}
+
+// TestApply_RestoresWhenCancelled tests that cancelling an apply after
+// the servers were set restores the previous servers.
+func TestApply_RestoresWhenCancelled(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	applier := New(mock, config.Settings{})
+	applier.Checker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+		cancel()
+		return silentChecker(ctx, servers)
+	})
+
+	_, err := applier.Apply(ctx, "Wi-Fi", config.Profile{Servers: []string{"192.0.2.1"}})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got: %v", err)
+	}
+	var rollbackErr *RollbackError
+	if errors.As(err, &rollbackErr) {
+		t.Errorf("expected no RollbackError, got: %v", err)
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 1 || got[0] != "9.9.9.9" {
+		t.Errorf("expected 9.9.9.9 to be restored, got %v", got)
+	}
+}
+
+// TestApply_Persistence tests that a profile's persistence picks the
+// client the change is made with, overriding the global setting.
+func TestApply_Persistence(t *testing.T) {
+	persistent := dns.NewMockClient()
+	persistent.PersistenceMode = dns.Persistent
+	runtime := dns.NewMockClient()
+	runtime.PersistenceMode = dns.Runtime
+	runtime.Alternate = persistent
+	applier := newTestApplier(runtime, config.Settings{Persistence: config.PersistenceRuntime})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{
+		Servers:     []string{"9.9.9.9"},
+		Persistence: config.PersistencePersistent,
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(persistent.SetCalls) != 1 || len(runtime.SetCalls) != 0 {
+		t.Errorf("expected the change to be persistent, got %d persistent and %d runtime calls",
+			len(persistent.SetCalls), len(runtime.SetCalls))
+	}
+}
+
+// TestApply_PersistenceUnsupported tests that a persistence the backend
+// cannot provide fails before anything changes.
+func TestApply_PersistenceUnsupported(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.PersistenceMode = dns.Runtime
+	applier := newTestApplier(mock, config.Settings{Persistence: config.PersistencePersistent})
+
+	err := applier.Profile(context.Background(), "Wi-Fi", config.Profile{Servers: []string{"9.9.9.9"}})
+
+	if !errors.Is(err, dns.ErrPersistenceUnsupported) {
+		t.Errorf("expected ErrPersistenceUnsupported, got: %v", err)
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no set calls, got %d", len(mock.SetCalls))
+	}
+}
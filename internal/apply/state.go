@@ -0,0 +1,99 @@
+package apply
+
+import (
+	"context"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// State is the DNS configuration of a network service at one point in
+// time, as reported by the backend.
+type State struct {
+	// Servers are the servers in use; empty means DHCP.
+	Servers []string
+	// DHCP is set if Servers were learned automatically rather than set
+	// manually, for backends that report both.
+	DHCP bool
+	// Domains are the configured domains, if the backend manages them.
+	Domains dns.Domains
+}
+
+// IsDHCP returns true if the state uses automatic DNS.
+func (s State) IsDHCP() bool {
+	return s.DHCP || len(s.Servers) == 0
+}
+
+// Capture reads the current DNS state of a service, including whether
+// its servers were set manually. The read is bounded by the read timeout.
+func (a *Applier) Capture(ctx context.Context, service string) (State, error) {
+	client := dns.WithContext(a.Client)
+
+	var state State
+	err := dns.WithTimeout(ctx, a.Settings.Timeouts.ReadTimeout(), func(ctx context.Context) error {
+		servers, err := client.GetDNSServersContext(ctx, service)
+		if err != nil {
+			return err
+		}
+		state.Servers = servers
+
+		manual, err := dns.HasManualDNS(ctx, a.Client, service, servers)
+		if err != nil {
+			return err
+		}
+		state.DHCP = len(servers) > 0 && !manual
+
+		if domainClient, ok := a.Client.(dns.DomainClient); ok {
+			domains, err := domainClient.GetDomains(ctx, service)
+			if err != nil {
+				return err
+			}
+			state.Domains = domains
+		}
+		return nil
+	})
+	return state, err
+}
+
+// Restore puts a service back into a captured state.
+// A DHCP state is restored by clearing, even if it lists the servers DHCP
+// gave, which brings back the network's own domains on some backends;
+// the captured domains are only set if they did not come back. Otherwise
+// servers and domains are set as captured. The change is bounded by the
+// apply timeout.
+func (a *Applier) Restore(ctx context.Context, service string, state State) error {
+	client := dns.WithContext(a.Client)
+	domainClient, hasDomains := a.Client.(dns.DomainClient)
+
+	err := dns.WithTimeout(ctx, a.Settings.Timeouts.ApplyTimeout(), func(ctx context.Context) error {
+		if !state.IsDHCP() {
+			if combined, ok := a.Client.(dns.CombinedClient); ok {
+				return combined.SetDNSAndDomains(ctx, service, state.Servers, state.Domains)
+			}
+			if err := client.SetDNSServersContext(ctx, service, state.Servers); err != nil {
+				return err
+			}
+			if hasDomains {
+				return domainClient.SetDomains(ctx, service, state.Domains)
+			}
+			return nil
+		}
+
+		if err := client.ClearDNSServersContext(ctx, service); err != nil {
+			return err
+		}
+		if !hasDomains {
+			return nil
+		}
+		current, err := domainClient.GetDomains(ctx, service)
+		if err != nil || current.Equal(state.Domains) {
+			return err
+		}
+		return domainClient.SetDomains(ctx, service, state.Domains)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.flush(ctx)
+	return nil
+}
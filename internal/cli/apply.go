@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+)
+
+// runApply implements "dnsctl apply <profile>".
+// The profile is applied to each targeted service in turn; if any of them
+// fail, the others are still changed and the failures are listed.
+func runApply(ctx context.Context, a *App, args []string) error {
+	fs := a.newFlagSet("apply")
+	service := fs.String("service", "", "comma-separated network services, globs such as \"en*\", or \"all\" (default: the profile's services, then default_service)")
+	verifyFlag := fs.Bool("verify", false, "query each server after applying (default: settings.verify)")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf("apply requires exactly one profile name")
+	}
+	name := positional[0]
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile, ok := cfg.GetProfile(name)
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+
+	verify := cfg.Settings.Verify
+	if isFlagSet(fs, "verify") {
+		verify = *verifyFlag
+	}
+
+	return a.applyProfile(ctx, cfg, name, profile, applyOptions{service: *service, verify: verify})
+}
+
+// applyOptions controls how applyProfile targets and checks services.
+type applyOptions struct {
+	// service is the --service value; empty uses the profile's services,
+	// then the default service.
+	service string
+	// verify queries the new servers and reports the results.
+	verify bool
+	// skipActive leaves services alone whose servers already match the
+	// profile, so they are not needlessly reconnected.
+	skipActive bool
+}
+
+// applyProfile applies a profile to the targeted services and reports
+// the outcome for each.
+func (a *App) applyProfile(ctx context.Context, cfg *config.Config, name string, profile config.Profile, opts applyOptions) error {
+	client, err := a.client(cfg)
+	if err != nil {
+		return err
+	}
+
+	patterns := profile.Services
+	if opts.service != "" {
+		patterns = splitServices(opts.service)
+	}
+	if len(patterns) == 0 {
+		patterns = []string{cfg.DefaultService}
+	}
+	targets, err := dns.ResolveServices(client, patterns)
+	if err != nil {
+		return err
+	}
+
+	if opts.skipActive {
+		targets = a.skipActive(ctx, client, cfg, name, profile, targets)
+		if len(targets) == 0 {
+			return nil
+		}
+	}
+
+	settings := cfg.Settings
+	settings.Verify = opts.verify
+	applier := apply.New(client, settings)
+	applier.Checker = a.Checker
+
+	results := applier.ApplyAll(ctx, targets, profile)
+
+	// A single service keeps the plain error; several report each failure
+	// and then how many failed
+	if len(results) == 1 {
+		return a.reportApply(name, results[0], opts.verify, settings.VerifyProbeName())
+	}
+
+	failed := 0
+	for _, r := range results {
+		if err := a.reportApply(name, r, opts.verify, settings.VerifyProbeName()); err != nil {
+			fmt.Fprintf(a.Stderr, "Error: %v\n", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to apply profile %s to %d of %d services", name, failed, len(results))
+	}
+	return nil
+}
+
+// skipActive returns the targets whose servers do not already match the
+// profile, reporting the others as already active. Services whose
+// servers cannot be read are kept, so applying reports the problem.
+func (a *App) skipActive(ctx context.Context, client dns.Client, cfg *config.Config, name string, profile config.Profile, targets []string) []string {
+	contextClient := dns.WithContext(client)
+
+	var remaining []string
+	for _, service := range targets {
+		var servers []string
+		var manual bool
+		err := dns.WithTimeout(ctx, cfg.Settings.Timeouts.ReadTimeout(), func(ctx context.Context) (err error) {
+			servers, err = contextClient.GetDNSServersContext(ctx, service)
+			if err != nil {
+				return err
+			}
+			manual, err = dns.HasManualDNS(ctx, client, service, servers)
+			return err
+		})
+		if err == nil && profile.MatchesDNS(servers, !manual, cfg.Settings.MatchOptions()) {
+			fmt.Fprintf(a.Stdout, "Profile %s already active on %s\n", name, service)
+			continue
+		}
+		remaining = append(remaining, service)
+	}
+	return remaining
+}
+
+// reportApply prints the outcome of applying a profile to one service and
+// returns an error if it failed or, when verifying, no server answered.
+func (a *App) reportApply(name string, r apply.ServiceResult, verify bool, probeName string) error {
+	if r.Err != nil {
+		return fmt.Errorf("failed to apply profile %s to %s: %w", name, r.Service, r.Err)
+	}
+
+	fmt.Fprintf(a.Stdout, "Applied profile %s to %s\n", name, r.Service)
+
+	if !verify {
+		return nil
+	}
+	for _, p := range r.Probes {
+		fmt.Fprintf(a.Stdout, "  %s\n", p)
+	}
+	if len(r.Probes) > 0 && !probe.AnyOK(r.Probes) {
+		return fmt.Errorf("verification failed on %s: no server answered a query for %s", r.Service, probeName)
+	}
+	return nil
+}
+
+// splitServices splits a comma-separated --service value into entries.
+func splitServices(value string) []string {
+	var services []string
+	for _, service := range strings.Split(value, ",") {
+		if service = strings.TrimSpace(service); service != "" {
+			services = append(services, service)
+		}
+	}
+	return services
+}
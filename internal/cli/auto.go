@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/network"
+)
+
+// runAuto implements "dnsctl auto".
+// It applies the profile of the first rule matching the current network.
+// Services already using the profile are left alone, so it is cheap to
+// run from network hooks or on a timer.
+func runAuto(ctx context.Context, a *App, args []string) error {
+	fs := a.newFlagSet("auto")
+	service := fs.String("service", "", "comma-separated network services, globs such as \"en*\", or \"all\" (default: the profile's services, then default_service)")
+	verifyFlag := fs.Bool("verify", false, "query each server after applying (default: settings.verify)")
+	dryRun := fs.Bool("dry-run", false, "show the matching rule without applying it")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageErrorf("auto takes no arguments")
+	}
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Rules) == 0 {
+		return fmt.Errorf("no rules configured in %s", a.configPath())
+	}
+
+	info, err := a.detect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to detect network: %w", err)
+	}
+	fmt.Fprintf(a.Stdout, "Network: %s\n", describeNetwork(info))
+
+	idx := info.MatchRule(cfg.Rules)
+	if idx == -1 {
+		fmt.Fprintln(a.Stdout, "No rule matches the current network")
+		return nil
+	}
+	rule := cfg.Rules[idx]
+	fmt.Fprintf(a.Stdout, "Matched rule %d: profile %s\n", idx+1, rule.Profile)
+
+	if *dryRun {
+		return nil
+	}
+
+	// Validation guarantees the profile exists
+	profile, _ := cfg.GetProfile(rule.Profile)
+
+	verify := cfg.Settings.Verify
+	if isFlagSet(fs, "verify") {
+		verify = *verifyFlag
+	}
+
+	return a.applyProfile(ctx, cfg, rule.Profile, profile, applyOptions{
+		service:    *service,
+		verify:     verify,
+		skipActive: true,
+	})
+}
+
+// detect describes the current network.
+func (a *App) detect(ctx context.Context) (network.Info, error) {
+	if a.Detect != nil {
+		return a.Detect(ctx)
+	}
+	return network.NewDetector().Detect(ctx)
+}
+
+// describeNetwork summarizes the facts rules can match on.
+func describeNetwork(info network.Info) string {
+	var parts []string
+	if info.SSID != "" {
+		parts = append(parts, fmt.Sprintf("SSID %q", info.SSID))
+	}
+	if info.Gateway.IsValid() {
+		gateway := "gateway " + info.Gateway.String()
+		if info.GatewayMAC != "" {
+			gateway += " (" + info.GatewayMAC + ")"
+		}
+		parts = append(parts, gateway)
+	}
+	for _, iface := range info.Interfaces {
+		var prefixes []string
+		for _, prefix := range iface.Prefixes {
+			prefixes = append(prefixes, prefix.String())
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", iface.Name, strings.Join(prefixes, " ")))
+	}
+
+	if len(parts) == 0 {
+		return "not connected"
+	}
+	return strings.Join(parts, ", ")
+}
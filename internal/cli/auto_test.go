@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/network"
+)
+
+// autoConfigYAML has rules for an office and a home network.
+const autoConfigYAML = `version: 1
+default_service: Wi-Fi
+profiles:
+  home:
+    servers: ["192.168.1.100"]
+  office:
+    servers: ["10.0.0.53"]
+rules:
+  - profile: office
+    subnet: 10.20.0.0/16
+  - profile: home
+    ssid: HomeNet
+    gateway_mac: "a0:b1:c2:d3:e4:f5"
+`
+
+// homeNetwork is the network matched by the home rule.
+var homeNetwork = network.Info{
+	SSID:       "HomeNet",
+	Gateway:    netip.MustParseAddr("192.168.1.1"),
+	GatewayMAC: "a0:b1:c2:d3:e4:f5",
+	Interfaces: []network.Interface{
+		{Name: "wlan0", Prefixes: []netip.Prefix{netip.MustParsePrefix("192.168.1.23/24")}},
+	},
+}
+
+// useNetwork writes the rules config and makes the app see info.
+func useNetwork(t *testing.T, app *App, info network.Info) {
+	t.Helper()
+
+	if err := os.WriteFile(app.ConfigPath, []byte(autoConfigYAML), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	app.Detect = func(ctx context.Context) (network.Info, error) {
+		return info, nil
+	}
+}
+
+// TestAuto_AppliesMatchingProfile tests that the first matching rule is applied.
+func TestAuto_AppliesMatchingProfile(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	useNetwork(t, app, homeNetwork)
+
+	code := app.Run([]string{"auto"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Matched rule 2: profile home") {
+		t.Errorf("expected matched rule, got:\n%s", stdout.String())
+	}
+	if len(mock.SetCalls) != 1 || mock.SetCalls[0].Servers[0] != "192.168.1.100" {
+		t.Errorf("expected home servers to be set, got %v", mock.SetCalls)
+	}
+}
+
+// TestAuto_SkipsActiveProfile tests that a service already using the
+// profile is not changed again.
+func TestAuto_SkipsActiveProfile(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	useNetwork(t, app, homeNetwork)
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.100"}
+
+	code := app.Run([]string{"auto"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Profile home already active on Wi-Fi") {
+		t.Errorf("expected already active message, got:\n%s", stdout.String())
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no set calls, got %v", mock.SetCalls)
+	}
+}
+
+// TestAuto_SkipsActiveDHCPProfile tests that a DHCP profile is active on
+// a service using the servers DHCP gave it.
+func TestAuto_SkipsActiveDHCPProfile(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	config := autoConfigYAML + `  - profile: traveling
+    ssid: Airport
+`
+	config = strings.Replace(config, "rules:", "  traveling:\n    dhcp: true\nrules:", 1)
+	if err := os.WriteFile(app.ConfigPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	app.Detect = func(ctx context.Context) (network.Info, error) {
+		return network.Info{SSID: "Airport"}, nil
+	}
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.1"}
+	mock.Automatic = map[string]bool{"Wi-Fi": true}
+
+	code := app.Run([]string{"auto"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Profile traveling already active on Wi-Fi") {
+		t.Errorf("expected already active message, got:\n%s", stdout.String())
+	}
+	if len(mock.ClearCalls) != 0 {
+		t.Errorf("expected no clear calls, got %v", mock.ClearCalls)
+	}
+}
+
+// TestAuto_NoMatch tests that nothing changes when no rule matches.
+func TestAuto_NoMatch(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	useNetwork(t, app, network.Info{SSID: "Airport"})
+
+	code := app.Run([]string{"auto"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "No rule matches the current network") {
+		t.Errorf("expected no match message, got:\n%s", stdout.String())
+	}
+	if len(mock.SetCalls) != 0 || len(mock.ClearCalls) != 0 {
+		t.Errorf("expected no changes, got %v %v", mock.SetCalls, mock.ClearCalls)
+	}
+}
+
+// TestAuto_DryRun tests that --dry-run reports the match without applying.
+func TestAuto_DryRun(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	office := network.Info{Interfaces: []network.Interface{
+		{Name: "en7", Prefixes: []netip.Prefix{netip.MustParsePrefix("10.20.4.7/16")}},
+	}}
+	useNetwork(t, app, office)
+
+	code := app.Run([]string{"auto", "--dry-run"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Network: en7 10.20.4.7/16") {
+		t.Errorf("expected network summary, got:\n%s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "Matched rule 1: profile office") {
+		t.Errorf("expected office rule, got:\n%s", stdout.String())
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no set calls, got %v", mock.SetCalls)
+	}
+}
+
+// TestAuto_NoRules tests that auto fails without a rules section.
+func TestAuto_NoRules(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"auto"})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "no rules configured") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+// TestAuto_DetectError tests that detection failures are reported.
+func TestAuto_DetectError(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+	useNetwork(t, app, network.Info{})
+	app.Detect = func(ctx context.Context) (network.Info, error) {
+		return network.Info{}, errors.New("permission denied")
+	}
+
+	code := app.Run([]string{"auto"})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "failed to detect network: permission denied") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// runBackends implements "dnsctl backends".
+func runBackends(ctx context.Context, a *App, args []string) error {
+	fs := a.newFlagSet("backends")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageErrorf("backends takes no arguments")
+	}
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	detect := a.DetectBackends
+	if detect == nil {
+		detect = dns.DetectBackends
+	}
+	detections := detect()
+
+	// The selected backend is the named one, or else the first usable one
+	name := a.backendName(cfg)
+	source := "automatically"
+	switch {
+	case a.Backend != "" && a.Backend != dns.BackendAuto:
+		source = "by --backend"
+	case name != "" && name != dns.BackendAuto:
+		source = "by settings.backend"
+	default:
+		name = ""
+		for _, d := range detections {
+			if d.Err == nil && !d.Manual {
+				name = d.Name
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, d := range detections {
+		marker := " "
+		if d.Name == name {
+			marker = "*"
+		}
+		if d.Err != nil {
+			fmt.Fprintf(&b, "%s %-15s %-4d rejected: %v\n", marker, d.Name, d.Priority, d.Err)
+		} else {
+			fmt.Fprintf(&b, "%s %-15s %-4d detected: %s", marker, d.Name, d.Priority, d.Description)
+			if d.Manual {
+				b.WriteString(" (only when selected)")
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n")
+	if name == "" {
+		b.WriteString("No backend is usable.\n")
+	} else {
+		fmt.Fprintf(&b, "* selected %s\n", source)
+	}
+
+	_, err = fmt.Fprint(a.Stdout, b.String())
+	return err
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// testDetections returns a rejected backend, two usable ones and a usable
+// manual one.
+func testDetections() []dns.Detection {
+	return []dns.Detection{
+		{Backend: dns.Backend{Name: "networkmanager", Priority: 40}, Err: errors.New("NetworkManager is inactive")},
+		{Backend: dns.Backend{Name: "resolved", Description: "systemd-resolved", Priority: 50}},
+		{Backend: dns.Backend{Name: "resolv.conf", Description: "/etc/resolv.conf", Priority: 10}},
+		{Backend: dns.Backend{Name: "networkd", Description: "systemd-networkd drop-ins", Manual: true}},
+	}
+}
+
+// TestBackends_Auto tests that backends lists why each backend was
+// rejected and marks the first usable one.
+func TestBackends_Auto(t *testing.T) {
+	app, _, stdout, _ := testApp(t)
+	app.DetectBackends = testDetections
+
+	code := app.Run([]string{"backends"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"  networkmanager  40   rejected: NetworkManager is inactive",
+		"* resolved        50   detected: systemd-resolved",
+		"  resolv.conf     10   detected: /etc/resolv.conf",
+		"  networkd        0    detected: systemd-networkd drop-ins (only when selected)",
+		"* selected automatically",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+// TestBackends_Selected tests that the backend from settings.backend or
+// --backend is marked, even if another has a higher priority.
+func TestBackends_Selected(t *testing.T) {
+	app, _, stdout, _ := testApp(t)
+	app.DetectBackends = testDetections
+	if err := os.WriteFile(app.ConfigPath, []byte(testConfigYAML+"  backend: resolv.conf\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	app.Run([]string{"backends"})
+	if !strings.Contains(stdout.String(), "* resolv.conf") || !strings.Contains(stdout.String(), "* selected by settings.backend") {
+		t.Errorf("expected resolv.conf selected by settings.backend, got:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	app.Run([]string{"--backend", "networkd", "backends"})
+	if !strings.Contains(stdout.String(), "* networkd") || !strings.Contains(stdout.String(), "* selected by --backend") {
+		t.Errorf("expected networkd selected by --backend, got:\n%s", stdout.String())
+	}
+}
+
+// TestBackends_Names tests that every backend of this platform can be
+// named in settings.backend.
+func TestBackends_Names(t *testing.T) {
+	for _, backend := range dns.Backends() {
+		if !slices.Contains(config.Backends, backend.Name) {
+			t.Errorf("expected %s in config.Backends", backend.Name)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/helper"
+	"github.com/nycjv321/dnsctl/internal/network"
+	"github.com/nycjv321/dnsctl/internal/probe"
+	"github.com/nycjv321/dnsctl/internal/server"
+	"github.com/nycjv321/dnsctl/internal/tui"
+)
+
+// App holds the dependencies shared by the TUI and all subcommands.
+type App struct {
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// ConfigPath is the configuration file to load.
+	// An empty path uses config.DefaultConfigPath.
+	ConfigPath string
+
+	// Backend selects the DNS backend, overriding settings.backend. It
+	// is set by the --backend flag.
+	Backend string
+
+	// NewClient creates the DNS client for a backend name, which is empty
+	// or "auto" to detect one. It defaults to dns.NewBackendClient and is
+	// overridden in tests.
+	NewClient func(backend string) (dns.Client, error)
+
+	// DetectBackends probes the backends for "dnsctl backends". It
+	// defaults to dns.DetectBackends and is overridden in tests.
+	DetectBackends func() []dns.Detection
+
+	// Checker probes servers after a change. Nil sends real DNS queries;
+	// tests override it.
+	Checker probe.Checker
+
+	// Detect describes the current network for "dnsctl auto". Nil
+	// inspects the running system; tests override it.
+	Detect func(ctx context.Context) (network.Info, error)
+}
+
+// New creates an App that writes to the process's standard streams.
+func New() *App {
+	return &App{
+		Stdout:         os.Stdout,
+		Stderr:         os.Stderr,
+		NewClient:      dns.NewBackendClient,
+		DetectBackends: dns.DetectBackends,
+	}
+}
+
+// command describes a subcommand.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, a *App, args []string) error
+
+	// hidden commands are not listed in the help.
+	hidden bool
+}
+
+// commands returns all available subcommands.
+func (a *App) commands() []command {
+	return []command{
+		{
+			name:    "apply",
+			usage:   "apply <profile> [--service NAME[,NAME...]|GLOB|all] [--verify]",
+			summary: "Apply a DNS profile to one or more network services",
+			run:     runApply,
+		},
+		{
+			name:    "auto",
+			usage:   "auto [--service NAME[,NAME...]|GLOB|all] [--verify] [--dry-run]",
+			summary: "Apply the profile of the first rule matching the current network",
+			run:     runAuto,
+		},
+		{
+			name:    "daemon",
+			usage:   "daemon",
+			summary: "Keep profiles applied as the network changes",
+			run:     runDaemon,
+		},
+		{
+			name:    "doctor",
+			usage:   "doctor [--output json|text]",
+			summary: "Diagnose why DNS may not work as configured",
+			run:     runDoctor,
+		},
+		{
+			name:    "serve",
+			usage:   "serve [--socket PATH] [--group NAME]",
+			summary: "Serve the local control API on a Unix socket",
+			run:     runServe,
+		},
+		{
+			name:    "helper",
+			usage:   "helper <request>",
+			summary: "Make one DNS change as the privileged helper",
+			run:     runHelper,
+			hidden:  true,
+		},
+		{
+			name:    "status",
+			usage:   "status [--output json|yaml|text]",
+			summary: "Show DNS servers and matching profiles for every service",
+			run:     runStatus,
+		},
+		{
+			name:    "backends",
+			usage:   "backends",
+			summary: "List the DNS backends and why any were rejected",
+			run:     runBackends,
+		},
+		{
+			name:    "config",
+			usage:   "config validate [path]",
+			summary: "Check the configuration file for errors",
+			run:     runConfig,
+		},
+		{
+			name:    "snapshot",
+			usage:   "snapshot save|restore [name] | snapshot list",
+			summary: "Save or restore the DNS settings of every service",
+			run:     runSnapshot,
+		},
+	}
+}
+
+// usageError indicates the command line was invalid.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// usageErrorf creates a usageError with a formatted message.
+func usageErrorf(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Run executes the command line and returns the process exit code.
+// With no arguments it launches the interactive TUI.
+func (a *App) Run(args []string) int {
+	args, err := a.parseGlobalFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		a.printUsage(a.Stdout)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(a.Stderr, "Error: %v\n\n", err)
+		a.printUsage(a.Stderr)
+		return 2
+	}
+
+	if len(args) == 0 {
+		return a.runTUI()
+	}
+
+	name := args[0]
+	if name == "help" {
+		a.printUsage(a.Stdout)
+		return 0
+	}
+
+	for _, cmd := range a.commands() {
+		if cmd.name != name {
+			continue
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := cmd.run(ctx, a, args[1:])
+		stop()
+
+		if err == nil {
+			return 0
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+
+		var usageErr *usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(a.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(a.Stderr, "Usage: dnsctl %s\n", cmd.usage)
+			return 2
+		}
+
+		a.printError(err)
+		return 1
+	}
+
+	fmt.Fprintf(a.Stderr, "Error: unknown command %q\n\n", name)
+	a.printUsage(a.Stderr)
+	return 2
+}
+
+// parseGlobalFlags parses the flags before the command, which apply to
+// every command and the TUI, and returns the remaining arguments.
+func (a *App) parseGlobalFlags(args []string) ([]string, error) {
+	fs := flag.NewFlagSet("dnsctl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&a.Backend, "backend", a.Backend, "")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := config.ValidateBackend(a.Backend); err != nil {
+		return nil, fmt.Errorf("--backend: %w", err)
+	}
+	return fs.Args(), nil
+}
+
+// runTUI launches the interactive TUI.
+func (a *App) runTUI() int {
+	cfg, err := a.loadConfig()
+	if err != nil {
+		a.printError(err)
+		return 1
+	}
+
+	model, err := a.tuiModel(cfg)
+	if err != nil {
+		a.printError(err)
+		return 1
+	}
+
+	p := tea.NewProgram(model, tea.WithAltScreen())
+
+	if _, err := p.Run(); err != nil {
+		fmt.Fprintf(a.Stderr, "Error running program: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// tuiModel returns the TUI's model. With server.connect, the TUI reads
+// through the server and changes DNS by applying the server's profiles,
+// so it needs no privileges of its own. Otherwise it uses the backend and
+// saves a snapshot before each change.
+func (a *App) tuiModel(cfg *config.Config) (tui.Model, error) {
+	if !cfg.Server.Connect {
+		client, err := a.client(cfg)
+		if err != nil {
+			return tui.Model{}, err
+		}
+		return tui.NewModel(cfg, client).WithSnapshots(a.snapshots()), nil
+	}
+
+	ctx := context.Background()
+	client, err := server.Connect(ctx, cfg.Server.SocketPath())
+	if err != nil {
+		return tui.Model{}, err
+	}
+	profiles, err := client.Profiles(ctx)
+	if err != nil {
+		return tui.Model{}, fmt.Errorf("failed to list the server's profiles: %w", err)
+	}
+
+	connected := *cfg
+	connected.Profiles = profiles
+	return tui.NewModel(&connected, client).WithRemote(client), nil
+}
+
+// printUsage writes the top-level help text.
+func (a *App) printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: dnsctl [--backend NAME] [command] [flags]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Run without a command to launch the interactive TUI.")
+	fmt.Fprintln(w, "--backend overrides settings.backend; see \"dnsctl backends\".")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range a.commands() {
+		if cmd.hidden {
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "  %-10s %s\n", "help", "Show this help")
+}
+
+// printError reports a command failure on stderr.
+func (a *App) printError(err error) {
+	if errors.Is(err, dns.ErrNoDNSBackend) {
+		fmt.Fprintln(a.Stderr, "Error: no supported DNS management system detected")
+		fmt.Fprintln(a.Stderr, "")
+		fmt.Fprintln(a.Stderr, "Supported systems:")
+		fmt.Fprintln(a.Stderr, "  - macOS with networksetup")
+		fmt.Fprintln(a.Stderr, "  - Linux with systemd-resolved (resolvectl)")
+		fmt.Fprintln(a.Stderr, "  - Linux with NetworkManager (nmcli)")
+		fmt.Fprintln(a.Stderr, "  - Linux with openresolv or resolvconf")
+		fmt.Fprintln(a.Stderr, "  - Linux with a plain /etc/resolv.conf")
+		fmt.Fprintln(a.Stderr, "")
+		fmt.Fprintln(a.Stderr, "Run \"dnsctl backends\" to see why each was rejected.")
+		return
+	}
+
+	fmt.Fprintf(a.Stderr, "Error: %v\n", err)
+}
+
+// loadConfig loads and validates the configuration file.
+func (a *App) loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(a.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s:\n%w", a.configPath(), err)
+	}
+	return cfg, nil
+}
+
+// configPath returns the configuration file path in use.
+func (a *App) configPath() string {
+	if a.ConfigPath != "" {
+		return a.ConfigPath
+	}
+	return config.DefaultConfigPath()
+}
+
+// client creates the DNS client for commands that run as the user. With
+// settings.elevate, changes are made by the privileged helper.
+func (a *App) client(cfg *config.Config) (dns.Client, error) {
+	name := a.backendName(cfg)
+	c, err := a.backend(name)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Settings.Elevate == "" {
+		return c, nil
+	}
+	return helper.New(c, name, cfg.Settings.Elevate, cmdexec.Exec())
+}
+
+// backendName returns the backend to use: the --backend flag's, else
+// settings.backend. Empty or "auto" detects it.
+func (a *App) backendName(cfg *config.Config) string {
+	if a.Backend != "" {
+		return a.Backend
+	}
+	return cfg.Settings.Backend
+}
+
+// backend creates the DNS client that calls the named backend directly.
+func (a *App) backend(name string) (dns.Client, error) {
+	newClient := a.NewClient
+	if newClient == nil {
+		newClient = dns.NewBackendClient
+	}
+
+	c, err := newClient(name)
+	if err != nil {
+		if errors.Is(err, dns.ErrNoDNSBackend) || errors.Is(err, dns.ErrUnknownBackend) || errors.Is(err, dns.ErrBackendUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("creating DNS client: %w", err)
+	}
+	return c, nil
+}
+
+// newFlagSet creates a flag set for a subcommand that reports to stderr.
+func (a *App) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.Stderr)
+	fs.Usage = func() {
+		for _, cmd := range a.commands() {
+			if cmd.name == name {
+				fmt.Fprintf(a.Stderr, "Usage: dnsctl %s\n", cmd.usage)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// isFlagSet reports whether the named flag was given on the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// parseArgs parses flags that may appear before or after positional
+// arguments, e.g. "apply home --service wlan0". It returns the positional
+// arguments in order.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,473 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+	"github.com/nycjv321/dnsctl/internal/probe/probetest"
+)
+
+// testConfigYAML is a configuration with known profiles.
+const testConfigYAML = `version: 1
+default_service: Wi-Fi
+profiles:
+  cloudflare:
+    description: Cloudflare DNS
+    servers: ["1.1.1.1", "1.0.0.1"]
+  traveling:
+    description: Use DHCP
+    dhcp: true
+settings:
+  flush_cache: true
+`
+
+// testApp creates an App backed by a mock client and a temp config file.
+func testApp(t *testing.T) (*App, *dns.MockClient, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(testConfigYAML), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	mock := dns.NewMockClient()
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	app := &App{
+		Stdout:     stdout,
+		Stderr:     stderr,
+		ConfigPath: configPath,
+		NewClient: func(string) (dns.Client, error) {
+			return mock, nil
+		},
+		Checker: answeringChecker,
+	}
+	return app, mock, stdout, stderr
+}
+
+// answeringChecker reports every server as answering without sending queries.
+var answeringChecker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+	results := make([]probe.Result, len(servers))
+	for i, server := range servers {
+		results[i] = probe.Result{Server: server, Latency: time.Millisecond}
+	}
+	return results
+})
+
+// TestRun_Help tests that help prints usage and succeeds.
+func TestRun_Help(t *testing.T) {
+	app, _, stdout, _ := testApp(t)
+
+	code := app.Run([]string{"help"})
+
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "apply") {
+		t.Error("expected usage to list apply command")
+	}
+}
+
+// TestRun_UnknownCommand tests that unknown commands fail with usage.
+func TestRun_UnknownCommand(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"bogus"})
+
+	if code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "bogus"`) {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+// TestRun_BackendFlag tests that --backend selects the backend, overriding
+// settings.backend.
+func TestRun_BackendFlag(t *testing.T) {
+	app, mock, _, _ := testApp(t)
+	if err := os.WriteFile(app.ConfigPath, []byte(testConfigYAML+"  backend: resolved\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	var backend string
+	app.NewClient = func(name string) (dns.Client, error) {
+		backend = name
+		return mock, nil
+	}
+
+	code := app.Run([]string{"--backend", "networkmanager", "apply", "cloudflare"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if backend != "networkmanager" {
+		t.Errorf("expected networkmanager, got %q", backend)
+	}
+}
+
+// TestRun_BackendSetting tests that settings.backend selects the backend.
+func TestRun_BackendSetting(t *testing.T) {
+	app, mock, _, _ := testApp(t)
+	if err := os.WriteFile(app.ConfigPath, []byte(testConfigYAML+"  backend: resolved\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	var backend string
+	app.NewClient = func(name string) (dns.Client, error) {
+		backend = name
+		return mock, nil
+	}
+
+	code := app.Run([]string{"apply", "cloudflare"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if backend != "resolved" {
+		t.Errorf("expected resolved, got %q", backend)
+	}
+}
+
+// TestRun_InvalidBackendFlag tests that unknown backends are usage errors.
+func TestRun_InvalidBackendFlag(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"--backend", "bogus", "status"})
+
+	if code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `--backend: unsupported value "bogus"`) {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+// TestRun_BackendUnavailable tests that a selected backend that cannot be
+// used is reported without wrapping.
+func TestRun_BackendUnavailable(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+	app.NewClient = func(name string) (dns.Client, error) {
+		return nil, fmt.Errorf("%w: %s: NetworkManager is inactive", dns.ErrBackendUnavailable, name)
+	}
+
+	code := app.Run([]string{"--backend", "networkmanager", "status"})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Error: backend not available: networkmanager: NetworkManager is inactive") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+// TestApply_SetsServers tests applying a profile to the default service.
+func TestApply_SetsServers(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+
+	code := app.Run([]string{"apply", "cloudflare"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if len(mock.SetCalls) != 1 {
+		t.Fatalf("expected 1 set call, got %d", len(mock.SetCalls))
+	}
+	if mock.SetCalls[0].Service != "Wi-Fi" {
+		t.Errorf("expected Wi-Fi, got %s", mock.SetCalls[0].Service)
+	}
+	if mock.FlushCalls != 1 {
+		t.Errorf("expected 1 flush call, got %d", mock.FlushCalls)
+	}
+	if !strings.Contains(stdout.String(), "Applied profile cloudflare to Wi-Fi") {
+		t.Errorf("unexpected stdout: %s", stdout.String())
+	}
+}
+
+// TestApply_ServiceFlagAfterProfile tests that --service may follow the profile.
+func TestApply_ServiceFlagAfterProfile(t *testing.T) {
+	app, mock, _, _ := testApp(t)
+
+	code := app.Run([]string{"apply", "traveling", "--service", "wlan0"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if len(mock.ClearCalls) != 1 || mock.ClearCalls[0] != "wlan0" {
+		t.Errorf("expected clear call for wlan0, got %v", mock.ClearCalls)
+	}
+}
+
+// TestApply_UnknownProfile tests that unknown profiles fail.
+func TestApply_UnknownProfile(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+
+	code := app.Run([]string{"apply", "nonexistent"})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown profile "nonexistent"`) {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+	if len(mock.SetCalls) != 0 || len(mock.ClearCalls) != 0 {
+		t.Error("expected no DNS changes")
+	}
+}
+
+// TestApply_MissingProfile tests that a profile name is required.
+func TestApply_MissingProfile(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"apply"})
+
+	if code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Usage: dnsctl apply") {
+		t.Errorf("expected usage, got: %s", stderr.String())
+	}
+}
+
+// TestApply_BackendError tests that backend failures exit non-zero.
+func TestApply_BackendError(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+	mock.SetError = errors.New("permission denied")
+
+	code := app.Run([]string{"apply", "cloudflare"})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "permission denied") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+// TestApply_NoBackend tests the message shown when no backend is available.
+func TestApply_NoBackend(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+	app.NewClient = func(string) (dns.Client, error) {
+		return nil, dns.ErrNoDNSBackend
+	}
+
+	code := app.Run([]string{"apply", "cloudflare"})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Supported systems:") {
+		t.Errorf("expected supported systems list, got: %s", stderr.String())
+	}
+}
+
+// TestApply_ResolvesAutoService tests that "auto" targets the detected service.
+func TestApply_ResolvesAutoService(t *testing.T) {
+	app, mock, _, _ := testApp(t)
+	mock.DefaultName = "Ethernet"
+
+	code := app.Run([]string{"apply", "cloudflare", "--service", "auto"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if len(mock.SetCalls) != 1 || mock.SetCalls[0].Service != "Ethernet" {
+		t.Errorf("expected set call for Ethernet, got %v", mock.SetCalls)
+	}
+}
+
+// TestApply_MultipleServices tests a comma-separated list and a glob.
+func TestApply_MultipleServices(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	mock.Services = []string{"en0", "en7", "wlan0"}
+
+	code := app.Run([]string{"apply", "cloudflare", "--service", "wlan0,en*"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	for _, service := range []string{"wlan0", "en0", "en7"} {
+		if !strings.Contains(stdout.String(), "Applied profile cloudflare to "+service) {
+			t.Errorf("expected %s to be reported, got:\n%s", service, stdout.String())
+		}
+	}
+	if len(mock.SetCalls) != 3 {
+		t.Errorf("expected 3 set calls, got %v", mock.SetCalls)
+	}
+}
+
+// TestApply_AllServices tests that "all" targets every service.
+func TestApply_AllServices(t *testing.T) {
+	app, mock, _, _ := testApp(t)
+
+	code := app.Run([]string{"apply", "traveling", "--service", "all"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if strings.Join(mock.ClearCalls, ",") != "Wi-Fi,Ethernet" {
+		t.Errorf("expected both services to be cleared, got %v", mock.ClearCalls)
+	}
+}
+
+// TestApply_PartialFailure tests that each failing service is reported and
+// the others are still changed.
+func TestApply_PartialFailure(t *testing.T) {
+	app, mock, stdout, stderr := testApp(t)
+	mock.ServiceErrors = map[string]error{"Ethernet": errors.New("device not managed")}
+
+	code := app.Run([]string{"apply", "cloudflare", "--service", "all"})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Applied profile cloudflare to Wi-Fi") {
+		t.Errorf("expected Wi-Fi success, got:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "failed to apply profile cloudflare to Ethernet: device not managed") {
+		t.Errorf("expected Ethernet failure, got:\n%s", stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "failed to apply profile cloudflare to 1 of 2 services") {
+		t.Errorf("expected failure count, got:\n%s", stderr.String())
+	}
+}
+
+// TestApply_ProfileServices tests that a profile's services are used when
+// no --service is given.
+func TestApply_ProfileServices(t *testing.T) {
+	app, mock, _, _ := testApp(t)
+	config := `version: 1
+default_service: Wi-Fi
+profiles:
+  office:
+    servers: ["10.0.0.53"]
+    services: ["Ethernet"]
+`
+	if err := os.WriteFile(app.ConfigPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	code := app.Run([]string{"apply", "office"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if len(mock.SetCalls) != 1 || mock.SetCalls[0].Service != "Ethernet" {
+		t.Errorf("expected set call for Ethernet, got %v", mock.SetCalls)
+	}
+}
+
+// TestApply_NoMatchingService tests that a glob matching nothing fails.
+func TestApply_NoMatchingService(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+
+	code := app.Run([]string{"apply", "cloudflare", "--service", "en*"})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "no network service matches: en*") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no set calls, got %v", mock.SetCalls)
+	}
+}
+
+// TestApply_Verify tests that --verify queries each server and reports it.
+func TestApply_Verify(t *testing.T) {
+	app, _, stdout, stderr := testApp(t)
+	app.Checker = nil
+	good := probetest.Start(t, probetest.Options{})
+	bad := probetest.Start(t, probetest.Options{Silent: true})
+	config := fmt.Sprintf(`version: 1
+profiles:
+  local:
+    servers: [%q, %q]
+settings:
+  timeouts:
+    probe: 100ms
+`, good.Addr, bad.Addr)
+	if err := os.WriteFile(app.ConfigPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	code := app.Run([]string{"apply", "local", "--service", "Wi-Fi", "--verify"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), bad.Addr+" timed out") {
+		t.Errorf("expected timeout for %s, got:\n%s", bad.Addr, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "  "+good.Addr+" ") {
+		t.Errorf("expected latency for %s, got:\n%s", good.Addr, stdout.String())
+	}
+}
+
+// TestApply_VerifyFails tests that apply fails when no server answers and rollback is off.
+func TestApply_VerifyFails(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+	app.Checker = nil
+	bad := probetest.Start(t, probetest.Options{Silent: true})
+	config := fmt.Sprintf(`version: 1
+profiles:
+  local:
+    servers: [%q]
+    rollback: false
+settings:
+  verify: true
+  timeouts:
+    probe: 50ms
+`, bad.Addr)
+	if err := os.WriteFile(app.ConfigPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	code := app.Run([]string{"apply", "local", "--service", "Wi-Fi"})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "no server answered a query for example.com") {
+		t.Errorf("expected verification error, got: %s", stderr.String())
+	}
+}
+
+// TestApply_RollsBack tests that unreachable servers are rolled back.
+func TestApply_RollsBack(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+	app.Checker = nil
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	bad := probetest.Start(t, probetest.Options{Silent: true})
+	config := fmt.Sprintf(`version: 1
+profiles:
+  local:
+    servers: [%q]
+settings:
+  timeouts:
+    probe: 50ms
+`, bad.Addr)
+	if err := os.WriteFile(app.ConfigPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	code := app.Run([]string{"apply", "local", "--service", "Wi-Fi"})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "rolled back to previous DNS") {
+		t.Errorf("expected rollback error, got: %s", stderr.String())
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 1 || got[0] != "9.9.9.9" {
+		t.Errorf("expected previous servers to be restored, got %v", got)
+	}
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+)
+
+// runConfig implements the "dnsctl config" command group.
+func runConfig(ctx context.Context, a *App, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("config requires a subcommand")
+	}
+
+	switch args[0] {
+	case "validate":
+		return runConfigValidate(a, args[1:])
+	default:
+		return usageErrorf("unknown config subcommand %q", args[0])
+	}
+}
+
+// runConfigValidate implements "dnsctl config validate [path]".
+func runConfigValidate(a *App, args []string) error {
+	fs := a.newFlagSet("config")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return usageErrorf("config validate takes at most one path")
+	}
+
+	path := a.configPath()
+	if len(positional) == 1 {
+		path = positional[0]
+	}
+
+	// Load falls back to defaults for a missing file, which would hide typos in the path
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		var errs config.ValidationErrors
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				if e.Line > 0 {
+					fmt.Fprintf(a.Stderr, "%s:%d:%d: %s: %s\n", path, e.Line, e.Column, e.Path, e.Message)
+				} else {
+					fmt.Fprintf(a.Stderr, "%s: %s: %s\n", path, e.Path, e.Message)
+				}
+			}
+			return fmt.Errorf("%s: %d problem(s) found", path, len(errs))
+		}
+		return err
+	}
+
+	fmt.Fprintf(a.Stdout, "%s: OK\n", path)
+	return nil
+}
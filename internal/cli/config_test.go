@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConfigValidate_Valid tests validating a correct configuration.
+func TestConfigValidate_Valid(t *testing.T) {
+	app, _, stdout, _ := testApp(t)
+
+	code := app.Run([]string{"config", "validate"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "OK") {
+		t.Errorf("unexpected stdout: %s", stdout.String())
+	}
+}
+
+// TestConfigValidate_Invalid tests that problems are reported with line numbers.
+func TestConfigValidate_Invalid(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	content := "version: 1\nprofiles:\n  home:\n    servers: [\"1.1.1\"]\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	code := app.Run([]string{"config", "validate", path})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	want := path + `:4:15: profiles.home.servers[0]: invalid server address "1.1.1"`
+	if !strings.Contains(stderr.String(), want) {
+		t.Errorf("expected %q in stderr, got: %s", want, stderr.String())
+	}
+}
+
+// TestConfigValidate_MissingFile tests that a missing file is an error.
+func TestConfigValidate_MissingFile(t *testing.T) {
+	app, _, _, _ := testApp(t)
+
+	code := app.Run([]string{"config", "validate", filepath.Join(t.TempDir(), "missing.yaml")})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+}
+
+// TestApply_InvalidConfig tests that commands refuse to run with an invalid config.
+func TestApply_InvalidConfig(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+	content := "version: 1\nprofiles:\n  home:\n    servers: [\"1.1.1\"]\n"
+	if err := os.WriteFile(app.ConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	code := app.Run([]string{"apply", "home"})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "line 4, column 15") {
+		t.Errorf("expected line number in error, got: %s", stderr.String())
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Error("expected no DNS changes")
+	}
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"log"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/daemon"
+)
+
+// runDaemon implements "dnsctl daemon".
+// It runs in the foreground, logging to stderr, until interrupted or
+// sent SIGTERM.
+func runDaemon(ctx context.Context, a *App, args []string) error {
+	fs := a.newFlagSet("daemon")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageErrorf("daemon takes no arguments")
+	}
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := a.backend(a.backendName(cfg))
+	if err != nil {
+		return err
+	}
+
+	applier := apply.New(client, cfg.Settings)
+	applier.Checker = a.Checker
+
+	d := daemon.New(cfg, applier, log.New(a.Stderr, "dnsctl: ", log.LstdFlags))
+	if a.Detect != nil {
+		d.Detect = a.Detect
+	}
+	return d.Run(ctx)
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDaemon_NothingToManage tests that the daemon exits without rules or services.
+func TestDaemon_NothingToManage(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"daemon"})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "no rules or daemon services configured") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/doctor"
+)
+
+// runDoctor implements "dnsctl doctor".
+func runDoctor(ctx context.Context, a *App, args []string) error {
+	fs := a.newFlagSet("doctor")
+	output := fs.String("output", "text", "output format: json or text")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageErrorf("doctor takes no arguments")
+	}
+
+	var write func(io.Writer, doctor.Report) error
+	switch *output {
+	case "json":
+		write = writeDoctorJSON
+	case "text":
+		write = writeDoctorText
+	default:
+		return usageErrorf("unsupported output format %q", *output)
+	}
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := a.client(cfg)
+	if err != nil {
+		return err
+	}
+
+	d := doctor.New(client, cfg)
+	d.Checker = a.Checker
+	report := d.Run(ctx)
+
+	if err := write(a.Stdout, report); err != nil {
+		return err
+	}
+	if failed := report.Count(doctor.Fail); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report.Checks))
+	}
+	return nil
+}
+
+// writeDoctorJSON writes the report as indented JSON.
+func writeDoctorJSON(w io.Writer, report doctor.Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// writeDoctorText writes the report in a human-readable form, with the
+// hint below each warning or failure.
+func writeDoctorText(w io.Writer, report doctor.Report) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Backend: %s\n\n", report.Backend)
+	for _, check := range report.Checks {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", check.Status, check.Name, check.Message)
+		if check.Hint != "" {
+			fmt.Fprintf(&b, "       %s\n", check.Hint)
+		}
+	}
+	fmt.Fprintf(&b, "\n%d passed, %d warnings, %d failed\n",
+		report.Count(doctor.Pass), report.Count(doctor.Warn), report.Count(doctor.Fail))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
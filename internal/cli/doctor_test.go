@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/doctor"
+	"github.com/nycjv321/dnsctl/internal/probe"
+)
+
+// TestDoctor_JSON tests the JSON doctor report.
+func TestDoctor_JSON(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	mock.DNSServers["Wi-Fi"] = []string{"1.1.1.1"}
+
+	app.Run([]string{"doctor", "--output", "json"})
+
+	var report doctor.Report
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if report.Backend != "mock" || report.Status == "" {
+		t.Errorf("expected the mock backend and an overall status, got %+v", report)
+	}
+	i := slices.IndexFunc(report.Checks, func(c doctor.Check) bool { return c.Name == "servers on Wi-Fi" })
+	if i < 0 || report.Checks[i].Status != doctor.Pass {
+		t.Errorf("expected Wi-Fi's servers to pass, got %+v", report.Checks)
+	}
+}
+
+// TestDoctor_Fails tests that failing checks are listed with their hint
+// and make the command fail.
+func TestDoctor_Fails(t *testing.T) {
+	app, mock, stdout, stderr := testApp(t)
+	mock.DNSServers["Wi-Fi"] = []string{"192.0.2.1"}
+	app.Checker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+		results := make([]probe.Result, len(servers))
+		for i, server := range servers {
+			results[i] = probe.Result{Server: server, Err: errors.New("refused")}
+		}
+		return results
+	})
+
+	code := app.Run([]string{"doctor"})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "[fail] servers on Wi-Fi: no server answers: 192.0.2.1 failed: refused\n       Check that") {
+		t.Errorf("expected the failure and its hint, got:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "checks failed") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+// TestDoctor_UnsupportedOutput tests that unknown formats are usage errors.
+func TestDoctor_UnsupportedOutput(t *testing.T) {
+	app, _, _, _ := testApp(t)
+
+	if code := app.Run([]string{"doctor", "--output", "yaml"}); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/helper"
+)
+
+// runHelper implements "dnsctl helper <request>".
+// It is started through sudo or pkexec by a user's dnsctl to make one
+// change. It takes no flags and reads no config, since it runs as root on
+// behalf of someone else; the request alone says what to do, including
+// which backend to use and how long to take.
+func runHelper(ctx context.Context, a *App, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("helper takes exactly one request")
+	}
+
+	req, err := helper.ParseRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := a.backend(req.Backend)
+	if err != nil {
+		return err
+	}
+
+	return dns.WithTimeout(ctx, req.Limit(), func(ctx context.Context) error {
+		return helper.Execute(ctx, client, req)
+	})
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/helper"
+)
+
+// TestHelper_SetsServers tests that the helper makes the requested change.
+func TestHelper_SetsServers(t *testing.T) {
+	app, mock, _, _ := testApp(t)
+
+	code := app.Run([]string{"helper", `{"version":1,"op":"set_servers","service":"Wi-Fi","servers":["1.1.1.1"]}`})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if len(mock.SetCalls) != 1 || !slices.Equal(mock.SetCalls[0].Servers, []string{"1.1.1.1"}) {
+		t.Errorf("unexpected set calls: %v", mock.SetCalls)
+	}
+}
+
+// TestHelper_Timeout tests that the helper gives up after the request's
+// timeout.
+func TestHelper_Timeout(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+	mock.Delay = time.Hour
+
+	code := app.Run([]string{"helper", `{"version":1,"op":"clear_servers","service":"Wi-Fi","timeout":20000000}`})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "timed out after 20ms") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+// TestHelper_RejectsInvalidRequests tests that nothing changes for a
+// request that does not validate.
+func TestHelper_RejectsInvalidRequests(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+
+	code := app.Run([]string{"helper", `{"version":1,"op":"set_servers","service":"Wi-Fi","servers":["1.1.1.1"],"user":"root"}`})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown field "user"`) {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no changes, got %v", mock.SetCalls)
+	}
+}
+
+// TestHelper_Hidden tests that the helper is not listed in the help.
+func TestHelper_Hidden(t *testing.T) {
+	app, _, stdout, _ := testApp(t)
+
+	app.Run([]string{"help"})
+
+	if strings.Contains(stdout.String(), "helper") {
+		t.Errorf("expected helper to be hidden, got: %s", stdout.String())
+	}
+}
+
+// TestClient_Elevate tests that settings.elevate routes changes through
+// the helper.
+func TestClient_Elevate(t *testing.T) {
+	app, _, _, _ := testApp(t)
+	cfg, err := app.loadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Settings.Elevate = "sudo"
+
+	client, err := app.client(cfg)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, ok := client.(helper.DomainClient); !ok {
+		t.Errorf("expected a helper client, got %T", client)
+	}
+}
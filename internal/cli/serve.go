@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"log"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/server"
+)
+
+// runServe implements "dnsctl serve".
+// It serves the control API in the foreground, logging every change to
+// stderr, until interrupted or sent SIGTERM.
+func runServe(ctx context.Context, a *App, args []string) error {
+	fs := a.newFlagSet("serve")
+	socket := fs.String("socket", "", "path of the API socket (default: server.socket)")
+	group := fs.String("group", "", "group allowed to connect (default: server.group)")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageErrorf("serve takes no arguments")
+	}
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := a.backend(a.backendName(cfg))
+	if err != nil {
+		return err
+	}
+
+	path := cfg.Server.SocketPath()
+	if *socket != "" {
+		path = *socket
+	}
+	socketGroup := cfg.Server.Group
+	if isFlagSet(fs, "group") {
+		socketGroup = *group
+	}
+
+	l, err := server.Listen(path, socketGroup)
+	if err != nil {
+		return err
+	}
+
+	applier := apply.New(client, cfg.Settings)
+	applier.Checker = a.Checker
+
+	s := server.New(cfg, applier, log.New(a.Stderr, "dnsctl: ", log.LstdFlags))
+	return s.Serve(ctx, l)
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/server"
+)
+
+// TestServe_TUIConnects tests that with server.connect the TUI connects
+// to "dnsctl serve", which applies its profiles by name.
+func TestServe_TUIConnects(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+	socket := filepath.Join(t.TempDir(), "dnsctl.sock")
+	configYAML := testConfigYAML + "server:\n  socket: " + socket + "\n  connect: true\n"
+	if err := os.WriteFile(app.ConfigPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runServe(ctx, app, nil)
+	}()
+
+	cfg, err := app.loadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	// Wait for the server to listen
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(socket); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, modelErr := app.tuiModel(cfg)
+	client, err := server.Connect(ctx, socket)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	_, applyErr := client.Apply(ctx, "cloudflare", []string{"Wi-Fi"})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected serve to stop cleanly, got: %v", err)
+	}
+	if modelErr != nil {
+		t.Fatalf("failed to create the TUI: %v", modelErr)
+	}
+	if applyErr != nil {
+		t.Fatalf("failed to apply: %v", applyErr)
+	}
+	if len(mock.SetCalls) != 1 || !slices.Equal(mock.SetCalls[0].Servers, []string{"1.1.1.1", "1.0.0.1"}) {
+		t.Errorf("expected the server to apply cloudflare, got %v", mock.SetCalls)
+	}
+	if !strings.Contains(stderr.String(), "apply cloudflare on Wi-Fi (1.1.1.1, 1.0.0.1)") {
+		t.Errorf("expected the change to be logged, got: %s", stderr.String())
+	}
+}
+
+// TestServe_NoArguments tests that serve rejects positional arguments.
+func TestServe_NoArguments(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"serve", "now"})
+
+	if code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "serve takes no arguments") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
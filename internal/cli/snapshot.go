@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/snapshot"
+)
+
+// runSnapshot implements the "dnsctl snapshot" command group.
+func runSnapshot(ctx context.Context, a *App, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("snapshot requires a subcommand")
+	}
+
+	switch args[0] {
+	case "save":
+		return runSnapshotSave(ctx, a, args[1:])
+	case "restore":
+		return runSnapshotRestore(ctx, a, args[1:])
+	case "list":
+		return runSnapshotList(a, args[1:])
+	default:
+		return usageErrorf("unknown snapshot subcommand %q", args[0])
+	}
+}
+
+// snapshotName parses the optional snapshot name argument.
+func snapshotName(a *App, sub string, args []string) (string, error) {
+	fs := a.newFlagSet("snapshot")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return "", err
+	}
+	if len(positional) > 1 {
+		return "", usageErrorf("snapshot %s takes at most one name", sub)
+	}
+
+	name := snapshot.DefaultName
+	if len(positional) == 1 {
+		name = positional[0]
+	}
+	if err := snapshot.ValidateName(name); err != nil {
+		return "", usageErrorf("%v", err)
+	}
+	return name, nil
+}
+
+// runSnapshotSave implements "dnsctl snapshot save [name]".
+func runSnapshotSave(ctx context.Context, a *App, args []string) error {
+	name, err := snapshotName(a, "save", args)
+	if err != nil {
+		return err
+	}
+
+	applier, err := a.applier()
+	if err != nil {
+		return err
+	}
+
+	snap, err := snapshot.Take(ctx, applier, name)
+	if err != nil {
+		return fmt.Errorf("failed to take snapshot: %w", err)
+	}
+	if err := a.snapshots().Save(snap); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.Stdout, "Saved snapshot %s (%d services)\n", name, len(snap.Services))
+	return nil
+}
+
+// runSnapshotRestore implements "dnsctl snapshot restore [name]".
+func runSnapshotRestore(ctx context.Context, a *App, args []string) error {
+	name, err := snapshotName(a, "restore", args)
+	if err != nil {
+		return err
+	}
+
+	snap, err := a.snapshots().Load(name)
+	if err != nil {
+		return err
+	}
+
+	applier, err := a.applier()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range snapshot.Restore(ctx, applier, snap) {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Fprintf(a.Stdout, "%s: failed: %v\n", r.Service, r.Err)
+		case r.Changed:
+			fmt.Fprintf(a.Stdout, "%s: restored\n", r.Service)
+		default:
+			fmt.Fprintf(a.Stdout, "%s: unchanged\n", r.Service)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to restore %d of %d services from snapshot %s", failed, len(snap.Services), name)
+	}
+	return nil
+}
+
+// runSnapshotList implements "dnsctl snapshot list".
+func runSnapshotList(a *App, args []string) error {
+	fs := a.newFlagSet("snapshot")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageErrorf("snapshot list takes no arguments")
+	}
+
+	names, err := a.snapshots().List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintln(a.Stdout, name)
+	}
+	return nil
+}
+
+// applier creates an Applier from the validated config and DNS client.
+func (a *App) applier() (*apply.Applier, error) {
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := a.client(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	applier := apply.New(client, cfg.Settings)
+	applier.Checker = a.Checker
+	return applier, nil
+}
+
+// snapshots returns the snapshot store next to the config file.
+func (a *App) snapshots() *snapshot.Store {
+	return snapshot.NewStore(a.configPath())
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSnapshot_SaveAndRestore tests restoring services to a saved snapshot.
+func TestSnapshot_SaveAndRestore(t *testing.T) {
+	app, mock, stdout, stderr := testApp(t)
+	mock.DNSServers["Wi-Fi"] = []string{"1.1.1.1"}
+
+	if code := app.Run([]string{"snapshot", "save", "home"}); code != 0 {
+		t.Fatalf("expected save to succeed, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Saved snapshot home (2 services)") {
+		t.Errorf("unexpected save output: %s", stdout.String())
+	}
+
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	stdout.Reset()
+	code := app.Run([]string{"snapshot", "restore", "home"})
+
+	if code != 0 {
+		t.Fatalf("expected restore to succeed, got %d: %s", code, stderr.String())
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 1 || got[0] != "1.1.1.1" {
+		t.Errorf("expected 1.1.1.1 to be restored, got %v", got)
+	}
+	output := stdout.String()
+	if !strings.Contains(output, "Wi-Fi: restored") || !strings.Contains(output, "Ethernet: unchanged") {
+		t.Errorf("unexpected restore output: %s", output)
+	}
+}
+
+// TestSnapshot_DefaultName tests that the name is optional.
+func TestSnapshot_DefaultName(t *testing.T) {
+	app, _, stdout, _ := testApp(t)
+
+	if code := app.Run([]string{"snapshot", "save"}); code != 0 {
+		t.Fatalf("expected save to succeed, got %d", code)
+	}
+	stdout.Reset()
+	if code := app.Run([]string{"snapshot", "list"}); code != 0 {
+		t.Fatalf("expected list to succeed, got %d", code)
+	}
+
+	if stdout.String() != "default\n" {
+		t.Errorf("expected default snapshot, got %q", stdout.String())
+	}
+}
+
+// TestSnapshot_RestoreMissing tests restoring a snapshot that doesn't exist.
+func TestSnapshot_RestoreMissing(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"snapshot", "restore", "nope"})
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "snapshot not found: nope") {
+		t.Errorf("unexpected error: %s", stderr.String())
+	}
+}
+
+// TestSnapshot_InvalidName tests that names with path separators are rejected.
+func TestSnapshot_InvalidName(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"snapshot", "save", "../escape"})
+
+	if code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "invalid snapshot name") {
+		t.Errorf("unexpected error: %s", stderr.String())
+	}
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/status"
+	"gopkg.in/yaml.v3"
+)
+
+// runStatus implements "dnsctl status".
+func runStatus(ctx context.Context, a *App, args []string) error {
+	fs := a.newFlagSet("status")
+	output := fs.String("output", "text", "output format: json, yaml or text")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageErrorf("status takes no arguments")
+	}
+
+	var write func(io.Writer, status.Report) error
+	switch *output {
+	case "json":
+		write = writeStatusJSON
+	case "yaml":
+		write = writeStatusYAML
+	case "text":
+		write = writeStatusText
+	default:
+		return usageErrorf("unsupported output format %q", *output)
+	}
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := a.client(cfg)
+	if err != nil {
+		return err
+	}
+
+	report, err := status.Collect(ctx, client, cfg)
+	if err != nil {
+		return err
+	}
+
+	return write(a.Stdout, report)
+}
+
+// writeStatusJSON writes the report as indented JSON.
+func writeStatusJSON(w io.Writer, report status.Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// writeStatusYAML writes the report as YAML.
+func writeStatusYAML(w io.Writer, report status.Report) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// writeStatusText writes the report in a human-readable form.
+func writeStatusText(w io.Writer, report status.Report) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Backend: %s\n", report.Backend)
+	for _, s := range report.Services {
+		b.WriteString("\n")
+		b.WriteString(s.Name)
+		if s.Default {
+			b.WriteString(" (default)")
+		}
+		b.WriteString("\n")
+
+		switch {
+		case s.Error != "":
+			fmt.Fprintf(&b, "  Error:   %s\n", s.Error)
+		case s.DHCP && len(s.Servers) > 0:
+			fmt.Fprintf(&b, "  DNS:     DHCP (automatic): %s\n", strings.Join(s.Servers, ", "))
+		case s.DHCP:
+			b.WriteString("  DNS:     DHCP (automatic)\n")
+		default:
+			fmt.Fprintf(&b, "  DNS:     %s\n", strings.Join(s.Servers, ", "))
+		}
+
+		domains := dns.Domains{Search: s.Domains, RouteOnly: s.RouteOnlyDomains}
+		if !domains.IsEmpty() {
+			fmt.Fprintf(&b, "  Domains: %s\n", strings.Join(domains.Entries(), ", "))
+		}
+
+		if s.Profile != "" {
+			fmt.Fprintf(&b, "  Profile: %s\n", s.Profile)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/status"
+	"gopkg.in/yaml.v3"
+)
+
+// TestStatus_JSON tests the JSON status output.
+func TestStatus_JSON(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	mock.DNSServers["Wi-Fi"] = []string{"1.0.0.1", "1.1.1.1"}
+
+	code := app.Run([]string{"status", "--output", "json"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	var report status.Report
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if report.Backend != "mock" {
+		t.Errorf("expected mock backend, got %s", report.Backend)
+	}
+	if len(report.Services) != 2 {
+		t.Fatalf("expected 2 services, got %d", len(report.Services))
+	}
+
+	wifi := report.Services[0]
+	if wifi.Name != "Wi-Fi" || !wifi.Default {
+		t.Errorf("expected default Wi-Fi service, got %+v", wifi)
+	}
+	if wifi.Profile != "cloudflare" {
+		t.Errorf("expected cloudflare to match regardless of order, got %q", wifi.Profile)
+	}
+
+	ethernet := report.Services[1]
+	if !ethernet.DHCP {
+		t.Error("expected Ethernet to use DHCP")
+	}
+	if ethernet.Profile != "traveling" {
+		t.Errorf("expected traveling to match DHCP, got %q", ethernet.Profile)
+	}
+	if !strings.Contains(stdout.String(), `"servers": []`) {
+		t.Error("expected empty servers to encode as an empty list")
+	}
+}
+
+// TestStatus_YAML tests the YAML status output.
+func TestStatus_YAML(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+
+	code := app.Run([]string{"status", "--output", "yaml"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	var report status.Report
+	if err := yaml.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	if len(report.Services) != 2 {
+		t.Fatalf("expected 2 services, got %d", len(report.Services))
+	}
+	if report.Services[0].Profile != "" {
+		t.Errorf("expected no matching profile, got %q", report.Services[0].Profile)
+	}
+}
+
+// TestStatus_Text tests the default text status output.
+func TestStatus_Text(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	mock.DNSServers["Wi-Fi"] = []string{"1.1.1.1", "1.0.0.1"}
+
+	code := app.Run([]string{"status"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	output := stdout.String()
+	for _, want := range []string{"Backend: mock", "Wi-Fi (default)", "1.1.1.1, 1.0.0.1", "Profile: cloudflare", "DHCP (automatic)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+// TestStatus_DHCPServers tests that servers learned from DHCP are listed
+// as such and match the DHCP profile.
+func TestStatus_DHCPServers(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.1"}
+	mock.Automatic = map[string]bool{"Wi-Fi": true}
+
+	code := app.Run([]string{"status"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	output := stdout.String()
+	for _, want := range []string{"DNS:     DHCP (automatic): 192.168.1.1", "Profile: traveling"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+// TestStatus_Domains tests that search and routing domains are reported.
+func TestStatus_Domains(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	mock.Domains = map[string]dns.Domains{
+		"Wi-Fi": {Search: []string{"corp.example.com"}, RouteOnly: []string{"internal.example.com"}},
+	}
+
+	code := app.Run([]string{"status"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Domains: corp.example.com, ~internal.example.com") {
+		t.Errorf("expected domains in output, got:\n%s", stdout.String())
+	}
+}
+
+// TestStatus_GetError tests that per-service errors are reported.
+func TestStatus_GetError(t *testing.T) {
+	app, mock, stdout, _ := testApp(t)
+	mock.GetError = errors.New("service not found")
+
+	code := app.Run([]string{"status", "--output", "json"})
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), `"error": "service not found"`) {
+		t.Errorf("expected error in output, got:\n%s", stdout.String())
+	}
+}
+
+// TestStatus_ListError tests that listing failures exit non-zero.
+func TestStatus_ListError(t *testing.T) {
+	app, mock, _, stderr := testApp(t)
+	mock.ListError = errors.New("network unavailable")
+
+	code := app.Run([]string{"status"})
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "network unavailable") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+// TestStatus_InvalidOutput tests that unknown formats are rejected.
+func TestStatus_InvalidOutput(t *testing.T) {
+	app, _, _, stderr := testApp(t)
+
+	code := app.Run([]string{"status", "--output", "xml"})
+
+	if code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unsupported output format "xml"`) {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+}
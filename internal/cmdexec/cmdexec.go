@@ -0,0 +1,113 @@
+// Package cmdexec runs the external commands some DNS backends and
+// network detection rely on, behind an interface that tests replace.
+package cmdexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Runner executes external commands on behalf of the exec-based backends.
+// It exists so backends can be tested against recorded command output,
+// with cmdexectest.Runner.
+type Runner interface {
+	// Run executes the named command and returns its output.
+	// A non-zero exit status yields a *CommandError alongside the Result.
+	// If ctx is done before the command exits, the command is killed and
+	// the context's error is returned.
+	Run(ctx context.Context, name string, args ...string) (Result, error)
+
+	// RunInput is Run with input written to the command's stdin.
+	RunInput(ctx context.Context, input []byte, name string, args ...string) (Result, error)
+
+	// LookPath returns the path of the named command, or an error if it
+	// is not installed. Backends use it to detect their tools.
+	LookPath(name string) (string, error)
+}
+
+// Result holds the output of a finished command.
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// Output returns the command's diagnostic output for error messages:
+// stderr if the command wrote any, otherwise stdout.
+func (r Result) Output() string {
+	if text := strings.TrimSpace(string(r.Stderr)); text != "" {
+		return text
+	}
+	return strings.TrimSpace(string(r.Stdout))
+}
+
+// CommandError is returned when a command exits with a non-zero status.
+type CommandError struct {
+	Name     string
+	Args     []string
+	ExitCode int
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s exited with status %d", e.Name, e.ExitCode)
+}
+
+// execRunner runs commands with os/exec.
+type execRunner struct{}
+
+// Exec returns the Runner used outside of tests, which executes commands
+// with os/exec.
+func Exec() Runner {
+	return execRunner{}
+}
+
+// Run executes the command and captures stdout and stderr separately.
+func (r execRunner) Run(ctx context.Context, name string, args ...string) (Result, error) {
+	return r.RunInput(ctx, nil, name, args...)
+}
+
+// RunInput executes the command with input on stdin and captures stdout
+// and stderr separately.
+func (execRunner) RunInput(ctx context.Context, input []byte, name string, args ...string) (Result, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := Result{
+		Stdout: stdout.Bytes(),
+		Stderr: stderr.Bytes(),
+	}
+
+	// A killed command reports "signal: killed"; report why it was killed instead
+	if err != nil && ctx.Err() != nil {
+		result.ExitCode = -1
+		return result, ctx.Err()
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		return result, &CommandError{Name: name, Args: args, ExitCode: result.ExitCode}
+	}
+	if err != nil {
+		result.ExitCode = -1
+		return result, err
+	}
+
+	return result, nil
+}
+
+// LookPath searches for the command in the directories of PATH.
+func (execRunner) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
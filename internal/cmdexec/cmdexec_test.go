@@ -0,0 +1,45 @@
+package cmdexec
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestExec_ExitCode tests that the real runner reports exit codes.
+func TestExec_ExitCode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	result, err := Exec().Run(context.Background(), "sh", "-c", "echo out; echo err >&2; exit 3")
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected CommandError, got: %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", result.ExitCode)
+	}
+	if strings.TrimSpace(string(result.Stdout)) != "out" || strings.TrimSpace(string(result.Stderr)) != "err" {
+		t.Errorf("unexpected output: stdout=%q stderr=%q", result.Stdout, result.Stderr)
+	}
+}
+
+// TestExec_Input tests that the real runner writes input to stdin.
+func TestExec_Input(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	result, err := Exec().RunInput(context.Background(), []byte("nameserver 1.1.1.1\n"), "cat")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(result.Stdout) != "nameserver 1.1.1.1\n" {
+		t.Errorf("unexpected stdout: %q", result.Stdout)
+	}
+}
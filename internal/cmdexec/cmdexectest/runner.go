@@ -0,0 +1,126 @@
+// Package cmdexectest provides a cmdexec.Runner that replays recorded
+// command output, for tests.
+package cmdexectest
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// Call records a command executed through a Runner.
+type Call struct {
+	Name string
+	Args []string
+	// Input is what the command was given on stdin, if anything.
+	Input string
+}
+
+// String returns the command line of the call.
+func (c Call) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Runner is a cmdexec.Runner for tests. It records every command it is
+// asked to run and replays canned results keyed by command line. It is
+// safe for concurrent use; register results before running commands,
+// and read Calls once they are done.
+type Runner struct {
+	// Results maps a command line such as "resolvectl dns wlan0" to the
+	// result it produces. Results with a non-zero ExitCode fail.
+	Results map[string]cmdexec.Result
+
+	// Errors maps a command line to an error returned instead of running,
+	// e.g. exec.ErrNotFound for a missing binary.
+	Errors map[string]error
+
+	// Missing lists the commands LookPath does not find; every other
+	// command is found in /usr/bin.
+	Missing map[string]bool
+
+	// Calls records every command in the order it was run.
+	Calls []Call
+
+	mu sync.Mutex
+}
+
+// NewRunner creates an empty Runner.
+func NewRunner() *Runner {
+	return &Runner{
+		Results: make(map[string]cmdexec.Result),
+		Errors:  make(map[string]error),
+		Missing: make(map[string]bool),
+	}
+}
+
+// Expect registers the stdout returned by a successful command.
+func (f *Runner) Expect(command string, stdout string) *Runner {
+	f.Results[command] = cmdexec.Result{Stdout: []byte(stdout)}
+	return f
+}
+
+// Fail registers a command that exits with the given status and stderr.
+func (f *Runner) Fail(command string, exitCode int, stderr string) *Runner {
+	f.Results[command] = cmdexec.Result{Stderr: []byte(stderr), ExitCode: exitCode}
+	return f
+}
+
+// Run records the call and replays the registered result.
+// A done context fails the call as a killed command would.
+func (f *Runner) Run(ctx context.Context, name string, args ...string) (cmdexec.Result, error) {
+	return f.RunInput(ctx, nil, name, args...)
+}
+
+// RunInput is Run that also records the input. Results are looked up by
+// command line alone.
+func (f *Runner) RunInput(ctx context.Context, input []byte, name string, args ...string) (cmdexec.Result, error) {
+	call := Call{Name: name, Args: args, Input: string(input)}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, call)
+
+	if err := ctx.Err(); err != nil {
+		return cmdexec.Result{ExitCode: -1}, err
+	}
+
+	key := call.String()
+	if err, ok := f.Errors[key]; ok {
+		return cmdexec.Result{ExitCode: -1}, err
+	}
+
+	result, ok := f.Results[key]
+	if !ok {
+		return cmdexec.Result{ExitCode: -1}, fmt.Errorf("fake runner: unexpected command %q", key)
+	}
+	if result.ExitCode != 0 {
+		return result, &cmdexec.CommandError{Name: name, Args: args, ExitCode: result.ExitCode}
+	}
+
+	return result, nil
+}
+
+// LookPath finds every command that is not listed in Missing.
+func (f *Runner) LookPath(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Missing[name] {
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return "/usr/bin/" + name, nil
+}
+
+// Commands returns the command lines of all recorded calls.
+func (f *Runner) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	commands := make([]string, len(f.Calls))
+	for i, call := range f.Calls {
+		commands[i] = call.String()
+	}
+	return commands
+}
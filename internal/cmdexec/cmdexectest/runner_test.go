@@ -0,0 +1,69 @@
+package cmdexectest
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// TestRunner_ReplaysResults tests that registered output is returned.
+func TestRunner_ReplaysResults(t *testing.T) {
+	runner := NewRunner().Expect("echo hello", "hello\n")
+
+	result, err := runner.Run(context.Background(), "echo", "hello")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(result.Stdout) != "hello\n" {
+		t.Errorf("unexpected stdout: %q", result.Stdout)
+	}
+	if got := runner.Commands(); len(got) != 1 || got[0] != "echo hello" {
+		t.Errorf("expected the command to be recorded, got %q", got)
+	}
+}
+
+// TestRunner_Failure tests that non-zero exit codes return a CommandError.
+func TestRunner_Failure(t *testing.T) {
+	runner := NewRunner().Fail("false", 1, "boom")
+
+	result, err := runner.Run(context.Background(), "false")
+
+	var cmdErr *cmdexec.CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected CommandError, got: %v", err)
+	}
+	if cmdErr.ExitCode != 1 || result.ExitCode != 1 {
+		t.Errorf("expected exit code 1, got %d", cmdErr.ExitCode)
+	}
+	if result.Output() != "boom" {
+		t.Errorf("expected output boom, got %q", result.Output())
+	}
+}
+
+// TestRunner_UnexpectedCommand tests that unknown commands fail.
+func TestRunner_UnexpectedCommand(t *testing.T) {
+	runner := NewRunner()
+
+	_, err := runner.Run(context.Background(), "rm", "-rf", "/")
+
+	if err == nil || !strings.Contains(err.Error(), `unexpected command "rm -rf /"`) {
+		t.Errorf("expected unexpected command error, got: %v", err)
+	}
+}
+
+// TestRunner_Errors tests injecting errors such as missing binaries.
+func TestRunner_Errors(t *testing.T) {
+	runner := NewRunner()
+	runner.Errors["nscd -i hosts"] = exec.ErrNotFound
+
+	_, err := runner.Run(context.Background(), "nscd", "-i", "hosts")
+
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("expected exec.ErrNotFound, got: %v", err)
+	}
+}
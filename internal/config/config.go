@@ -5,7 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
+	"github.com/nycjv321/dnsctl/internal/probe"
 	"gopkg.in/yaml.v3"
 )
 
@@ -14,6 +16,25 @@ type Profile struct {
 	Description string   `yaml:"description"`
 	Servers     []string `yaml:"servers,omitempty"`
 	DHCP        bool     `yaml:"dhcp,omitempty"`
+
+	// Domains are search domains: single-label names are completed with
+	// them and lookups under them go to this profile's servers.
+	Domains []string `yaml:"domains,omitempty"`
+	// RouteOnlyDomains only route lookups under them to this profile's
+	// servers, without being used for name completion.
+	RouteOnlyDomains []string `yaml:"route_only_domains,omitempty"`
+
+	// Rollback restores the previous DNS if none of the new servers
+	// answer after applying. It is enabled unless set to false.
+	Rollback *bool `yaml:"rollback,omitempty"`
+
+	// Services are the network services the profile is applied to when
+	// none is given: names, globs such as "en*", or "all". When empty,
+	// the default service is used.
+	Services []string `yaml:"services,omitempty"`
+
+	// Persistence overrides Settings.Persistence for this profile.
+	Persistence string `yaml:"persistence,omitempty"`
 }
 
 // IsDHCP returns true if this profile clears DNS to use DHCP.
@@ -21,9 +42,205 @@ func (p Profile) IsDHCP() bool {
 	return p.DHCP || len(p.Servers) == 0
 }
 
+// RollbackEnabled returns true unless the profile opts out of rollback.
+func (p Profile) RollbackEnabled() bool {
+	return p.Rollback == nil || *p.Rollback
+}
+
+// HasDomains returns true if this profile sets search or routing domains.
+func (p Profile) HasDomains() bool {
+	return len(p.Domains) > 0 || len(p.RouteOnlyDomains) > 0
+}
+
+// Rule selects a profile for the network the machine is connected to.
+// Every condition that is set must hold; a rule without conditions
+// always matches, which makes it a fallback when listed last.
+type Rule struct {
+	// Profile is the name of the profile to apply.
+	Profile string `yaml:"profile"`
+
+	// SSID matches the name of the connected Wi-Fi network.
+	SSID string `yaml:"ssid,omitempty"`
+	// Gateway matches the IP address of the default gateway.
+	Gateway string `yaml:"gateway,omitempty"`
+	// GatewayMAC matches the hardware address of the default gateway.
+	GatewayMAC string `yaml:"gateway_mac,omitempty"`
+	// Interface matches the name of a connected interface; globs such
+	// as "en*" are allowed.
+	Interface string `yaml:"interface,omitempty"`
+	// Subnet matches if a connected interface has an address in this
+	// CIDR, e.g. "10.20.0.0/16".
+	Subnet string `yaml:"subnet,omitempty"`
+}
+
+// Daemon configures "dnsctl daemon".
+type Daemon struct {
+	// Services assigns a profile to keep applied on each service.
+	// Profiles chosen by a matching rule take precedence.
+	Services []ServiceProfile `yaml:"services,omitempty"`
+	// PollInterval is how often DNS is re-checked, to catch changes that
+	// raise no network event, such as DHCP replacing the servers.
+	PollInterval time.Duration `yaml:"poll_interval,omitempty"`
+	// Debounce is how long the network must be quiet after a change
+	// before profiles are re-applied, so a flapping link is handled once.
+	Debounce time.Duration `yaml:"debounce,omitempty"`
+}
+
+// ServiceProfile assigns a profile to a service; globs such as "en*"
+// are allowed.
+type ServiceProfile struct {
+	Service string `yaml:"service"`
+	Profile string `yaml:"profile"`
+}
+
+// Default daemon intervals used when a Daemon field is unset.
+const (
+	DefaultPollInterval = 30 * time.Second
+	DefaultDebounce     = 2 * time.Second
+)
+
+// PollEvery returns the poll interval, or its default if unset.
+func (d Daemon) PollEvery() time.Duration {
+	return durationOr(d.PollInterval, DefaultPollInterval)
+}
+
+// DebounceDelay returns the debounce delay, or its default if unset.
+func (d Daemon) DebounceDelay() time.Duration {
+	return durationOr(d.Debounce, DefaultDebounce)
+}
+
+// Server configures "dnsctl serve" and how the TUI reaches it.
+type Server struct {
+	// Socket is the path of the API's Unix socket.
+	Socket string `yaml:"socket,omitempty"`
+	// Group may connect to the socket. Without one, only the user running
+	// the server can.
+	Group string `yaml:"group,omitempty"`
+	// Connect makes the TUI change DNS through the server instead of
+	// calling the backend itself, so it can run unprivileged.
+	Connect bool `yaml:"connect,omitempty"`
+}
+
+// SocketPath returns the socket path, or its default if unset.
+func (s Server) SocketPath() string {
+	if s.Socket != "" {
+		return s.Socket
+	}
+	return DefaultSocketPath
+}
+
 // Settings contains application settings.
 type Settings struct {
-	FlushCache bool `yaml:"flush_cache"`
+	FlushCache      bool     `yaml:"flush_cache"`
+	MatchExactOrder bool     `yaml:"match_exact_order,omitempty"`
+	Timeouts        Timeouts `yaml:"timeouts,omitempty"`
+
+	// Verify sends a test query to each server after applying a profile.
+	Verify bool `yaml:"verify,omitempty"`
+	// ProbeName is the domain queried when verifying servers.
+	ProbeName string `yaml:"probe_name,omitempty"`
+
+	// Elevate runs DNS changes in a privileged helper started with sudo
+	// or pkexec, so dnsctl itself runs as the user. Empty changes DNS
+	// directly, which needs dnsctl to run as root.
+	Elevate string `yaml:"elevate,omitempty"`
+
+	// Persistence chooses whether changes last until reboot (runtime) or
+	// are saved in the network configuration (persistent), where the
+	// backend can do both. Empty uses the backend's own behaviour.
+	Persistence string `yaml:"persistence,omitempty"`
+
+	// Backend selects the DNS backend by name, overriding automatic
+	// detection. Empty or "auto" detects it.
+	Backend string `yaml:"backend,omitempty"`
+}
+
+// BackendAuto detects the DNS backend for Settings.Backend.
+const BackendAuto = "auto"
+
+// Backends lists the backend names Settings.Backend accepts besides
+// BackendAuto, across platforms. Each platform offers some of them.
+var Backends = []string{"networkd", "resolved", "networkmanager", "resolvconf", "resolv.conf", "networksetup"}
+
+// Ways of starting the privileged helper for Settings.Elevate.
+const (
+	ElevateSudo   = "sudo"
+	ElevatePkexec = "pkexec"
+)
+
+// Values of Settings.Persistence and Profile.Persistence.
+const (
+	PersistenceRuntime    = "runtime"
+	PersistencePersistent = "persistent"
+)
+
+// PersistenceFor returns the persistence to apply a profile with: its
+// own, else the global setting. Empty leaves it to the backend.
+func (s Settings) PersistenceFor(profile Profile) string {
+	if profile.Persistence != "" {
+		return profile.Persistence
+	}
+	return s.Persistence
+}
+
+// DefaultProbeName is queried when verifying servers if ProbeName is unset.
+const DefaultProbeName = probe.DefaultName
+
+// VerifyProbeName returns the probe name, or its default if unset.
+func (s Settings) VerifyProbeName() string {
+	if s.ProbeName != "" {
+		return s.ProbeName
+	}
+	return DefaultProbeName
+}
+
+// Default operation timeouts used when a Timeouts field is unset.
+const (
+	DefaultReadTimeout  = 10 * time.Second
+	DefaultApplyTimeout = 30 * time.Second
+	DefaultFlushTimeout = 10 * time.Second
+	DefaultProbeTimeout = probe.DefaultTimeout
+)
+
+// Timeouts limits how long DNS backend operations may run.
+// Durations use Go syntax, e.g. "5s" or "1m".
+type Timeouts struct {
+	// Read bounds listing services and reading DNS servers.
+	Read time.Duration `yaml:"read,omitempty"`
+	// Apply bounds setting or clearing DNS servers.
+	Apply time.Duration `yaml:"apply,omitempty"`
+	// Flush bounds flushing the DNS cache.
+	Flush time.Duration `yaml:"flush,omitempty"`
+	// Probe bounds each verification query.
+	Probe time.Duration `yaml:"probe,omitempty"`
+}
+
+// ReadTimeout returns the read timeout, or its default if unset.
+func (t Timeouts) ReadTimeout() time.Duration {
+	return durationOr(t.Read, DefaultReadTimeout)
+}
+
+// ApplyTimeout returns the apply timeout, or its default if unset.
+func (t Timeouts) ApplyTimeout() time.Duration {
+	return durationOr(t.Apply, DefaultApplyTimeout)
+}
+
+// FlushTimeout returns the flush timeout, or its default if unset.
+func (t Timeouts) FlushTimeout() time.Duration {
+	return durationOr(t.Flush, DefaultFlushTimeout)
+}
+
+// ProbeTimeout returns the probe timeout, or its default if unset.
+func (t Timeouts) ProbeTimeout() time.Duration {
+	return durationOr(t.Probe, DefaultProbeTimeout)
+}
+
+// durationOr returns d, or def if d is not positive.
+func durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
 }
 
 // Config represents the application configuration.
@@ -32,6 +249,19 @@ type Config struct {
 	DefaultService string             `yaml:"default_service"`
 	Profiles       map[string]Profile `yaml:"profiles"`
 	Settings       Settings           `yaml:"settings"`
+
+	// Rules pick a profile for "dnsctl auto". The first matching rule wins.
+	Rules []Rule `yaml:"rules,omitempty"`
+
+	// Daemon configures "dnsctl daemon".
+	Daemon Daemon `yaml:"daemon,omitempty"`
+
+	// Server configures "dnsctl serve".
+	Server Server `yaml:"server,omitempty"`
+
+	// node is the parsed YAML document, kept so Validate can report
+	// line and column numbers.
+	node *yaml.Node
 }
 
 // DefaultConfigPath returns the default configuration file path.
@@ -57,10 +287,16 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := node.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	cfg.node = &node
 
 	// Apply defaults
 	if cfg.DefaultService == "" {
@@ -2,6 +2,9 @@
 
 package config
 
+// DefaultSocketPath is where "dnsctl serve" listens unless configured.
+const DefaultSocketPath = "/var/run/dnsctl.sock"
+
 func defaultServiceName() string {
 	return "Wi-Fi"
 }
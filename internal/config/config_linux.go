@@ -2,6 +2,9 @@
 
 package config
 
+// DefaultSocketPath is where "dnsctl serve" listens unless configured.
+const DefaultSocketPath = "/run/dnsctl.sock"
+
 func defaultServiceName() string {
 	// On Linux, interfaces are auto-detected at runtime.
 	// Return "auto" as a placeholder to indicate auto-detection.
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // TestDefaultConfig_HasRequiredProfiles tests that default config has expected profiles.
@@ -425,3 +426,108 @@ func TestGoogleProfile_HasCorrectServers(t *testing.T) {
 		t.Errorf("expected 8.8.4.4, got %s", profile.Servers[1])
 	}
 }
+
+// TestTimeouts_Defaults tests that unset timeouts use defaults.
+func TestTimeouts_Defaults(t *testing.T) {
+	var timeouts Timeouts
+
+	if timeouts.ReadTimeout() != DefaultReadTimeout {
+		t.Errorf("expected %s, got %s", DefaultReadTimeout, timeouts.ReadTimeout())
+	}
+	if timeouts.ApplyTimeout() != DefaultApplyTimeout {
+		t.Errorf("expected %s, got %s", DefaultApplyTimeout, timeouts.ApplyTimeout())
+	}
+	if timeouts.FlushTimeout() != DefaultFlushTimeout {
+		t.Errorf("expected %s, got %s", DefaultFlushTimeout, timeouts.FlushTimeout())
+	}
+}
+
+// TestLoad_ParsesTimeouts tests that timeouts are parsed as durations.
+func TestLoad_ParsesTimeouts(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	yamlContent := `version: 1
+settings:
+  timeouts:
+    read: 2s
+    apply: 1m
+`
+	if err := os.WriteFile(configPath, []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if cfg.Settings.Timeouts.ReadTimeout() != 2*time.Second {
+		t.Errorf("expected 2s, got %s", cfg.Settings.Timeouts.ReadTimeout())
+	}
+	if cfg.Settings.Timeouts.ApplyTimeout() != time.Minute {
+		t.Errorf("expected 1m, got %s", cfg.Settings.Timeouts.ApplyTimeout())
+	}
+	if cfg.Settings.Timeouts.FlushTimeout() != DefaultFlushTimeout {
+		t.Errorf("expected default flush timeout, got %s", cfg.Settings.Timeouts.FlushTimeout())
+	}
+}
+
+// TestLoad_ParsesDomains tests loading search and routing domains.
+func TestLoad_ParsesDomains(t *testing.T) {
+	cfg := loadString(t, `profiles:
+  work:
+    servers: ["10.0.0.53"]
+    domains: [corp.example.com]
+    route_only_domains: [internal.example.com]
+`)
+
+	profile := cfg.Profiles["work"]
+
+	if len(profile.Domains) != 1 || profile.Domains[0] != "corp.example.com" {
+		t.Errorf("expected domains [corp.example.com], got %v", profile.Domains)
+	}
+	if len(profile.RouteOnlyDomains) != 1 || profile.RouteOnlyDomains[0] != "internal.example.com" {
+		t.Errorf("expected route-only domains [internal.example.com], got %v", profile.RouteOnlyDomains)
+	}
+	if !profile.HasDomains() {
+		t.Error("expected profile to have domains")
+	}
+}
+
+// TestLoad_ParsesVerify tests loading the verification settings and their defaults.
+func TestLoad_ParsesVerify(t *testing.T) {
+	cfg := loadString(t, `settings:
+  verify: true
+  timeouts:
+    probe: 500ms
+`)
+
+	if !cfg.Settings.Verify {
+		t.Error("expected verify to be enabled")
+	}
+	if cfg.Settings.VerifyProbeName() != DefaultProbeName {
+		t.Errorf("expected default probe name, got %s", cfg.Settings.VerifyProbeName())
+	}
+	if cfg.Settings.Timeouts.ProbeTimeout() != 500*time.Millisecond {
+		t.Errorf("expected 500ms, got %s", cfg.Settings.Timeouts.ProbeTimeout())
+	}
+}
+
+// TestProfile_RollbackEnabled tests that rollback is on unless disabled.
+func TestProfile_RollbackEnabled(t *testing.T) {
+	cfg := loadString(t, `profiles:
+  home:
+    servers: ["192.168.1.100"]
+  lab:
+    servers: ["10.0.0.53"]
+    rollback: false
+`)
+
+	if !cfg.Profiles["home"].RollbackEnabled() {
+		t.Error("expected rollback to be enabled by default")
+	}
+	if cfg.Profiles["lab"].RollbackEnabled() {
+		t.Error("expected rollback to be disabled")
+	}
+}
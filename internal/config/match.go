@@ -0,0 +1,75 @@
+package config
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// MatchOptions controls how DNS servers are compared against profiles.
+type MatchOptions struct {
+	// ExactOrder requires servers to be in the same order as the profile.
+	// By default the comparison ignores order.
+	ExactOrder bool
+}
+
+// Matches reports whether the given servers are the ones this profile sets.
+// An empty server list matches any DHCP profile.
+func (p Profile) Matches(servers []string, opts MatchOptions) bool {
+	if p.IsDHCP() {
+		return len(servers) == 0
+	}
+	if len(servers) != len(p.Servers) {
+		return false
+	}
+
+	want := normalizeServers(p.Servers)
+	got := normalizeServers(servers)
+	if !opts.ExactOrder {
+		slices.Sort(want)
+		slices.Sort(got)
+	}
+
+	return slices.Equal(want, got)
+}
+
+// MatchesDNS reports whether a service's DNS is what this profile sets.
+// dhcp says the servers were learned automatically, which like having no
+// servers only matches a DHCP profile.
+func (p Profile) MatchesDNS(servers []string, dhcp bool, opts MatchOptions) bool {
+	if dhcp {
+		return p.IsDHCP()
+	}
+	return p.Matches(servers, opts)
+}
+
+// MatchProfiles returns the sorted names of all profiles matching the
+// given servers, which dhcp says were learned automatically.
+func (c *Config) MatchProfiles(servers []string, dhcp bool, opts MatchOptions) []string {
+	var names []string
+	for _, name := range c.ProfileNames() {
+		if c.Profiles[name].MatchesDNS(servers, dhcp, opts) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// MatchOptions returns the match options from the settings.
+func (s Settings) MatchOptions() MatchOptions {
+	return MatchOptions{ExactOrder: s.MatchExactOrder}
+}
+
+// normalizeServers returns a copy of servers in canonical form so that
+// equivalent spellings of the same address compare equal.
+func normalizeServers(servers []string) []string {
+	result := make([]string, len(servers))
+	for i, s := range servers {
+		s = strings.TrimSpace(s)
+		if addr, err := netip.ParseAddr(s); err == nil {
+			s = addr.String()
+		}
+		result[i] = s
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package config
+
+import "testing"
+
+// TestProfileMatches_IgnoresOrder tests that matching is order-insensitive by default.
+func TestProfileMatches_IgnoresOrder(t *testing.T) {
+	profile := Profile{Servers: []string{"1.1.1.1", "1.0.0.1"}}
+
+	if !profile.Matches([]string{"1.0.0.1", "1.1.1.1"}, MatchOptions{}) {
+		t.Error("expected servers in a different order to match")
+	}
+}
+
+// TestProfileMatches_ExactOrder tests that ExactOrder requires the same order.
+func TestProfileMatches_ExactOrder(t *testing.T) {
+	profile := Profile{Servers: []string{"1.1.1.1", "1.0.0.1"}}
+	opts := MatchOptions{ExactOrder: true}
+
+	if profile.Matches([]string{"1.0.0.1", "1.1.1.1"}, opts) {
+		t.Error("expected servers in a different order not to match")
+	}
+	if !profile.Matches([]string{"1.1.1.1", "1.0.0.1"}, opts) {
+		t.Error("expected servers in the same order to match")
+	}
+}
+
+// TestProfileMatches_DifferentServers tests that subsets and supersets don't match.
+func TestProfileMatches_DifferentServers(t *testing.T) {
+	profile := Profile{Servers: []string{"1.1.1.1", "1.0.0.1"}}
+
+	if profile.Matches([]string{"1.1.1.1"}, MatchOptions{}) {
+		t.Error("expected a subset not to match")
+	}
+	if profile.Matches([]string{"1.1.1.1", "1.0.0.1", "8.8.8.8"}, MatchOptions{}) {
+		t.Error("expected a superset not to match")
+	}
+}
+
+// TestProfileMatches_DHCP tests that an empty server list matches DHCP profiles.
+func TestProfileMatches_DHCP(t *testing.T) {
+	dhcp := Profile{DHCP: true}
+	servers := Profile{Servers: []string{"1.1.1.1"}}
+
+	if !dhcp.Matches(nil, MatchOptions{}) {
+		t.Error("expected empty servers to match DHCP profile")
+	}
+	if dhcp.Matches([]string{"1.1.1.1"}, MatchOptions{}) {
+		t.Error("expected servers not to match DHCP profile")
+	}
+	if servers.Matches(nil, MatchOptions{}) {
+		t.Error("expected empty servers not to match a server profile")
+	}
+}
+
+// TestProfileMatches_NormalizesIPv6 tests that equivalent IPv6 spellings match.
+func TestProfileMatches_NormalizesIPv6(t *testing.T) {
+	profile := Profile{Servers: []string{"2606:4700:4700::1111"}}
+
+	if !profile.Matches([]string{"2606:4700:4700:0:0:0:0:1111"}, MatchOptions{}) {
+		t.Error("expected expanded IPv6 address to match")
+	}
+}
+
+// TestMatchProfiles_ReturnsAllMatches tests that all matching profiles are returned sorted.
+func TestMatchProfiles_ReturnsAllMatches(t *testing.T) {
+	cfg := &Config{
+		Profiles: map[string]Profile{
+			"traveling":  {DHCP: true},
+			"automatic":  {Servers: nil},
+			"cloudflare": {Servers: []string{"1.1.1.1"}},
+		},
+	}
+
+	matches := cfg.MatchProfiles(nil, false, MatchOptions{})
+
+	if len(matches) != 2 || matches[0] != "automatic" || matches[1] != "traveling" {
+		t.Errorf("expected [automatic traveling], got %v", matches)
+	}
+	if matches := cfg.MatchProfiles([]string{"8.8.8.8"}, false, MatchOptions{}); len(matches) != 0 {
+		t.Errorf("expected no matches, got %v", matches)
+	}
+}
+
+// TestMatchesDNS_DHCPServers tests that servers learned from DHCP match
+// DHCP profiles, and not a profile that sets the same servers.
+func TestMatchesDNS_DHCPServers(t *testing.T) {
+	dhcp := Profile{DHCP: true}
+	router := Profile{Servers: []string{"192.168.1.1"}}
+	servers := []string{"192.168.1.1"}
+
+	if !dhcp.MatchesDNS(servers, true, MatchOptions{}) {
+		t.Error("expected DHCP servers to match a DHCP profile")
+	}
+	if router.MatchesDNS(servers, true, MatchOptions{}) {
+		t.Error("expected DHCP servers not to match a manual profile")
+	}
+	if !router.MatchesDNS(servers, false, MatchOptions{}) || dhcp.MatchesDNS(servers, false, MatchOptions{}) {
+		t.Error("expected manual servers to match only the manual profile")
+	}
+}
@@ -0,0 +1,425 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SupportedVersion is the only configuration format version understood
+// by this build.
+const SupportedVersion = 1
+
+// ValidationError describes a single problem found in the configuration.
+type ValidationError struct {
+	// Line and Column locate the problem in the YAML source.
+	// They are zero when the configuration was not loaded from a file.
+	Line   int
+	Column int
+
+	// Path is the dotted path of the offending field, e.g. "profiles.home.servers[1]".
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d, column %d: ", e.Line, e.Column)
+	}
+	if e.Path != "" {
+		fmt.Fprintf(&b, "%s: ", e.Path)
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// ValidationErrors is the list of problems returned by Config.Validate.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validate checks the configuration for mistakes that would otherwise only
+// surface when a backend command fails: unsupported versions, unknown keys,
+// malformed server addresses and contradictory profile settings.
+// It returns ValidationErrors listing every problem found.
+func (c *Config) Validate() error {
+	v := &validator{root: c.node}
+
+	if c.node != nil {
+		v.checkKnownFields(c.node, reflect.TypeOf(Config{}), "")
+	}
+
+	// A missing version is treated as the supported one
+	if c.Version != SupportedVersion && (c.Version != 0 || v.lookup("version") != nil) {
+		v.errorf([]string{"version"}, "unsupported version %d (supported: %d)", c.Version, SupportedVersion)
+	}
+
+	timeouts := map[string]time.Duration{
+		"read":  c.Settings.Timeouts.Read,
+		"apply": c.Settings.Timeouts.Apply,
+		"flush": c.Settings.Timeouts.Flush,
+		"probe": c.Settings.Timeouts.Probe,
+	}
+	for _, key := range []string{"read", "apply", "flush", "probe"} {
+		if timeouts[key] < 0 {
+			v.errorf([]string{"settings", "timeouts", key}, "timeout must not be negative")
+		}
+	}
+
+	if c.Settings.ProbeName != "" {
+		if err := ValidateDomain(c.Settings.ProbeName); err != nil {
+			v.errorf([]string{"settings", "probe_name"}, "%v", err)
+		}
+	}
+
+	switch c.Settings.Elevate {
+	case "", ElevateSudo, ElevatePkexec:
+	default:
+		v.errorf([]string{"settings", "elevate"}, "unsupported value %q (supported: %s, %s)", c.Settings.Elevate, ElevateSudo, ElevatePkexec)
+	}
+	v.checkPersistence([]string{"settings", "persistence"}, c.Settings.Persistence)
+	if err := ValidateBackend(c.Settings.Backend); err != nil {
+		v.errorf([]string{"settings", "backend"}, "%v", err)
+	}
+
+	for _, name := range c.ProfileNames() {
+		profile := c.Profiles[name]
+		path := []string{"profiles", name}
+
+		if profile.DHCP && len(profile.Servers) > 0 {
+			v.errorf(path, "dhcp: true cannot be combined with servers")
+		}
+
+		for i, server := range profile.Servers {
+			if err := ValidateServer(server); err != nil {
+				v.errorf(append(path, "servers", strconv.Itoa(i)), "%v", err)
+			}
+		}
+
+		for i, domain := range profile.Domains {
+			if err := ValidateDomain(domain); err != nil {
+				v.errorf(append(path, "domains", strconv.Itoa(i)), "%v", err)
+			}
+		}
+
+		for i, domain := range profile.RouteOnlyDomains {
+			// "." routes every lookup to this profile's servers
+			if domain == "." {
+				continue
+			}
+			if err := ValidateDomain(domain); err != nil {
+				v.errorf(append(path, "route_only_domains", strconv.Itoa(i)), "%v", err)
+			}
+		}
+
+		for i, service := range profile.Services {
+			if err := ValidateServicePattern(service); err != nil {
+				v.errorf(append(path, "services", strconv.Itoa(i)), "%v", err)
+			}
+		}
+
+		v.checkPersistence(append(path, "persistence"), profile.Persistence)
+	}
+
+	for i, rule := range c.Rules {
+		v.validateRule(c, rule, []string{"rules", strconv.Itoa(i)})
+	}
+
+	for i, entry := range c.Daemon.Services {
+		path := []string{"daemon", "services", strconv.Itoa(i)}
+		if err := ValidateServicePattern(entry.Service); err != nil {
+			v.errorf(append(path, "service"), "%v", err)
+		}
+		if _, ok := c.Profiles[entry.Profile]; !ok {
+			v.errorf(append(path, "profile"), "unknown profile %q", entry.Profile)
+		}
+	}
+	if c.Daemon.PollInterval < 0 {
+		v.errorf([]string{"daemon", "poll_interval"}, "interval must not be negative")
+	}
+	if c.Daemon.Debounce < 0 {
+		v.errorf([]string{"daemon", "debounce"}, "delay must not be negative")
+	}
+
+	if c.Server.Socket != "" && !filepath.IsAbs(c.Server.Socket) {
+		v.errorf([]string{"server", "socket"}, "socket path must be absolute")
+	}
+
+	if len(v.errs) > 0 {
+		sort.SliceStable(v.errs, func(i, j int) bool {
+			return v.errs[i].Line < v.errs[j].Line
+		})
+		return v.errs
+	}
+	return nil
+}
+
+// ValidateBackend checks that a backend name is empty, BackendAuto or one
+// of Backends.
+func ValidateBackend(backend string) error {
+	if backend == "" || backend == BackendAuto || slices.Contains(Backends, backend) {
+		return nil
+	}
+	return fmt.Errorf("unsupported value %q (supported: %s, %s)", backend, BackendAuto, strings.Join(Backends, ", "))
+}
+
+// checkPersistence checks a persistence setting.
+func (v *validator) checkPersistence(path []string, persistence string) {
+	switch persistence {
+	case "", PersistenceRuntime, PersistencePersistent:
+	default:
+		v.errorf(path, "unsupported value %q (supported: %s, %s)", persistence, PersistenceRuntime, PersistencePersistent)
+	}
+}
+
+// validateRule checks that a rule names an existing profile and that its
+// conditions are well-formed.
+func (v *validator) validateRule(c *Config, rule Rule, path []string) {
+	if rule.Profile == "" {
+		v.errorf(path, "rule must name a profile")
+	} else if _, ok := c.Profiles[rule.Profile]; !ok {
+		v.errorf(append(path, "profile"), "unknown profile %q", rule.Profile)
+	}
+
+	if rule.Gateway != "" {
+		if _, err := netip.ParseAddr(rule.Gateway); err != nil {
+			v.errorf(append(path, "gateway"), "invalid IP address %q", rule.Gateway)
+		}
+	}
+	if rule.GatewayMAC != "" {
+		if _, err := net.ParseMAC(rule.GatewayMAC); err != nil {
+			v.errorf(append(path, "gateway_mac"), "invalid MAC address %q", rule.GatewayMAC)
+		}
+	}
+	if rule.Interface != "" {
+		if err := ValidateServicePattern(rule.Interface); err != nil {
+			v.errorf(append(path, "interface"), "%v", err)
+		}
+	}
+	if rule.Subnet != "" {
+		if _, err := netip.ParsePrefix(rule.Subnet); err != nil {
+			v.errorf(append(path, "subnet"), "invalid subnet %q (use CIDR notation, e.g. 10.0.0.0/8)", rule.Subnet)
+		}
+	}
+}
+
+// ValidateServicePattern checks that a service entry is non-empty and,
+// if it is a glob, well-formed.
+func ValidateServicePattern(service string) error {
+	if strings.TrimSpace(service) == "" {
+		return fmt.Errorf("service name must not be empty")
+	}
+	if _, err := path.Match(service, ""); err != nil {
+		return fmt.Errorf("invalid service pattern %q", service)
+	}
+	return nil
+}
+
+// ValidateServer checks that a server is an IPv4 or IPv6 address,
+// optionally with a zone ("fe80::1%eth0") or port ("1.1.1.1:53", "[::1]:53").
+func ValidateServer(server string) error {
+	if _, err := netip.ParseAddr(server); err == nil {
+		return nil
+	}
+	if _, err := netip.ParseAddrPort(server); err == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid server address %q", server)
+}
+
+// ValidateDomain checks that a domain is a valid DNS name such as
+// "corp.example.com". A single trailing dot is allowed.
+func ValidateDomain(domain string) error {
+	name := strings.TrimSuffix(domain, ".")
+	if name == "" || len(name) > 253 {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid domain %q", domain)
+		}
+		for _, r := range label {
+			if !isDomainRune(r) {
+				return fmt.Errorf("invalid domain %q", domain)
+			}
+		}
+	}
+	return nil
+}
+
+// isDomainRune reports whether r may appear in a domain label.
+func isDomainRune(r rune) bool {
+	return r == '-' || r == '_' ||
+		('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9')
+}
+
+// validator accumulates validation errors and locates them in the YAML tree.
+type validator struct {
+	root *yaml.Node
+	errs ValidationErrors
+}
+
+// errorf records an error for the field at path.
+func (v *validator) errorf(path []string, format string, args ...any) {
+	err := ValidationError{
+		Path:    v.formatPath(path),
+		Message: fmt.Sprintf(format, args...),
+	}
+	if node := v.lookup(path...); node != nil {
+		err.Line = node.Line
+		err.Column = node.Column
+	}
+	v.errs = append(v.errs, err)
+}
+
+// lookup returns the YAML node at path, or nil if it does not exist.
+// Numeric path elements index into sequences.
+func (v *validator) lookup(path ...string) *yaml.Node {
+	node := v.root
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	for _, key := range path {
+		if node == nil {
+			return nil
+		}
+		switch node.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					next = node.Content[i+1]
+					break
+				}
+			}
+			node = next
+		case yaml.SequenceNode:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[idx]
+		default:
+			return nil
+		}
+	}
+
+	return node
+}
+
+// checkKnownFields reports mapping keys that do not correspond to a field of t.
+func (v *validator) checkKnownFields(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.DocumentNode {
+		for _, child := range node.Content {
+			v.checkKnownFields(child, t, path)
+		}
+		return
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				v.errs = append(v.errs, ValidationError{
+					Line:    key.Line,
+					Column:  key.Column,
+					Path:    joinPath(path, key.Value),
+					Message: "unknown key",
+				})
+				continue
+			}
+			v.checkKnownFields(value, field.Type, joinPath(path, key.Value))
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.checkKnownFields(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, child := range node.Content {
+			v.checkKnownFields(child, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// yamlFields maps the YAML keys of a struct type to their fields.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// formatPath renders a lookup path, e.g. ["profiles", "home", "servers", "1"]
+// becomes "profiles.home.servers[1]". An element is an index only if its
+// parent in the YAML tree is a sequence, so a profile named "1" stays a
+// key. Where the tree does not say, numeric elements are indexes.
+func (v *validator) formatPath(path []string) string {
+	result := ""
+	for i, elem := range path {
+		parent := v.lookup(path[:i]...)
+		_, err := strconv.Atoi(elem)
+		if (parent != nil && parent.Kind == yaml.SequenceNode) || (parent == nil && err == nil) {
+			result += "[" + elem + "]"
+			continue
+		}
+		result = joinPath(result, elem)
+	}
+	return result
+}
+
+// joinPath appends a key to a dotted path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,407 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadString writes YAML to a temp file and loads it.
+func loadString(t *testing.T, content string) *Config {
+	t.Helper()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	return cfg
+}
+
+// validationErrors runs Validate and returns the individual errors.
+func validationErrors(t *testing.T, cfg *Config) ValidationErrors {
+	t.Helper()
+
+	err := cfg.Validate()
+	if err == nil {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %T: %v", err, err)
+	}
+	return errs
+}
+
+// TestValidate_DefaultConfig tests that the default config is valid.
+func TestValidate_DefaultConfig(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("expected default config to be valid, got: %v", err)
+	}
+}
+
+// TestValidate_ValidServers tests the accepted server address forms.
+func TestValidate_ValidServers(t *testing.T) {
+	cfg := loadString(t, `version: 1
+profiles:
+  mixed:
+    servers:
+      - 1.1.1.1
+      - 1.1.1.1:53
+      - 2606:4700:4700::1111
+      - "[2606:4700:4700::1111]:853"
+      - fe80::1%eth0
+`)
+
+	if errs := validationErrors(t, cfg); len(errs) != 0 {
+		t.Errorf("expected no errors, got: %v", errs)
+	}
+}
+
+// TestValidate_InvalidServer tests that malformed servers are reported with position.
+func TestValidate_InvalidServer(t *testing.T) {
+	cfg := loadString(t, `version: 1
+profiles:
+  home:
+    servers:
+      - 1.1.1.1
+      - 1.1.1
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Line != 6 || errs[0].Column != 9 {
+		t.Errorf("expected line 6, column 9, got line %d, column %d", errs[0].Line, errs[0].Column)
+	}
+	if errs[0].Path != "profiles.home.servers[1]" {
+		t.Errorf("unexpected path: %s", errs[0].Path)
+	}
+	if !strings.Contains(errs[0].Error(), `invalid server address "1.1.1"`) {
+		t.Errorf("unexpected message: %v", errs[0])
+	}
+}
+
+// TestValidate_NumericProfileName tests that a profile named like an index
+// is reported as a key.
+func TestValidate_NumericProfileName(t *testing.T) {
+	cfg := loadString(t, `version: 1
+profiles:
+  "1":
+    servers:
+      - 1.1.1
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "profiles.1.servers[0]" {
+		t.Errorf("expected profiles.1.servers[0], got %s", errs[0].Path)
+	}
+}
+
+// TestValidate_DHCPWithServers tests that dhcp and servers are mutually exclusive.
+func TestValidate_DHCPWithServers(t *testing.T) {
+	cfg := loadString(t, `version: 1
+profiles:
+  traveling:
+    dhcp: true
+    servers: ["8.8.8.8"]
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if !strings.Contains(errs[0].Message, "dhcp: true cannot be combined with servers") {
+		t.Errorf("unexpected message: %s", errs[0].Message)
+	}
+}
+
+// TestValidate_UnknownKeys tests that unknown keys are reported at any depth.
+func TestValidate_UnknownKeys(t *testing.T) {
+	cfg := loadString(t, `version: 1
+default_servce: Wi-Fi
+profiles:
+  home:
+    severs: ["1.1.1.1"]
+settings:
+  flush_cache: true
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "default_servce" || errs[0].Line != 2 {
+		t.Errorf("unexpected first error: %v", errs[0])
+	}
+	if errs[1].Path != "profiles.home.severs" || errs[1].Line != 5 {
+		t.Errorf("unexpected second error: %v", errs[1])
+	}
+}
+
+// TestValidate_UnsupportedVersion tests that only the supported version is accepted.
+func TestValidate_UnsupportedVersion(t *testing.T) {
+	cfg := loadString(t, `version: 2
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Line != 1 || !strings.Contains(errs[0].Message, "unsupported version 2") {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
+
+// TestValidate_MissingVersion tests that a missing version is accepted.
+func TestValidate_MissingVersion(t *testing.T) {
+	cfg := loadString(t, `profiles: {}
+`)
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+// TestValidate_WithoutSource tests validating a config built in code.
+func TestValidate_WithoutSource(t *testing.T) {
+	cfg := &Config{
+		Version: 1,
+		Profiles: map[string]Profile{
+			"bad": {Servers: []string{"not-an-ip"}},
+		},
+	}
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errs))
+	}
+	if errs[0].Line != 0 {
+		t.Errorf("expected no line number, got %d", errs[0].Line)
+	}
+	if errs[0].Error() != `profiles.bad.servers[0]: invalid server address "not-an-ip"` {
+		t.Errorf("unexpected message: %v", errs[0])
+	}
+}
+
+// TestValidate_NegativeTimeout tests that negative timeouts are rejected.
+func TestValidate_NegativeTimeout(t *testing.T) {
+	cfg := loadString(t, `version: 1
+settings:
+  timeouts:
+    apply: -5s
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 || errs[0].Path != "settings.timeouts.apply" {
+		t.Errorf("expected one error for settings.timeouts.apply, got: %v", errs)
+	}
+}
+
+// TestValidate_Domains tests that search and routing domains are checked.
+func TestValidate_Domains(t *testing.T) {
+	cfg := loadString(t, `version: 1
+profiles:
+  work:
+    servers: ["10.0.0.53"]
+    domains:
+      - corp.example.com
+      - bad domain
+    route_only_domains:
+      - internal.example.com
+      - "."
+      - -invalid.example.com
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "profiles.work.domains[1]" || errs[0].Line != 7 {
+		t.Errorf("unexpected first error: %v", errs[0])
+	}
+	if errs[1].Path != "profiles.work.route_only_domains[2]" || errs[1].Line != 11 {
+		t.Errorf("unexpected second error: %v", errs[1])
+	}
+}
+
+// TestValidate_Services tests that profile service patterns are checked.
+func TestValidate_Services(t *testing.T) {
+	cfg := loadString(t, `version: 1
+profiles:
+  home:
+    servers: ["1.1.1.1"]
+    services:
+      - Wi-Fi
+      - "en*"
+      - "en["
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "profiles.home.services[2]" || errs[0].Line != 8 {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
+
+// TestValidate_Rules tests that rules name known profiles and valid conditions.
+func TestValidate_Rules(t *testing.T) {
+	cfg := loadString(t, `version: 1
+profiles:
+  home:
+    servers: ["192.168.1.100"]
+rules:
+  - profile: home
+    ssid: HomeNet
+    gateway_mac: "a0:b1:c2:d3:e4:f5"
+  - profile: office
+    subnet: 10.20.0.0/16
+  - profile: home
+    gateway: 192.168.1.300
+    subnet: 10.20.0.0
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "rules[1].profile" || errs[0].Line != 9 {
+		t.Errorf("unexpected first error: %v", errs[0])
+	}
+	if errs[1].Path != "rules[2].gateway" || errs[1].Line != 12 {
+		t.Errorf("unexpected second error: %v", errs[1])
+	}
+	if errs[2].Path != "rules[2].subnet" || errs[2].Line != 13 {
+		t.Errorf("unexpected third error: %v", errs[2])
+	}
+}
+
+// TestValidate_Daemon tests the daemon's service assignments and intervals.
+func TestValidate_Daemon(t *testing.T) {
+	cfg := loadString(t, `version: 1
+profiles:
+  home:
+    servers: ["192.168.1.100"]
+daemon:
+  services:
+    - service: Wi-Fi
+      profile: home
+    - service: "en*"
+      profile: work
+  poll_interval: -1s
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "daemon.services[1].profile" || errs[0].Line != 10 {
+		t.Errorf("unexpected first error: %v", errs[0])
+	}
+	if errs[1].Path != "daemon.poll_interval" || errs[1].Line != 11 {
+		t.Errorf("unexpected second error: %v", errs[1])
+	}
+}
+
+// TestValidate_ServerSocket tests that the server socket must be an absolute path.
+func TestValidate_ServerSocket(t *testing.T) {
+	cfg := loadString(t, `version: 1
+server:
+  socket: dnsctl.sock
+  group: dnsctl
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "server.socket" || errs[0].Line != 3 {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
+
+// TestValidate_Elevate tests that only known helper launchers are accepted.
+func TestValidate_Elevate(t *testing.T) {
+	cfg := loadString(t, `version: 1
+settings:
+  elevate: doas
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "settings.elevate" || !strings.Contains(errs[0].Message, `unsupported value "doas"`) {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
+
+// TestValidate_Persistence tests that only known persistence modes are
+// accepted, globally and per profile.
+func TestValidate_Persistence(t *testing.T) {
+	cfg := loadString(t, `version: 1
+settings:
+  persistence: forever
+profiles:
+  home:
+    servers: [192.168.1.1]
+    persistence: persistent
+  travel:
+    servers: [1.1.1.1]
+    persistence: boot
+`)
+
+	errs := validationErrors(t, cfg)
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Path != "settings.persistence" || !strings.Contains(errs[0].Message, `unsupported value "forever"`) {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+	if errs[1].Path != "profiles.travel.persistence" || errs[1].Line != 10 {
+		t.Errorf("unexpected error: %v", errs[1])
+	}
+}
+
+// TestValidate_Backend tests that only known backends are accepted.
+func TestValidate_Backend(t *testing.T) {
+	valid := loadString(t, "version: 1\nsettings:\n  backend: networkmanager\n")
+	invalid := loadString(t, "version: 1\nsettings:\n  backend: nmcli\n")
+
+	validErr := valid.Validate()
+	errs := validationErrors(t, invalid)
+
+	if validErr != nil {
+		t.Errorf("expected no error, got: %v", validErr)
+	}
+	if len(errs) != 1 || errs[0].Path != "settings.backend" || !strings.Contains(errs[0].Message, `unsupported value "nmcli" (supported: auto, networkd,`) {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,263 @@
+// Package daemon keeps the configured DNS profiles applied while the
+// network changes underneath them.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/network"
+)
+
+// ErrNothingToManage is returned when the config has neither rules nor
+// daemon services, so the daemon would never change anything.
+var ErrNothingToManage = errors.New("no rules or daemon services configured")
+
+// Daemon re-applies profiles when the network changes, when a poll finds
+// a service's servers changed (e.g. by DHCP), and on startup.
+type Daemon struct {
+	Config  *config.Config
+	Applier *apply.Applier
+	Log     *log.Logger
+
+	// Detect describes the current network for rules. It is only called
+	// when rules are configured.
+	Detect func(ctx context.Context) (network.Info, error)
+
+	// Events subscribes to network changes. Nil uses the system's
+	// netlink or routing socket; if that fails, the daemon only polls.
+	Events func(ctx context.Context) (<-chan struct{}, error)
+
+	// services remembers the last decision for each service, so repeated
+	// polls only log when something changes.
+	services map[string]serviceState
+	// rule is the index of the last matching rule, or -1 for none.
+	rule int
+	// ruleChecked is set once rules were evaluated.
+	ruleChecked bool
+}
+
+// serviceState is what the daemon last did to a service.
+type serviceState struct {
+	profile string
+	// active is set once the profile was seen or made active.
+	active bool
+	// failed is set when applying the profile failed. It is not retried
+	// until the network changes.
+	failed bool
+}
+
+// New creates a Daemon for a config, changing DNS through applier.
+func New(cfg *config.Config, applier *apply.Applier, logger *log.Logger) *Daemon {
+	return &Daemon{
+		Config:  cfg,
+		Applier: applier,
+		Log:     logger,
+		Detect:  network.NewDetector().Detect,
+
+		services: make(map[string]serviceState),
+	}
+}
+
+// assignment is the profile a service should use, and why.
+type assignment struct {
+	service string
+	profile string
+	reason  string
+}
+
+// Run enforces the configured profiles until ctx is done.
+// A change that is in progress when ctx is cancelled is finished first,
+// bounded by the apply timeout, so shutdown never leaves DNS half-changed.
+func (d *Daemon) Run(ctx context.Context) error {
+	if len(d.Config.Rules) == 0 && len(d.Config.Daemon.Services) == 0 {
+		return ErrNothingToManage
+	}
+
+	interval := d.Config.Daemon.PollEvery()
+	debounce := d.Config.Daemon.DebounceDelay()
+	d.Log.Printf("starting with %s backend (poll every %s, debounce %s)", d.Applier.Client.Name(), interval, debounce)
+
+	subscribe := d.Events
+	if subscribe == nil {
+		subscribe = systemEvents
+	}
+	events, err := subscribe(ctx)
+	if err != nil {
+		d.Log.Printf("network events unavailable, polling only: %v", err)
+	}
+
+	d.reconcile(ctx, "startup", true)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var timer *time.Timer
+	var settled <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.Log.Print("shutting down")
+			return nil
+
+		case _, ok := <-events:
+			if !ok {
+				d.Log.Print("network events stopped, polling only")
+				events = nil
+				continue
+			}
+			// Wait for the network to settle before acting
+			if timer == nil {
+				timer = time.NewTimer(debounce)
+			} else {
+				timer.Reset(debounce)
+			}
+			settled = timer.C
+
+		case <-settled:
+			settled = nil
+			d.reconcile(ctx, "network changed", true)
+
+		case <-ticker.C:
+			d.reconcile(ctx, "poll", false)
+		}
+	}
+}
+
+// reconcile applies the planned profile to every service that does not
+// already use it. Failed profiles are only retried when networkChanged.
+func (d *Daemon) reconcile(ctx context.Context, trigger string, networkChanged bool) {
+	// Finish this pass even if shutdown was requested meanwhile
+	ctx = context.WithoutCancel(ctx)
+
+	plan, err := d.plan(ctx)
+	if err != nil {
+		d.Log.Printf("%s: %v", trigger, err)
+		return
+	}
+
+	for _, a := range plan {
+		d.enforce(ctx, a, networkChanged)
+	}
+}
+
+// plan returns the profile each service should use: the profile of the
+// first matching rule for its services, then the daemon's own service
+// assignments for any service not yet covered.
+func (d *Daemon) plan(ctx context.Context) ([]assignment, error) {
+	var plan []assignment
+	assigned := make(map[string]bool)
+
+	assign := func(patterns []string, profile, reason string) {
+		for _, pattern := range patterns {
+			services, err := dns.ResolveServices(d.Applier.Client, []string{pattern})
+			if errors.Is(err, dns.ErrNoMatchingService) {
+				// E.g. a dock that is unplugged right now
+				continue
+			}
+			if err != nil {
+				d.Log.Printf("%s: %v", pattern, err)
+				continue
+			}
+			for _, service := range services {
+				if !assigned[service] {
+					assigned[service] = true
+					plan = append(plan, assignment{service: service, profile: profile, reason: reason})
+				}
+			}
+		}
+	}
+
+	if len(d.Config.Rules) > 0 {
+		info, err := d.Detect(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect network: %w", err)
+		}
+
+		idx := info.MatchRule(d.Config.Rules)
+		if idx != d.rule || !d.ruleChecked {
+			if idx == -1 {
+				d.Log.Print("no rule matches the current network")
+			} else {
+				d.Log.Printf("rule %d matches the current network: profile %s", idx+1, d.Config.Rules[idx].Profile)
+			}
+			d.rule = idx
+			d.ruleChecked = true
+		}
+
+		if idx != -1 {
+			name := d.Config.Rules[idx].Profile
+			profile, _ := d.Config.GetProfile(name)
+			patterns := profile.Services
+			if len(patterns) == 0 {
+				patterns = []string{d.Config.DefaultService}
+			}
+			assign(patterns, name, fmt.Sprintf("rule %d", idx+1))
+		}
+	}
+
+	for _, entry := range d.Config.Daemon.Services {
+		assign([]string{entry.Service}, entry.Profile, "assigned to "+entry.Service)
+	}
+
+	return plan, nil
+}
+
+// enforce applies an assignment unless its profile is already active.
+func (d *Daemon) enforce(ctx context.Context, a assignment, networkChanged bool) {
+	profile, ok := d.Config.GetProfile(a.profile)
+	if !ok {
+		d.Log.Printf("%s: unknown profile %q", a.service, a.profile)
+		return
+	}
+
+	state, err := d.Applier.Capture(ctx, a.service)
+	if err != nil {
+		d.Log.Printf("%s: failed to read DNS: %v", a.service, err)
+		return
+	}
+
+	last := d.services[a.service]
+	if profile.MatchesDNS(state.Servers, state.IsDHCP(), d.Config.Settings.MatchOptions()) {
+		if last.profile != a.profile || !last.active {
+			d.Log.Printf("%s: profile %s already active (%s)", a.service, a.profile, a.reason)
+		}
+		d.services[a.service] = serviceState{profile: a.profile, active: true}
+		return
+	}
+
+	if last.profile == a.profile && last.failed && !networkChanged {
+		return
+	}
+
+	if last.profile == a.profile && last.active {
+		d.Log.Printf("%s: servers changed to %s, re-applying profile %s (%s)", a.service, describeServers(state.Servers), a.profile, a.reason)
+	} else {
+		d.Log.Printf("%s: applying profile %s (%s)", a.service, a.profile, a.reason)
+	}
+
+	if _, err := d.Applier.Apply(ctx, a.service, profile); err != nil {
+		d.Log.Printf("%s: failed to apply profile %s: %v; retrying after the next network change", a.service, a.profile, err)
+		d.services[a.service] = serviceState{profile: a.profile, failed: true}
+		return
+	}
+
+	d.Log.Printf("%s: applied profile %s", a.service, a.profile)
+	d.services[a.service] = serviceState{profile: a.profile, active: true}
+}
+
+// describeServers formats servers for the log, with "DHCP" for none.
+func describeServers(servers []string) string {
+	if len(servers) == 0 {
+		return "DHCP"
+	}
+	return strings.Join(servers, ", ")
+}
@@ -0,0 +1,257 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/network"
+	"github.com/nycjv321/dnsctl/internal/probe"
+)
+
+// testConfig assigns "home" to Wi-Fi and has a rule for the office network.
+func testConfig() *config.Config {
+	return &config.Config{
+		DefaultService: "Wi-Fi",
+		Profiles: map[string]config.Profile{
+			"home":   {Servers: []string{"192.168.1.100"}},
+			"office": {Servers: []string{"10.0.0.53"}},
+		},
+		Rules: []config.Rule{
+			{Profile: "office", SSID: "Office"},
+		},
+		Daemon: config.Daemon{
+			Services: []config.ServiceProfile{
+				{Service: "Wi-Fi", Profile: "home"},
+				{Service: "Ether*", Profile: "home"},
+			},
+		},
+	}
+}
+
+// testDaemon creates a Daemon on a mock client that sees info as the
+// network and logs to the returned buffer.
+func testDaemon(cfg *config.Config, info network.Info) (*Daemon, *dns.MockClient, *bytes.Buffer) {
+	mock := dns.NewMockClient()
+	applier := apply.New(mock, cfg.Settings)
+	applier.Checker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+		results := make([]probe.Result, len(servers))
+		for i, server := range servers {
+			results[i] = probe.Result{Server: server, Latency: time.Millisecond}
+		}
+		return results
+	})
+
+	logs := &bytes.Buffer{}
+	d := New(cfg, applier, log.New(logs, "", 0))
+	d.Detect = func(ctx context.Context) (network.Info, error) {
+		return info, nil
+	}
+	d.Events = func(ctx context.Context) (<-chan struct{}, error) {
+		return nil, errors.New("not in tests")
+	}
+	return d, mock, logs
+}
+
+// TestRun_AppliesOnStartup tests that assigned profiles are applied once
+// on startup and the daemon stops when cancelled.
+func TestRun_AppliesOnStartup(t *testing.T) {
+	d, mock, logs := testDaemon(testConfig(), network.Info{SSID: "HomeNet"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := d.Run(ctx)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(mock.SetCalls) != 2 {
+		t.Fatalf("expected 2 set calls, got %v", mock.SetCalls)
+	}
+	for _, want := range []string{
+		"no rule matches the current network",
+		"Wi-Fi: applying profile home (assigned to Wi-Fi)",
+		"Ethernet: applied profile home",
+		"shutting down",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected log %q, got:\n%s", want, logs.String())
+		}
+	}
+}
+
+// TestRun_NothingToManage tests that the daemon refuses to run without work.
+func TestRun_NothingToManage(t *testing.T) {
+	cfg := testConfig()
+	cfg.Rules = nil
+	cfg.Daemon.Services = nil
+	d, _, _ := testDaemon(cfg, network.Info{})
+
+	err := d.Run(context.Background())
+
+	if !errors.Is(err, ErrNothingToManage) {
+		t.Errorf("expected ErrNothingToManage, got: %v", err)
+	}
+}
+
+// TestPlan_RuleTakesPrecedence tests that a matching rule overrides the
+// daemon's own assignment for its services.
+func TestPlan_RuleTakesPrecedence(t *testing.T) {
+	d, _, logs := testDaemon(testConfig(), network.Info{SSID: "Office"})
+
+	plan, err := d.plan(context.Background())
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(plan) != 2 {
+		t.Fatalf("expected 2 assignments, got %+v", plan)
+	}
+	if plan[0].service != "Wi-Fi" || plan[0].profile != "office" || plan[0].reason != "rule 1" {
+		t.Errorf("expected office on Wi-Fi by rule 1, got %+v", plan[0])
+	}
+	if plan[1].service != "Ethernet" || plan[1].profile != "home" {
+		t.Errorf("expected home on Ethernet, got %+v", plan[1])
+	}
+	if !strings.Contains(logs.String(), "rule 1 matches the current network: profile office") {
+		t.Errorf("expected rule match to be logged, got:\n%s", logs.String())
+	}
+}
+
+// TestPlan_SkipsMissingServices tests that a glob matching nothing, such
+// as an unplugged dock, is not an error.
+func TestPlan_SkipsMissingServices(t *testing.T) {
+	d, mock, _ := testDaemon(testConfig(), network.Info{})
+	mock.Services = []string{"Wi-Fi"}
+
+	plan, err := d.plan(context.Background())
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(plan) != 1 || plan[0].service != "Wi-Fi" {
+		t.Errorf("expected only Wi-Fi, got %+v", plan)
+	}
+}
+
+// TestReconcile_ReappliesAfterDHCPOverwrite tests that a poll notices
+// servers replaced behind the daemon's back.
+func TestReconcile_ReappliesAfterDHCPOverwrite(t *testing.T) {
+	cfg := testConfig()
+	cfg.Daemon.Services = cfg.Daemon.Services[:1]
+	d, mock, logs := testDaemon(cfg, network.Info{})
+	ctx := context.Background()
+
+	d.reconcile(ctx, "startup", true)
+	d.reconcile(ctx, "poll", false)
+
+	if len(mock.SetCalls) != 1 {
+		t.Fatalf("expected an active profile not to be re-applied, got %v", mock.SetCalls)
+	}
+
+	// DHCP renews the lease and puts the router back
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.1"}
+	d.reconcile(ctx, "poll", false)
+
+	if len(mock.SetCalls) != 2 {
+		t.Fatalf("expected the profile to be re-applied, got %v", mock.SetCalls)
+	}
+	if !strings.Contains(logs.String(), "Wi-Fi: servers changed to 192.168.1.1, re-applying profile home") {
+		t.Errorf("expected overwrite to be logged, got:\n%s", logs.String())
+	}
+}
+
+// TestReconcile_DHCPProfileWithServers tests that a DHCP profile stays
+// active while the service uses the servers DHCP gave it.
+func TestReconcile_DHCPProfileWithServers(t *testing.T) {
+	cfg := testConfig()
+	cfg.Profiles["home"] = config.Profile{DHCP: true}
+	cfg.Daemon.Services = cfg.Daemon.Services[:1]
+	d, mock, logs := testDaemon(cfg, network.Info{})
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.1"}
+	mock.Automatic = map[string]bool{"Wi-Fi": true}
+	ctx := context.Background()
+
+	d.reconcile(ctx, "startup", true)
+	d.reconcile(ctx, "poll", false)
+
+	if len(mock.ClearCalls) != 0 {
+		t.Errorf("expected the DHCP servers to be left alone, got %v", mock.ClearCalls)
+	}
+	if !strings.Contains(logs.String(), "Wi-Fi: profile home already active") {
+		t.Errorf("expected the profile to be active, got:\n%s", logs.String())
+	}
+}
+
+// TestReconcile_RetriesFailuresOnNetworkChange tests that a failing
+// profile is not retried on every poll.
+func TestReconcile_RetriesFailuresOnNetworkChange(t *testing.T) {
+	cfg := testConfig()
+	cfg.Daemon.Services = cfg.Daemon.Services[:1]
+	d, mock, logs := testDaemon(cfg, network.Info{})
+	mock.SetError = errors.New("permission denied")
+	ctx := context.Background()
+
+	d.reconcile(ctx, "startup", true)
+	d.reconcile(ctx, "poll", false)
+
+	if len(mock.SetCalls) != 1 {
+		t.Fatalf("expected no retry on poll, got %v", mock.SetCalls)
+	}
+
+	d.reconcile(ctx, "network changed", true)
+
+	if len(mock.SetCalls) != 2 {
+		t.Errorf("expected a retry after the network changed, got %v", mock.SetCalls)
+	}
+	if !strings.Contains(logs.String(), "Wi-Fi: failed to apply profile home: permission denied") {
+		t.Errorf("expected failure to be logged, got:\n%s", logs.String())
+	}
+}
+
+// TestRun_DebouncesEvents tests that a burst of network events causes a
+// single re-check once the network is quiet.
+func TestRun_DebouncesEvents(t *testing.T) {
+	cfg := testConfig()
+	cfg.Daemon.Debounce = 50 * time.Millisecond
+	cfg.Daemon.PollInterval = time.Hour
+	d, _, _ := testDaemon(cfg, network.Info{})
+
+	var checks atomic.Int32
+	d.Detect = func(ctx context.Context) (network.Info, error) {
+		checks.Add(1)
+		return network.Info{}, nil
+	}
+	events := make(chan struct{})
+	d.Events = func(ctx context.Context) (<-chan struct{}, error) {
+		return events, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+
+	// A flapping link
+	for range 5 {
+		events <- struct{}{}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// One check on startup and one after the burst
+	if got := checks.Load(); got != 2 {
+		t.Errorf("expected 2 checks, got %d", got)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build linux || darwin
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// readTimeout bounds each read from a routing socket so a cancelled
+// watch notices promptly.
+const readTimeout = 500 * time.Millisecond
+
+// socketEvents reads change messages from a routing socket until ctx is
+// done, then closes it. Messages are not decoded: any of them means the
+// network may have changed. A burst of messages yields at most one
+// pending event, and the channel is closed if the socket fails.
+func socketEvents(ctx context.Context, fd int) (<-chan struct{}, error) {
+	tv := unix.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	events := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case events <- struct{}{}:
+		default:
+			// A pending event already covers this change
+		}
+	}
+
+	go func() {
+		defer close(events)
+		defer unix.Close(fd)
+
+		buf := make([]byte, 64*1024)
+		for ctx.Err() == nil {
+			n, err := unix.Read(fd, buf)
+			switch {
+			case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+				continue
+			case errors.Is(err, unix.ENOBUFS):
+				// Messages were dropped because we fell behind; they
+				// still tell us something changed
+				notify()
+			case err != nil:
+				return
+			case n > 0:
+				notify()
+			}
+		}
+	}()
+
+	return events, nil
+}
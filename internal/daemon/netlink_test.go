@@ -0,0 +1,33 @@
+//go:build linux
+
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSystemEvents_ClosesOnCancel tests that the netlink watch stops
+// when its context is cancelled.
+func TestSystemEvents_ClosesOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := systemEvents(ctx)
+	if err != nil {
+		t.Skipf("netlink unavailable: %v", err)
+	}
+
+	cancel()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("expected events to close after cancel")
+		}
+	}
+}
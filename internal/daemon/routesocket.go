@@ -0,0 +1,22 @@
+//go:build darwin
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// systemEvents subscribes to interface, address and route changes on
+// the BSD routing socket.
+func systemEvents(ctx context.Context) (<-chan struct{}, error) {
+	fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open routing socket: %w", err)
+	}
+	unix.CloseOnExec(fd)
+
+	return socketEvents(ctx, fd)
+}
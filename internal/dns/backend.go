@@ -0,0 +1,115 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// BackendAuto selects the usable backend with the highest priority that
+// is not Manual.
+const BackendAuto = "auto"
+
+// ErrUnknownBackend is returned when a backend name is not registered on
+// this platform.
+var ErrUnknownBackend = errors.New("unknown backend")
+
+// ErrBackendUnavailable is returned when the selected backend cannot be
+// used on this system.
+var ErrBackendUnavailable = errors.New("backend not available")
+
+// Backend is a DNS management system dnsctl can manage DNS through.
+type Backend struct {
+	// Name selects the backend in settings.backend and --backend.
+	Name string
+
+	// Description says what the backend manages DNS with.
+	Description string
+
+	// Priority orders automatic selection: the usable backend with the
+	// highest priority is used.
+	Priority int
+
+	// Manual backends are only used when named, never selected
+	// automatically.
+	Manual bool
+
+	// Probe returns nil if the backend can be used on this system, or an
+	// error saying why not. It only inspects the system.
+	Probe func(runner cmdexec.Runner) error
+
+	// New creates the backend's client once Probe has passed.
+	New func(runner cmdexec.Runner) (Client, error)
+}
+
+// Detection is the outcome of probing a backend.
+type Detection struct {
+	Backend
+
+	// Err says why the backend cannot be used, or is nil if it can.
+	Err error
+}
+
+// Backends returns the backends of this platform, highest priority first.
+func Backends() []Backend {
+	backends := platformBackends()
+	sort.SliceStable(backends, func(i, j int) bool {
+		return backends[i].Priority > backends[j].Priority
+	})
+	return backends
+}
+
+// NewClient creates a DNS client for the usable backend with the highest
+// priority.
+func NewClient() (Client, error) {
+	return NewBackendClient(BackendAuto)
+}
+
+// NewBackendClient creates a DNS client for the named backend. An empty
+// name or BackendAuto selects one like NewClient.
+func NewBackendClient(name string) (Client, error) {
+	return newBackendClient(Backends(), name, cmdexec.Exec())
+}
+
+// DetectBackends probes every backend of this platform.
+func DetectBackends() []Detection {
+	return detectBackends(Backends(), cmdexec.Exec())
+}
+
+// newBackendClient creates a client for the named backend among backends,
+// which are ordered by priority.
+func newBackendClient(backends []Backend, name string, runner cmdexec.Runner) (Client, error) {
+	if name == "" || name == BackendAuto {
+		for _, backend := range backends {
+			if !backend.Manual && backend.Probe(runner) == nil {
+				return backend.New(runner)
+			}
+		}
+		return nil, ErrNoDNSBackend
+	}
+
+	var names []string
+	for _, backend := range backends {
+		if backend.Name != name {
+			names = append(names, backend.Name)
+			continue
+		}
+		if err := backend.Probe(runner); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrBackendUnavailable, name, err)
+		}
+		return backend.New(runner)
+	}
+	return nil, fmt.Errorf("%w %q on this platform (available: %s)", ErrUnknownBackend, name, strings.Join(names, ", "))
+}
+
+// detectBackends probes each of backends.
+func detectBackends(backends []Backend, runner cmdexec.Runner) []Detection {
+	detections := make([]Detection, 0, len(backends))
+	for _, backend := range backends {
+		detections = append(detections, Detection{Backend: backend, Err: backend.Probe(runner)})
+	}
+	return detections
+}
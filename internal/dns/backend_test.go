@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// namedMock is a MockClient named after its backend.
+type namedMock struct {
+	*MockClient
+	name string
+}
+
+// Name returns the backend's name.
+func (c namedMock) Name() string {
+	return c.name
+}
+
+// testBackends returns backends by decreasing priority: "first" is not
+// running, while "second" and "third" are usable.
+func testBackends() []Backend {
+	backend := func(name string, priority int, probeErr error) Backend {
+		return Backend{
+			Name:     name,
+			Priority: priority,
+			Probe:    func(cmdexec.Runner) error { return probeErr },
+			New: func(cmdexec.Runner) (Client, error) {
+				return namedMock{MockClient: NewMockClient(), name: name}, nil
+			},
+		}
+	}
+	return []Backend{
+		backend("first", 30, errors.New("first is inactive")),
+		backend("second", 20, nil),
+		backend("third", 10, nil),
+	}
+}
+
+// TestNewBackendClient_Auto tests that the usable backend with the
+// highest priority is selected.
+func TestNewBackendClient_Auto(t *testing.T) {
+	for _, name := range []string{"", BackendAuto} {
+		client, err := newBackendClient(testBackends(), name, cmdexectest.NewRunner())
+
+		if err != nil || client.Name() != "second" {
+			t.Errorf("expected second for %q, got %v (%v)", name, client, err)
+		}
+	}
+}
+
+// TestNewBackendClient_Manual tests that manual backends are skipped by
+// automatic selection but used when named.
+func TestNewBackendClient_Manual(t *testing.T) {
+	backends := testBackends()
+	backends[0].Probe = func(cmdexec.Runner) error { return nil }
+	backends[0].Manual = true
+
+	client, err := newBackendClient(backends, BackendAuto, cmdexectest.NewRunner())
+	if err != nil || client.Name() != "second" {
+		t.Errorf("expected second, got %v (%v)", client, err)
+	}
+
+	client, err = newBackendClient(backends, "first", cmdexectest.NewRunner())
+	if err != nil || client.Name() != "first" {
+		t.Errorf("expected first when named, got %v (%v)", client, err)
+	}
+}
+
+// TestNewBackendClient_Named tests that a named backend is used even if
+// another has a higher priority.
+func TestNewBackendClient_Named(t *testing.T) {
+	client, err := newBackendClient(testBackends(), "third", cmdexectest.NewRunner())
+
+	if err != nil || client.Name() != "third" {
+		t.Errorf("expected third, got %v (%v)", client, err)
+	}
+}
+
+// TestNewBackendClient_Unavailable tests that a named backend that fails
+// its probe is reported with the reason.
+func TestNewBackendClient_Unavailable(t *testing.T) {
+	_, err := newBackendClient(testBackends(), "first", cmdexectest.NewRunner())
+
+	if !errors.Is(err, ErrBackendUnavailable) || !strings.Contains(err.Error(), "first: first is inactive") {
+		t.Errorf("expected ErrBackendUnavailable with the reason, got: %v", err)
+	}
+}
+
+// TestNewBackendClient_Unknown tests that unknown names are rejected with
+// the available ones.
+func TestNewBackendClient_Unknown(t *testing.T) {
+	_, err := newBackendClient(testBackends(), "fourth", cmdexectest.NewRunner())
+
+	if !errors.Is(err, ErrUnknownBackend) || !strings.Contains(err.Error(), "(available: first, second, third)") {
+		t.Errorf("expected ErrUnknownBackend with the available backends, got: %v", err)
+	}
+}
+
+// TestNewBackendClient_NoneUsable tests that automatic selection fails
+// with ErrNoDNSBackend if no backend is usable.
+func TestNewBackendClient_NoneUsable(t *testing.T) {
+	backends := testBackends()[:1]
+
+	_, err := newBackendClient(backends, BackendAuto, cmdexectest.NewRunner())
+
+	if !errors.Is(err, ErrNoDNSBackend) {
+		t.Errorf("expected ErrNoDNSBackend, got: %v", err)
+	}
+}
+
+// TestDetectBackends tests that every backend is probed, in order.
+func TestDetectBackends(t *testing.T) {
+	detections := detectBackends(testBackends(), cmdexectest.NewRunner())
+
+	if len(detections) != 3 {
+		t.Fatalf("expected 3 detections, got %d", len(detections))
+	}
+	if detections[0].Name != "first" || detections[0].Err == nil {
+		t.Errorf("expected first to be rejected, got %+v", detections[0])
+	}
+	if detections[1].Err != nil || detections[2].Err != nil {
+		t.Errorf("expected second and third to be usable, got %v, %v", detections[1].Err, detections[2].Err)
+	}
+}
+
+// TestBackends_Priority tests that the platform's backends are ordered by
+// decreasing priority and have unique names.
+func TestBackends_Priority(t *testing.T) {
+	backends := Backends()
+	seen := make(map[string]bool)
+
+	for i, backend := range backends {
+		if i > 0 && backend.Priority > backends[i-1].Priority {
+			t.Errorf("expected %s after %s", backend.Name, backends[i-1].Name)
+		}
+		if seen[backend.Name] {
+			t.Errorf("duplicate backend %s", backend.Name)
+		}
+		seen[backend.Name] = true
+	}
+}
@@ -1,14 +1,37 @@
 package dns
 
+import (
+	"context"
+	"errors"
+)
+
+// ErrServerPortsUnsupported is returned by backends that can only use
+// servers on the standard DNS port.
+var ErrServerPortsUnsupported = errors.New("server ports are not supported by this backend")
+
 // Client defines the interface for DNS management operations.
 // Implementations provide platform-specific DNS configuration.
 type Client interface {
+	Reader
+	Writer
+}
+
+// Reader holds the read-only operations of a Client. They need no
+// privileges, so they can run in the user's own process.
+type Reader interface {
 	// ListNetworkServices returns all available network services/interfaces.
 	ListNetworkServices() ([]string, error)
 
 	// GetDNSServers returns the current DNS servers for a network service.
 	GetDNSServers(service string) ([]string, error)
 
+	// Name returns the backend name for display purposes.
+	Name() string
+}
+
+// Writer holds the operations of a Client that change the system's DNS
+// configuration. They usually require root.
+type Writer interface {
 	// SetDNSServers sets the DNS servers for a network service.
 	SetDNSServers(service string, servers []string) error
 
@@ -17,7 +40,26 @@ type Client interface {
 
 	// FlushCache flushes the DNS cache.
 	FlushCache() error
+}
 
-	// Name returns the backend name for display purposes.
-	Name() string
+// ManualDetector is implemented by clients that report the servers a
+// service learned from DHCP or router advertisements alongside those set
+// manually. Other clients only report manually set servers.
+type ManualDetector interface {
+	// HasManualDNS reports whether the servers of a service were set
+	// manually, rather than learned automatically.
+	HasManualDNS(ctx context.Context, service string) (bool, error)
+}
+
+// HasManualDNS reports whether servers, which a service uses, were set
+// manually. Any servers were, unless client is a ManualDetector that
+// says otherwise.
+func HasManualDNS(ctx context.Context, client Client, service string, servers []string) (bool, error) {
+	if len(servers) == 0 {
+		return false, nil
+	}
+	if detector, ok := client.(ManualDetector); ok {
+		return detector.HasManualDNS(ctx, service)
+	}
+	return true, nil
 }
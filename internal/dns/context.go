@@ -0,0 +1,134 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ContextClient is a Client whose operations accept a context, so callers
+// can cancel them or bound them with a deadline.
+type ContextClient interface {
+	Client
+
+	// ListNetworkServicesContext is ListNetworkServices with a context.
+	ListNetworkServicesContext(ctx context.Context) ([]string, error)
+
+	// GetDNSServersContext is GetDNSServers with a context.
+	GetDNSServersContext(ctx context.Context, service string) ([]string, error)
+
+	// SetDNSServersContext is SetDNSServers with a context.
+	SetDNSServersContext(ctx context.Context, service string, servers []string) error
+
+	// ClearDNSServersContext is ClearDNSServers with a context.
+	ClearDNSServersContext(ctx context.Context, service string) error
+
+	// FlushCacheContext is FlushCache with a context.
+	FlushCacheContext(ctx context.Context) error
+}
+
+// WithContext returns c as a ContextClient. Clients that do not support
+// contexts natively are wrapped so that calls return as soon as the
+// context is done, although the underlying operation keeps running.
+func WithContext(c Client) ContextClient {
+	if cc, ok := c.(ContextClient); ok {
+		return cc
+	}
+	return contextAdapter{c}
+}
+
+// contextAdapter adds context support to a plain Client.
+type contextAdapter struct {
+	Client
+}
+
+func (a contextAdapter) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	return await(ctx, a.ListNetworkServices)
+}
+
+func (a contextAdapter) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	return await(ctx, func() ([]string, error) {
+		return a.GetDNSServers(service)
+	})
+}
+
+func (a contextAdapter) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	_, err := await(ctx, func() (struct{}, error) {
+		return struct{}{}, a.SetDNSServers(service, servers)
+	})
+	return err
+}
+
+func (a contextAdapter) ClearDNSServersContext(ctx context.Context, service string) error {
+	_, err := await(ctx, func() (struct{}, error) {
+		return struct{}{}, a.ClearDNSServers(service)
+	})
+	return err
+}
+
+func (a contextAdapter) FlushCacheContext(ctx context.Context) error {
+	_, err := await(ctx, func() (struct{}, error) {
+		return struct{}{}, a.FlushCache()
+	})
+	return err
+}
+
+// await runs fn in the background and waits for it or for ctx to be done.
+func await[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// TimeoutError is returned when an operation exceeds its timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.Timeout)
+}
+
+// Unwrap allows errors.Is(err, context.DeadlineExceeded).
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// WithTimeout runs fn with a context that expires after timeout.
+// If that deadline is what stopped fn, the error is a *TimeoutError.
+// A non-positive timeout runs fn without a deadline.
+func WithTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(timeoutCtx)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return &TimeoutError{Timeout: timeout}
+	}
+	return err
+}
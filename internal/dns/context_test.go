@@ -0,0 +1,89 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// plainClient hides the context methods of MockClient.
+type plainClient struct {
+	Client
+}
+
+// TestWithContext_ReturnsNativeClient tests that context-aware clients are used directly.
+func TestWithContext_ReturnsNativeClient(t *testing.T) {
+	mock := NewMockClient()
+
+	if WithContext(mock) != ContextClient(mock) {
+		t.Error("expected the mock client to be returned unchanged")
+	}
+}
+
+// TestWithContext_AdaptsPlainClient tests that plain clients stop waiting on cancellation.
+func TestWithContext_AdaptsPlainClient(t *testing.T) {
+	mock := NewMockClient()
+	mock.Delay = time.Hour
+	client := WithContext(plainClient{mock})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := client.SetDNSServersContext(ctx, "Wi-Fi", []string{"1.1.1.1"})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
+
+// TestWithContext_AdaptsPlainClient_Success tests that results pass through the adapter.
+func TestWithContext_AdaptsPlainClient_Success(t *testing.T) {
+	mock := NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	client := WithContext(plainClient{mock})
+
+	servers, err := client.GetDNSServersContext(context.Background(), "Wi-Fi")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(servers) != 1 || servers[0] != "9.9.9.9" {
+		t.Errorf("expected [9.9.9.9], got %v", servers)
+	}
+}
+
+// TestWithTimeout_ReportsTimeout tests that an expired deadline becomes a TimeoutError.
+func TestWithTimeout_ReportsTimeout(t *testing.T) {
+	mock := NewMockClient()
+	mock.Delay = time.Hour
+
+	err := WithTimeout(context.Background(), 10*time.Millisecond, func(ctx context.Context) error {
+		return mock.SetDNSServersContext(ctx, "Wi-Fi", []string{"1.1.1.1"})
+	})
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected TimeoutError, got: %v", err)
+	}
+	if err.Error() != "timed out after 10ms" {
+		t.Errorf("unexpected message: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected TimeoutError to match context.DeadlineExceeded")
+	}
+}
+
+// TestWithTimeout_ParentCancellation tests that cancellation is not reported as a timeout.
+func TestWithTimeout_ParentCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := WithTimeout(ctx, time.Hour, func(ctx context.Context) error {
+		return ctx.Err()
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ErrRouteOnlyDomainsUnsupported is returned by backends that cannot
+// restrict a domain to routing only.
+var ErrRouteOnlyDomainsUnsupported = errors.New("routing-only domains are not supported by this backend")
+
+// Domains holds the DNS domains configured for a network service.
+type Domains struct {
+	// Search domains are appended to single-label names and also route
+	// queries for those domains to the service's servers.
+	Search []string
+
+	// RouteOnly domains only route queries for those domains to the
+	// service's servers. They are stored without the "~" prefix.
+	RouteOnly []string
+}
+
+// IsEmpty returns true if no domains are set.
+func (d Domains) IsEmpty() bool {
+	return len(d.Search) == 0 && len(d.RouteOnly) == 0
+}
+
+// Equal reports whether both sets contain the same domains, ignoring order.
+func (d Domains) Equal(other Domains) bool {
+	return equalUnordered(d.Search, other.Search) && equalUnordered(d.RouteOnly, other.RouteOnly)
+}
+
+// Entries returns the domains in resolved/NetworkManager notation, where
+// routing-only domains carry a "~" prefix.
+func (d Domains) Entries() []string {
+	entries := make([]string, 0, len(d.Search)+len(d.RouteOnly))
+	entries = append(entries, d.Search...)
+	for _, domain := range d.RouteOnly {
+		entries = append(entries, "~"+domain)
+	}
+	return entries
+}
+
+// ParseDomainEntries splits entries in "~" notation into Domains.
+func ParseDomainEntries(entries []string) Domains {
+	var d Domains
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			continue
+		case strings.HasPrefix(entry, "~"):
+			d.RouteOnly = append(d.RouteOnly, strings.TrimPrefix(entry, "~"))
+		default:
+			d.Search = append(d.Search, entry)
+		}
+	}
+	return d
+}
+
+// DomainClient is implemented by clients that can manage DNS search and
+// routing domains in addition to servers.
+type DomainClient interface {
+	// GetDomains returns the domains configured for a network service.
+	GetDomains(ctx context.Context, service string) (Domains, error)
+
+	// SetDomains replaces the domains of a network service.
+	// An empty Domains removes all manually configured domains.
+	SetDomains(ctx context.Context, service string, domains Domains) error
+}
+
+// CombinedClient is implemented by DomainClients that can replace the
+// servers and domains of a service in one change. Backends whose changes
+// reactivate a connection or reconfigure a link implement it, so that
+// applying a profile does so only once.
+type CombinedClient interface {
+	DomainClient
+
+	// SetDNSAndDomains replaces the servers and domains of a network
+	// service. No servers reverts to DHCP servers, as ClearDNSServers does.
+	SetDNSAndDomains(ctx context.Context, service string, servers []string, domains Domains) error
+}
+
+// equalUnordered reports whether a and b contain the same strings.
+func equalUnordered(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+}
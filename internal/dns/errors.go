@@ -4,3 +4,9 @@ import "errors"
 
 // ErrNoDNSBackend is returned when no supported DNS management system is detected.
 var ErrNoDNSBackend = errors.New("no supported DNS management system detected")
+
+// ErrNoActiveService is returned when no network service could be detected.
+var ErrNoActiveService = errors.New("no active network service detected")
+
+// ErrNoMatchingService is returned when a service pattern matches no network service.
+var ErrNoMatchingService = errors.New("no network service matches")
@@ -3,57 +3,144 @@
 package dns
 
 import (
-	"os/exec"
+	"context"
+	"errors"
+	"fmt"
 	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
 )
 
-// NewClient creates a new DNS client for Linux.
-// It auto-detects the available DNS management system.
-// Priority: systemd-resolved > NetworkManager
-func NewClient() (Client, error) {
-	// Check for systemd-resolved
-	if isResolvedActive() {
-		return &resolvedClient{}, nil
+// platformBackends returns the Linux backends. Automatic selection
+// prefers systemd-resolved, then NetworkManager, resolvconf and finally
+// /etc/resolv.conf. systemd-networkd drop-ins rewrite the network
+// configuration, so they are only used when named; persistent changes
+// through systemd-resolved use them too.
+func platformBackends() []Backend {
+	return []Backend{
+		{
+			Name:        "networkd",
+			Description: "systemd-networkd drop-ins, applied by systemd-resolved",
+			Manual:      true,
+			Probe:       probeNetworkd,
+			New: func(runner cmdexec.Runner) (Client, error) {
+				networkd := &networkdClient{runner: runner}
+				networkd.runtime = newResolvedClient(runner, networkd)
+				return networkd, nil
+			},
+		},
+		{
+			Name:        "resolved",
+			Description: "systemd-resolved",
+			Priority:    50,
+			Probe: func(runner cmdexec.Runner) error {
+				return checkService(runner, "resolvectl", "systemd-resolved")
+			},
+			New: newResolved,
+		},
+		{
+			Name:        "networkmanager",
+			Description: "NetworkManager connections",
+			Priority:    40,
+			Probe: func(runner cmdexec.Runner) error {
+				return checkService(runner, "nmcli", "NetworkManager")
+			},
+			New: func(runner cmdexec.Runner) (Client, error) {
+				return newNMClient(runner, false), nil
+			},
+		},
+		{
+			Name:        "resolvconf",
+			Description: "openresolv or Debian's resolvconf",
+			Priority:    30,
+			Probe: func(runner cmdexec.Runner) error {
+				if _, err := runner.LookPath("resolvconf"); err != nil {
+					return errors.New("resolvconf not found")
+				}
+				if findOpenresolvStateDir("/") == "" {
+					return errors.New("resolvconf keeps no records in /run/resolvconf")
+				}
+				return nil
+			},
+			New: func(runner cmdexec.Runner) (Client, error) {
+				client, ok := detectOpenresolv(runner, "/")
+				if !ok {
+					return nil, fmt.Errorf("%w: resolvconf keeps no records in /run/resolvconf", ErrBackendUnavailable)
+				}
+				return client, nil
+			},
+		},
+		{
+			Name:        "resolv.conf",
+			Description: "/" + resolvConfPath + ", edited directly",
+			Priority:    10,
+			Probe: func(runner cmdexec.Runner) error {
+				return (&resolvConfClient{root: "/"}).probe()
+			},
+			New: func(runner cmdexec.Runner) (Client, error) {
+				return &resolvConfClient{}, nil
+			},
+		},
 	}
+}
 
-	// Check for NetworkManager
-	if isNetworkManagerActive() {
-		return &nmClient{}, nil
+// probeNetworkd accepts systemd-networkd if it runs along with
+// systemd-resolved, which applies its DNS settings, and NetworkManager
+// does not manage the links instead.
+func probeNetworkd(runner cmdexec.Runner) error {
+	if err := checkService(runner, "networkctl", "systemd-networkd"); err != nil {
+		return err
 	}
-
-	return nil, ErrNoDNSBackend
+	if err := checkService(runner, "resolvectl", "systemd-resolved"); err != nil {
+		return fmt.Errorf("networkd's DNS settings need systemd-resolved: %w", err)
+	}
+	if isServiceActive(runner, "nmcli", "NetworkManager") {
+		return errors.New("NetworkManager is running and manages the links")
+	}
+	return nil
 }
 
-// isResolvedActive checks if systemd-resolved is active.
-func isResolvedActive() bool {
-	// Check if resolvectl exists
-	if _, err := exec.LookPath("resolvectl"); err != nil {
-		return false
+// newResolved creates the systemd-resolved client. Persistent changes go
+// through NetworkManager, or else systemd-networkd, if either is running.
+func newResolved(runner cmdexec.Runner) (Client, error) {
+	if isServiceActive(runner, "nmcli", "NetworkManager") {
+		return newResolvedClient(runner, newNMClient(runner, true)), nil
 	}
-
-	// Check if systemd-resolved service is active
-	cmd := exec.Command("systemctl", "is-active", "systemd-resolved")
-	output, err := cmd.Output()
-	if err != nil {
-		return false
+	if isServiceActive(runner, "networkctl", "systemd-networkd") {
+		networkd := &networkdClient{runner: runner}
+		resolved := newResolvedClient(runner, networkd)
+		networkd.runtime = resolved
+		return resolved, nil
 	}
+	return newResolvedClient(runner, nil), nil
+}
 
-	return strings.TrimSpace(string(output)) == "active"
+// isServiceActive checks if a systemd service is active and its
+// command-line tool is installed.
+func isServiceActive(runner cmdexec.Runner, tool, unit string) bool {
+	return checkService(runner, tool, unit) == nil
 }
 
-// isNetworkManagerActive checks if NetworkManager is active.
-func isNetworkManagerActive() bool {
-	// Check if nmcli exists
-	if _, err := exec.LookPath("nmcli"); err != nil {
-		return false
+// checkService returns nil if a systemd service is active and its
+// command-line tool is installed, or an error saying which is not.
+func checkService(runner cmdexec.Runner, tool, unit string) error {
+	// Check if the tool exists
+	if _, err := runner.LookPath(tool); err != nil {
+		return fmt.Errorf("%s not found", tool)
 	}
 
-	// Check if NetworkManager service is active
-	cmd := exec.Command("systemctl", "is-active", "NetworkManager")
-	output, err := cmd.Output()
-	if err != nil {
-		return false
+	// Check if the service is active; systemctl prints the state even
+	// when it exits with an error
+	result, err := runner.Run(context.Background(), "systemctl", "is-active", unit)
+	state := strings.TrimSpace(string(result.Stdout))
+	switch {
+	case state == "active":
+		return nil
+	case state != "":
+		return fmt.Errorf("%s is %s", unit, state)
+	case err != nil:
+		return fmt.Errorf("failed to check %s: %w", unit, err)
+	default:
+		return fmt.Errorf("%s is not active", unit)
 	}
-
-	return strings.TrimSpace(string(output)) == "active"
 }
@@ -0,0 +1,39 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// dbusConnectTimeout bounds connecting to the system bus and finding a
+// service on it, so a hung bus falls back to the service's command line
+// tool instead of stalling every command.
+const dbusConnectTimeout = 2 * time.Second
+
+// connectSystemBus connects to the system bus and runs check, which
+// finds the service to use, giving up on both after timeout.
+func connectSystemBus(timeout time.Duration, check func(ctx context.Context, conn *dbus.Conn) error) (*dbus.Conn, error) {
+	// Cancelling the connection's context closes it, which also aborts
+	// a hung handshake
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(timeout, cancel)
+
+	conn, err := dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := check(ctx, conn); err != nil {
+		cancel()
+		return nil, err
+	}
+	if !timer.Stop() {
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+	return conn, nil
+}
@@ -0,0 +1,118 @@
+//go:build linux
+
+package dns
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// startBus starts a private session bus and returns its address.
+func startBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address",
+		"--address=unix:path="+filepath.Join(t.TempDir(), "bus"))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to start dbus-daemon: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// connectBus connects to the bus at address for the length of the test.
+func connectBus(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// hungBus serves a system bus that accepts connections and never answers
+// them, for the length of the test.
+func hungBus(t *testing.T) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "bus")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", "unix:path="+socket)
+}
+
+// TestConnectSystemBus_Timeout tests that a bus which never completes the
+// handshake is given up on.
+func TestConnectSystemBus_Timeout(t *testing.T) {
+	hungBus(t)
+
+	start := time.Now()
+	conn, err := connectSystemBus(50*time.Millisecond, func(ctx context.Context, conn *dbus.Conn) error {
+		return nil
+	})
+
+	if err == nil {
+		conn.Close()
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected to give up quickly, took %s", elapsed)
+	}
+}
+
+// TestConnectSystemBus_CheckTimeout tests that a service which does not
+// answer the check is given up on.
+func TestConnectSystemBus_CheckTimeout(t *testing.T) {
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", startBus(t))
+
+	conn, err := connectSystemBus(50*time.Millisecond, func(ctx context.Context, conn *dbus.Conn) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err == nil {
+		conn.Close()
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,355 @@
+//go:build linux
+
+package dns
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// Paths used by the networkd backend, relative to its root.
+const (
+	networkdConfigDir = "etc/systemd/network"
+	networkdLinksDir  = "run/systemd/netif/links"
+)
+
+// networkdDropIn is the name of dnsctl's drop-in file. Drop-ins are read
+// in order, so it sorts after the usual ones.
+const networkdDropIn = "99-dnsctl.conf"
+
+// networkdHeader heads every drop-in dnsctl writes.
+const networkdHeader = "# Written by dnsctl; removed when DNS is cleared"
+
+// networkdClient manages DNS for systemd-networkd links by writing a
+// drop-in for the .network file of each link, so changes survive reboots
+// and link reconfiguration, unlike "resolvectl dns".
+//
+// The drop-in goes to /etc/systemd/network/<file>.network.d/, which
+// networkd reads whichever directory the .network file itself is in.
+// Servers and domains are only reported while the drop-in exists.
+type networkdClient struct {
+	runner cmdexec.Runner
+
+	// root is prepended to every path, so tests can use a temp dir.
+	root string
+
+	// runtime changes the same links until they are reconfigured,
+	// through systemd-resolved. It is nil if resolved is not running.
+	runtime Client
+}
+
+// Name returns the backend name for display purposes.
+func (c *networkdClient) Name() string {
+	return "systemd-networkd"
+}
+
+// Persistence returns Persistent, as changes are saved in drop-ins.
+func (c *networkdClient) Persistence() Persistence {
+	return Persistent
+}
+
+// WithPersistence returns c for persistent changes, or systemd-resolved
+// for runtime ones.
+func (c *networkdClient) WithPersistence(p Persistence) (Client, error) {
+	if p == Persistent {
+		return c, nil
+	}
+	if c.runtime == nil {
+		return nil, unsupportedPersistence(c, p)
+	}
+	return c.runtime, nil
+}
+
+// ListNetworkServices returns the links networkd manages.
+func (c *networkdClient) ListNetworkServices() ([]string, error) {
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns the links networkd manages, that is
+// those with a .network file.
+func (c *networkdClient) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(c.path(sysClassNet))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	var services []string
+	for _, entry := range entries {
+		if file, _ := c.networkFile(entry.Name()); file != "" {
+			services = append(services, entry.Name())
+		}
+	}
+	return services, nil
+}
+
+// DefaultService returns the interface holding the default route.
+func (c *networkdClient) DefaultService() (string, error) {
+	return defaultRouteInterface()
+}
+
+// GetDNSServers returns the servers of dnsctl's drop-in, or none if the
+// link has no drop-in of dnsctl.
+func (c *networkdClient) GetDNSServers(service string) ([]string, error) {
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the servers of dnsctl's drop-in.
+func (c *networkdClient) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	dropIn, err := c.readDropIn(service)
+	if err != nil {
+		return nil, err
+	}
+	return dropIn.servers, nil
+}
+
+// SetDNSServers writes the servers to dnsctl's drop-in.
+func (c *networkdClient) SetDNSServers(service string, servers []string) error {
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext writes the servers to dnsctl's drop-in, keeping
+// its domains, and reconfigures the link.
+func (c *networkdClient) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	dropIn, err := c.readDropIn(service)
+	if err != nil {
+		return err
+	}
+	dropIn.servers = servers
+	return c.writeDropIn(ctx, "set DNS servers", service, dropIn)
+}
+
+// ClearDNSServers removes dnsctl's drop-in.
+func (c *networkdClient) ClearDNSServers(service string) error {
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext removes dnsctl's drop-in, which also drops its
+// domains, and reconfigures the link.
+func (c *networkdClient) ClearDNSServersContext(ctx context.Context, service string) error {
+	return c.writeDropIn(ctx, "clear DNS servers", service, networkdDropInFile{})
+}
+
+// FlushCache flushes the DNS cache of systemd-resolved, which resolves
+// for networkd.
+func (c *networkdClient) FlushCache() error {
+	return c.FlushCacheContext(context.Background())
+}
+
+// FlushCacheContext flushes the DNS cache of systemd-resolved.
+func (c *networkdClient) FlushCacheContext(ctx context.Context) error {
+	_, err := run(ctx, c.runner, "flush DNS cache", "resolvectl", "flush-caches")
+	return err
+}
+
+// GetDomains returns the domains of dnsctl's drop-in.
+func (c *networkdClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	dropIn, err := c.readDropIn(service)
+	if err != nil {
+		return Domains{}, err
+	}
+	return dropIn.domains, nil
+}
+
+// SetDomains writes the domains to dnsctl's drop-in, keeping its servers,
+// and reconfigures the link.
+func (c *networkdClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	dropIn, err := c.readDropIn(service)
+	if err != nil {
+		return err
+	}
+	dropIn.domains = domains
+	return c.writeDropIn(ctx, "set domains", service, dropIn)
+}
+
+// SetDNSAndDomains writes the servers and domains to dnsctl's drop-in
+// at once, so the link is only reconfigured once. Without servers, the
+// link goes back to the servers of its .network file and DHCP.
+func (c *networkdClient) SetDNSAndDomains(ctx context.Context, service string, servers []string, domains Domains) error {
+	dropIn := networkdDropInFile{servers: servers, domains: domains}
+	return c.writeDropIn(ctx, "set DNS servers and domains", service, dropIn)
+}
+
+// path returns a path under the client's root.
+func (c *networkdClient) path(name ...string) string {
+	return filepath.Join(append([]string{c.root, "/"}, name...)...)
+}
+
+// networkFile returns the .network file networkd applied to a link, from
+// its state file, or "" if networkd does not manage the link.
+func (c *networkdClient) networkFile(service string) (string, error) {
+	return c.linkState(service, "NETWORK_FILE")
+}
+
+// automaticDNSServers returns the servers networkd gives a link, from the
+// .network file, its drop-ins and DHCP.
+func (c *networkdClient) automaticDNSServers(ctx context.Context, service string) ([]string, error) {
+	servers, err := c.linkState(service, "DNS")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(servers), nil
+}
+
+// linkState returns a value of a link's networkd state file, or "" if
+// networkd does not manage the link.
+func (c *networkdClient) linkState(service, key string) (string, error) {
+	index, err := os.ReadFile(c.path(sysClassNet, service, "ifindex"))
+	if err != nil {
+		return "", fmt.Errorf("unknown interface %q", service)
+	}
+
+	state, err := os.Open(c.path(networkdLinksDir, strings.TrimSpace(string(index))))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read networkd state of %s: %w", service, err)
+	}
+	defer state.Close()
+
+	scanner := bufio.NewScanner(state)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), key+"="); ok {
+			return value, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read networkd state of %s: %w", service, err)
+	}
+	return "", nil
+}
+
+// dropInPath returns the path of dnsctl's drop-in for a link.
+func (c *networkdClient) dropInPath(service string) (string, error) {
+	if err := checkInterfaceName(service); err != nil {
+		return "", err
+	}
+
+	file, err := c.networkFile(service)
+	if err != nil {
+		return "", err
+	}
+	if file == "" {
+		return "", fmt.Errorf("%s is not managed by systemd-networkd", service)
+	}
+	return c.path(networkdConfigDir, filepath.Base(file)+".d", networkdDropIn), nil
+}
+
+// readDropIn parses dnsctl's drop-in for a link. A missing drop-in is
+// empty.
+func (c *networkdClient) readDropIn(service string) (networkdDropInFile, error) {
+	path, err := c.dropInPath(service)
+	if err != nil {
+		return networkdDropInFile{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return networkdDropInFile{}, fmt.Errorf("failed to read networkd drop-in: %w", err)
+	}
+	return parseNetworkdDropIn(string(data)), nil
+}
+
+// writeDropIn replaces dnsctl's drop-in for a link, or removes it if
+// empty, and has networkd apply it.
+func (c *networkdClient) writeDropIn(ctx context.Context, action, service string, dropIn networkdDropInFile) error {
+	path, err := c.dropInPath(service)
+	if err != nil {
+		return err
+	}
+
+	if dropIn.isEmpty() {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s: %w", action, err)
+		}
+		// Other tools may keep drop-ins there, so only remove it if empty
+		os.Remove(filepath.Dir(path))
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to %s: %w", action, err)
+		}
+		if err := writeFileAtomic(path, dropIn.String()); err != nil {
+			return fmt.Errorf("failed to %s: %w", action, err)
+		}
+	}
+
+	// reload rereads the files; reconfigure applies them to the link
+	if _, err := run(ctx, c.runner, "reload systemd-networkd", "networkctl", "reload"); err != nil {
+		return err
+	}
+	_, err = run(ctx, c.runner, "reconfigure "+service, "networkctl", "reconfigure", service)
+	return err
+}
+
+// networkdDropInFile is the content of dnsctl's drop-in.
+type networkdDropInFile struct {
+	servers []string
+	domains Domains
+}
+
+// parseNetworkdDropIn reads the DNS= and Domains= settings of a drop-in
+// dnsctl wrote.
+func parseNetworkdDropIn(content string) networkdDropInFile {
+	var dropIn networkdDropInFile
+	var domains []string
+	section := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		if section != "[Network]" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "DNS":
+			dropIn.servers = append(dropIn.servers, strings.Fields(value)...)
+		case "Domains":
+			domains = append(domains, strings.Fields(value)...)
+		}
+	}
+
+	dropIn.domains = ParseDomainEntries(domains)
+	return dropIn
+}
+
+// isEmpty returns true if the drop-in sets nothing.
+func (d networkdDropInFile) isEmpty() bool {
+	return len(d.servers) == 0 && d.domains.IsEmpty()
+}
+
+// String returns the drop-in content. An empty DNS= or Domains= first
+// resets the list, so the settings replace rather than add to those of
+// the .network file. Servers from DHCP and router advertisements are
+// ignored, so they cannot come back.
+func (d networkdDropInFile) String() string {
+	var b strings.Builder
+	b.WriteString(networkdHeader + "\n[Network]\n")
+	if len(d.servers) > 0 {
+		b.WriteString("DNS=\nDNS=" + strings.Join(d.servers, " ") + "\n")
+	}
+	if !d.domains.IsEmpty() {
+		b.WriteString("Domains=\nDomains=" + strings.Join(d.domains.Entries(), " ") + "\n")
+	}
+	if len(d.servers) > 0 {
+		b.WriteString("\n[DHCPv4]\nUseDNS=no\n\n[DHCPv6]\nUseDNS=no\n\n[IPv6AcceptRA]\nUseDNS=no\n")
+	}
+	return b.String()
+}
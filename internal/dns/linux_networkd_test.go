@@ -0,0 +1,239 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// networkdRoot creates a temp root where networkd manages eth0 (index 2)
+// with 10-eth0.network, and docker0 (index 3) is unmanaged.
+func networkdRoot(t *testing.T) (*networkdClient, *cmdexectest.Runner, string) {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"sys/class/net/eth0/ifindex":    "2\n",
+		"sys/class/net/docker0/ifindex": "3\n",
+		"run/systemd/netif/links/2":     fixture(t, "networkd_link.txt"),
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := cmdexectest.NewRunner().
+		Expect("networkctl reload", "").
+		Expect("networkctl reconfigure eth0", "")
+	return &networkdClient{runner: runner, root: root}, runner, root
+}
+
+// networkdDropInPath is where eth0's drop-in goes under the test root.
+const networkdDropInPath = "etc/systemd/network/10-eth0.network.d/99-dnsctl.conf"
+
+// TestNetworkd_ListNetworkServices tests that only links with a .network
+// file are listed.
+func TestNetworkd_ListNetworkServices(t *testing.T) {
+	client, _, _ := networkdRoot(t)
+
+	services, err := client.ListNetworkServices()
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(services, ",") != "eth0" {
+		t.Errorf("expected [eth0], got %v", services)
+	}
+}
+
+// TestNetworkd_SetDNSServers tests that servers are written to a drop-in
+// that replaces the .network file's servers, and networkd reapplies it.
+func TestNetworkd_SetDNSServers(t *testing.T) {
+	client, runner, root := networkdRoot(t)
+
+	err := client.SetDNSServers("eth0", []string{"1.1.1.1", "2606:4700:4700::1111"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := networkdHeader + `
+[Network]
+DNS=
+DNS=1.1.1.1 2606:4700:4700::1111
+
+[DHCPv4]
+UseDNS=no
+
+[DHCPv6]
+UseDNS=no
+
+[IPv6AcceptRA]
+UseDNS=no
+`
+	if got := readFile(t, filepath.Join(root, networkdDropInPath)); got != want {
+		t.Errorf("unexpected drop-in:\n%s", got)
+	}
+	if got := strings.Join(runner.Commands(), "; "); got != "networkctl reload; networkctl reconfigure eth0" {
+		t.Errorf("unexpected commands: %s", got)
+	}
+
+	servers, err := client.GetDNSServers("eth0")
+	if err != nil || strings.Join(servers, ",") != "1.1.1.1,2606:4700:4700::1111" {
+		t.Errorf("expected the new servers, got %v (%v)", servers, err)
+	}
+}
+
+// TestNetworkd_GetDNSServers_NoDropIn tests that a link without a drop-in
+// counts as automatic.
+func TestNetworkd_GetDNSServers_NoDropIn(t *testing.T) {
+	client, _, _ := networkdRoot(t)
+
+	servers, err := client.GetDNSServers("eth0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if servers != nil {
+		t.Errorf("expected no servers, got %v", servers)
+	}
+}
+
+// TestNetworkd_Domains tests that domains, including routing-only ones,
+// share the drop-in with the servers.
+func TestNetworkd_Domains(t *testing.T) {
+	client, _, root := networkdRoot(t)
+	ctx := context.Background()
+	if err := client.SetDNSServers("eth0", []string{"10.0.0.53"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.SetDomains(ctx, "eth0", Domains{Search: []string{"corp.example.com"}, RouteOnly: []string{"internal"}})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	got := readFile(t, filepath.Join(root, networkdDropInPath))
+	if !strings.Contains(got, "DNS=10.0.0.53\nDomains=\nDomains=corp.example.com ~internal\n") {
+		t.Errorf("unexpected drop-in:\n%s", got)
+	}
+	domains, err := client.GetDomains(ctx, "eth0")
+	want := Domains{Search: []string{"corp.example.com"}, RouteOnly: []string{"internal"}}
+	if err != nil || !domains.Equal(want) {
+		t.Errorf("expected %v, got %v (%v)", want, domains, err)
+	}
+}
+
+// TestNetworkd_SetDNSAndDomains tests that servers and domains are written
+// together, with a single reload.
+func TestNetworkd_SetDNSAndDomains(t *testing.T) {
+	client, runner, root := networkdRoot(t)
+
+	err := client.SetDNSAndDomains(context.Background(), "eth0", []string{"10.0.0.53"}, Domains{Search: []string{"corp.example.com"}})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	got := readFile(t, filepath.Join(root, networkdDropInPath))
+	if !strings.Contains(got, "DNS=10.0.0.53\nDomains=\nDomains=corp.example.com\n") {
+		t.Errorf("unexpected drop-in:\n%s", got)
+	}
+	if got := strings.Join(runner.Commands(), "; "); got != "networkctl reload; networkctl reconfigure eth0" {
+		t.Errorf("unexpected commands: %s", got)
+	}
+}
+
+// TestNetworkd_ClearDNSServers tests that clearing removes the drop-in
+// and its directory, and networkd reapplies the .network file.
+func TestNetworkd_ClearDNSServers(t *testing.T) {
+	client, runner, root := networkdRoot(t)
+	if err := client.SetDNSServers("eth0", []string{"1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.ClearDNSServers("eth0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(filepath.Join(root, networkdDropInPath))); !os.IsNotExist(err) {
+		t.Errorf("expected the drop-in directory to be removed, got: %v", err)
+	}
+	if len(runner.Calls) != 4 {
+		t.Errorf("expected networkd to be reloaded twice, got %v", runner.Commands())
+	}
+}
+
+// TestNetworkd_ClearDNSServers_KeepsOtherDropIns tests that drop-ins of
+// other tools are left alone.
+func TestNetworkd_ClearDNSServers_KeepsOtherDropIns(t *testing.T) {
+	client, _, root := networkdRoot(t)
+	if err := client.SetDNSServers("eth0", []string{"1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(filepath.Dir(filepath.Join(root, networkdDropInPath)), "10-mtu.conf")
+	if err := os.WriteFile(other, []byte("[Link]\nMTUBytes=9000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.ClearDNSServers("eth0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := readFile(t, other); got != "[Link]\nMTUBytes=9000\n" {
+		t.Errorf("expected the other drop-in to stay, got: %q", got)
+	}
+}
+
+// TestNetworkd_ClearDNSServers_NoDropIn tests that clearing a link
+// without a drop-in does nothing.
+func TestNetworkd_ClearDNSServers_NoDropIn(t *testing.T) {
+	client, runner, _ := networkdRoot(t)
+
+	err := client.ClearDNSServers("eth0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(runner.Calls) != 0 {
+		t.Errorf("expected no commands, got %v", runner.Commands())
+	}
+}
+
+// TestNetworkd_Unmanaged tests that links networkd does not manage are
+// rejected.
+func TestNetworkd_Unmanaged(t *testing.T) {
+	client, _, _ := networkdRoot(t)
+
+	err := client.SetDNSServers("docker0", []string{"1.1.1.1"})
+	_, unknownErr := client.GetDNSServers("wlan0")
+
+	if err == nil || !strings.Contains(err.Error(), "docker0 is not managed by systemd-networkd") {
+		t.Errorf("expected unmanaged error, got: %v", err)
+	}
+	if unknownErr == nil || !strings.Contains(unknownErr.Error(), `unknown interface "wlan0"`) {
+		t.Errorf("expected unknown interface error, got: %v", unknownErr)
+	}
+}
+
+// TestNetworkd_ReloadFailure tests that networkctl's output is part of
+// the error.
+func TestNetworkd_ReloadFailure(t *testing.T) {
+	client, runner, _ := networkdRoot(t)
+	runner.Fail("networkctl reload", 1, "Failed to reload network settings: Access denied")
+
+	err := client.SetDNSServers("eth0", []string{"1.1.1.1"})
+
+	if err == nil || !strings.Contains(err.Error(), "failed to reload systemd-networkd: Failed to reload network settings: Access denied") {
+		t.Errorf("expected networkctl's error, got: %v", err)
+	}
+}
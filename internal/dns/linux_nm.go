@@ -3,31 +3,51 @@
 package dns
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"net/netip"
 	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
 )
 
 // nmClient provides DNS management via NetworkManager.
-type nmClient struct{}
+type nmClient struct {
+	runner cmdexec.Runner
+}
 
 // Name returns the backend name for display purposes.
 func (c *nmClient) Name() string {
 	return "NetworkManager"
 }
 
+// Persistence returns Persistent, as changes are saved in the connection.
+func (c *nmClient) Persistence() Persistence {
+	return Persistent
+}
+
 // ListNetworkServices returns all active network connections.
 func (c *nmClient) ListNetworkServices() ([]string, error) {
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns all active network connections.
+func (c *nmClient) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
 	// Get active connections in terse format
-	cmd := exec.Command("nmcli", "-t", "-f", "NAME", "connection", "show", "--active")
-	output, err := cmd.Output()
+	result, err := run(ctx, c.runner, "list network services",
+		"nmcli", "-t", "-f", "NAME", "connection", "show", "--active")
 	if err != nil {
-		return nil, fmt.Errorf("failed to list network services: %w", err)
+		return nil, err
 	}
 
-	text := strings.TrimSpace(string(output))
+	return parseNmcliConnections(string(result.Stdout)), nil
+}
+
+// parseNmcliConnections parses terse "nmcli -t -f NAME" output.
+func parseNmcliConnections(output string) []string {
+	text := strings.TrimSpace(output)
 	if text == "" {
-		return nil, nil
+		return nil
 	}
 
 	lines := strings.Split(text, "\n")
@@ -35,105 +55,426 @@ func (c *nmClient) ListNetworkServices() ([]string, error) {
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line != "" {
-			connections = append(connections, line)
+			connections = append(connections, UnescapeNmcli(line))
 		}
 	}
 
-	return connections, nil
+	return connections
 }
 
-// GetDNSServers returns the current DNS servers for a connection.
-func (c *nmClient) GetDNSServers(service string) ([]string, error) {
-	cmd := exec.Command("nmcli", "-t", "-f", "ipv4.dns", "connection", "show", service)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get DNS servers for %s: %w", service, err)
+// DefaultService returns the active connection on the device holding
+// the default route.
+func (c *nmClient) DefaultService() (string, error) {
+	device, err := defaultRouteInterface()
+	if err != nil || device == "" {
+		return "", err
 	}
 
-	text := strings.TrimSpace(string(output))
-	if text == "" {
-		return nil, nil
+	result, err := run(context.Background(), c.runner, "list active connections",
+		"nmcli", "-t", "-f", "NAME,DEVICE", "connection", "show", "--active")
+	if err != nil {
+		return "", err
 	}
 
-	// Output format: "ipv4.dns:8.8.8.8,8.8.4.4"
-	colonIdx := strings.Index(text, ":")
-	if colonIdx == -1 {
-		return nil, nil
+	return parseConnectionForDevice(string(result.Stdout), device), nil
+}
+
+// parseConnectionForDevice finds the connection bound to a device in
+// terse "NAME:DEVICE" nmcli output. Colons in names are escaped as "\:".
+func parseConnectionForDevice(output, device string) string {
+	for _, line := range strings.Split(output, "\n") {
+		idx := strings.LastIndex(line, ":")
+		if idx == -1 {
+			continue
+		}
+		if strings.TrimSpace(line[idx+1:]) != device {
+			continue
+		}
+		return UnescapeNmcli(line[:idx])
 	}
+	return ""
+}
 
-	serverPart := strings.TrimSpace(text[colonIdx+1:])
-	if serverPart == "" {
-		return nil, nil
+// UnescapeNmcli reverses the escaping of terse nmcli output, where
+// colons and backslashes in values are escaped with a backslash.
+func UnescapeNmcli(value string) string {
+	value = strings.ReplaceAll(value, "\\:", ":")
+	return strings.ReplaceAll(value, "\\\\", "\\")
+}
+
+// GetDNSServers returns the current DNS servers for a connection.
+func (c *nmClient) GetDNSServers(service string) ([]string, error) {
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the current IPv4 and IPv6 DNS servers for a connection.
+func (c *nmClient) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	result, err := run(ctx, c.runner, "get DNS servers for "+service,
+		"nmcli", "-t", "-f", "ipv4.dns,ipv6.dns", "connection", "show", service)
+	if err != nil {
+		return nil, err
 	}
 
-	// DNS servers are comma-separated
-	servers := strings.Split(serverPart, ",")
+	return parseNmcliDNS(string(result.Stdout)), nil
+}
+
+// parseNmcliDNS parses terse "nmcli -t -f ipv4.dns,ipv6.dns" output,
+// returning IPv4 servers followed by IPv6 servers.
+func parseNmcliDNS(output string) []string {
 	var result []string
-	for _, s := range servers {
-		s = strings.TrimSpace(s)
-		if s != "" {
-			result = append(result, s)
+
+	for _, line := range strings.Split(output, "\n") {
+		// Output format: "ipv4.dns:8.8.8.8,8.8.4.4" and
+		// "ipv6.dns:2606\:4700\:4700\:\:1111"; colons in values may be escaped
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || (key != "ipv4.dns" && key != "ipv6.dns") {
+			continue
+		}
+
+		// DNS servers are comma-separated
+		for _, s := range strings.Split(UnescapeNmcli(value), ",") {
+			s = strings.TrimSpace(s)
+			if s != "" {
+				result = append(result, s)
+			}
 		}
 	}
 
-	return result, nil
+	return result
 }
 
 // SetDNSServers sets the DNS servers for a connection.
 func (c *nmClient) SetDNSServers(service string, servers []string) error {
-	dnsValue := strings.Join(servers, ",")
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
 
-	// Modify the connection
-	cmd := exec.Command("nmcli", "connection", "modify", service,
-		"ipv4.dns", dnsValue,
-		"ipv4.ignore-auto-dns", "yes")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set DNS servers: %s: %w", string(output), err)
+// SetDNSServersContext sets the DNS servers for a connection, ignoring
+// those from DHCP and router advertisements.
+func (c *nmClient) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	return c.modify(ctx, "set DNS servers", service, nmcliServers(servers)...)
+}
+
+// ClearDNSServers clears DNS servers, reverting to DHCP defaults.
+func (c *nmClient) ClearDNSServers(service string) error {
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext clears DNS servers, reverting to DHCP defaults.
+// Search domains are left alone.
+func (c *nmClient) ClearDNSServersContext(ctx context.Context, service string) error {
+	return c.modify(ctx, "clear DNS servers", service, nmcliServers(nil)...)
+}
+
+// GetDomains returns the search and routing domains for a connection.
+func (c *nmClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	result, err := run(ctx, c.runner, "get domains for "+service,
+		"nmcli", "-t", "-f", "ipv4.dns-search,ipv6.dns-search", "connection", "show", service)
+	if err != nil {
+		return Domains{}, err
 	}
 
-	// Reactivate the connection to apply changes
-	cmd = exec.Command("nmcli", "connection", "up", service)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to reactivate connection: %s: %w", string(output), err)
+	return parseNmcliDomains(string(result.Stdout)), nil
+}
+
+// parseNmcliDomains parses terse "nmcli -t -f ipv4.dns-search,ipv6.dns-search"
+// output. NetworkManager marks routing-only domains with a "~" prefix.
+// Domains present for both address families are only reported once.
+func parseNmcliDomains(output string) Domains {
+	var entries []string
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || (key != "ipv4.dns-search" && key != "ipv6.dns-search") {
+			continue
+		}
+
+		for _, entry := range strings.Split(UnescapeNmcli(value), ",") {
+			entry = strings.TrimSpace(entry)
+			if entry != "" && !seen[entry] {
+				seen[entry] = true
+				entries = append(entries, entry)
+			}
+		}
 	}
 
-	return nil
+	return ParseDomainEntries(entries)
 }
 
-// ClearDNSServers clears DNS servers, reverting to DHCP defaults.
-func (c *nmClient) ClearDNSServers(service string) error {
-	// Clear manual DNS and enable auto DNS
-	cmd := exec.Command("nmcli", "connection", "modify", service,
-		"ipv4.dns", "",
-		"ipv4.ignore-auto-dns", "no")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to clear DNS servers: %s: %w", string(output), err)
+// SetDomains sets the search and routing domains for a connection.
+// The connection is only reactivated when the domains actually change,
+// since reactivation briefly drops the link.
+func (c *nmClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	current, err := c.GetDomains(ctx, service)
+	if err != nil {
+		return err
+	}
+	if current.Equal(domains) {
+		return nil
+	}
+
+	return c.modify(ctx, "set domains", service, nmcliDomains(domains)...)
+}
+
+// SetDNSAndDomains sets the servers and domains of a connection with a
+// single modification, so the connection is only reactivated once.
+func (c *nmClient) SetDNSAndDomains(ctx context.Context, service string, servers []string, domains Domains) error {
+	settings := append(nmcliServers(servers), nmcliDomains(domains)...)
+	return c.modify(ctx, "set DNS servers and domains", service, settings...)
+}
+
+// nmcliServers returns the nmcli settings for a set of servers.
+// NetworkManager keeps IPv4 and IPv6 servers in separate properties, so
+// servers are split by address family. With servers, automatic DNS is
+// ignored for both families so DHCP or router advertisements cannot add
+// servers back; without, it is enabled again.
+func nmcliServers(servers []string) []string {
+	ignoreAuto := "no"
+	if len(servers) > 0 {
+		ignoreAuto = "yes"
 	}
 
-	// Reactivate the connection to apply changes
-	cmd = exec.Command("nmcli", "connection", "up", service)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to reactivate connection: %s: %w", string(output), err)
+	v4, v6 := splitByFamily(servers)
+	return []string{
+		"ipv4.dns", strings.Join(v4, ","),
+		"ipv4.ignore-auto-dns", ignoreAuto,
+		"ipv6.dns", strings.Join(v6, ","),
+		"ipv6.ignore-auto-dns", ignoreAuto,
 	}
+}
 
-	return nil
+// nmcliDomains returns the nmcli settings for a set of domains.
+// Domains are stored on the IPv4 settings only; NetworkManager applies
+// them to the whole connection.
+func nmcliDomains(domains Domains) []string {
+	return []string{
+		"ipv4.dns-search", strings.Join(domains.Entries(), ","),
+		"ipv6.dns-search", "",
+	}
+}
+
+// splitByFamily separates IPv4 servers from IPv6 servers, preserving order.
+// Servers may carry a port or zone; anything unparseable is treated as
+// IPv4 so that nmcli reports it.
+func splitByFamily(servers []string) (v4, v6 []string) {
+	for _, server := range servers {
+		if isIPv6(server) {
+			v6 = append(v6, server)
+		} else {
+			v4 = append(v4, server)
+		}
+	}
+	return v4, v6
+}
+
+// isIPv6 reports whether a server address is an IPv6 address.
+func isIPv6(server string) bool {
+	if addr, err := netip.ParseAddr(server); err == nil {
+		return addr.Is6() && !addr.Is4In6()
+	}
+	if addrPort, err := netip.ParseAddrPort(server); err == nil {
+		return addrPort.Addr().Is6() && !addrPort.Addr().Is4In6()
+	}
+	return false
+}
+
+// modify changes the settings of a connection, then brings it up again
+// so they take effect.
+func (c *nmClient) modify(ctx context.Context, action, service string, settings ...string) error {
+	args := append([]string{"connection", "modify", service}, settings...)
+	if _, err := run(ctx, c.runner, action, "nmcli", args...); err != nil {
+		return err
+	}
+
+	_, err := run(ctx, c.runner, "reactivate connection", "nmcli", "connection", "up", service)
+	return err
 }
 
 // FlushCache flushes the DNS cache.
 func (c *nmClient) FlushCache() error {
+	return c.FlushCacheContext(context.Background())
+}
+
+// FlushCacheContext flushes the DNS cache.
+func (c *nmClient) FlushCacheContext(ctx context.Context) error {
 	// Try resolvectl first (if systemd-resolved is being used as a cache)
-	cmd := exec.Command("resolvectl", "flush-caches")
-	if err := cmd.Run(); err == nil {
+	if _, err := c.runner.Run(ctx, "resolvectl", "flush-caches"); err == nil {
 		return nil
 	}
 
 	// Try nscd if available
-	cmd = exec.Command("nscd", "-i", "hosts")
-	if err := cmd.Run(); err == nil {
+	if _, err := c.runner.Run(ctx, "nscd", "-i", "hosts"); err == nil {
 		return nil
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// If neither works, return success anyway since NetworkManager
 	// may not have a cache to flush
 	return nil
 }
+
+// nmLinkClient changes DNS through NetworkManager for services named by
+// interface, as systemd-resolved lists them, by changing the connection
+// active on each interface.
+type nmLinkClient struct {
+	nm *nmClient
+}
+
+// Name returns the backend name for display purposes.
+func (c *nmLinkClient) Name() string {
+	return c.nm.Name()
+}
+
+// Persistence returns Persistent, as changes are saved in the connection.
+func (c *nmLinkClient) Persistence() Persistence {
+	return Persistent
+}
+
+// ListNetworkServices returns the interfaces of active connections.
+func (c *nmLinkClient) ListNetworkServices() ([]string, error) {
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns the interfaces of active connections.
+func (c *nmLinkClient) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	result, err := run(ctx, c.nm.runner, "list network services",
+		"nmcli", "-t", "-f", "DEVICE", "connection", "show", "--active")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(result.Stdout)), nil
+}
+
+// DefaultService returns the interface holding the default route.
+func (c *nmLinkClient) DefaultService() (string, error) {
+	return defaultRouteInterface()
+}
+
+// GetDNSServers returns the DNS servers of the interface's connection.
+func (c *nmLinkClient) GetDNSServers(service string) ([]string, error) {
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the DNS servers of the interface's connection.
+func (c *nmLinkClient) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	connection, err := c.connection(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	return c.nm.GetDNSServersContext(ctx, connection)
+}
+
+// SetDNSServers sets the DNS servers of the interface's connection.
+func (c *nmLinkClient) SetDNSServers(service string, servers []string) error {
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext sets the DNS servers of the interface's connection.
+func (c *nmLinkClient) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	connection, err := c.connection(ctx, service)
+	if err != nil {
+		return err
+	}
+	return c.nm.SetDNSServersContext(ctx, connection, servers)
+}
+
+// ClearDNSServers clears the DNS servers of the interface's connection.
+func (c *nmLinkClient) ClearDNSServers(service string) error {
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext clears the DNS servers of the interface's
+// connection.
+func (c *nmLinkClient) ClearDNSServersContext(ctx context.Context, service string) error {
+	connection, err := c.connection(ctx, service)
+	if err != nil {
+		return err
+	}
+	return c.nm.ClearDNSServersContext(ctx, connection)
+}
+
+// FlushCache flushes the DNS cache.
+func (c *nmLinkClient) FlushCache() error {
+	return c.nm.FlushCache()
+}
+
+// FlushCacheContext flushes the DNS cache.
+func (c *nmLinkClient) FlushCacheContext(ctx context.Context) error {
+	return c.nm.FlushCacheContext(ctx)
+}
+
+// GetDomains returns the domains of the interface's connection.
+func (c *nmLinkClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	connection, err := c.connection(ctx, service)
+	if err != nil {
+		return Domains{}, err
+	}
+	return c.nm.GetDomains(ctx, connection)
+}
+
+// SetDomains sets the domains of the interface's connection.
+func (c *nmLinkClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	connection, err := c.connection(ctx, service)
+	if err != nil {
+		return err
+	}
+	return c.nm.SetDomains(ctx, connection, domains)
+}
+
+// SetDNSAndDomains sets the servers and domains of the interface's
+// connection at once.
+func (c *nmLinkClient) SetDNSAndDomains(ctx context.Context, service string, servers []string, domains Domains) error {
+	connection, err := c.connection(ctx, service)
+	if err != nil {
+		return err
+	}
+	return c.nm.SetDNSAndDomains(ctx, connection, servers, domains)
+}
+
+// automaticDNSServers returns the servers NetworkManager gives an
+// interface, from DHCP, router advertisements and its connection.
+func (c *nmLinkClient) automaticDNSServers(ctx context.Context, service string) ([]string, error) {
+	return nmcliDeviceDNS(ctx, c.nm.runner, service)
+}
+
+// nmcliDeviceDNS returns the servers NetworkManager gives a device, from
+// terse "nmcli -t -f IP4.DNS,IP6.DNS device show" output, in which each
+// server is listed as "IP4.DNS[1]:192.168.1.1".
+func nmcliDeviceDNS(ctx context.Context, runner cmdexec.Runner, device string) ([]string, error) {
+	result, err := run(ctx, runner, "get DNS servers of "+device,
+		"nmcli", "-t", "-f", "IP4.DNS,IP6.DNS", "device", "show", device)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []string
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || (!strings.HasPrefix(key, "IP4.DNS") && !strings.HasPrefix(key, "IP6.DNS")) {
+			continue
+		}
+		if value = UnescapeNmcli(value); value != "" {
+			servers = append(servers, value)
+		}
+	}
+	return servers, nil
+}
+
+// connection returns the connection active on an interface.
+func (c *nmLinkClient) connection(ctx context.Context, device string) (string, error) {
+	result, err := run(ctx, c.nm.runner, "list active connections",
+		"nmcli", "-t", "-f", "NAME,DEVICE", "connection", "show", "--active")
+	if err != nil {
+		return "", err
+	}
+
+	connection := parseConnectionForDevice(string(result.Stdout), device)
+	if connection == "" {
+		return "", fmt.Errorf("%s has no active NetworkManager connection", device)
+	}
+	return connection, nil
+}
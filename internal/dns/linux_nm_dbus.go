@@ -0,0 +1,431 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// NetworkManager's D-Bus name, root object and interfaces.
+const (
+	nmBusName               = "org.freedesktop.NetworkManager"
+	nmPath                  = dbus.ObjectPath("/org/freedesktop/NetworkManager")
+	nmInterface             = "org.freedesktop.NetworkManager"
+	nmActiveInterface       = nmInterface + ".Connection.Active"
+	nmDeviceInterface       = nmInterface + ".Device"
+	nmSettingsConnInterface = nmInterface + ".Settings.Connection"
+)
+
+// nmSettings holds the settings of a connection by setting and property
+// name, such as "ipv4" and "dns", as NetworkManager passes them over D-Bus.
+type nmSettings = map[string]map[string]dbus.Variant
+
+// nmDBusClient manages DNS through NetworkManager's D-Bus API. Changes are
+// saved in the settings of the active connection and reapplied to its
+// devices, which, unlike "nmcli connection up", keeps the link up.
+type nmDBusClient struct {
+	conn *dbus.Conn
+
+	// runner flushes the DNS cache, which NetworkManager has no method for.
+	runner cmdexec.Runner
+
+	// byInterface names services by the interface of the connection, as
+	// systemd-resolved lists them, instead of by the connection.
+	byInterface bool
+}
+
+// nmActiveConnection is a connection NetworkManager has activated.
+type nmActiveConnection struct {
+	id string
+
+	// settings is the path of the settings the connection was activated
+	// from.
+	settings dbus.ObjectPath
+
+	devices []nmDevice
+}
+
+// nmDevice is a device an active connection is on.
+type nmDevice struct {
+	path dbus.ObjectPath
+	name string
+}
+
+// newNMDBusClient connects to NetworkManager on the system bus, giving up
+// after dbusConnectTimeout.
+func newNMDBusClient(runner cmdexec.Runner, byInterface bool) (*nmDBusClient, error) {
+	client := &nmDBusClient{runner: runner, byInterface: byInterface}
+	_, err := connectSystemBus(dbusConnectTimeout, func(ctx context.Context, conn *dbus.Conn) error {
+		client.conn = conn
+		var version string
+		return client.property(ctx, nmPath, nmInterface, "Version", &version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newNMClient returns a NetworkManager client that uses D-Bus if it can
+// reach NetworkManager there, or nmcli otherwise.
+func newNMClient(runner cmdexec.Runner, byInterface bool) Client {
+	if client, err := newNMDBusClient(runner, byInterface); err == nil {
+		return client
+	}
+	if byInterface {
+		return &nmLinkClient{nm: &nmClient{runner: runner}}
+	}
+	return &nmClient{runner: runner}
+}
+
+// Name returns the backend name for display purposes.
+func (c *nmDBusClient) Name() string {
+	return "NetworkManager"
+}
+
+// Persistence returns Persistent, as changes are saved in the connection.
+func (c *nmDBusClient) Persistence() Persistence {
+	return Persistent
+}
+
+// ListNetworkServices returns all active network connections.
+func (c *nmDBusClient) ListNetworkServices() ([]string, error) {
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns all active network connections, or
+// their interfaces.
+func (c *nmDBusClient) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	active, err := c.activeConnections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network services: %w", err)
+	}
+
+	var services []string
+	for _, connection := range active {
+		if !c.byInterface {
+			services = append(services, connection.id)
+			continue
+		}
+		for _, device := range connection.devices {
+			services = append(services, device.name)
+		}
+	}
+	return services, nil
+}
+
+// DefaultService returns NetworkManager's primary connection, or the
+// interface holding the default route.
+func (c *nmDBusClient) DefaultService() (string, error) {
+	if c.byInterface {
+		return defaultRouteInterface()
+	}
+
+	ctx := context.Background()
+	var primary dbus.ObjectPath
+	if err := c.property(ctx, nmPath, nmInterface, "PrimaryConnection", &primary); err != nil {
+		return "", fmt.Errorf("failed to get primary connection: %w", err)
+	}
+	if primary == "/" {
+		return "", nil
+	}
+
+	var id string
+	if err := c.property(ctx, primary, nmActiveInterface, "Id", &id); err != nil {
+		return "", fmt.Errorf("failed to get primary connection: %w", err)
+	}
+	return id, nil
+}
+
+// GetDNSServers returns the current DNS servers for a connection.
+func (c *nmDBusClient) GetDNSServers(service string) ([]string, error) {
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the IPv4 and IPv6 DNS servers of the
+// active connection's settings.
+func (c *nmDBusClient) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	_, settings, err := c.lookup(ctx, "get DNS servers for "+service, service)
+	if err != nil {
+		return nil, err
+	}
+	return append(nmServers(settings, "ipv4"), nmServers(settings, "ipv6")...), nil
+}
+
+// SetDNSServers sets the DNS servers for a connection.
+func (c *nmDBusClient) SetDNSServers(service string, servers []string) error {
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext sets the DNS servers for a connection, split by
+// address family. Automatic DNS is ignored for both families so DHCP or
+// router advertisements cannot add servers back.
+func (c *nmDBusClient) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	connection, settings, err := c.lookup(ctx, "set DNS servers", service)
+	if err != nil {
+		return err
+	}
+
+	v4, v6 := splitByFamily(servers)
+	setNMServers(settings, "ipv4", v4)
+	setNMServers(settings, "ipv6", v6)
+	return c.update(ctx, "set DNS servers", connection, settings)
+}
+
+// ClearDNSServers clears DNS servers, reverting to DHCP defaults.
+func (c *nmDBusClient) ClearDNSServers(service string) error {
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext clears DNS servers, reverting to DHCP defaults.
+// Search domains are left alone, as with the nmcli backend.
+func (c *nmDBusClient) ClearDNSServersContext(ctx context.Context, service string) error {
+	connection, settings, err := c.lookup(ctx, "clear DNS servers", service)
+	if err != nil {
+		return err
+	}
+
+	// Settings left out of an update go back to their defaults
+	for _, family := range []string{"ipv4", "ipv6"} {
+		for _, property := range []string{"dns", "dns-data", "ignore-auto-dns"} {
+			delete(settings[family], property)
+		}
+	}
+	return c.update(ctx, "clear DNS servers", connection, settings)
+}
+
+// FlushCache flushes the DNS cache.
+func (c *nmDBusClient) FlushCache() error {
+	return c.FlushCacheContext(context.Background())
+}
+
+// FlushCacheContext flushes the DNS cache the way the nmcli backend does.
+func (c *nmDBusClient) FlushCacheContext(ctx context.Context) error {
+	return (&nmClient{runner: c.runner}).FlushCacheContext(ctx)
+}
+
+// automaticDNSServers returns the servers NetworkManager gives an
+// interface, the way the nmcli backend reads them.
+func (c *nmDBusClient) automaticDNSServers(ctx context.Context, service string) ([]string, error) {
+	return nmcliDeviceDNS(ctx, c.runner, service)
+}
+
+// GetDomains returns the search and routing domains for a connection.
+// Domains present for both address families are only reported once.
+func (c *nmDBusClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	_, settings, err := c.lookup(ctx, "get domains for "+service, service)
+	if err != nil {
+		return Domains{}, err
+	}
+	return nmDomains(settings), nil
+}
+
+// SetDomains sets the search and routing domains for a connection. Like
+// the nmcli backend, domains are stored on the IPv4 settings only.
+func (c *nmDBusClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	connection, settings, err := c.lookup(ctx, "set domains", service)
+	if err != nil {
+		return err
+	}
+	if nmDomains(settings).Equal(domains) {
+		return nil
+	}
+
+	if domains.IsEmpty() {
+		delete(settings["ipv4"], "dns-search")
+	} else {
+		setNMProperty(settings, "ipv4", "dns-search", domains.Entries())
+	}
+	delete(settings["ipv6"], "dns-search")
+	return c.update(ctx, "set domains", connection, settings)
+}
+
+// lookup finds the active connection for a service and reads its
+// settings.
+func (c *nmDBusClient) lookup(ctx context.Context, action, service string) (nmActiveConnection, nmSettings, error) {
+	active, err := c.activeConnections(ctx)
+	if err != nil {
+		return nmActiveConnection{}, nil, fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	i := slices.IndexFunc(active, func(connection nmActiveConnection) bool {
+		if !c.byInterface {
+			return connection.id == service
+		}
+		return slices.ContainsFunc(connection.devices, func(device nmDevice) bool {
+			return device.name == service
+		})
+	})
+	if i == -1 {
+		if c.byInterface {
+			return nmActiveConnection{}, nil, fmt.Errorf("%s has no active NetworkManager connection", service)
+		}
+		return nmActiveConnection{}, nil, fmt.Errorf("%q is not an active NetworkManager connection", service)
+	}
+
+	var settings nmSettings
+	if err := c.conn.Object(nmBusName, active[i].settings).
+		CallWithContext(ctx, nmSettingsConnInterface+".GetSettings", 0).Store(&settings); err != nil {
+		return nmActiveConnection{}, nil, fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return active[i], settings, nil
+}
+
+// update saves a connection's settings and reapplies them to its devices.
+func (c *nmDBusClient) update(ctx context.Context, action string, connection nmActiveConnection, settings nmSettings) error {
+	if err := c.conn.Object(nmBusName, connection.settings).
+		CallWithContext(ctx, nmSettingsConnInterface+".Update", 0, settings).Err; err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	for _, device := range connection.devices {
+		// Empty settings reapply the saved ones; version 0 skips the check
+		// that nobody reapplied in between
+		if err := c.conn.Object(nmBusName, device.path).
+			CallWithContext(ctx, nmDeviceInterface+".Reapply", 0, nmSettings{}, uint64(0), uint32(0)).Err; err != nil {
+			return fmt.Errorf("failed to reapply %s on %s: %w", connection.id, device.name, err)
+		}
+	}
+	return nil
+}
+
+// activeConnections returns NetworkManager's active connections.
+func (c *nmDBusClient) activeConnections(ctx context.Context) ([]nmActiveConnection, error) {
+	var paths []dbus.ObjectPath
+	if err := c.property(ctx, nmPath, nmInterface, "ActiveConnections", &paths); err != nil {
+		return nil, err
+	}
+
+	active := make([]nmActiveConnection, 0, len(paths))
+	for _, path := range paths {
+		var connection nmActiveConnection
+		var devices []dbus.ObjectPath
+		if err := c.property(ctx, path, nmActiveInterface, "Id", &connection.id); err != nil {
+			return nil, err
+		}
+		if err := c.property(ctx, path, nmActiveInterface, "Connection", &connection.settings); err != nil {
+			return nil, err
+		}
+		if err := c.property(ctx, path, nmActiveInterface, "Devices", &devices); err != nil {
+			return nil, err
+		}
+
+		for _, device := range devices {
+			var name string
+			if err := c.property(ctx, device, nmDeviceInterface, "Interface", &name); err != nil {
+				return nil, err
+			}
+			connection.devices = append(connection.devices, nmDevice{path: device, name: name})
+		}
+		active = append(active, connection)
+	}
+	return active, nil
+}
+
+// property reads a property of a NetworkManager object into value.
+func (c *nmDBusClient) property(ctx context.Context, path dbus.ObjectPath, iface, name string, value any) error {
+	var variant dbus.Variant
+	if err := c.conn.Object(nmBusName, path).
+		CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, iface, name).Store(&variant); err != nil {
+		return err
+	}
+	return variant.Store(value)
+}
+
+// nmServers returns the DNS servers of a connection's "ipv4" or "ipv6"
+// settings. NetworkManager 1.42 and later report them as strings in
+// dns-data, which keeps ports and zones; earlier versions only have dns,
+// with addresses in binary form.
+func nmServers(settings nmSettings, family string) []string {
+	if data, ok := settings[family]["dns-data"].Value().([]string); ok {
+		return data
+	}
+
+	var servers []string
+	switch dns := settings[family]["dns"].Value().(type) {
+	case []uint32:
+		// IPv4 addresses are in network byte order
+		for _, v := range dns {
+			var b [4]byte
+			binary.NativeEndian.PutUint32(b[:], v)
+			servers = append(servers, netip.AddrFrom4(b).String())
+		}
+	case [][]byte:
+		for _, v := range dns {
+			if addr, ok := netip.AddrFromSlice(v); ok {
+				servers = append(servers, addr.String())
+			}
+		}
+	}
+	return servers
+}
+
+// setNMServers replaces the DNS servers of a connection's "ipv4" or "ipv6"
+// settings and ignores automatic ones. Plain addresses go in dns, which
+// every NetworkManager version reads; only servers with a port or zone
+// need dns-data.
+func setNMServers(settings nmSettings, family string, servers []string) {
+	if settings[family] == nil && len(servers) == 0 {
+		return
+	}
+
+	var v4 []uint32
+	var v6 [][]byte
+	plain := true
+	for _, server := range servers {
+		addr, err := netip.ParseAddr(server)
+		switch {
+		case err != nil || addr.Zone() != "":
+			plain = false
+		case family == "ipv4":
+			b := addr.Unmap().As4()
+			v4 = append(v4, binary.NativeEndian.Uint32(b[:]))
+		default:
+			b := addr.As16()
+			v6 = append(v6, b[:])
+		}
+	}
+
+	delete(settings[family], "dns")
+	delete(settings[family], "dns-data")
+	switch {
+	case !plain:
+		setNMProperty(settings, family, "dns-data", servers)
+	case family == "ipv4":
+		setNMProperty(settings, family, "dns", v4)
+	default:
+		setNMProperty(settings, family, "dns", v6)
+	}
+	setNMProperty(settings, family, "ignore-auto-dns", true)
+}
+
+// setNMProperty sets a property of a connection's settings.
+func setNMProperty(settings nmSettings, setting, property string, value any) {
+	if settings[setting] == nil {
+		settings[setting] = make(map[string]dbus.Variant)
+	}
+	settings[setting][property] = dbus.MakeVariant(value)
+}
+
+// nmDomains returns the search and routing domains of a connection's
+// settings. NetworkManager marks routing-only domains with a "~" prefix.
+func nmDomains(settings nmSettings) Domains {
+	var entries []string
+	for _, family := range []string{"ipv4", "ipv6"} {
+		search, _ := settings[family]["dns-search"].Value().([]string)
+		for _, entry := range search {
+			if !slices.Contains(entries, entry) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return ParseDomainEntries(entries)
+}
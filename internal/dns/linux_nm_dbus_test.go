@@ -0,0 +1,355 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// fakeNMConnection is a connection profile of the fake NetworkManager.
+type fakeNMConnection struct {
+	mu       sync.Mutex
+	settings nmSettings
+	updates  int
+
+	// fail makes Update fail with this message.
+	fail string
+}
+
+// GetSettings returns the connection's settings.
+func (c *fakeNMConnection) GetSettings() (nmSettings, *dbus.Error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings, nil
+}
+
+// Update replaces the connection's settings.
+func (c *fakeNMConnection) Update(settings nmSettings) *dbus.Error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail != "" {
+		return dbus.NewError("org.freedesktop.NetworkManager.Settings.Connection.PermissionDenied", []any{c.fail})
+	}
+	c.settings = settings
+	c.updates++
+	return nil
+}
+
+// edit changes the connection while the bus may be serving it.
+func (c *fakeNMConnection) edit(f func(settings nmSettings)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f(c.settings)
+}
+
+// state returns the connection's settings and how often it was updated.
+func (c *fakeNMConnection) state() (nmSettings, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings, c.updates
+}
+
+// fakeNMDevice is a device of the fake NetworkManager.
+type fakeNMDevice struct {
+	mu        sync.Mutex
+	reapplied int
+}
+
+// Reapply counts the calls; only a reapply of the saved settings is
+// expected.
+func (d *fakeNMDevice) Reapply(connection nmSettings, version uint64, flags uint32) *dbus.Error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(connection) != 0 {
+		return dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []any{"unexpected settings"})
+	}
+	d.reapplied++
+	return nil
+}
+
+// reapplies returns how often the device was reapplied.
+func (d *fakeNMDevice) reapplies() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reapplied
+}
+
+// fakeNM is the object tree of a fake NetworkManager: "Wired connection 1"
+// is the primary connection, active on eth0, and "VPN" is active on tun0.
+type fakeNM struct {
+	wired, vpn *fakeNMConnection
+	eth0, tun0 *fakeNMDevice
+}
+
+// nmBus serves a fake NetworkManager on a private bus and returns a
+// client connected to it.
+func nmBus(t *testing.T, byInterface bool) (*nmDBusClient, *cmdexectest.Runner, *fakeNM) {
+	t.Helper()
+	address := startBus(t)
+	server := connectBus(t, address)
+
+	nm := &fakeNM{
+		wired: &fakeNMConnection{settings: nmSettings{
+			"connection": {"id": dbus.MakeVariant("Wired connection 1"), "type": dbus.MakeVariant("802-3-ethernet")},
+			"ipv4":       {"method": dbus.MakeVariant("auto")},
+			"ipv6":       {"method": dbus.MakeVariant("auto")},
+		}},
+		vpn: &fakeNMConnection{settings: nmSettings{
+			"connection": {"id": dbus.MakeVariant("VPN"), "type": dbus.MakeVariant("wireguard")},
+			"ipv4":       {"method": dbus.MakeVariant("manual")},
+		}},
+		eth0: &fakeNMDevice{},
+		tun0: &fakeNMDevice{},
+	}
+
+	props := map[dbus.ObjectPath]prop.Map{
+		nmPath: {nmInterface: {
+			"Version":           {Value: "1.46.0"},
+			"ActiveConnections": {Value: []dbus.ObjectPath{nmPath + "/ActiveConnection/1", nmPath + "/ActiveConnection/2"}},
+			"PrimaryConnection": {Value: nmPath + "/ActiveConnection/1"},
+		}},
+		nmPath + "/ActiveConnection/1": {nmActiveInterface: {
+			"Id":         {Value: "Wired connection 1"},
+			"Connection": {Value: nmPath + "/Settings/1"},
+			"Devices":    {Value: []dbus.ObjectPath{nmPath + "/Devices/2"}},
+		}},
+		nmPath + "/ActiveConnection/2": {nmActiveInterface: {
+			"Id":         {Value: "VPN"},
+			"Connection": {Value: nmPath + "/Settings/2"},
+			"Devices":    {Value: []dbus.ObjectPath{nmPath + "/Devices/5"}},
+		}},
+		nmPath + "/Devices/2": {nmDeviceInterface: {"Interface": {Value: "eth0"}}},
+		nmPath + "/Devices/5": {nmDeviceInterface: {"Interface": {Value: "tun0"}}},
+	}
+	for path, m := range props {
+		if _, err := prop.Export(server, path, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, object := range map[dbus.ObjectPath]any{
+		nmPath + "/Settings/1": nm.wired,
+		nmPath + "/Settings/2": nm.vpn,
+	} {
+		if err := server.Export(object, path, nmSettingsConnInterface); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, object := range map[dbus.ObjectPath]any{
+		nmPath + "/Devices/2": nm.eth0,
+		nmPath + "/Devices/5": nm.tun0,
+	} {
+		if err := server.Export(object, path, nmDeviceInterface); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reply, err := server.RequestName(nmBusName, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to own %s: %v", nmBusName, err)
+	}
+
+	runner := cmdexectest.NewRunner()
+	return &nmDBusClient{conn: connectBus(t, address), runner: runner, byInterface: byInterface}, runner, nm
+}
+
+// TestNMDBus_ListNetworkServices tests that active connections are listed
+// by name, or by interface.
+func TestNMDBus_ListNetworkServices(t *testing.T) {
+	client, _, _ := nmBus(t, false)
+	links := &nmDBusClient{conn: client.conn, byInterface: true}
+
+	services, err := client.ListNetworkServices()
+	interfaces, ifaceErr := links.ListNetworkServices()
+
+	if err != nil || strings.Join(services, ",") != "Wired connection 1,VPN" {
+		t.Errorf("expected the active connections, got %v (%v)", services, err)
+	}
+	if ifaceErr != nil || strings.Join(interfaces, ",") != "eth0,tun0" {
+		t.Errorf("expected their interfaces, got %v (%v)", interfaces, ifaceErr)
+	}
+}
+
+// TestNMDBus_DefaultService tests that the primary connection is the
+// default service.
+func TestNMDBus_DefaultService(t *testing.T) {
+	client, _, _ := nmBus(t, false)
+
+	service, err := client.DefaultService()
+
+	if err != nil || service != "Wired connection 1" {
+		t.Errorf("expected Wired connection 1, got %q (%v)", service, err)
+	}
+}
+
+// TestNMDBus_GetDNSServers tests reading servers in both the binary dns
+// form and dns-data, which takes precedence.
+func TestNMDBus_GetDNSServers(t *testing.T) {
+	client, _, nm := nmBus(t, false)
+	nm.wired.edit(func(settings nmSettings) {
+		settings["ipv4"]["dns"] = dbus.MakeVariant([]uint32{binary.NativeEndian.Uint32([]byte{192, 168, 1, 53})})
+		settings["ipv6"]["dns"] = dbus.MakeVariant([][]byte{{0x26, 0x06, 0x47, 0, 0x47, 0, 15: 0x11}})
+	})
+	nm.vpn.edit(func(settings nmSettings) {
+		settings["ipv4"]["dns"] = dbus.MakeVariant([]uint32{binary.NativeEndian.Uint32([]byte{10, 8, 0, 1})})
+		settings["ipv4"]["dns-data"] = dbus.MakeVariant([]string{"10.8.0.1#5353"})
+	})
+
+	servers, err := client.GetDNSServers("Wired connection 1")
+	vpn, vpnErr := client.GetDNSServers("VPN")
+
+	if err != nil || strings.Join(servers, ",") != "192.168.1.53,2606:4700:4700::11" {
+		t.Errorf("expected the binary servers, got %v (%v)", servers, err)
+	}
+	if vpnErr != nil || strings.Join(vpn, ",") != "10.8.0.1#5353" {
+		t.Errorf("expected dns-data, got %v (%v)", vpn, vpnErr)
+	}
+}
+
+// TestNMDBus_SetDNSServers tests that servers are saved, split by family,
+// and reapplied to the device without reactivating the connection.
+func TestNMDBus_SetDNSServers(t *testing.T) {
+	client, runner, nm := nmBus(t, false)
+
+	err := client.SetDNSServers("Wired connection 1", []string{"1.1.1.1", "2606:4700:4700::1111", "1.0.0.1"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	settings, updates := nm.wired.state()
+	if updates != 1 || nm.eth0.reapplies() != 1 || nm.tun0.reapplies() != 0 {
+		t.Errorf("expected one update reapplied on eth0, got %d updates, %d and %d reapplies",
+			updates, nm.eth0.reapplies(), nm.tun0.reapplies())
+	}
+	if len(runner.Calls) != 0 {
+		t.Errorf("expected no commands, got %v", runner.Commands())
+	}
+	if settings["ipv4"]["ignore-auto-dns"].Value() != true || settings["ipv6"]["ignore-auto-dns"].Value() != true {
+		t.Errorf("expected automatic DNS to be ignored, got %v", settings)
+	}
+	if settings["ipv4"]["method"].Value() != "auto" {
+		t.Errorf("expected the other settings to be kept, got %v", settings["ipv4"])
+	}
+	servers, err := client.GetDNSServers("Wired connection 1")
+	if err != nil || strings.Join(servers, ",") != "1.1.1.1,1.0.0.1,2606:4700:4700::1111" {
+		t.Errorf("expected the new servers, got %v (%v)", servers, err)
+	}
+}
+
+// TestNMDBus_SetDNSServers_Port tests that servers with a port are saved
+// in dns-data.
+func TestNMDBus_SetDNSServers_Port(t *testing.T) {
+	client, _, nm := nmBus(t, false)
+
+	err := client.SetDNSServers("VPN", []string{"10.8.0.1:5353"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	settings, _ := nm.vpn.state()
+	if _, ok := settings["ipv4"]["dns"]; ok {
+		t.Errorf("expected no binary servers, got %v", settings["ipv4"])
+	}
+	if data, _ := settings["ipv4"]["dns-data"].Value().([]string); strings.Join(data, ",") != "10.8.0.1:5353" {
+		t.Errorf("expected dns-data, got %v", settings["ipv4"])
+	}
+	if _, ok := settings["ipv6"]; ok {
+		t.Errorf("expected no IPv6 settings to be added, got %v", settings["ipv6"])
+	}
+}
+
+// TestNMDBus_ClearDNSServers tests that servers and ignoring automatic DNS
+// are reset, while domains are kept.
+func TestNMDBus_ClearDNSServers(t *testing.T) {
+	client, _, nm := nmBus(t, false)
+	ctx := context.Background()
+	if err := client.SetDNSServers("Wired connection 1", []string{"1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetDomains(ctx, "Wired connection 1", Domains{Search: []string{"corp.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.ClearDNSServers("Wired connection 1")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	settings, _ := nm.wired.state()
+	if len(settings["ipv4"]) != 2 || len(settings["ipv6"]) != 1 {
+		t.Errorf("expected only the methods and domains to be left, got %v", settings)
+	}
+	if search, _ := settings["ipv4"]["dns-search"].Value().([]string); strings.Join(search, ",") != "corp.example.com" {
+		t.Errorf("expected the domains to be kept, got %v", settings["ipv4"])
+	}
+	if nm.eth0.reapplies() != 3 {
+		t.Errorf("expected every change to be reapplied, got %d", nm.eth0.reapplies())
+	}
+}
+
+// TestNMDBus_Domains tests that domains, including routing-only ones, are
+// saved on the IPv4 settings, and unchanged domains are not saved again.
+func TestNMDBus_Domains(t *testing.T) {
+	client, _, nm := nmBus(t, true)
+	ctx := context.Background()
+	want := Domains{Search: []string{"corp.example.com"}, RouteOnly: []string{"internal"}}
+
+	err := client.SetDomains(ctx, "eth0", want)
+	again := client.SetDomains(ctx, "eth0", want)
+
+	if err != nil || again != nil {
+		t.Fatalf("expected no errors, got: %v, %v", err, again)
+	}
+	settings, updates := nm.wired.state()
+	if search, _ := settings["ipv4"]["dns-search"].Value().([]string); strings.Join(search, ",") != "corp.example.com,~internal" {
+		t.Errorf("unexpected dns-search: %v", settings["ipv4"])
+	}
+	if updates != 1 {
+		t.Errorf("expected one update, got %d", updates)
+	}
+	domains, err := client.GetDomains(ctx, "eth0")
+	if err != nil || !domains.Equal(want) {
+		t.Errorf("expected %v, got %v (%v)", want, domains, err)
+	}
+}
+
+// TestNMDBus_UnknownService tests that services without an active
+// connection are rejected.
+func TestNMDBus_UnknownService(t *testing.T) {
+	client, _, _ := nmBus(t, false)
+	links := &nmDBusClient{conn: client.conn, byInterface: true}
+
+	err := client.SetDNSServers("Home", []string{"1.1.1.1"})
+	_, linkErr := links.GetDNSServers("wlan0")
+
+	if err == nil || !strings.Contains(err.Error(), `"Home" is not an active NetworkManager connection`) {
+		t.Errorf("expected unknown connection error, got: %v", err)
+	}
+	if linkErr == nil || !strings.Contains(linkErr.Error(), "wlan0 has no active NetworkManager connection") {
+		t.Errorf("expected no connection error, got: %v", linkErr)
+	}
+}
+
+// TestNMDBus_UpdateFailure tests that NetworkManager's error is part of
+// the error and nothing is reapplied.
+func TestNMDBus_UpdateFailure(t *testing.T) {
+	client, _, nm := nmBus(t, false)
+	nm.wired.mu.Lock()
+	nm.wired.fail = "Insufficient privileges"
+	nm.wired.mu.Unlock()
+
+	err := client.SetDNSServers("Wired connection 1", []string{"1.1.1.1"})
+
+	if err == nil || !strings.Contains(err.Error(), "failed to set DNS servers: Insufficient privileges") {
+		t.Errorf("expected NetworkManager's error, got: %v", err)
+	}
+	if nm.eth0.reapplies() != 0 {
+		t.Errorf("expected no reapply, got %d", nm.eth0.reapplies())
+	}
+}
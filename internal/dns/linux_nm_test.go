@@ -0,0 +1,312 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// TestNM_ListNetworkServices tests parsing active connections, including escaped names.
+func TestNM_ListNetworkServices(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("nmcli -t -f NAME connection show --active", fixture(t, "nmcli_active.txt"))
+	client := &nmClient{runner: runner}
+
+	services, err := client.ListNetworkServices()
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []string{"Wired connection 1", "Home:5GHz", "docker0"}
+	if strings.Join(services, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, services)
+	}
+}
+
+// TestNM_GetDNSServers tests reading and merging IPv4 and IPv6 servers.
+func TestNM_GetDNSServers(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("nmcli -t -f ipv4.dns,ipv6.dns connection show Home", fixture(t, "nmcli_dns.txt"))
+	client := &nmClient{runner: runner}
+
+	servers, err := client.GetDNSServers("Home")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []string{"8.8.8.8", "8.8.4.4", "2001:4860:4860::8888", "2001:4860:4860::8844"}
+	if strings.Join(servers, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, servers)
+	}
+}
+
+// TestNM_GetDNSServers_Empty tests a connection without manual DNS.
+func TestNM_GetDNSServers_Empty(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("nmcli -t -f ipv4.dns,ipv6.dns connection show Home", fixture(t, "nmcli_dns_empty.txt"))
+	client := &nmClient{runner: runner}
+
+	servers, err := client.GetDNSServers("Home")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if servers != nil {
+		t.Errorf("expected no servers, got %v", servers)
+	}
+}
+
+// TestNM_GetDNSServers_Unescaped tests output from nmcli versions that don't escape colons.
+func TestNM_GetDNSServers_Unescaped(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("nmcli -t -f ipv4.dns,ipv6.dns connection show Home",
+		"ipv4.dns:\nipv6.dns:2606:4700:4700::1111\n")
+	client := &nmClient{runner: runner}
+
+	servers, err := client.GetDNSServers("Home")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(servers) != 1 || servers[0] != "2606:4700:4700::1111" {
+		t.Errorf("expected [2606:4700:4700::1111], got %v", servers)
+	}
+}
+
+// TestNM_SetDNSServers tests that servers are split by family and the connection reactivated.
+func TestNM_SetDNSServers(t *testing.T) {
+	modify := "nmcli connection modify Home " +
+		"ipv4.dns 1.1.1.1,1.0.0.1 ipv4.ignore-auto-dns yes " +
+		"ipv6.dns 2606:4700:4700::1111 ipv6.ignore-auto-dns yes"
+	runner := cmdexectest.NewRunner().
+		Expect(modify, "").
+		Expect("nmcli connection up Home", "Connection successfully activated\n")
+	client := &nmClient{runner: runner}
+
+	if err := client.SetDNSServers("Home", []string{"1.1.1.1", "2606:4700:4700::1111", "1.0.0.1"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, modify, "nmcli connection up Home")
+}
+
+// TestNM_SetDNSServers_IPv4Only tests that IPv6 DNS is emptied when only IPv4 is given.
+func TestNM_SetDNSServers_IPv4Only(t *testing.T) {
+	modify := "nmcli connection modify Home " +
+		"ipv4.dns 9.9.9.9 ipv4.ignore-auto-dns yes " +
+		"ipv6.dns  ipv6.ignore-auto-dns yes"
+	runner := cmdexectest.NewRunner().
+		Expect(modify, "").
+		Expect("nmcli connection up Home", "")
+	client := &nmClient{runner: runner}
+
+	if err := client.SetDNSServers("Home", []string{"9.9.9.9"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if runner.Calls[0].Args[8] != "" {
+		t.Errorf("expected empty ipv6.dns value, got %q", runner.Calls[0].Args[8])
+	}
+}
+
+// TestNM_SetDNSServers_ModifyError tests that a failed modify stops before reactivating.
+func TestNM_SetDNSServers_ModifyError(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Fail("nmcli connection modify Home ipv4.dns 1.1.1 ipv4.ignore-auto-dns yes ipv6.dns  ipv6.ignore-auto-dns yes", 2,
+			"Error: failed to modify ipv4.dns: invalid IP address: Invalid IPv4 address '1.1.1'.")
+	client := &nmClient{runner: runner}
+
+	err := client.SetDNSServers("Home", []string{"1.1.1"})
+
+	if err == nil || !strings.Contains(err.Error(), "invalid IP address") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(runner.Calls) != 1 {
+		t.Errorf("expected 1 command, got %v", runner.Commands())
+	}
+}
+
+// TestNM_ClearDNSServers tests that clearing re-enables automatic DNS for
+// both families and leaves the domains alone.
+func TestNM_ClearDNSServers(t *testing.T) {
+	modify := "nmcli connection modify Home " +
+		"ipv4.dns  ipv4.ignore-auto-dns no " +
+		"ipv6.dns  ipv6.ignore-auto-dns no"
+	runner := cmdexectest.NewRunner().
+		Expect(modify, "").
+		Expect("nmcli connection up Home", "")
+	client := &nmClient{runner: runner}
+
+	if err := client.ClearDNSServers("Home"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, modify, "nmcli connection up Home")
+}
+
+// TestNM_GetDomains tests parsing search and routing domains of both families.
+func TestNM_GetDomains(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("nmcli -t -f ipv4.dns-search,ipv6.dns-search connection show Work", fixture(t, "nmcli_domains.txt"))
+	client := &nmClient{runner: runner}
+
+	domains, err := client.GetDomains(context.Background(), "Work")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(domains.Search, ",") != "corp.example.com,example.com" {
+		t.Errorf("expected search domains, got %v", domains.Search)
+	}
+	if strings.Join(domains.RouteOnly, ",") != "internal.example.com" {
+		t.Errorf("expected routing domains, got %v", domains.RouteOnly)
+	}
+}
+
+// TestNM_SetDomains tests that changed domains are written and the connection reactivated.
+func TestNM_SetDomains(t *testing.T) {
+	show := "nmcli -t -f ipv4.dns-search,ipv6.dns-search connection show Work"
+	modify := "nmcli connection modify Work ipv4.dns-search corp.example.com,~internal.example.com ipv6.dns-search "
+	runner := cmdexectest.NewRunner().
+		Expect(show, "ipv4.dns-search:\nipv6.dns-search:\n").
+		Expect(modify, "").
+		Expect("nmcli connection up Work", "")
+	client := &nmClient{runner: runner}
+
+	err := client.SetDomains(context.Background(), "Work", Domains{
+		Search:    []string{"corp.example.com"},
+		RouteOnly: []string{"internal.example.com"},
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, show, modify, "nmcli connection up Work")
+}
+
+// TestNM_SetDomains_Unchanged tests that unchanged domains skip reactivation.
+func TestNM_SetDomains_Unchanged(t *testing.T) {
+	show := "nmcli -t -f ipv4.dns-search,ipv6.dns-search connection show Work"
+	runner := cmdexectest.NewRunner().Expect(show, fixture(t, "nmcli_domains.txt"))
+	client := &nmClient{runner: runner}
+
+	err := client.SetDomains(context.Background(), "Work", Domains{
+		Search:    []string{"example.com", "corp.example.com"},
+		RouteOnly: []string{"internal.example.com"},
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, show)
+}
+
+// TestNM_SetDNSAndDomains tests that servers and domains are changed
+// together, with a single reactivation.
+func TestNM_SetDNSAndDomains(t *testing.T) {
+	modify := "nmcli connection modify Work ipv4.dns 10.0.0.53 ipv4.ignore-auto-dns yes ipv6.dns  ipv6.ignore-auto-dns yes " +
+		"ipv4.dns-search corp.example.com ipv6.dns-search "
+	runner := cmdexectest.NewRunner().
+		Expect(modify, "").
+		Expect("nmcli connection up Work", "")
+	client := &nmClient{runner: runner}
+
+	err := client.SetDNSAndDomains(context.Background(), "Work", []string{"10.0.0.53"}, Domains{Search: []string{"corp.example.com"}})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, modify, "nmcli connection up Work")
+}
+
+// TestNMLink_AutomaticDNSServers tests reading the servers NetworkManager
+// gives a device, including escaped IPv6 addresses.
+func TestNMLink_AutomaticDNSServers(t *testing.T) {
+	show := "nmcli -t -f IP4.DNS,IP6.DNS device show wlan0"
+	runner := cmdexectest.NewRunner().Expect(show, "IP4.DNS[1]:192.168.1.1\nIP4.DNS[2]:192.168.1.2\nIP6.DNS[1]:fe80\\:\\:1\n")
+	client := &nmLinkClient{nm: &nmClient{runner: runner}}
+
+	servers, err := client.automaticDNSServers(context.Background(), "wlan0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(servers, ",") != "192.168.1.1,192.168.1.2,fe80::1" {
+		t.Errorf("expected the device's servers, got %v", servers)
+	}
+}
+
+// TestSplitByFamily tests separating servers by address family.
+func TestSplitByFamily(t *testing.T) {
+	v4, v6 := splitByFamily([]string{
+		"1.1.1.1",
+		"2606:4700:4700::1111",
+		"1.0.0.1:53",
+		"[2606:4700:4700::1001]:53",
+		"fe80::1%eth0",
+	})
+
+	if strings.Join(v4, ",") != "1.1.1.1,1.0.0.1:53" {
+		t.Errorf("unexpected IPv4 servers: %v", v4)
+	}
+	if strings.Join(v6, ",") != "2606:4700:4700::1111,[2606:4700:4700::1001]:53,fe80::1%eth0" {
+		t.Errorf("unexpected IPv6 servers: %v", v6)
+	}
+}
+
+// TestNM_FlushCache_FallsBack tests that flushing tries nscd and never fails.
+func TestNM_FlushCache_FallsBack(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Fail("resolvectl flush-caches", 1, "").
+		Fail("nscd -i hosts", 127, "")
+	client := &nmClient{runner: runner}
+
+	if err := client.FlushCache(); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, "resolvectl flush-caches", "nscd -i hosts")
+}
+
+// TestParseConnectionForDevice tests mapping a device to its NM connection.
+func TestParseConnectionForDevice(t *testing.T) {
+	output := "Wired connection 1:enp0s31f6\nHome\\:5GHz:wlan0\nlo:lo\n"
+
+	if name := parseConnectionForDevice(output, "wlan0"); name != "Home:5GHz" {
+		t.Errorf("expected Home:5GHz, got %q", name)
+	}
+	if name := parseConnectionForDevice(output, "enp0s31f6"); name != "Wired connection 1" {
+		t.Errorf("expected Wired connection 1, got %q", name)
+	}
+	if name := parseConnectionForDevice(output, "eth9"); name != "" {
+		t.Errorf("expected no connection, got %q", name)
+	}
+}
+
+// TestNMLink_SetDNSServers tests that servers for an interface are set on
+// the connection active on it.
+func TestNMLink_SetDNSServers(t *testing.T) {
+	modify := "nmcli connection modify Home:5GHz " +
+		"ipv4.dns 1.1.1.1 ipv4.ignore-auto-dns yes " +
+		"ipv6.dns  ipv6.ignore-auto-dns yes"
+	runner := cmdexectest.NewRunner().
+		Expect("nmcli -t -f NAME,DEVICE connection show --active", "Wired connection 1:eth0\nHome\\:5GHz:wlan0\n").
+		Expect(modify, "").
+		Expect("nmcli connection up Home:5GHz", "")
+	client := &nmLinkClient{nm: &nmClient{runner: runner}}
+
+	err := client.SetDNSServers("wlan0", []string{"1.1.1.1"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, "nmcli -t -f NAME,DEVICE connection show --active", modify, "nmcli connection up Home:5GHz")
+}
+
+// TestNMLink_NoConnection tests the error for an interface without an
+// active connection.
+func TestNMLink_NoConnection(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("nmcli -t -f NAME,DEVICE connection show --active", "Wired connection 1:eth0\n")
+	client := &nmLinkClient{nm: &nmClient{runner: runner}}
+
+	_, err := client.GetDNSServers("wlan0")
+
+	if err == nil || !strings.Contains(err.Error(), "wlan0 has no active NetworkManager connection") {
+		t.Errorf("expected no connection error, got: %v", err)
+	}
+}
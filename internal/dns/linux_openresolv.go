@@ -0,0 +1,267 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// State directories holding one resolv.conf record per interface, relative
+// to the root: openresolv's first, then Debian's resolvconf.
+var openresolvStateDirs = []string{
+	"run/resolvconf/interfaces",
+	"run/resolvconf/interface",
+}
+
+// sysClassNet lists the network interfaces, relative to the root.
+const sysClassNet = "sys/class/net"
+
+// openresolvSuffix names dnsctl's records, after the interface name, the
+// way DHCP clients register theirs as "eth0.dhcp".
+const openresolvSuffix = ".dnsctl"
+
+// openresolvClient manages DNS through resolvconf, either openresolv or
+// Debian's resolvconf, which build resolv.conf from records that DHCP
+// clients and VPNs add per interface.
+//
+// dnsctl adds its own record for an interface, "<iface>.dnsctl", and
+// deletes it again to return to the other records. With openresolv the
+// record is exclusive, so only its servers are used; Debian's resolvconf
+// has no such option and orders records by /etc/resolvconf/interface-order.
+type openresolvClient struct {
+	runner cmdexec.Runner
+
+	// root is prepended to every path, so tests can use a temp dir.
+	root string
+
+	// stateDir is where resolvconf keeps the records, relative to root.
+	stateDir string
+
+	// exclusive is set for openresolv, whose -x option makes a record
+	// take precedence over all others.
+	exclusive bool
+}
+
+// detectOpenresolv returns a client if resolvconf keeps records under
+// root, and whether it found them.
+func detectOpenresolv(runner cmdexec.Runner, root string) (*openresolvClient, bool) {
+	dir := findOpenresolvStateDir(root)
+	if dir == "" {
+		return nil, false
+	}
+
+	// Only openresolv knows --version
+	result, err := runner.Run(context.Background(), "resolvconf", "--version")
+	exclusive := err == nil && strings.HasPrefix(strings.TrimSpace(string(result.Stdout)), "openresolv")
+
+	return &openresolvClient{runner: runner, root: root, stateDir: dir, exclusive: exclusive}, true
+}
+
+// findOpenresolvStateDir returns the state directory resolvconf keeps under
+// root, or "" if there is none.
+func findOpenresolvStateDir(root string) string {
+	for _, dir := range openresolvStateDirs {
+		if info, err := os.Stat(filepath.Join(root, "/", dir)); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// Name returns the backend name for display purposes.
+func (c *openresolvClient) Name() string {
+	return "resolvconf"
+}
+
+// Persistence returns Runtime, as resolvconf keeps its records under /run.
+func (c *openresolvClient) Persistence() Persistence {
+	return Runtime
+}
+
+// ListNetworkServices returns the interfaces that have records.
+func (c *openresolvClient) ListNetworkServices() ([]string, error) {
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns the interfaces that have records.
+// Records are named after the interface, usually followed by the program
+// that added them.
+func (c *openresolvClient) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(c.path(c.stateDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resolvconf records: %w", err)
+	}
+
+	var services []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if iface := c.recordInterface(entry.Name()); !slices.Contains(services, iface) {
+			services = append(services, iface)
+		}
+	}
+	return services, nil
+}
+
+// DefaultService returns the interface holding the default route.
+func (c *openresolvClient) DefaultService() (string, error) {
+	return defaultRouteInterface()
+}
+
+// GetDNSServers returns the servers of dnsctl's record, or none if the
+// interface has no record of dnsctl.
+func (c *openresolvClient) GetDNSServers(service string) ([]string, error) {
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the servers of dnsctl's record.
+func (c *openresolvClient) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	record, err := c.readRecord(service)
+	if err != nil {
+		return nil, err
+	}
+	return record.nameservers(), nil
+}
+
+// SetDNSServers adds dnsctl's record for an interface.
+func (c *openresolvClient) SetDNSServers(service string, servers []string) error {
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext adds dnsctl's record for an interface, replacing
+// an earlier one and keeping its search domains.
+func (c *openresolvClient) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	record, err := c.readRecord(service)
+	if err != nil {
+		return err
+	}
+	return c.writeRecord(ctx, "set DNS servers", service, servers, record.search())
+}
+
+// ClearDNSServers deletes dnsctl's record for an interface.
+func (c *openresolvClient) ClearDNSServers(service string) error {
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext deletes dnsctl's record for an interface, which
+// also drops its search domains.
+func (c *openresolvClient) ClearDNSServersContext(ctx context.Context, service string) error {
+	return c.deleteRecord(ctx, "clear DNS servers", service)
+}
+
+// FlushCache does nothing, as resolvconf restarts the local resolvers it
+// feeds on every change.
+func (c *openresolvClient) FlushCache() error {
+	return nil
+}
+
+// FlushCacheContext does nothing, like FlushCache.
+func (c *openresolvClient) FlushCacheContext(ctx context.Context) error {
+	return nil
+}
+
+// GetDomains returns the search domains of dnsctl's record.
+func (c *openresolvClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	record, err := c.readRecord(service)
+	if err != nil {
+		return Domains{}, err
+	}
+	return Domains{Search: record.search()}, nil
+}
+
+// SetDomains replaces the search domains of dnsctl's record. resolv.conf
+// cannot route a domain to particular servers, so routing-only domains
+// are not supported.
+func (c *openresolvClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	if len(domains.RouteOnly) > 0 {
+		return ErrRouteOnlyDomainsUnsupported
+	}
+
+	record, err := c.readRecord(service)
+	if err != nil {
+		return err
+	}
+	return c.writeRecord(ctx, "set domains", service, record.nameservers(), domains.Search)
+}
+
+// path returns a path under the client's root.
+func (c *openresolvClient) path(name ...string) string {
+	return filepath.Join(append([]string{c.root, "/"}, name...)...)
+}
+
+// recordInterface returns the interface a record belongs to: the record
+// name without its last dot-separated part, unless the whole name is an
+// interface, as with VLANs such as "eth0.100".
+func (c *openresolvClient) recordInterface(record string) string {
+	if _, err := os.Stat(c.path(sysClassNet, record)); err == nil {
+		return record
+	}
+	if i := strings.LastIndex(record, "."); i > 0 {
+		return record[:i]
+	}
+	return record
+}
+
+// readRecord parses dnsctl's record for an interface. A missing record is
+// empty.
+func (c *openresolvClient) readRecord(service string) (resolvConf, error) {
+	if err := checkInterfaceName(service); err != nil {
+		return resolvConf{}, err
+	}
+
+	data, err := os.ReadFile(c.path(c.stateDir, service+openresolvSuffix))
+	if err != nil && !os.IsNotExist(err) {
+		return resolvConf{}, fmt.Errorf("failed to read resolvconf record: %w", err)
+	}
+	return parseResolvConf(string(data)), nil
+}
+
+// writeRecord adds dnsctl's record for an interface with servers and
+// search domains, or deletes it if both are empty.
+func (c *openresolvClient) writeRecord(ctx context.Context, action, service string, servers, search []string) error {
+	if len(servers) == 0 && len(search) == 0 {
+		return c.deleteRecord(ctx, action, service)
+	}
+
+	var record resolvConf
+	record.setNameservers(servers)
+	record.setSearch(search)
+
+	args := []string{"-a", service + openresolvSuffix}
+	if c.exclusive {
+		args = append([]string{"-x"}, args...)
+	}
+	_, err := runInput(ctx, c.runner, []byte(record.String()), action, "resolvconf", args...)
+	return err
+}
+
+// deleteRecord deletes dnsctl's record for an interface, if there is one.
+func (c *openresolvClient) deleteRecord(ctx context.Context, action, service string) error {
+	if err := checkInterfaceName(service); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(c.path(c.stateDir, service+openresolvSuffix)); os.IsNotExist(err) {
+		return nil
+	}
+	_, err := run(ctx, c.runner, action, "resolvconf", "-d", service+openresolvSuffix)
+	return err
+}
+
+// checkInterfaceName rejects names that resolvconf would take as an
+// option or that would leave its state directory.
+func checkInterfaceName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") || strings.HasPrefix(name, ".") ||
+		strings.ContainsAny(name, "/ \t\n") {
+		return fmt.Errorf("invalid interface name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// openresolvRoot creates a temp root whose resolvconf state directory
+// holds the given records.
+func openresolvRoot(t *testing.T, stateDir string, records map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, stateDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range records {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// openresolvStateDir is openresolv's state directory.
+var openresolvStateDir = openresolvStateDirs[0]
+
+// TestOpenresolv_Detect tests that openresolv is detected by its state
+// directory and version, and gets exclusive records.
+func TestOpenresolv_Detect(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, nil)
+	runner := cmdexectest.NewRunner().Expect("resolvconf --version", "openresolv 3.13.2\nCopyright (c) 2007-2023 Roy Marples\n")
+
+	client, ok := detectOpenresolv(runner, root)
+
+	if !ok {
+		t.Fatal("expected openresolv to be detected")
+	}
+	if client.stateDir != openresolvStateDir || !client.exclusive {
+		t.Errorf("expected exclusive records in %s, got %+v", openresolvStateDir, client)
+	}
+}
+
+// TestOpenresolv_DetectDebian tests that Debian's resolvconf, which has no
+// --version, is detected by its state directory.
+func TestOpenresolv_DetectDebian(t *testing.T) {
+	root := openresolvRoot(t, "run/resolvconf/interface", nil)
+	runner := cmdexectest.NewRunner().Fail("resolvconf --version", 99, "resolvconf: Error: Command not recognized")
+
+	client, ok := detectOpenresolv(runner, root)
+
+	if !ok {
+		t.Fatal("expected resolvconf to be detected")
+	}
+	if client.stateDir != "run/resolvconf/interface" || client.exclusive {
+		t.Errorf("expected plain records in run/resolvconf/interface, got %+v", client)
+	}
+}
+
+// TestOpenresolv_DetectMissing tests that nothing is detected without a
+// state directory.
+func TestOpenresolv_DetectMissing(t *testing.T) {
+	runner := cmdexectest.NewRunner()
+
+	_, ok := detectOpenresolv(runner, t.TempDir())
+
+	if ok {
+		t.Error("expected resolvconf not to be detected")
+	}
+	if len(runner.Calls) != 0 {
+		t.Errorf("expected no commands, got %v", runner.Commands())
+	}
+}
+
+// TestOpenresolv_ListNetworkServices tests that interfaces are taken from
+// the record names, once each.
+func TestOpenresolv_ListNetworkServices(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, map[string]string{
+		"eth0.dhcp":     "nameserver 192.168.1.1\n",
+		"eth0.dnsctl":   "nameserver 1.1.1.1\n",
+		"eth0.100.dhcp": "nameserver 10.0.100.1\n",
+		"tun0":          "nameserver 10.8.0.1\n",
+		"wlan0.dhcpcd":  "nameserver 192.168.2.1\n",
+	})
+	if err := os.MkdirAll(filepath.Join(root, sysClassNet, "eth0.100"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	client := &openresolvClient{runner: cmdexectest.NewRunner(), root: root, stateDir: openresolvStateDir}
+
+	services, err := client.ListNetworkServices()
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []string{"eth0.100", "eth0", "tun0", "wlan0"}
+	if strings.Join(services, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, services)
+	}
+}
+
+// TestOpenresolv_GetDNSServers tests that only dnsctl's record is read.
+func TestOpenresolv_GetDNSServers(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, map[string]string{
+		"eth0.dhcp":   "nameserver 192.168.1.1\n",
+		"eth0.dnsctl": "nameserver 1.1.1.1\nnameserver 1.0.0.1\n",
+		"wlan0.dhcp":  "nameserver 192.168.2.1\n",
+	})
+	client := &openresolvClient{runner: cmdexectest.NewRunner(), root: root, stateDir: openresolvStateDir}
+
+	servers, err := client.GetDNSServers("eth0")
+	automatic, autoErr := client.GetDNSServers("wlan0")
+
+	if err != nil || strings.Join(servers, ",") != "1.1.1.1,1.0.0.1" {
+		t.Errorf("expected dnsctl's servers, got %v (%v)", servers, err)
+	}
+	if autoErr != nil || automatic != nil {
+		t.Errorf("expected no servers without a record, got %v (%v)", automatic, autoErr)
+	}
+}
+
+// TestOpenresolv_SetDNSServers tests that servers are added as an
+// exclusive record that keeps the search domains.
+func TestOpenresolv_SetDNSServers(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, map[string]string{
+		"eth0.dnsctl": "nameserver 9.9.9.9\nsearch corp.example.com\n",
+	})
+	runner := cmdexectest.NewRunner().Expect("resolvconf -x -a eth0.dnsctl", "")
+	client := &openresolvClient{runner: runner, root: root, stateDir: openresolvStateDir, exclusive: true}
+
+	err := client.SetDNSServers("eth0", []string{"1.1.1.1", "2606:4700:4700::1111"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(runner.Calls) != 1 {
+		t.Fatalf("expected one command, got %v", runner.Commands())
+	}
+	want := "nameserver 1.1.1.1\nnameserver 2606:4700:4700::1111\nsearch corp.example.com\n"
+	if runner.Calls[0].Input != want {
+		t.Errorf("unexpected record:\n%s", runner.Calls[0].Input)
+	}
+}
+
+// TestOpenresolv_SetDNSServers_Debian tests that Debian's resolvconf gets
+// a plain record.
+func TestOpenresolv_SetDNSServers_Debian(t *testing.T) {
+	root := openresolvRoot(t, "run/resolvconf/interface", nil)
+	runner := cmdexectest.NewRunner().Expect("resolvconf -a eth0.dnsctl", "")
+	client := &openresolvClient{runner: runner, root: root, stateDir: "run/resolvconf/interface"}
+
+	err := client.SetDNSServers("eth0", []string{"1.1.1.1"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := strings.Join(runner.Commands(), "; "); got != "resolvconf -a eth0.dnsctl" {
+		t.Errorf("expected a plain record, got: %s", got)
+	}
+}
+
+// TestOpenresolv_SetDNSServers_Failure tests that resolvconf's output is
+// part of the error.
+func TestOpenresolv_SetDNSServers_Failure(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, nil)
+	runner := cmdexectest.NewRunner().Fail("resolvconf -a eth0.dnsctl", 1, "resolvconf: Permission denied")
+	client := &openresolvClient{runner: runner, root: root, stateDir: openresolvStateDir}
+
+	err := client.SetDNSServers("eth0", []string{"1.1.1.1"})
+
+	if err == nil || !strings.Contains(err.Error(), "failed to set DNS servers: resolvconf: Permission denied") {
+		t.Errorf("expected resolvconf's error, got: %v", err)
+	}
+}
+
+// TestOpenresolv_ClearDNSServers tests that dnsctl's record is deleted,
+// and nothing is run without one.
+func TestOpenresolv_ClearDNSServers(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, map[string]string{
+		"eth0.dnsctl": "nameserver 1.1.1.1\n",
+		"wlan0.dhcp":  "nameserver 192.168.2.1\n",
+	})
+	runner := cmdexectest.NewRunner().Expect("resolvconf -d eth0.dnsctl", "")
+	client := &openresolvClient{runner: runner, root: root, stateDir: openresolvStateDir}
+
+	err := client.ClearDNSServers("eth0")
+	noRecordErr := client.ClearDNSServers("wlan0")
+
+	if err != nil || noRecordErr != nil {
+		t.Fatalf("expected no errors, got: %v, %v", err, noRecordErr)
+	}
+	if got := strings.Join(runner.Commands(), "; "); got != "resolvconf -d eth0.dnsctl" {
+		t.Errorf("expected only eth0's record to be deleted, got: %s", got)
+	}
+}
+
+// TestOpenresolv_Domains tests replacing the search domains while keeping
+// the servers, and deleting a record left empty.
+func TestOpenresolv_Domains(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, map[string]string{
+		"eth0.dnsctl":  "nameserver 1.1.1.1\nsearch old.example.com\n",
+		"wlan0.dnsctl": "search lan\n",
+	})
+	runner := cmdexectest.NewRunner().
+		Expect("resolvconf -a eth0.dnsctl", "").
+		Expect("resolvconf -d wlan0.dnsctl", "")
+	client := &openresolvClient{runner: runner, root: root, stateDir: openresolvStateDir}
+	ctx := context.Background()
+
+	domains, err := client.GetDomains(ctx, "eth0")
+	setErr := client.SetDomains(ctx, "eth0", Domains{Search: []string{"corp.example.com"}})
+	clearErr := client.SetDomains(ctx, "wlan0", Domains{})
+
+	if err != nil || strings.Join(domains.Search, ",") != "old.example.com" {
+		t.Errorf("expected the record's domains, got %v (%v)", domains, err)
+	}
+	if setErr != nil || clearErr != nil {
+		t.Fatalf("expected no errors, got: %v, %v", setErr, clearErr)
+	}
+	if got := strings.Join(runner.Commands(), "; "); got != "resolvconf -a eth0.dnsctl; resolvconf -d wlan0.dnsctl" {
+		t.Errorf("unexpected commands: %s", got)
+	}
+	if want := "nameserver 1.1.1.1\nsearch corp.example.com\n"; runner.Calls[0].Input != want {
+		t.Errorf("unexpected record:\n%s", runner.Calls[0].Input)
+	}
+}
+
+// TestOpenresolv_RouteOnlyDomains tests that routing-only domains are
+// reported as unsupported.
+func TestOpenresolv_RouteOnlyDomains(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, nil)
+	client := &openresolvClient{runner: cmdexectest.NewRunner(), root: root, stateDir: openresolvStateDir}
+
+	err := client.SetDomains(context.Background(), "eth0", Domains{RouteOnly: []string{"corp"}})
+
+	if !errors.Is(err, ErrRouteOnlyDomainsUnsupported) {
+		t.Errorf("expected ErrRouteOnlyDomainsUnsupported, got: %v", err)
+	}
+}
+
+// TestOpenresolv_InvalidInterface tests that names resolvconf would read
+// as options or paths are rejected before it runs.
+func TestOpenresolv_InvalidInterface(t *testing.T) {
+	root := openresolvRoot(t, openresolvStateDir, nil)
+	runner := cmdexectest.NewRunner()
+	client := &openresolvClient{runner: runner, root: root, stateDir: openresolvStateDir}
+
+	for _, name := range []string{"-d", "../eth0", "", "eth 0"} {
+		err := client.SetDNSServers(name, []string{"1.1.1.1"})
+
+		if err == nil || !strings.Contains(err.Error(), "invalid interface name") {
+			t.Errorf("expected invalid interface error for %q, got: %v", name, err)
+		}
+	}
+	if len(runner.Calls) != 0 {
+		t.Errorf("expected no commands, got %v", runner.Commands())
+	}
+}
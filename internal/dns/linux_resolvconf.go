@@ -0,0 +1,387 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Paths managed by the resolv.conf backend, relative to its root.
+const (
+	resolvConfPath   = "etc/resolv.conf"
+	resolvConfBackup = "etc/resolv.conf.dnsctl-backup"
+)
+
+// ResolvConfService is the only service of the resolv.conf backend, as
+// the file configures DNS for the whole system.
+const ResolvConfService = "system"
+
+// resolvConfMarker heads a resolv.conf written by dnsctl. A file without
+// it was rewritten by another tool since.
+const resolvConfMarker = "# Generated by dnsctl; the original is saved as resolv.conf.dnsctl-backup"
+
+// resolvConfClient manages DNS by rewriting /etc/resolv.conf, for systems
+// without a DNS manager such as containers, Alpine and minimal VMs.
+//
+// The original file is backed up before the first change and restored by
+// ClearDNSServers. Servers are only reported while dnsctl's own file is
+// in place; anything else counts as automatic, like DHCP elsewhere.
+type resolvConfClient struct {
+	// root is prepended to every path, so tests can use a temp dir.
+	root string
+}
+
+// Name returns the backend name for display purposes.
+func (c *resolvConfClient) Name() string {
+	return "resolv.conf"
+}
+
+// Persistence returns Persistent, as the file survives reboots.
+func (c *resolvConfClient) Persistence() Persistence {
+	return Persistent
+}
+
+// ListNetworkServices returns the single system-wide service.
+func (c *resolvConfClient) ListNetworkServices() ([]string, error) {
+	return []string{ResolvConfService}, nil
+}
+
+// DefaultService returns the single system-wide service.
+func (c *resolvConfClient) DefaultService() (string, error) {
+	return ResolvConfService, nil
+}
+
+// GetDNSServers returns the servers dnsctl wrote, or none if the file is
+// not dnsctl's.
+func (c *resolvConfClient) GetDNSServers(service string) ([]string, error) {
+	if err := checkResolvConfService(service); err != nil {
+		return nil, err
+	}
+
+	conf, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if !conf.managed() {
+		return nil, nil
+	}
+	return conf.nameservers(), nil
+}
+
+// SetDNSServers replaces the nameserver lines, keeping everything else.
+// resolv.conf has no way to give a port, so servers must be addresses.
+func (c *resolvConfClient) SetDNSServers(service string, servers []string) error {
+	if err := checkResolvConfService(service); err != nil {
+		return err
+	}
+	for _, server := range servers {
+		if _, err := netip.ParseAddr(server); err != nil {
+			return fmt.Errorf("%w: %s", ErrServerPortsUnsupported, server)
+		}
+	}
+	return c.update(func(conf *resolvConf) {
+		conf.setNameservers(servers)
+	})
+}
+
+// ClearDNSServers puts the original file back, or removes the file if
+// there was none, which the backup records as empty; to the resolver an
+// empty file is the same as none. Without a backup, or if another tool
+// has rewritten the file since, the file is left alone.
+func (c *resolvConfClient) ClearDNSServers(service string) error {
+	if err := checkResolvConfService(service); err != nil {
+		return err
+	}
+
+	backup, err := os.ReadFile(c.path(resolvConfBackup))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read resolv.conf backup: %w", err)
+	}
+
+	conf, err := c.read()
+	if err != nil {
+		return err
+	}
+	switch {
+	case !conf.managed():
+	case len(backup) == 0:
+		if err := os.Remove(c.path(resolvConfPath)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove resolv.conf: %w", err)
+		}
+	default:
+		if err := c.write(string(backup)); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(c.path(resolvConfBackup)); err != nil {
+		return fmt.Errorf("failed to remove resolv.conf backup: %w", err)
+	}
+	return nil
+}
+
+// FlushCache does nothing, as there is no caching resolver to flush.
+func (c *resolvConfClient) FlushCache() error {
+	return nil
+}
+
+// GetDomains returns the search domains dnsctl wrote.
+func (c *resolvConfClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	if err := checkResolvConfService(service); err != nil {
+		return Domains{}, err
+	}
+
+	conf, err := c.read()
+	if err != nil {
+		return Domains{}, err
+	}
+	if !conf.managed() {
+		return Domains{}, nil
+	}
+	return Domains{Search: conf.search()}, nil
+}
+
+// SetDomains replaces the search line. resolv.conf cannot route a domain
+// to particular servers, so routing-only domains are not supported.
+func (c *resolvConfClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	if err := checkResolvConfService(service); err != nil {
+		return err
+	}
+	if len(domains.RouteOnly) > 0 {
+		return ErrRouteOnlyDomainsUnsupported
+	}
+	return c.update(func(conf *resolvConf) {
+		conf.setSearch(domains.Search)
+	})
+}
+
+// checkResolvConfService rejects services other than ResolvConfService.
+func checkResolvConfService(service string) error {
+	if service != ResolvConfService {
+		return fmt.Errorf("unknown service %q: resolv.conf only configures %q", service, ResolvConfService)
+	}
+	return nil
+}
+
+// path returns a managed path under the client's root.
+func (c *resolvConfClient) path(name string) string {
+	return filepath.Join(c.root, "/", name)
+}
+
+// read parses the current resolv.conf. A missing file is empty.
+func (c *resolvConfClient) read() (resolvConf, error) {
+	data, err := os.ReadFile(c.path(resolvConfPath))
+	if err != nil && !os.IsNotExist(err) {
+		return resolvConf{}, fmt.Errorf("failed to read resolv.conf: %w", err)
+	}
+	return parseResolvConf(string(data)), nil
+}
+
+// update changes resolv.conf, backing up the original first unless the
+// file is already dnsctl's.
+func (c *resolvConfClient) update(change func(*resolvConf)) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	conf, err := c.read()
+	if err != nil {
+		return err
+	}
+
+	if !conf.managed() {
+		// Another tool may have rewritten the file since the last backup,
+		// so the current file is the one to restore
+		if err := writeFileAtomic(c.path(resolvConfBackup), conf.String()); err != nil {
+			return fmt.Errorf("failed to back up resolv.conf: %w", err)
+		}
+		conf.lines = slices.Insert(conf.lines, 0, resolvConfMarker)
+	}
+
+	change(&conf)
+	return c.write(conf.String())
+}
+
+// probe accepts a resolv.conf that exists and that dnsctl may change.
+func (c *resolvConfClient) probe() error {
+	if _, err := os.Lstat(c.path(resolvConfPath)); err != nil {
+		return err
+	}
+	return c.checkWritable()
+}
+
+// checkWritable refuses to change a resolv.conf that is a symlink, as it
+// then belongs to another tool such as systemd-resolved or resolvconf.
+func (c *resolvConfClient) checkWritable() error {
+	path := c.path(resolvConfPath)
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to inspect resolv.conf: %w", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, _ := os.Readlink(path)
+		return fmt.Errorf("/%s is a symlink to %s managed by another tool; refusing to change it", resolvConfPath, target)
+	}
+	return nil
+}
+
+// write replaces resolv.conf with content.
+func (c *resolvConfClient) write(content string) error {
+	if err := writeFileAtomic(c.path(resolvConfPath), content); err != nil {
+		return fmt.Errorf("failed to write resolv.conf: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces a file by renaming a complete copy over it, so
+// readers never see it half-written. The file keeps its permissions.
+// Containers often bind-mount resolv.conf, which cannot be renamed over;
+// it is then rewritten in place.
+func writeFileAtomic(path, content string) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if errors.Is(err, syscall.EBUSY) {
+		return os.WriteFile(path, []byte(content), perm)
+	}
+	return err
+}
+
+// resolvConf is a parsed resolv.conf that keeps every line, so comments
+// and unknown directives survive a rewrite.
+type resolvConf struct {
+	lines []string
+}
+
+// parseResolvConf splits resolv.conf content into lines.
+func parseResolvConf(content string) resolvConf {
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return resolvConf{}
+	}
+	return resolvConf{lines: strings.Split(content, "\n")}
+}
+
+// String returns the file content.
+func (r resolvConf) String() string {
+	if len(r.lines) == 0 {
+		return ""
+	}
+	return strings.Join(r.lines, "\n") + "\n"
+}
+
+// managed reports whether the file was written by dnsctl.
+func (r resolvConf) managed() bool {
+	return len(r.lines) > 0 && r.lines[0] == resolvConfMarker
+}
+
+// directive returns the keyword and arguments of a line, or "" for
+// comments and blank lines.
+func directive(line string) (string, []string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+		return "", nil
+	}
+	return fields[0], fields[1:]
+}
+
+// nameservers returns the servers of all nameserver lines.
+func (r resolvConf) nameservers() []string {
+	var servers []string
+	for _, line := range r.lines {
+		if keyword, args := directive(line); keyword == "nameserver" && len(args) > 0 {
+			servers = append(servers, args[0])
+		}
+	}
+	return servers
+}
+
+// search returns the search domains. As in the resolver, the last search
+// or domain line wins.
+func (r resolvConf) search() []string {
+	var domains []string
+	for _, line := range r.lines {
+		if keyword, args := directive(line); keyword == "search" || keyword == "domain" {
+			domains = args
+		}
+	}
+	return domains
+}
+
+// setNameservers replaces the nameserver lines.
+func (r *resolvConf) setNameservers(servers []string) {
+	lines := make([]string, len(servers))
+	for i, server := range servers {
+		lines[i] = "nameserver " + server
+	}
+	r.replace(lines, "nameserver")
+}
+
+// setSearch replaces the search and domain lines.
+func (r *resolvConf) setSearch(domains []string) {
+	var lines []string
+	if len(domains) > 0 {
+		lines = []string{"search " + strings.Join(domains, " ")}
+	}
+	r.replace(lines, "search", "domain")
+}
+
+// replace removes every line with one of the keywords and puts
+// replacement where the first of them was, or at the end.
+func (r *resolvConf) replace(replacement []string, keywords ...string) {
+	at := -1
+	var lines []string
+	for _, line := range r.lines {
+		if keyword, _ := directive(line); slices.Contains(keywords, keyword) {
+			if at == -1 {
+				at = len(lines)
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if at == -1 {
+		at = len(lines)
+	}
+	r.lines = slices.Insert(lines, at, replacement...)
+}
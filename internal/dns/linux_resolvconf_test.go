@@ -0,0 +1,302 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resolvConfRoot creates a temp root whose /etc/resolv.conf has content.
+func resolvConfRoot(t *testing.T, content string) (*resolvConfClient, string) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, resolvConfPath), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &resolvConfClient{root: root}, root
+}
+
+// readFile returns a file's content, or "" if it does not exist.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestResolvConf_SetDNSServers tests that nameserver lines are replaced in
+// place, keeping comments and options, and the original is backed up.
+func TestResolvConf_SetDNSServers(t *testing.T) {
+	original := fixture(t, "resolv.conf")
+	client, root := resolvConfRoot(t, original)
+
+	err := client.SetDNSServers(ResolvConfService, []string{"1.1.1.1", "1.0.0.1"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := resolvConfMarker + `
+# Written by the installer
+; keep the local cache first
+nameserver 1.1.1.1
+nameserver 1.0.0.1
+search lan example.com
+options edns0 timeout:2
+`
+	if got := readFile(t, filepath.Join(root, resolvConfPath)); got != want {
+		t.Errorf("unexpected resolv.conf:\n%s", got)
+	}
+	if got := readFile(t, filepath.Join(root, resolvConfBackup)); got != original {
+		t.Errorf("expected the original as backup, got:\n%s", got)
+	}
+
+	servers, err := client.GetDNSServers(ResolvConfService)
+	if err != nil || strings.Join(servers, ",") != "1.1.1.1,1.0.0.1" {
+		t.Errorf("expected the new servers, got %v (%v)", servers, err)
+	}
+}
+
+// TestResolvConf_KeepsFirstBackup tests that later changes do not
+// overwrite the backup of the original file.
+func TestResolvConf_KeepsFirstBackup(t *testing.T) {
+	original := fixture(t, "resolv.conf")
+	client, root := resolvConfRoot(t, original)
+
+	if err := client.SetDNSServers(ResolvConfService, []string{"1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	err := client.SetDNSServers(ResolvConfService, []string{"9.9.9.9"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := readFile(t, filepath.Join(root, resolvConfBackup)); got != original {
+		t.Errorf("expected the original as backup, got:\n%s", got)
+	}
+	if got := readFile(t, filepath.Join(root, resolvConfPath)); strings.Count(got, resolvConfMarker) != 1 {
+		t.Errorf("expected a single marker, got:\n%s", got)
+	}
+}
+
+// TestResolvConf_GetDNSServers_Unmanaged tests that a file dnsctl did not
+// write counts as automatic.
+func TestResolvConf_GetDNSServers_Unmanaged(t *testing.T) {
+	client, _ := resolvConfRoot(t, fixture(t, "resolv.conf"))
+
+	servers, err := client.GetDNSServers(ResolvConfService)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if servers != nil {
+		t.Errorf("expected no servers, got %v", servers)
+	}
+}
+
+// TestResolvConf_ClearDNSServers tests that clearing restores the original.
+func TestResolvConf_ClearDNSServers(t *testing.T) {
+	original := fixture(t, "resolv.conf")
+	client, root := resolvConfRoot(t, original)
+	if err := client.SetDNSServers(ResolvConfService, []string{"1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.ClearDNSServers(ResolvConfService)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := readFile(t, filepath.Join(root, resolvConfPath)); got != original {
+		t.Errorf("expected the original back, got:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, resolvConfBackup)); !os.IsNotExist(err) {
+		t.Errorf("expected the backup to be removed, got: %v", err)
+	}
+}
+
+// TestResolvConf_ClearDNSServers_RewrittenByOthers tests that a file
+// another tool wrote after dnsctl is not replaced by the old backup.
+func TestResolvConf_ClearDNSServers_RewrittenByOthers(t *testing.T) {
+	client, root := resolvConfRoot(t, fixture(t, "resolv.conf"))
+	if err := client.SetDNSServers(ResolvConfService, []string{"1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	dhcp := "nameserver 10.0.0.1\n"
+	if err := os.WriteFile(filepath.Join(root, resolvConfPath), []byte(dhcp), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.ClearDNSServers(ResolvConfService)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := readFile(t, filepath.Join(root, resolvConfPath)); got != dhcp {
+		t.Errorf("expected the other tool's file to stay, got:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, resolvConfBackup)); !os.IsNotExist(err) {
+		t.Errorf("expected the stale backup to be removed, got: %v", err)
+	}
+}
+
+// TestResolvConf_RefusesSymlink tests that a resolv.conf owned by another
+// tool through a symlink is never written.
+func TestResolvConf_RefusesSymlink(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(root, "stub-resolv.conf")
+	if err := os.WriteFile(target, []byte("nameserver 127.0.0.53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(root, resolvConfPath)); err != nil {
+		t.Fatal(err)
+	}
+	client := &resolvConfClient{root: root}
+
+	err := client.SetDNSServers(ResolvConfService, []string{"1.1.1.1"})
+
+	if err == nil || !strings.Contains(err.Error(), "is a symlink to "+target) {
+		t.Errorf("expected symlink error, got: %v", err)
+	}
+	if got := readFile(t, target); got != "nameserver 127.0.0.53\n" {
+		t.Errorf("expected the target to be untouched, got:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, resolvConfBackup)); !os.IsNotExist(err) {
+		t.Errorf("expected no backup, got: %v", err)
+	}
+	if err := client.probe(); err == nil || !strings.Contains(err.Error(), "is a symlink to "+target) {
+		t.Errorf("expected the probe to reject the symlink, got: %v", err)
+	}
+}
+
+// TestResolvConf_Probe tests that a plain resolv.conf is usable and a
+// missing one is not.
+func TestResolvConf_Probe(t *testing.T) {
+	client, _ := resolvConfRoot(t, fixture(t, "resolv.conf"))
+	missing := &resolvConfClient{root: t.TempDir()}
+
+	if err := client.probe(); err != nil {
+		t.Errorf("expected a plain file to be usable, got: %v", err)
+	}
+	if err := missing.probe(); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file to be rejected, got: %v", err)
+	}
+}
+
+// TestResolvConf_Domains tests replacing the search line, including the
+// legacy domain directive.
+func TestResolvConf_Domains(t *testing.T) {
+	client, root := resolvConfRoot(t, "domain old.example.com\nnameserver 192.168.1.1\nsearch lan\n")
+	ctx := context.Background()
+
+	err := client.SetDomains(ctx, ResolvConfService, Domains{Search: []string{"corp.example.com", "example.com"}})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := resolvConfMarker + "\nsearch corp.example.com example.com\nnameserver 192.168.1.1\n"
+	if got := readFile(t, filepath.Join(root, resolvConfPath)); got != want {
+		t.Errorf("unexpected resolv.conf:\n%s", got)
+	}
+	domains, err := client.GetDomains(ctx, ResolvConfService)
+	if err != nil || strings.Join(domains.Search, ",") != "corp.example.com,example.com" {
+		t.Errorf("expected the new domains, got %v (%v)", domains, err)
+	}
+}
+
+// TestResolvConf_RouteOnlyDomains tests that routing-only domains are
+// reported as unsupported.
+func TestResolvConf_RouteOnlyDomains(t *testing.T) {
+	client, _ := resolvConfRoot(t, fixture(t, "resolv.conf"))
+
+	err := client.SetDomains(context.Background(), ResolvConfService, Domains{RouteOnly: []string{"corp"}})
+
+	if !errors.Is(err, ErrRouteOnlyDomainsUnsupported) {
+		t.Errorf("expected ErrRouteOnlyDomainsUnsupported, got: %v", err)
+	}
+}
+
+// TestResolvConf_MissingFile tests that servers can be set when there is
+// no resolv.conf yet.
+func TestResolvConf_MissingFile(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	client := &resolvConfClient{root: root}
+
+	err := client.SetDNSServers(ResolvConfService, []string{"1.1.1.1"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := readFile(t, filepath.Join(root, resolvConfPath)); got != resolvConfMarker+"\nnameserver 1.1.1.1\n" {
+		t.Errorf("unexpected resolv.conf:\n%s", got)
+	}
+}
+
+// TestResolvConf_ClearDNSServers_MissingFile tests that clearing removes
+// the file when there was none before.
+func TestResolvConf_ClearDNSServers_MissingFile(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	client := &resolvConfClient{root: root}
+	if err := client.SetDNSServers(ResolvConfService, []string{"1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.ClearDNSServers(ResolvConfService)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, name := range []string{resolvConfPath, resolvConfBackup} {
+		if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("expected /%s to be removed, got: %v", name, err)
+		}
+	}
+}
+
+// TestResolvConf_ServerPorts tests that servers with a port are rejected,
+// as resolv.conf cannot express them, and zones are kept.
+func TestResolvConf_ServerPorts(t *testing.T) {
+	client, root := resolvConfRoot(t, fixture(t, "resolv.conf"))
+	original := readFile(t, filepath.Join(root, resolvConfPath))
+
+	err := client.SetDNSServers(ResolvConfService, []string{"9.9.9.9", "1.1.1.1:53"})
+
+	if !errors.Is(err, ErrServerPortsUnsupported) || !strings.Contains(err.Error(), "1.1.1.1:53") {
+		t.Errorf("expected ErrServerPortsUnsupported for 1.1.1.1:53, got: %v", err)
+	}
+	if got := readFile(t, filepath.Join(root, resolvConfPath)); got != original {
+		t.Errorf("expected resolv.conf to be unchanged, got:\n%s", got)
+	}
+	if err := client.SetDNSServers(ResolvConfService, []string{"fe80::1%eth0"}); err != nil {
+		t.Errorf("expected a zoned address to be accepted, got: %v", err)
+	}
+}
+
+// TestResolvConf_UnknownService tests that only the system service exists.
+func TestResolvConf_UnknownService(t *testing.T) {
+	client, _ := resolvConfRoot(t, fixture(t, "resolv.conf"))
+
+	err := client.SetDNSServers("eth0", []string{"1.1.1.1"})
+
+	if err == nil || !strings.Contains(err.Error(), `unknown service "eth0"`) {
+		t.Errorf("expected unknown service error, got: %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// systemd-resolved's D-Bus name, manager object and interfaces.
+const (
+	resolve1BusName       = "org.freedesktop.resolve1"
+	resolve1Path          = dbus.ObjectPath("/org/freedesktop/resolve1")
+	resolve1Manager       = "org.freedesktop.resolve1.Manager"
+	resolve1LinkInterface = "org.freedesktop.resolve1.Link"
+)
+
+// Address families as resolved passes them, AF_INET and AF_INET6.
+const (
+	afInet  = 2
+	afInet6 = 10
+)
+
+// arphrdLoopback is the type of loopback interfaces in sys/class/net.
+const arphrdLoopback = "772"
+
+// resolve1Client manages DNS through systemd-resolved's D-Bus API, which
+// "resolvectl" uses too. It makes the same runtime changes as the
+// resolvectl backend without parsing its output.
+type resolve1Client struct {
+	conn *dbus.Conn
+
+	// root is prepended to sys/class/net, which maps interface names to
+	// the indexes resolved uses, so tests can use a temp dir.
+	root string
+
+	// persistent saves changes for the same links, through the network
+	// manager configuring them. It is nil if there is none.
+	persistent Client
+}
+
+// resolve1Address is a DNS server as SetLinkDNS takes it and the DNS link
+// property returns it.
+type resolve1Address struct {
+	Family  int32
+	Address []byte
+}
+
+// resolve1Server is a DNS server as SetLinkDNSEx takes it and the DNSEx
+// link property returns it.
+type resolve1Server struct {
+	Family  int32
+	Address []byte
+	Port    uint16
+	Name    string
+}
+
+// resolve1Domain is a domain as SetLinkDomains takes it and the Domains
+// link property returns it.
+type resolve1Domain struct {
+	Domain    string
+	RouteOnly bool
+}
+
+// newResolvedClient returns a systemd-resolved client that uses D-Bus if
+// it can reach resolved there, or resolvectl otherwise.
+func newResolvedClient(runner cmdexec.Runner, persistent Client) Client {
+	conn, err := connectSystemBus(dbusConnectTimeout, func(ctx context.Context, conn *dbus.Conn) error {
+		return conn.Object(resolve1BusName, resolve1Path).CallWithContext(ctx, "org.freedesktop.DBus.Peer.Ping", 0).Err
+	})
+	if err != nil {
+		return &resolvedClient{runner: runner, persistent: persistent}
+	}
+	return &resolve1Client{conn: conn, root: "/", persistent: persistent}
+}
+
+// Name returns the backend name for display purposes.
+func (c *resolve1Client) Name() string {
+	return "systemd-resolved"
+}
+
+// Persistence returns Runtime, as resolved keeps no configuration.
+func (c *resolve1Client) Persistence() Persistence {
+	return Runtime
+}
+
+// WithPersistence returns c for runtime changes, or the client of the
+// network manager configuring the links for persistent ones.
+func (c *resolve1Client) WithPersistence(p Persistence) (Client, error) {
+	return resolvedWithPersistence(c, c.persistent, p)
+}
+
+// ListNetworkServices returns all network interfaces but loopback.
+func (c *resolve1Client) ListNetworkServices() ([]string, error) {
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns all network interfaces but loopback,
+// which resolved does not configure.
+func (c *resolve1Client) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(c.path(sysClassNet))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	var services []string
+	for _, entry := range entries {
+		kind, _ := os.ReadFile(c.path(sysClassNet, entry.Name(), "type"))
+		if strings.TrimSpace(string(kind)) != arphrdLoopback {
+			services = append(services, entry.Name())
+		}
+	}
+	return services, nil
+}
+
+// DefaultService returns the interface holding the default route.
+func (c *resolve1Client) DefaultService() (string, error) {
+	return defaultRouteInterface()
+}
+
+// GetDNSServers returns the current DNS servers for an interface.
+func (c *resolve1Client) GetDNSServers(service string) ([]string, error) {
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the current DNS servers for an interface,
+// with their port and TLS server name if they have one. resolved before
+// version 246 has no DNSEx property and only reports addresses.
+func (c *resolve1Client) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	link, err := c.link(ctx, "get DNS servers for "+service, service)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []resolve1Server
+	if err := c.property(ctx, link, "DNSEx", &servers); err != nil {
+		var plain []resolve1Address
+		if err := c.property(ctx, link, "DNS", &plain); err != nil {
+			return nil, fmt.Errorf("failed to get DNS servers for %s: %w", service, err)
+		}
+		for _, server := range plain {
+			servers = append(servers, resolve1Server{Family: server.Family, Address: server.Address})
+		}
+	}
+
+	var result []string
+	for _, server := range servers {
+		if s, ok := server.format(); ok {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// HasManualDNS reports whether the servers of an interface were set
+// manually rather than by its network manager.
+func (c *resolve1Client) HasManualDNS(ctx context.Context, service string) (bool, error) {
+	servers, err := c.GetDNSServersContext(ctx, service)
+	if err != nil {
+		return false, err
+	}
+	return resolvedHasManualDNS(ctx, c.persistent, service, servers)
+}
+
+// SetDNSServers sets the DNS servers for an interface.
+func (c *resolve1Client) SetDNSServers(service string, servers []string) error {
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext sets the DNS servers for an interface. Servers take
+// the form resolvectl accepts: an address with an optional port and TLS
+// server name, as in "1.1.1.1:853#cloudflare-dns.com". Plain addresses
+// use SetLinkDNS, which every resolved version has.
+func (c *resolve1Client) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	index, err := c.index(service)
+	if err != nil {
+		return err
+	}
+
+	parsed := make([]resolve1Server, 0, len(servers))
+	extended := false
+	for _, s := range servers {
+		server, err := parseResolve1Server(s)
+		if err != nil {
+			return err
+		}
+		extended = extended || server.Port != 0 || server.Name != ""
+		parsed = append(parsed, server)
+	}
+
+	if extended {
+		return c.call(ctx, "set DNS servers", "SetLinkDNSEx", index, parsed)
+	}
+	plain := make([]resolve1Address, len(parsed))
+	for i, server := range parsed {
+		plain[i] = resolve1Address{Family: server.Family, Address: server.Address}
+	}
+	return c.call(ctx, "set DNS servers", "SetLinkDNS", index, plain)
+}
+
+// GetDomains returns the search and routing domains for an interface.
+func (c *resolve1Client) GetDomains(ctx context.Context, service string) (Domains, error) {
+	link, err := c.link(ctx, "get domains for "+service, service)
+	if err != nil {
+		return Domains{}, err
+	}
+
+	var entries []resolve1Domain
+	if err := c.property(ctx, link, "Domains", &entries); err != nil {
+		return Domains{}, fmt.Errorf("failed to get domains for %s: %w", service, err)
+	}
+
+	var domains Domains
+	for _, entry := range entries {
+		if entry.RouteOnly {
+			domains.RouteOnly = append(domains.RouteOnly, entry.Domain)
+		} else {
+			domains.Search = append(domains.Search, entry.Domain)
+		}
+	}
+	return domains, nil
+}
+
+// SetDomains sets the search and routing domains for an interface.
+func (c *resolve1Client) SetDomains(ctx context.Context, service string, domains Domains) error {
+	index, err := c.index(service)
+	if err != nil {
+		return err
+	}
+
+	entries := []resolve1Domain{}
+	for _, domain := range domains.Search {
+		entries = append(entries, resolve1Domain{Domain: domain})
+	}
+	for _, domain := range domains.RouteOnly {
+		entries = append(entries, resolve1Domain{Domain: domain, RouteOnly: true})
+	}
+	return c.call(ctx, "set domains", "SetLinkDomains", index, entries)
+}
+
+// ClearDNSServers clears DNS servers and domains, reverting to defaults.
+func (c *resolve1Client) ClearDNSServers(service string) error {
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext clears DNS servers and domains, reverting to defaults.
+func (c *resolve1Client) ClearDNSServersContext(ctx context.Context, service string) error {
+	index, err := c.index(service)
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, "clear DNS servers", "RevertLink", index)
+}
+
+// FlushCache flushes the DNS cache.
+func (c *resolve1Client) FlushCache() error {
+	return c.FlushCacheContext(context.Background())
+}
+
+// FlushCacheContext flushes the DNS cache.
+func (c *resolve1Client) FlushCacheContext(ctx context.Context) error {
+	return c.call(ctx, "flush DNS cache", "FlushCaches")
+}
+
+// path returns a path under the client's root.
+func (c *resolve1Client) path(name ...string) string {
+	return filepath.Join(append([]string{c.root, "/"}, name...)...)
+}
+
+// index returns the index of an interface.
+func (c *resolve1Client) index(service string) (int32, error) {
+	if err := checkInterfaceName(service); err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(c.path(sysClassNet, service, "ifindex"))
+	if err != nil {
+		return 0, fmt.Errorf("unknown interface %q", service)
+	}
+	index, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index of interface %q: %w", service, err)
+	}
+	return int32(index), nil
+}
+
+// link returns the path of resolved's object for an interface.
+func (c *resolve1Client) link(ctx context.Context, action, service string) (dbus.ObjectPath, error) {
+	index, err := c.index(service)
+	if err != nil {
+		return "", err
+	}
+
+	var link dbus.ObjectPath
+	if err := c.conn.Object(resolve1BusName, resolve1Path).
+		CallWithContext(ctx, resolve1Manager+".GetLink", 0, index).Store(&link); err != nil {
+		return "", fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return link, nil
+}
+
+// call calls a method of resolved's manager that changes its state. Such
+// calls let polkit ask the user to authorize them, as resolvectl does.
+// The message is built here because Object.Call drops that flag.
+func (c *resolve1Client) call(ctx context.Context, action, method string, args ...any) error {
+	msg := &dbus.Message{
+		Type:  dbus.TypeMethodCall,
+		Flags: dbus.FlagAllowInteractiveAuthorization,
+		Headers: map[dbus.HeaderField]dbus.Variant{
+			dbus.FieldPath:        dbus.MakeVariant(resolve1Path),
+			dbus.FieldDestination: dbus.MakeVariant(resolve1BusName),
+			dbus.FieldInterface:   dbus.MakeVariant(resolve1Manager),
+			dbus.FieldMember:      dbus.MakeVariant(method),
+		},
+		Body: args,
+	}
+	if len(args) > 0 {
+		msg.Headers[dbus.FieldSignature] = dbus.MakeVariant(dbus.SignatureOf(args...))
+	}
+
+	if err := (<-c.conn.SendWithContext(ctx, msg, make(chan *dbus.Call, 1)).Done).Err; err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return nil
+}
+
+// property reads a property of one of resolved's links into value.
+func (c *resolve1Client) property(ctx context.Context, link dbus.ObjectPath, name string, value any) error {
+	var variant dbus.Variant
+	if err := c.conn.Object(resolve1BusName, link).
+		CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, resolve1LinkInterface, name).Store(&variant); err != nil {
+		return err
+	}
+	return variant.Store(value)
+}
+
+// parseResolve1Server parses a server in resolvectl's "address[:port][#name]"
+// form, where IPv6 addresses with a port are in brackets.
+func parseResolve1Server(s string) (resolve1Server, error) {
+	address, name, _ := strings.Cut(s, "#")
+
+	var server resolve1Server
+	if addrPort, err := netip.ParseAddrPort(address); err == nil {
+		server.Port = addrPort.Port()
+		address = addrPort.Addr().String()
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return resolve1Server{}, fmt.Errorf("invalid DNS server %q", s)
+	}
+
+	addr = addr.Unmap()
+	if addr.Is4() {
+		server.Family = afInet
+	} else {
+		server.Family = afInet6
+	}
+	server.Address = addr.AsSlice()
+	server.Name = name
+	return server, nil
+}
+
+// format returns the server in resolvectl's form, and false if its
+// address is invalid.
+func (s resolve1Server) format() (string, bool) {
+	addr, ok := netip.AddrFromSlice(s.Address)
+	if !ok || (s.Family == afInet) != addr.Is4() {
+		return "", false
+	}
+
+	result := addr.String()
+	if s.Port != 0 {
+		result = netip.AddrPortFrom(addr, s.Port).String()
+	}
+	if s.Name != "" {
+		result += "#" + s.Name
+	}
+	return result, true
+}
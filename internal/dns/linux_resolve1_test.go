@@ -0,0 +1,384 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// fakeResolve1 is a fake systemd-resolved with links 2 and 3. It records
+// the manager methods called, and serves each link's properties.
+type fakeResolve1 struct {
+	mu    sync.Mutex
+	calls []string
+
+	servers map[int32][]resolve1Server
+	domains map[int32][]resolve1Domain
+
+	// legacy drops the DNSEx property, as before resolved 246.
+	legacy bool
+}
+
+// authorize rejects a change the caller does not let polkit ask the user
+// about, as resolved does for unprivileged callers.
+func authorize(msg dbus.Message) *dbus.Error {
+	if msg.Flags&dbus.FlagAllowInteractiveAuthorization == 0 {
+		return dbus.NewError("org.freedesktop.DBus.Error.InteractiveAuthorizationRequired", []any{"Interactive authentication required."})
+	}
+	return nil
+}
+
+// record notes a method call.
+func (r *fakeResolve1) record(format string, args ...any) {
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+}
+
+// GetLink returns the path of a link.
+func (r *fakeResolve1) GetLink(index int32) (dbus.ObjectPath, *dbus.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.servers[index]; !ok {
+		return "", dbus.NewError("org.freedesktop.resolve1.NoSuchLink", []any{fmt.Sprintf("Link %d not known", index)})
+	}
+	return dbus.ObjectPath(fmt.Sprintf("%s/link/_3%d", resolve1Path, index)), nil
+}
+
+// SetLinkDNS replaces a link's servers.
+func (r *fakeResolve1) SetLinkDNS(msg dbus.Message, index int32, addresses []resolve1Address) *dbus.Error {
+	if err := authorize(msg); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record("SetLinkDNS %d", index)
+	r.servers[index] = nil
+	for _, address := range addresses {
+		r.servers[index] = append(r.servers[index], resolve1Server{Family: address.Family, Address: address.Address})
+	}
+	return nil
+}
+
+// SetLinkDNSEx replaces a link's servers, with ports and names.
+func (r *fakeResolve1) SetLinkDNSEx(msg dbus.Message, index int32, servers []resolve1Server) *dbus.Error {
+	if err := authorize(msg); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record("SetLinkDNSEx %d", index)
+	r.servers[index] = servers
+	return nil
+}
+
+// SetLinkDomains replaces a link's domains.
+func (r *fakeResolve1) SetLinkDomains(msg dbus.Message, index int32, domains []resolve1Domain) *dbus.Error {
+	if err := authorize(msg); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record("SetLinkDomains %d", index)
+	r.domains[index] = domains
+	return nil
+}
+
+// RevertLink drops a link's servers and domains.
+func (r *fakeResolve1) RevertLink(msg dbus.Message, index int32) *dbus.Error {
+	if err := authorize(msg); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record("RevertLink %d", index)
+	r.servers[index], r.domains[index] = nil, nil
+	return nil
+}
+
+// FlushCaches fails, as resolved does for unprivileged callers.
+func (r *fakeResolve1) FlushCaches() *dbus.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record("FlushCaches")
+	return dbus.NewError("org.freedesktop.DBus.Error.InteractiveAuthorizationRequired", []any{"Interactive authentication required."})
+}
+
+// methods returns the manager methods called.
+func (r *fakeResolve1) methods() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.calls, "; ")
+}
+
+// fakeResolve1Link serves the properties of one link of a fakeResolve1.
+type fakeResolve1Link struct {
+	resolved *fakeResolve1
+	index    int32
+}
+
+// Get returns a property of the link.
+func (l fakeResolve1Link) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	r := l.resolved
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case iface != resolve1LinkInterface:
+	case name == "DNSEx" && !r.legacy:
+		return dbus.MakeVariant(append([]resolve1Server{}, r.servers[l.index]...)), nil
+	case name == "DNS":
+		addresses := []resolve1Address{}
+		for _, server := range r.servers[l.index] {
+			addresses = append(addresses, resolve1Address{Family: server.Family, Address: server.Address})
+		}
+		return dbus.MakeVariant(addresses), nil
+	case name == "Domains":
+		return dbus.MakeVariant(append([]resolve1Domain{}, r.domains[l.index]...)), nil
+	}
+	return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []any{"Unknown property " + name})
+}
+
+// resolve1Bus serves a fake resolved on a private bus and returns a client
+// connected to it, under a root where lo is index 1, eth0 index 2 and
+// wlan0 index 3.
+func resolve1Bus(t *testing.T) (*resolve1Client, *fakeResolve1) {
+	t.Helper()
+	address := startBus(t)
+	server := connectBus(t, address)
+
+	resolved := &fakeResolve1{
+		servers: map[int32][]resolve1Server{2: nil, 3: nil},
+		domains: map[int32][]resolve1Domain{},
+	}
+	if err := server.Export(resolved, resolve1Path, resolve1Manager); err != nil {
+		t.Fatal(err)
+	}
+	for index := range resolved.servers {
+		link := fakeResolve1Link{resolved: resolved, index: index}
+		path := dbus.ObjectPath(fmt.Sprintf("%s/link/_3%d", resolve1Path, index))
+		if err := server.Export(link, path, "org.freedesktop.DBus.Properties"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reply, err := server.RequestName(resolve1BusName, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to own %s: %v", resolve1BusName, err)
+	}
+
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"sys/class/net/lo/ifindex":    "1\n",
+		"sys/class/net/lo/type":       "772\n",
+		"sys/class/net/eth0/ifindex":  "2\n",
+		"sys/class/net/eth0/type":     "1\n",
+		"sys/class/net/wlan0/ifindex": "3\n",
+		"sys/class/net/wlan0/type":    "1\n",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &resolve1Client{conn: connectBus(t, address), root: root}, resolved
+}
+
+// TestResolve1_ListNetworkServices tests that every interface but loopback
+// is listed.
+func TestResolve1_ListNetworkServices(t *testing.T) {
+	client, _ := resolve1Bus(t)
+
+	services, err := client.ListNetworkServices()
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(services, ",") != "eth0,wlan0" {
+		t.Errorf("expected [eth0 wlan0], got %v", services)
+	}
+}
+
+// TestResolve1_SetDNSServers tests that plain addresses are set by index
+// with SetLinkDNS and read back.
+func TestResolve1_SetDNSServers(t *testing.T) {
+	client, resolved := resolve1Bus(t)
+
+	err := client.SetDNSServers("wlan0", []string{"1.1.1.1", "2606:4700:4700::1111"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := resolved.methods(); got != "SetLinkDNS 3" {
+		t.Errorf("expected SetLinkDNS on link 3, got: %s", got)
+	}
+	servers, err := client.GetDNSServers("wlan0")
+	if err != nil || strings.Join(servers, ",") != "1.1.1.1,2606:4700:4700::1111" {
+		t.Errorf("expected the new servers, got %v (%v)", servers, err)
+	}
+}
+
+// TestResolve1_SetDNSServers_Extended tests that servers with a port or
+// TLS server name use SetLinkDNSEx and keep them.
+func TestResolve1_SetDNSServers_Extended(t *testing.T) {
+	client, resolved := resolve1Bus(t)
+	want := []string{"1.1.1.1:853#cloudflare-dns.com", "[2606:4700:4700::1111]:853", "9.9.9.9"}
+
+	err := client.SetDNSServers("eth0", want)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := resolved.methods(); got != "SetLinkDNSEx 2" {
+		t.Errorf("expected SetLinkDNSEx on link 2, got: %s", got)
+	}
+	servers, err := client.GetDNSServers("eth0")
+	if err != nil || strings.Join(servers, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v (%v)", want, servers, err)
+	}
+}
+
+// TestResolve1_GetDNSServers_Legacy tests reading the DNS property when
+// resolved has no DNSEx.
+func TestResolve1_GetDNSServers_Legacy(t *testing.T) {
+	client, resolved := resolve1Bus(t)
+	if err := client.SetDNSServers("eth0", []string{"192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	resolved.mu.Lock()
+	resolved.legacy = true
+	resolved.mu.Unlock()
+
+	servers, err := client.GetDNSServers("eth0")
+
+	if err != nil || strings.Join(servers, ",") != "192.168.1.1" {
+		t.Errorf("expected [192.168.1.1], got %v (%v)", servers, err)
+	}
+}
+
+// TestResolve1_InvalidServer tests that invalid servers are rejected
+// before resolved is called.
+func TestResolve1_InvalidServer(t *testing.T) {
+	client, resolved := resolve1Bus(t)
+
+	err := client.SetDNSServers("eth0", []string{"1.1.1.1", "dns.example.com"})
+
+	if err == nil || !strings.Contains(err.Error(), `invalid DNS server "dns.example.com"`) {
+		t.Errorf("expected invalid server error, got: %v", err)
+	}
+	if got := resolved.methods(); got != "" {
+		t.Errorf("expected no calls, got: %s", got)
+	}
+}
+
+// TestResolve1_Domains tests that search and routing-only domains are set
+// and read back.
+func TestResolve1_Domains(t *testing.T) {
+	client, _ := resolve1Bus(t)
+	ctx := context.Background()
+	want := Domains{Search: []string{"corp.example.com"}, RouteOnly: []string{"internal"}}
+
+	err := client.SetDomains(ctx, "eth0", want)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	domains, err := client.GetDomains(ctx, "eth0")
+	if err != nil || !domains.Equal(want) {
+		t.Errorf("expected %v, got %v (%v)", want, domains, err)
+	}
+}
+
+// TestResolve1_ClearDNSServers tests that clearing reverts the link.
+func TestResolve1_ClearDNSServers(t *testing.T) {
+	client, resolved := resolve1Bus(t)
+	if err := client.SetDNSServers("eth0", []string{"1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.ClearDNSServers("eth0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := resolved.methods(); got != "SetLinkDNS 2; RevertLink 2" {
+		t.Errorf("unexpected calls: %s", got)
+	}
+	servers, err := client.GetDNSServers("eth0")
+	if err != nil || servers != nil {
+		t.Errorf("expected no servers, got %v (%v)", servers, err)
+	}
+}
+
+// TestResolve1_FlushCache_Error tests that resolved's error is part of
+// the error.
+func TestResolve1_FlushCache_Error(t *testing.T) {
+	client, _ := resolve1Bus(t)
+
+	err := client.FlushCache()
+
+	if err == nil || !strings.Contains(err.Error(), "failed to flush DNS cache: Interactive authentication required.") {
+		t.Errorf("expected resolved's error, got: %v", err)
+	}
+}
+
+// TestResolve1_UnknownInterface tests that interfaces are mapped to
+// indexes, and unknown ones are rejected.
+func TestResolve1_UnknownInterface(t *testing.T) {
+	client, resolved := resolve1Bus(t)
+
+	err := client.SetDNSServers("tun0", []string{"1.1.1.1"})
+	_, loErr := client.GetDNSServers("lo")
+
+	if err == nil || !strings.Contains(err.Error(), `unknown interface "tun0"`) {
+		t.Errorf("expected unknown interface error, got: %v", err)
+	}
+	if loErr == nil || !strings.Contains(loErr.Error(), "failed to get DNS servers for lo: Link 1 not known") {
+		t.Errorf("expected resolved's error, got: %v", loErr)
+	}
+	if got := resolved.methods(); got != "" {
+		t.Errorf("expected no calls, got: %s", got)
+	}
+}
+
+// TestNewResolvedClient tests that resolved is used over D-Bus when it is
+// on the system bus, and through resolvectl when the bus is unavailable.
+func TestNewResolvedClient(t *testing.T) {
+	bus := startBus(t)
+	empty := startBus(t)
+	runner := cmdexectest.NewRunner()
+
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", bus)
+	resolved := connectBus(t, bus)
+	if err := resolved.Export(&fakeResolve1{}, resolve1Path, resolve1Manager); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolved.RequestName(resolve1BusName, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+	onBus := newResolvedClient(runner, nil)
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", empty)
+	notOnBus := newResolvedClient(runner, nil)
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", "unix:path="+filepath.Join(t.TempDir(), "missing"))
+	noBus := newResolvedClient(runner, nil)
+
+	if _, ok := onBus.(*resolve1Client); !ok {
+		t.Errorf("expected the D-Bus client, got %T", onBus)
+	}
+	if _, ok := notOnBus.(*resolvedClient); !ok {
+		t.Errorf("expected the resolvectl client without resolved on the bus, got %T", notOnBus)
+	}
+	if _, ok := noBus.(*resolvedClient); !ok {
+		t.Errorf("expected the resolvectl client without a bus, got %T", noBus)
+	}
+}
@@ -4,29 +4,99 @@ package dns
 
 import (
 	"bufio"
+	"context"
 	"fmt"
-	"os/exec"
 	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
 )
 
-// resolvedClient provides DNS management via systemd-resolved.
-type resolvedClient struct{}
+// resolvedClient provides DNS management via systemd-resolved. Its
+// changes only last until the link is reconfigured or the system reboots.
+type resolvedClient struct {
+	runner cmdexec.Runner
+
+	// persistent saves changes for the same links, through the network
+	// manager configuring them. It is nil if there is none.
+	persistent Client
+}
 
 // Name returns the backend name for display purposes.
 func (c *resolvedClient) Name() string {
 	return "systemd-resolved"
 }
 
+// Persistence returns Runtime, as resolved keeps no configuration.
+func (c *resolvedClient) Persistence() Persistence {
+	return Runtime
+}
+
+// WithPersistence returns c for runtime changes, or the client of the
+// network manager configuring the links for persistent ones.
+func (c *resolvedClient) WithPersistence(p Persistence) (Client, error) {
+	return resolvedWithPersistence(c, c.persistent, p)
+}
+
+// resolvedWithPersistence returns runtime, a systemd-resolved client, for
+// runtime changes, or persistent for persistent ones.
+func resolvedWithPersistence(runtime, persistent Client, p Persistence) (Client, error) {
+	if p == Runtime {
+		return runtime, nil
+	}
+	if persistent == nil {
+		return nil, fmt.Errorf("%w: neither NetworkManager nor systemd-networkd manages the links of systemd-resolved", ErrPersistenceUnsupported)
+	}
+	return persistent, nil
+}
+
+// automaticServers is implemented by the network managers that configure
+// the links of systemd-resolved.
+type automaticServers interface {
+	// automaticDNSServers returns the servers the manager gives a link,
+	// which it learned from DHCP or has configured, and which the link
+	// goes back to when its runtime DNS is reverted.
+	automaticDNSServers(ctx context.Context, service string) ([]string, error)
+}
+
+// resolvedHasManualDNS reports whether servers, as resolved reports them
+// for a link, were set manually: they were unless the link's network
+// manager configured the same ones. Without a manager, resolved only has
+// servers that were set at runtime.
+func resolvedHasManualDNS(ctx context.Context, persistent Client, service string, servers []string) (bool, error) {
+	if len(servers) == 0 {
+		return false, nil
+	}
+	manager, ok := persistent.(automaticServers)
+	if !ok {
+		return true, nil
+	}
+
+	automatic, err := manager.automaticDNSServers(ctx, service)
+	if err != nil {
+		return false, err
+	}
+	return !equalUnordered(servers, automatic), nil
+}
+
 // ListNetworkServices returns all available network interfaces.
 func (c *resolvedClient) ListNetworkServices() ([]string, error) {
-	cmd := exec.Command("resolvectl", "status")
-	output, err := cmd.Output()
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns all available network interfaces.
+func (c *resolvedClient) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	result, err := run(ctx, c.runner, "list network services", "resolvectl", "status")
 	if err != nil {
-		return nil, fmt.Errorf("failed to list network services: %w", err)
+		return nil, err
 	}
 
+	return parseResolvectlLinks(string(result.Stdout))
+}
+
+// parseResolvectlLinks extracts interface names from "resolvectl status" output.
+func parseResolvectlLinks(output string) ([]string, error) {
 	var interfaces []string
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -49,64 +119,122 @@ func (c *resolvedClient) ListNetworkServices() ([]string, error) {
 	return interfaces, nil
 }
 
+// DefaultService returns the interface holding the default route.
+func (c *resolvedClient) DefaultService() (string, error) {
+	return defaultRouteInterface()
+}
+
 // GetDNSServers returns the current DNS servers for an interface.
 func (c *resolvedClient) GetDNSServers(service string) ([]string, error) {
-	cmd := exec.Command("resolvectl", "dns", service)
-	output, err := cmd.Output()
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the current DNS servers for an interface.
+func (c *resolvedClient) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	result, err := run(ctx, c.runner, "get DNS servers for "+service, "resolvectl", "dns", service)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get DNS servers for %s: %w", service, err)
+		return nil, err
 	}
 
-	text := strings.TrimSpace(string(output))
+	return parseResolvectlDNS(string(result.Stdout)), nil
+}
+
+// parseResolvectlDNS parses "resolvectl dns <link>" output.
+func parseResolvectlDNS(output string) []string {
+	text := strings.TrimSpace(output)
 	if text == "" {
-		return nil, nil
+		return nil
 	}
 
 	// Output format: "Link 2 (eth0): 8.8.8.8 8.8.4.4"
-	// Find the colon and parse servers after it
-	colonIdx := strings.Index(text, ":")
+	// Find the colon after the link name and parse servers after it.
+	// IPv6 servers contain colons too, so prefer the "):" separator.
+	colonIdx := strings.Index(text, "):")
+	if colonIdx != -1 {
+		colonIdx++
+	} else {
+		colonIdx = strings.Index(text, ":")
+	}
 	if colonIdx == -1 {
-		return nil, nil
+		return nil
 	}
 
 	serverPart := strings.TrimSpace(text[colonIdx+1:])
 	if serverPart == "" {
-		return nil, nil
+		return nil
 	}
 
-	servers := strings.Fields(serverPart)
-	return servers, nil
+	return strings.Fields(serverPart)
+}
+
+// HasManualDNS reports whether the servers of an interface were set
+// manually rather than by its network manager.
+func (c *resolvedClient) HasManualDNS(ctx context.Context, service string) (bool, error) {
+	servers, err := c.GetDNSServersContext(ctx, service)
+	if err != nil {
+		return false, err
+	}
+	return resolvedHasManualDNS(ctx, c.persistent, service, servers)
 }
 
 // SetDNSServers sets the DNS servers for an interface.
 func (c *resolvedClient) SetDNSServers(service string, servers []string) error {
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext sets the DNS servers for an interface.
+func (c *resolvedClient) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
 	args := []string{"dns", service}
 	args = append(args, servers...)
 
-	cmd := exec.Command("resolvectl", args...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set DNS servers: %s: %w", string(output), err)
+	_, err := run(ctx, c.runner, "set DNS servers", "resolvectl", args...)
+	return err
+}
+
+// GetDomains returns the search and routing domains for an interface.
+func (c *resolvedClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	result, err := run(ctx, c.runner, "get domains for "+service, "resolvectl", "domain", service)
+	if err != nil {
+		return Domains{}, err
 	}
 
-	return nil
+	// "resolvectl domain" uses the same "Link 3 (wlan0): a ~b" layout
+	return ParseDomainEntries(parseResolvectlDNS(string(result.Stdout))), nil
 }
 
-// ClearDNSServers clears DNS servers, reverting to defaults.
-func (c *resolvedClient) ClearDNSServers(service string) error {
-	cmd := exec.Command("resolvectl", "revert", service)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to clear DNS servers: %s: %w", string(output), err)
+// SetDomains sets the search and routing domains for an interface.
+// Routing-only domains are passed with a "~" prefix.
+func (c *resolvedClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	args := []string{"domain", service}
+	if domains.IsEmpty() {
+		// An empty argument resets the list
+		args = append(args, "")
+	} else {
+		args = append(args, domains.Entries()...)
 	}
 
-	return nil
+	_, err := run(ctx, c.runner, "set domains", "resolvectl", args...)
+	return err
+}
+
+// ClearDNSServers clears DNS servers and domains, reverting to defaults.
+func (c *resolvedClient) ClearDNSServers(service string) error {
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext clears DNS servers and domains, reverting to defaults.
+func (c *resolvedClient) ClearDNSServersContext(ctx context.Context, service string) error {
+	_, err := run(ctx, c.runner, "clear DNS servers", "resolvectl", "revert", service)
+	return err
 }
 
 // FlushCache flushes the DNS cache.
 func (c *resolvedClient) FlushCache() error {
-	cmd := exec.Command("resolvectl", "flush-caches")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to flush DNS cache: %s: %w", string(output), err)
-	}
+	return c.FlushCacheContext(context.Background())
+}
 
-	return nil
+// FlushCacheContext flushes the DNS cache.
+func (c *resolvedClient) FlushCacheContext(ctx context.Context) error {
+	_, err := run(ctx, c.runner, "flush DNS cache", "resolvectl", "flush-caches")
+	return err
 }
@@ -0,0 +1,202 @@
+//go:build linux
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// TestResolved_ListNetworkServices_MultiLink tests parsing multi-link status output.
+func TestResolved_ListNetworkServices_MultiLink(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("resolvectl status", fixture(t, "resolvectl_status.txt"))
+	client := &resolvedClient{runner: runner}
+
+	services, err := client.ListNetworkServices()
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []string{"enp0s31f6", "wlan0", "docker0", "tailscale0"}
+	if strings.Join(services, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, services)
+	}
+}
+
+// TestResolved_ListNetworkServices_Error tests that command failures are reported.
+func TestResolved_ListNetworkServices_Error(t *testing.T) {
+	runner := cmdexectest.NewRunner().Fail("resolvectl status", 1, "Failed to get global data: Unit dbus-org.freedesktop.resolve1.service not found.")
+	client := &resolvedClient{runner: runner}
+
+	_, err := client.ListNetworkServices()
+
+	if err == nil || !strings.Contains(err.Error(), "failed to list network services: Failed to get global data") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestResolved_GetDNSServers tests parsing servers, including scoped IPv6 addresses.
+func TestResolved_GetDNSServers(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("resolvectl dns enp0s31f6", fixture(t, "resolvectl_dns_enp0s31f6.txt"))
+	client := &resolvedClient{runner: runner}
+
+	servers, err := client.GetDNSServers("enp0s31f6")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(servers) != 2 || servers[0] != "192.168.1.1" || servers[1] != "fe80::1%2" {
+		t.Errorf("expected [192.168.1.1 fe80::1%%2], got %v", servers)
+	}
+}
+
+// TestResolved_GetDNSServers_Empty tests a link without servers.
+func TestResolved_GetDNSServers_Empty(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("resolvectl dns wlan0", fixture(t, "resolvectl_dns_wlan0.txt"))
+	client := &resolvedClient{runner: runner}
+
+	servers, err := client.GetDNSServers("wlan0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if servers != nil {
+		t.Errorf("expected no servers, got %v", servers)
+	}
+}
+
+// TestResolved_SetDNSServers tests the resolvectl arguments used to set DNS.
+func TestResolved_SetDNSServers(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("resolvectl dns wlan0 1.1.1.1 1.0.0.1", "")
+	client := &resolvedClient{runner: runner}
+
+	if err := client.SetDNSServers("wlan0", []string{"1.1.1.1", "1.0.0.1"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, "resolvectl dns wlan0 1.1.1.1 1.0.0.1")
+}
+
+// TestResolved_ClearDNSServers tests that clearing reverts the link.
+func TestResolved_ClearDNSServers(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("resolvectl revert wlan0", "")
+	client := &resolvedClient{runner: runner}
+
+	if err := client.ClearDNSServers("wlan0"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner, "resolvectl revert wlan0")
+}
+
+// TestResolved_GetDomains tests parsing search and routing domains.
+func TestResolved_GetDomains(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("resolvectl domain wlan0", fixture(t, "resolvectl_domain_wlan0.txt"))
+	client := &resolvedClient{runner: runner}
+
+	domains, err := client.GetDomains(context.Background(), "wlan0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(domains.Search, ",") != "corp.example.com" {
+		t.Errorf("expected search domains, got %v", domains.Search)
+	}
+	if strings.Join(domains.RouteOnly, ",") != "internal.example.com,." {
+		t.Errorf("expected routing domains, got %v", domains.RouteOnly)
+	}
+}
+
+// TestResolved_SetDomains tests routing domains are passed with a "~" prefix.
+func TestResolved_SetDomains(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Expect("resolvectl domain wlan0 corp.example.com ~internal.example.com", "").
+		Expect("resolvectl domain wlan0 ", "")
+	client := &resolvedClient{runner: runner}
+
+	if err := client.SetDomains(context.Background(), "wlan0", Domains{
+		Search:    []string{"corp.example.com"},
+		RouteOnly: []string{"internal.example.com"},
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := client.SetDomains(context.Background(), "wlan0", Domains{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner,
+		"resolvectl domain wlan0 corp.example.com ~internal.example.com",
+		"resolvectl domain wlan0 ",
+	)
+}
+
+// TestResolved_FlushCache tests flushing the resolved cache.
+func TestResolved_FlushCache(t *testing.T) {
+	runner := cmdexectest.NewRunner().Fail("resolvectl flush-caches", 1, "Access denied")
+	client := &resolvedClient{runner: runner}
+
+	err := client.FlushCache()
+
+	if err == nil || !strings.Contains(err.Error(), "failed to flush DNS cache: Access denied") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestResolved_HasManualDNS tests that servers count as manual unless the
+// link's network manager gave the same ones.
+func TestResolved_HasManualDNS(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers string
+		manager bool
+		want    bool
+	}{
+		{"from networkd", "192.168.1.1", true, false},
+		{"set at runtime", "1.1.1.1", true, true},
+		{"no manager", "192.168.1.1", false, true},
+		{"no servers", "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networkd, runner, _ := networkdRoot(t)
+			runner.Expect("resolvectl dns eth0", "Link 2 (eth0): "+tt.servers+"\n")
+			client := &resolvedClient{runner: runner}
+			if tt.manager {
+				client.persistent = networkd
+			}
+
+			manual, err := client.HasManualDNS(context.Background(), "eth0")
+
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if manual != tt.want {
+				t.Errorf("expected manual %v, got %v", tt.want, manual)
+			}
+		})
+	}
+}
+
+// TestResolved_WithPersistence tests that persistent changes go to the
+// network manager of the links, if there is one.
+func TestResolved_WithPersistence(t *testing.T) {
+	runner := cmdexectest.NewRunner()
+	networkd := &networkdClient{runner: runner}
+	client := &resolvedClient{runner: runner, persistent: networkd}
+
+	runtime, runtimeErr := WithPersistence(client, Runtime)
+	persistent, persistentErr := WithPersistence(client, Persistent)
+	_, unsupportedErr := WithPersistence(&resolvedClient{runner: runner}, Persistent)
+
+	if runtimeErr != nil || runtime != client {
+		t.Errorf("expected resolved for runtime changes, got %v (%v)", runtime, runtimeErr)
+	}
+	if persistentErr != nil || persistent != networkd {
+		t.Errorf("expected networkd for persistent changes, got %v (%v)", persistent, persistentErr)
+	}
+	if !errors.Is(unsupportedErr, ErrPersistenceUnsupported) {
+		t.Errorf("expected ErrPersistenceUnsupported, got: %v", unsupportedErr)
+	}
+}
@@ -0,0 +1,112 @@
+//go:build linux
+
+package dns
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcNetRoute is the kernel's IPv4 routing table.
+const ProcNetRoute = "/proc/net/route"
+
+// Route flags from /proc/net/route.
+const (
+	rtfUp      = 0x1
+	rtfGateway = 0x2
+)
+
+// DefaultRoute is an IPv4 default route from /proc/net/route.
+type DefaultRoute struct {
+	Interface string
+	// Gateway is the next hop, or the zero Addr for a route without
+	// RTF_GATEWAY such as a point-to-point link.
+	Gateway netip.Addr
+	Metric  uint64
+}
+
+// defaultRouteInterface returns the interface holding the IPv4 default route.
+func defaultRouteInterface() (string, error) {
+	f, err := os.Open(ProcNetRoute)
+	if err != nil {
+		return "", fmt.Errorf("failed to read routing table: %w", err)
+	}
+	defer f.Close()
+
+	routes, err := ParseDefaultRoutes(f)
+	if err != nil || len(routes) == 0 {
+		return "", err
+	}
+	return routes[0].Interface, nil
+}
+
+// ParseDefaultRoutes parses /proc/net/route content and returns the
+// default routes that are up, lowest metric first.
+func ParseDefaultRoutes(r io.Reader) ([]DefaultRoute, error) {
+	scanner := bufio.NewScanner(r)
+
+	var routes []DefaultRoute
+	header := true
+
+	for scanner.Scan() {
+		// Skip the column header line
+		if header {
+			header = false
+			continue
+		}
+
+		// Columns: Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			continue
+		}
+
+		metric, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		route := DefaultRoute{Interface: fields[0], Metric: metric}
+		if flags&rtfGateway != 0 {
+			route.Gateway = parseRouteAddr(fields[2])
+		}
+		routes = append(routes, route)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse routing table: %w", err)
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Metric < routes[j].Metric
+	})
+	return routes, nil
+}
+
+// parseRouteAddr decodes a little-endian hex IPv4 address from
+// /proc/net/route, returning the zero Addr if it is malformed.
+func parseRouteAddr(field string) netip.Addr {
+	raw, err := hex.DecodeString(field)
+	if err != nil || len(raw) != 4 {
+		return netip.Addr{}
+	}
+	var addr [4]byte
+	binary.BigEndian.PutUint32(addr[:], binary.LittleEndian.Uint32(raw))
+	return netip.AddrFrom4(addr)
+}
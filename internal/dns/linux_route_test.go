@@ -0,0 +1,65 @@
+//go:build linux
+
+package dns
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestParseDefaultRoutes_LowestMetric tests that default routes are ordered by metric.
+func TestParseDefaultRoutes_LowestMetric(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	00000000	0101A8C0	0003	0	0	600	00000000	0	0	0
+enp0s31f6	00000000	0100A8C0	0003	0	0	100	00000000	0	0	0
+enp0s31f6	0000A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`
+
+	routes, err := ParseDefaultRoutes(strings.NewReader(table))
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 default routes, got %v", routes)
+	}
+	if routes[0].Interface != "enp0s31f6" || routes[0].Gateway != netip.MustParseAddr("192.168.0.1") {
+		t.Errorf("expected enp0s31f6 via 192.168.0.1 first, got %+v", routes[0])
+	}
+	if routes[1].Interface != "wlan0" || routes[1].Gateway != netip.MustParseAddr("192.168.1.1") {
+		t.Errorf("expected wlan0 via 192.168.1.1 second, got %+v", routes[1])
+	}
+}
+
+// TestParseDefaultRoutes_SkipsDownRoutes tests that routes without RTF_UP are ignored.
+func TestParseDefaultRoutes_SkipsDownRoutes(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	010200C0	0002	0	0	0	00000000	0	0	0
+`
+
+	routes, err := ParseDefaultRoutes(strings.NewReader(table))
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(routes) != 0 {
+		t.Errorf("expected no routes, got %v", routes)
+	}
+}
+
+// TestParseDefaultRoutes_NoGateway tests that a route without RTF_GATEWAY has no gateway.
+func TestParseDefaultRoutes_NoGateway(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wg0	00000000	00000000	0001	0	0	0	00000000	0	0	0
+`
+
+	routes, err := ParseDefaultRoutes(strings.NewReader(table))
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(routes) != 1 || routes[0].Interface != "wg0" || routes[0].Gateway.IsValid() {
+		t.Errorf("expected wg0 without a gateway, got %v", routes)
+	}
+}
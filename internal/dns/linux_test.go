@@ -0,0 +1,62 @@
+//go:build linux
+
+package dns
+
+import (
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// TestCheckService tests the reasons a service is rejected, with the tool
+// looked up through the runner.
+func TestCheckService(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner *cmdexectest.Runner
+		want   string
+	}{
+		{
+			name:   "active",
+			runner: cmdexectest.NewRunner().Expect("systemctl is-active systemd-resolved", "active\n"),
+		},
+		{
+			name:   "no state",
+			runner: cmdexectest.NewRunner().Fail("systemctl is-active systemd-resolved", 3, ""),
+			want:   "failed to check systemd-resolved: systemctl exited with status 3",
+		},
+		{
+			name: "stopped",
+			runner: func() *cmdexectest.Runner {
+				runner := cmdexectest.NewRunner()
+				runner.Results["systemctl is-active systemd-resolved"] = cmdexec.Result{Stdout: []byte("failed\n"), ExitCode: 3}
+				return runner
+			}(),
+			want: "systemd-resolved is failed",
+		},
+		{
+			name: "missing tool",
+			runner: func() *cmdexectest.Runner {
+				runner := cmdexectest.NewRunner()
+				runner.Missing["resolvectl"] = true
+				return runner
+			}(),
+			want: "resolvectl not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkService(tt.runner, "resolvectl", "systemd-resolved")
+
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -3,17 +3,36 @@
 package dns
 
 import (
-	"fmt"
-	"os/exec"
+	"context"
+	"errors"
 	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
 )
 
 // macOSClient provides DNS management operations for macOS.
-type macOSClient struct{}
+type macOSClient struct {
+	runner cmdexec.Runner
+}
 
-// NewClient creates a new DNS client for the current platform.
-func NewClient() (Client, error) {
-	return &macOSClient{}, nil
+// platformBackends returns the macOS backends.
+func platformBackends() []Backend {
+	return []Backend{
+		{
+			Name:        "networksetup",
+			Description: "macOS network services, through networksetup",
+			Priority:    10,
+			Probe: func(runner cmdexec.Runner) error {
+				if _, err := runner.LookPath("networksetup"); err != nil {
+					return errors.New("networksetup not found")
+				}
+				return nil
+			},
+			New: func(runner cmdexec.Runner) (Client, error) {
+				return &macOSClient{runner: runner}, nil
+			},
+		},
+	}
 }
 
 // Name returns the backend name for display purposes.
@@ -21,15 +40,30 @@ func (c *macOSClient) Name() string {
 	return "macOS networksetup"
 }
 
+// Persistence returns Persistent, as networksetup saves changes in the
+// network preferences.
+func (c *macOSClient) Persistence() Persistence {
+	return Persistent
+}
+
 // ListNetworkServices returns all available network services.
 func (c *macOSClient) ListNetworkServices() ([]string, error) {
-	cmd := exec.Command("networksetup", "-listallnetworkservices")
-	output, err := cmd.Output()
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns all available network services.
+func (c *macOSClient) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	result, err := run(ctx, c.runner, "list network services", "networksetup", "-listallnetworkservices")
 	if err != nil {
-		return nil, fmt.Errorf("failed to list network services: %w", err)
+		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return parseNetworkServices(string(result.Stdout)), nil
+}
+
+// parseNetworkServices parses "networksetup -listallnetworkservices" output.
+func parseNetworkServices(output string) []string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
 	var services []string
 
 	for _, line := range lines {
@@ -46,22 +80,31 @@ func (c *macOSClient) ListNetworkServices() ([]string, error) {
 		}
 	}
 
-	return services, nil
+	return services
 }
 
 // GetDNSServers returns the current DNS servers for a network service.
 func (c *macOSClient) GetDNSServers(service string) ([]string, error) {
-	cmd := exec.Command("networksetup", "-getdnsservers", service)
-	output, err := cmd.Output()
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the current DNS servers for a network service.
+func (c *macOSClient) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	result, err := run(ctx, c.runner, "get DNS servers for "+service, "networksetup", "-getdnsservers", service)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get DNS servers for %s: %w", service, err)
+		return nil, err
 	}
 
-	text := strings.TrimSpace(string(output))
+	return parseDNSServers(string(result.Stdout)), nil
+}
+
+// parseDNSServers parses "networksetup -getdnsservers" output.
+func parseDNSServers(output string) []string {
+	text := strings.TrimSpace(output)
 
 	// Check if using DHCP (no manual DNS set)
 	if strings.Contains(text, "There aren't any DNS Servers set") {
-		return nil, nil
+		return nil
 	}
 
 	servers := strings.Split(text, "\n")
@@ -73,43 +116,97 @@ func (c *macOSClient) GetDNSServers(service string) ([]string, error) {
 		}
 	}
 
-	return result, nil
+	return result
 }
 
 // SetDNSServers sets the DNS servers for a network service.
 func (c *macOSClient) SetDNSServers(service string, servers []string) error {
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext sets the DNS servers for a network service.
+func (c *macOSClient) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
 	args := []string{"-setdnsservers", service}
 	args = append(args, servers...)
 
-	cmd := exec.Command("networksetup", args...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set DNS servers: %s: %w", string(output), err)
-	}
-
-	return nil
+	_, err := run(ctx, c.runner, "set DNS servers", "networksetup", args...)
+	return err
 }
 
 // ClearDNSServers clears DNS servers, reverting to DHCP defaults.
 func (c *macOSClient) ClearDNSServers(service string) error {
-	cmd := exec.Command("networksetup", "-setdnsservers", service, "empty")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to clear DNS servers: %s: %w", string(output), err)
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext clears DNS servers, reverting to DHCP defaults.
+// Search domains are left alone.
+func (c *macOSClient) ClearDNSServersContext(ctx context.Context, service string) error {
+	_, err := run(ctx, c.runner, "clear DNS servers", "networksetup", "-setdnsservers", service, "empty")
+	return err
+}
+
+// GetDomains returns the search domains for a network service.
+func (c *macOSClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	result, err := run(ctx, c.runner, "get search domains for "+service, "networksetup", "-getsearchdomains", service)
+	if err != nil {
+		return Domains{}, err
 	}
 
-	return nil
+	return Domains{Search: parseSearchDomains(string(result.Stdout))}, nil
+}
+
+// parseSearchDomains parses "networksetup -getsearchdomains" output.
+func parseSearchDomains(output string) []string {
+	text := strings.TrimSpace(output)
+
+	// Check if no search domains are set
+	if strings.Contains(text, "There aren't any Search Domains set") {
+		return nil
+	}
+
+	var result []string
+	for _, d := range strings.Split(text, "\n") {
+		d = strings.TrimSpace(d)
+		if d != "" {
+			result = append(result, d)
+		}
+	}
+
+	return result
+}
+
+// SetDomains sets the search domains for a network service.
+// networksetup has no notion of routing-only domains, so they are rejected.
+func (c *macOSClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	if len(domains.RouteOnly) > 0 {
+		return ErrRouteOnlyDomainsUnsupported
+	}
+
+	args := []string{"-setsearchdomains", service}
+	if len(domains.Search) == 0 {
+		args = append(args, "empty")
+	} else {
+		args = append(args, domains.Search...)
+	}
+
+	_, err := run(ctx, c.runner, "set search domains", "networksetup", args...)
+	return err
 }
 
 // FlushCache flushes the DNS cache.
 func (c *macOSClient) FlushCache() error {
-	cmd := exec.Command("dscacheutil", "-flushcache")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to flush DNS cache: %s: %w", string(output), err)
+	return c.FlushCacheContext(context.Background())
+}
+
+// FlushCacheContext flushes the DNS cache.
+func (c *macOSClient) FlushCacheContext(ctx context.Context) error {
+	if _, err := run(ctx, c.runner, "flush DNS cache", "dscacheutil", "-flushcache"); err != nil {
+		return err
 	}
 
-	// Also kill mDNSResponder to fully flush on newer macOS versions
-	cmd = exec.Command("killall", "-HUP", "mDNSResponder")
-	// Ignore errors for this command as it may require elevated privileges
-	_ = cmd.Run()
+	// Also kill mDNSResponder to fully flush on newer macOS versions.
+	// Ignore errors for this command as it may require elevated privileges.
+	_, _ = c.runner.Run(ctx, "killall", "-HUP", "mDNSResponder")
 
 	return nil
 }
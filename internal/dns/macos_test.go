@@ -0,0 +1,135 @@
+//go:build darwin
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// TestMacOS_ListNetworkServices tests skipping the header and disabled services.
+func TestMacOS_ListNetworkServices(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("networksetup -listallnetworkservices", fixture(t, "networksetup_services.txt"))
+	client := &macOSClient{runner: runner}
+
+	services, err := client.ListNetworkServices()
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []string{"USB 10/100/1000 LAN", "Wi-Fi", "iPhone USB"}
+	if strings.Join(services, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, services)
+	}
+}
+
+// TestMacOS_GetDNSServers tests parsing one server per line.
+func TestMacOS_GetDNSServers(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("networksetup -getdnsservers Wi-Fi", fixture(t, "networksetup_dns.txt"))
+	client := &macOSClient{runner: runner}
+
+	servers, err := client.GetDNSServers("Wi-Fi")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111"}
+	if strings.Join(servers, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, servers)
+	}
+}
+
+// TestMacOS_GetDNSServers_NoneSet tests the message printed when DHCP is used.
+func TestMacOS_GetDNSServers_NoneSet(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("networksetup -getdnsservers Wi-Fi", fixture(t, "networksetup_dns_empty.txt"))
+	client := &macOSClient{runner: runner}
+
+	servers, err := client.GetDNSServers("Wi-Fi")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if servers != nil {
+		t.Errorf("expected no servers, got %v", servers)
+	}
+}
+
+// TestMacOS_SetAndClear tests the networksetup arguments used to change DNS.
+func TestMacOS_SetAndClear(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Expect("networksetup -setdnsservers Wi-Fi 1.1.1.1 1.0.0.1", "").
+		Expect("networksetup -setdnsservers Wi-Fi empty", "")
+	client := &macOSClient{runner: runner}
+
+	if err := client.SetDNSServers("Wi-Fi", []string{"1.1.1.1", "1.0.0.1"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := client.ClearDNSServers("Wi-Fi"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	assertCommands(t, runner,
+		"networksetup -setdnsservers Wi-Fi 1.1.1.1 1.0.0.1",
+		"networksetup -setdnsservers Wi-Fi empty",
+	)
+}
+
+// TestMacOS_GetDomains tests parsing search domains.
+func TestMacOS_GetDomains(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("networksetup -getsearchdomains Wi-Fi", fixture(t, "networksetup_domains.txt"))
+	client := &macOSClient{runner: runner}
+
+	domains, err := client.GetDomains(context.Background(), "Wi-Fi")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(domains.Search, ",") != "corp.example.com,example.com" {
+		t.Errorf("expected search domains, got %v", domains.Search)
+	}
+}
+
+// TestMacOS_GetDomains_None tests the message shown when no domains are set.
+func TestMacOS_GetDomains_None(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("networksetup -getsearchdomains Wi-Fi", "There aren't any Search Domains set on Wi-Fi.\n")
+	client := &macOSClient{runner: runner}
+
+	domains, err := client.GetDomains(context.Background(), "Wi-Fi")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !domains.IsEmpty() {
+		t.Errorf("expected no domains, got %+v", domains)
+	}
+}
+
+// TestMacOS_SetDomains tests setting and rejecting domains.
+func TestMacOS_SetDomains(t *testing.T) {
+	runner := cmdexectest.NewRunner().Expect("networksetup -setsearchdomains Wi-Fi corp.example.com", "")
+	client := &macOSClient{runner: runner}
+
+	if err := client.SetDomains(context.Background(), "Wi-Fi", Domains{Search: []string{"corp.example.com"}}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	err := client.SetDomains(context.Background(), "Wi-Fi", Domains{RouteOnly: []string{"internal.example.com"}})
+	if !errors.Is(err, ErrRouteOnlyDomainsUnsupported) {
+		t.Errorf("expected ErrRouteOnlyDomainsUnsupported, got: %v", err)
+	}
+	assertCommands(t, runner, "networksetup -setsearchdomains Wi-Fi corp.example.com")
+}
+
+// TestMacOS_FlushCache_IgnoresKillallFailure tests that mDNSResponder errors are ignored.
+func TestMacOS_FlushCache_IgnoresKillallFailure(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Expect("dscacheutil -flushcache", "").
+		Fail("killall -HUP mDNSResponder", 1, "Operation not permitted")
+	client := &macOSClient{runner: runner}
+
+	if err := client.FlushCache(); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
@@ -1,28 +1,60 @@
 package dns
 
+import (
+	"context"
+	"time"
+)
+
 // SetDNSCall records a call to SetDNSServers.
 type SetDNSCall struct {
 	Service string
 	Servers []string
 }
 
+// SetDomainsCall records a call to SetDomains.
+type SetDomainsCall struct {
+	Service string
+	Domains Domains
+}
+
 // MockClient is a mock implementation of the DNS Client interface for testing.
 type MockClient struct {
 	// Configurable responses
-	Services   []string
-	DNSServers map[string][]string
+	Services    []string
+	DNSServers  map[string][]string
+	Domains     map[string]Domains
+	DefaultName string
 
 	// Error injection
-	ListError  error
-	GetError   error
-	SetError   error
-	ClearError error
-	FlushError error
+	ListError   error
+	GetError    error
+	SetError    error
+	ClearError  error
+	FlushError  error
+	DomainError error
+
+	// ServiceErrors fails SetDNSServers and ClearDNSServers for
+	// individual services, to simulate one interface being unmanaged.
+	ServiceErrors map[string]error
+
+	// Automatic lists the services whose servers were learned from
+	// DHCP, as HasManualDNS reports.
+	Automatic map[string]bool
+
+	// PersistenceMode is what Persistence reports, and Alternate is
+	// returned by WithPersistence for the other mode, if set.
+	PersistenceMode Persistence
+	Alternate       *MockClient
+
+	// Delay makes SetDNSServers and ClearDNSServers block for the given
+	// duration, or until their context is done, to simulate a hung backend.
+	Delay time.Duration
 
 	// Call recording
-	SetCalls   []SetDNSCall
-	ClearCalls []string
-	FlushCalls int
+	SetCalls        []SetDNSCall
+	ClearCalls      []string
+	FlushCalls      int
+	SetDomainsCalls []SetDomainsCall
 }
 
 // NewMockClient creates a new mock DNS client with sensible defaults.
@@ -41,6 +73,14 @@ func (m *MockClient) ListNetworkServices() ([]string, error) {
 	return m.Services, nil
 }
 
+// DefaultService returns the configured default service name.
+func (m *MockClient) DefaultService() (string, error) {
+	if m.ListError != nil {
+		return "", m.ListError
+	}
+	return m.DefaultName, nil
+}
+
 // GetDNSServers returns the DNS servers for the specified service.
 func (m *MockClient) GetDNSServers(service string) ([]string, error) {
 	if m.GetError != nil {
@@ -54,13 +94,24 @@ func (m *MockClient) GetDNSServers(service string) ([]string, error) {
 
 // SetDNSServers records the call and optionally returns an error.
 func (m *MockClient) SetDNSServers(service string, servers []string) error {
+	return m.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext records the call and optionally returns an error.
+func (m *MockClient) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
 	m.SetCalls = append(m.SetCalls, SetDNSCall{
 		Service: service,
 		Servers: servers,
 	})
+	if err := m.wait(ctx); err != nil {
+		return err
+	}
 	if m.SetError != nil {
 		return m.SetError
 	}
+	if err := m.ServiceErrors[service]; err != nil {
+		return err
+	}
 	if m.DNSServers == nil {
 		m.DNSServers = make(map[string][]string)
 	}
@@ -70,18 +121,76 @@ func (m *MockClient) SetDNSServers(service string, servers []string) error {
 
 // ClearDNSServers records the call and optionally returns an error.
 func (m *MockClient) ClearDNSServers(service string) error {
+	return m.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext records the call and optionally returns an error.
+func (m *MockClient) ClearDNSServersContext(ctx context.Context, service string) error {
 	m.ClearCalls = append(m.ClearCalls, service)
+	if err := m.wait(ctx); err != nil {
+		return err
+	}
 	if m.ClearError != nil {
 		return m.ClearError
 	}
+	if err := m.ServiceErrors[service]; err != nil {
+		return err
+	}
 	if m.DNSServers != nil {
 		delete(m.DNSServers, service)
 	}
+	if m.Domains != nil {
+		delete(m.Domains, service)
+	}
+	return nil
+}
+
+// HasManualDNS reports whether a service's servers were set manually,
+// which is all of them except for services listed in Automatic.
+func (m *MockClient) HasManualDNS(ctx context.Context, service string) (bool, error) {
+	if m.GetError != nil {
+		return false, m.GetError
+	}
+	return len(m.DNSServers[service]) > 0 && !m.Automatic[service], nil
+}
+
+// GetDomains returns the domains for the specified service.
+func (m *MockClient) GetDomains(ctx context.Context, service string) (Domains, error) {
+	if err := ctx.Err(); err != nil {
+		return Domains{}, err
+	}
+	if m.GetError != nil {
+		return Domains{}, m.GetError
+	}
+	return m.Domains[service], nil
+}
+
+// SetDomains records the call and optionally returns an error.
+func (m *MockClient) SetDomains(ctx context.Context, service string, domains Domains) error {
+	m.SetDomainsCalls = append(m.SetDomainsCalls, SetDomainsCall{
+		Service: service,
+		Domains: domains,
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if m.DomainError != nil {
+		return m.DomainError
+	}
+	if m.Domains == nil {
+		m.Domains = make(map[string]Domains)
+	}
+	m.Domains[service] = domains
 	return nil
 }
 
 // FlushCache records the call and optionally returns an error.
 func (m *MockClient) FlushCache() error {
+	return m.FlushCacheContext(context.Background())
+}
+
+// FlushCacheContext records the call and optionally returns an error.
+func (m *MockClient) FlushCacheContext(ctx context.Context) error {
 	m.FlushCalls++
 	if m.FlushError != nil {
 		return m.FlushError
@@ -89,7 +198,58 @@ func (m *MockClient) FlushCache() error {
 	return nil
 }
 
+// ListNetworkServicesContext returns the configured services list.
+func (m *MockClient) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListNetworkServices()
+}
+
+// GetDNSServersContext returns the DNS servers for the specified service.
+func (m *MockClient) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetDNSServers(service)
+}
+
+// wait blocks for Delay or until ctx is done.
+func (m *MockClient) wait(ctx context.Context) error {
+	if m.Delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(m.Delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Name returns the mock client name.
 func (m *MockClient) Name() string {
 	return "mock"
 }
+
+// Persistence returns the configured persistence.
+func (m *MockClient) Persistence() Persistence {
+	return m.PersistenceMode
+}
+
+// WithPersistence returns the mock itself or its Alternate, whichever
+// has persistence p.
+func (m *MockClient) WithPersistence(p Persistence) (Client, error) {
+	switch {
+	case m.PersistenceMode == p:
+		return m, nil
+	case m.Alternate != nil && m.Alternate.PersistenceMode == p:
+		return m.Alternate, nil
+	default:
+		return nil, unsupportedPersistence(m, p)
+	}
+}
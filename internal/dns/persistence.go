@@ -0,0 +1,67 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Persistence is how long a DNS change lasts.
+type Persistence string
+
+const (
+	// Runtime changes are lost on reboot, and often when the link is
+	// reconfigured.
+	Runtime Persistence = "runtime"
+	// Persistent changes are saved in the network configuration and
+	// survive reboots.
+	Persistent Persistence = "persistent"
+)
+
+// ErrPersistenceUnsupported is returned when a backend cannot make
+// changes with the requested persistence.
+var ErrPersistenceUnsupported = errors.New("persistence not supported by this backend")
+
+// PersistenceReporter is implemented by clients that know how long their
+// changes last.
+type PersistenceReporter interface {
+	// Persistence returns how long the client's changes last.
+	Persistence() Persistence
+}
+
+// PersistenceSwitcher is implemented by clients that can also make
+// changes with the other persistence, such as systemd-resolved changing
+// DNS for the running system and NetworkManager or systemd-networkd
+// saving it for the same links.
+type PersistenceSwitcher interface {
+	PersistenceReporter
+
+	// WithPersistence returns a client for the same services whose
+	// changes last as p.
+	WithPersistence(p Persistence) (Client, error)
+}
+
+// PersistenceOf returns how long c's changes last, or "" if unknown.
+func PersistenceOf(c Client) Persistence {
+	if reporter, ok := c.(PersistenceReporter); ok {
+		return reporter.Persistence()
+	}
+	return ""
+}
+
+// WithPersistence returns a client whose changes last as p. An empty p,
+// or the persistence c has anyway, returns c itself.
+func WithPersistence(c Client, p Persistence) (Client, error) {
+	if p == "" || PersistenceOf(c) == p {
+		return c, nil
+	}
+	if switcher, ok := c.(PersistenceSwitcher); ok {
+		return switcher.WithPersistence(p)
+	}
+	return nil, unsupportedPersistence(c, p)
+}
+
+// unsupportedPersistence returns the error for a client that cannot make
+// changes with persistence p.
+func unsupportedPersistence(c Client, p Persistence) error {
+	return fmt.Errorf("%w: %s cannot make %s changes", ErrPersistenceUnsupported, c.Name(), p)
+}
@@ -0,0 +1,26 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// run executes a command and wraps failures with the command's output,
+// matching the "failed to ...: <output>: <error>" messages of the backends.
+func run(ctx context.Context, r cmdexec.Runner, action string, name string, args ...string) (cmdexec.Result, error) {
+	return runInput(ctx, r, nil, action, name, args...)
+}
+
+// runInput is run with input written to the command's stdin.
+func runInput(ctx context.Context, r cmdexec.Runner, input []byte, action string, name string, args ...string) (cmdexec.Result, error) {
+	result, err := r.RunInput(ctx, input, name, args...)
+	if err != nil {
+		if output := result.Output(); output != "" {
+			return result, fmt.Errorf("failed to %s: %s: %w", action, output, err)
+		}
+		return result, fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package dns
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// fixture returns the contents of a file in testdata.
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+	return string(data)
+}
+
+// assertCommands checks the command lines recorded by a cmdexectest.Runner.
+func assertCommands(t *testing.T, runner *cmdexectest.Runner, want ...string) {
+	t.Helper()
+
+	got := runner.Commands()
+	if len(got) != len(want) {
+		t.Fatalf("expected commands %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected command %d to be %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+// TestRun_WrapsOutput tests that failures include the command's output.
+func TestRun_WrapsOutput(t *testing.T) {
+	runner := cmdexectest.NewRunner().Fail("resolvectl dns wlan0 1.1.1", 1, "Failed to parse DNS server address: 1.1.1\n")
+
+	_, err := run(context.Background(), runner, "set DNS servers", "resolvectl", "dns", "wlan0", "1.1.1")
+
+	want := "failed to set DNS servers: Failed to parse DNS server address: 1.1.1: resolvectl exited with status 1"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got: %v", want, err)
+	}
+}
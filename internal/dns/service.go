@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// AutoService is the placeholder service name that asks dnsctl to detect
+// the service holding the default route.
+const AutoService = "auto"
+
+// AllServices is the placeholder service name that targets every network
+// service the backend lists.
+const AllServices = "all"
+
+// DefaultServiceDetector is implemented by clients that can detect the
+// network service currently carrying the default route.
+type DefaultServiceDetector interface {
+	// DefaultService returns the service holding the default route,
+	// or an empty string if none could be determined.
+	DefaultService() (string, error)
+}
+
+// ResolveService resolves the AutoService placeholder into a real service
+// name. Any other name is returned unchanged.
+//
+// Detection prefers the client's DefaultServiceDetector, if implemented,
+// and falls back to the first active service.
+func ResolveService(c Client, service string) (string, error) {
+	if service != AutoService {
+		return service, nil
+	}
+
+	if detector, ok := c.(DefaultServiceDetector); ok {
+		if name, err := detector.DefaultService(); err == nil && name != "" {
+			return name, nil
+		}
+	}
+
+	services, err := c.ListNetworkServices()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect default service: %w", err)
+	}
+	if len(services) == 0 {
+		return "", ErrNoActiveService
+	}
+
+	return services[0], nil
+}
+
+// IsServicePattern returns true if service is a glob such as "en*"
+// rather than a plain service name.
+func IsServicePattern(service string) bool {
+	return strings.ContainsAny(service, "*?[")
+}
+
+// ResolveServices expands a list of service names into the services to
+// target. Each entry is a plain name, AutoService, AllServices, or a glob
+// such as "en*" matched against the listed services. Services are returned
+// in the order they were first matched, without duplicates.
+//
+// Plain names are passed through unchanged, like ResolveService does.
+// A glob or AllServices that matches no service is an error.
+func ResolveServices(c Client, services []string) ([]string, error) {
+	var (
+		resolved []string
+		seen     = make(map[string]bool)
+		listed   []string
+		didList  bool
+	)
+
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			resolved = append(resolved, name)
+		}
+	}
+
+	for _, service := range services {
+		if service != AllServices && !IsServicePattern(service) {
+			name, err := ResolveService(c, service)
+			if err != nil {
+				return nil, err
+			}
+			add(name)
+			continue
+		}
+
+		if !didList {
+			var err error
+			if listed, err = c.ListNetworkServices(); err != nil {
+				return nil, fmt.Errorf("failed to list services: %w", err)
+			}
+			didList = true
+		}
+
+		matched := false
+		for _, name := range listed {
+			ok := service == AllServices
+			if !ok {
+				var err error
+				if ok, err = path.Match(service, name); err != nil {
+					return nil, fmt.Errorf("invalid service pattern %q: %w", service, err)
+				}
+			}
+			if ok {
+				matched = true
+				add(name)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("%w: %s", ErrNoMatchingService, service)
+		}
+	}
+
+	if len(resolved) == 0 {
+		return nil, ErrNoActiveService
+	}
+	return resolved, nil
+}
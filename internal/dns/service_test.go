@@ -0,0 +1,125 @@
+package dns
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestResolveService_PassesThroughNames tests that real names are unchanged.
+func TestResolveService_PassesThroughNames(t *testing.T) {
+	mock := NewMockClient()
+	mock.DefaultName = "Ethernet"
+
+	service, err := ResolveService(mock, "Wi-Fi")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if service != "Wi-Fi" {
+		t.Errorf("expected Wi-Fi, got %s", service)
+	}
+}
+
+// TestResolveService_UsesDetector tests that auto uses the detected service.
+func TestResolveService_UsesDetector(t *testing.T) {
+	mock := NewMockClient()
+	mock.DefaultName = "Ethernet"
+
+	service, err := ResolveService(mock, AutoService)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if service != "Ethernet" {
+		t.Errorf("expected Ethernet, got %s", service)
+	}
+}
+
+// TestResolveService_FallsBackToFirstService tests the fallback when
+// detection finds nothing.
+func TestResolveService_FallsBackToFirstService(t *testing.T) {
+	mock := NewMockClient()
+
+	service, err := ResolveService(mock, AutoService)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if service != "Wi-Fi" {
+		t.Errorf("expected Wi-Fi, got %s", service)
+	}
+}
+
+// TestResolveService_NoServices tests that auto fails without services.
+func TestResolveService_NoServices(t *testing.T) {
+	mock := NewMockClient()
+	mock.Services = nil
+
+	_, err := ResolveService(mock, AutoService)
+
+	if !errors.Is(err, ErrNoActiveService) {
+		t.Errorf("expected ErrNoActiveService, got: %v", err)
+	}
+}
+
+// TestResolveServices_ExpandsPatterns tests names, globs and "all" together.
+func TestResolveServices_ExpandsPatterns(t *testing.T) {
+	mock := NewMockClient()
+	mock.Services = []string{"en0", "en7", "wlan0"}
+
+	services, err := ResolveServices(mock, []string{"wlan0", "en*", "en0"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(services, ",") != "wlan0,en0,en7" {
+		t.Errorf("expected [wlan0 en0 en7], got %v", services)
+	}
+
+	services, err = ResolveServices(mock, []string{AllServices})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(services, ",") != "en0,en7,wlan0" {
+		t.Errorf("expected every service, got %v", services)
+	}
+}
+
+// TestResolveServices_ResolvesAuto tests that auto is resolved in a list.
+func TestResolveServices_ResolvesAuto(t *testing.T) {
+	mock := NewMockClient()
+	mock.DefaultName = "Ethernet"
+
+	services, err := ResolveServices(mock, []string{AutoService, "Wi-Fi"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Join(services, ",") != "Ethernet,Wi-Fi" {
+		t.Errorf("expected [Ethernet Wi-Fi], got %v", services)
+	}
+}
+
+// TestResolveServices_NoMatch tests that a glob matching nothing fails.
+func TestResolveServices_NoMatch(t *testing.T) {
+	mock := NewMockClient()
+
+	_, err := ResolveServices(mock, []string{"Wi-Fi", "en*"})
+
+	if !errors.Is(err, ErrNoMatchingService) {
+		t.Errorf("expected ErrNoMatchingService, got: %v", err)
+	}
+}
+
+// TestResolveServices_BadPattern tests that malformed globs are reported.
+func TestResolveServices_BadPattern(t *testing.T) {
+	mock := NewMockClient()
+
+	_, err := ResolveServices(mock, []string{"en["})
+
+	if err == nil || !strings.Contains(err.Error(), "invalid service pattern") {
+		t.Errorf("expected invalid pattern error, got: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+// Package doctor diagnoses why DNS may not work as configured, for
+// "dnsctl doctor". It checks the files and services that decide which
+// servers the system resolver uses, compares them to what the backend
+// reports, and probes each configured server.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+// Check outcomes, from best to worst.
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Check is the outcome of one diagnostic.
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+
+	// Hint says how to fix a warning or failure.
+	Hint string `json:"hint,omitempty"`
+}
+
+// Report is the outcome of every diagnostic.
+type Report struct {
+	Backend string  `json:"backend"`
+	Status  Status  `json:"status"`
+	Checks  []Check `json:"checks"`
+}
+
+// Count returns the number of checks with the given status.
+func (r Report) Count(status Status) int {
+	n := 0
+	for _, check := range r.Checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Doctor runs the diagnostics against a backend.
+type Doctor struct {
+	Client dns.Client
+	Config *config.Config
+
+	// Checker probes the configured servers. When nil, a probe.Prober
+	// built from the config's settings is used.
+	Checker probe.Checker
+
+	// Root is prepended to the system files inspected, such as
+	// /etc/resolv.conf, so tests can use a temp dir.
+	Root string
+}
+
+// New creates a Doctor for the given client and config.
+func New(client dns.Client, cfg *config.Config) *Doctor {
+	return &Doctor{
+		Client: client,
+		Config: cfg,
+		Root:   "/",
+	}
+}
+
+// view is the DNS state as the backend reports it.
+type view struct {
+	// Err is set if the backend could not list its services.
+	Err error
+
+	// Default is the default service, or "" if it cannot be resolved.
+	Default string
+
+	Services []serviceState
+}
+
+// serviceState is the backend's view of one service.
+type serviceState struct {
+	Name    string
+	Servers []string
+	Err     error
+}
+
+// service returns the state of the named service.
+func (v view) service(name string) (serviceState, bool) {
+	for _, s := range v.Services {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return serviceState{}, false
+}
+
+// Run runs every diagnostic. Problems, including failing to read the
+// backend, are reported as checks rather than errors.
+func (d *Doctor) Run(ctx context.Context) Report {
+	v := d.read(ctx)
+
+	report := Report{Backend: d.Client.Name()}
+	report.Checks = append(report.Checks, d.checkBackend(v))
+	report.Checks = append(report.Checks, d.systemChecks(ctx, v)...)
+	report.Checks = append(report.Checks, d.serverChecks(ctx, v)...)
+
+	report.Status = Pass
+	for _, check := range report.Checks {
+		if check.Status == Fail || (check.Status == Warn && report.Status == Pass) {
+			report.Status = check.Status
+		}
+	}
+	return report
+}
+
+// read collects the servers of every service the backend lists. Each
+// read is bounded by the read timeout.
+func (d *Doctor) read(ctx context.Context) view {
+	client := dns.WithContext(d.Client)
+	timeout := d.Config.Settings.Timeouts.ReadTimeout()
+
+	var services []string
+	err := dns.WithTimeout(ctx, timeout, func(ctx context.Context) (err error) {
+		services, err = client.ListNetworkServicesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return view{Err: err}
+	}
+
+	// An unresolvable default service just skips the checks that need it
+	var v view
+	v.Default, _ = dns.ResolveService(client, d.Config.DefaultService)
+
+	for _, service := range services {
+		state := serviceState{Name: service}
+		state.Err = dns.WithTimeout(ctx, timeout, func(ctx context.Context) (err error) {
+			state.Servers, err = client.GetDNSServersContext(ctx, service)
+			return err
+		})
+		v.Services = append(v.Services, state)
+	}
+	return v
+}
+
+// checkBackend checks that the backend can list its services.
+func (d *Doctor) checkBackend(v view) Check {
+	check := Check{Name: "backend"}
+	if v.Err != nil {
+		check.Status = Fail
+		check.Message = fmt.Sprintf("%s cannot list network services: %v", d.Client.Name(), v.Err)
+		check.Hint = `Run "dnsctl backends" to see which backends are usable, and select another with --backend`
+		return check
+	}
+
+	if len(v.Services) == 0 {
+		check.Status = Warn
+		check.Message = d.Client.Name() + " manages no network services"
+		check.Hint = `Run "dnsctl backends" to check that the right backend is selected`
+		return check
+	}
+
+	names := make([]string, len(v.Services))
+	for i, s := range v.Services {
+		names[i] = s.Name
+	}
+	check.Status = Pass
+	check.Message = fmt.Sprintf("%s manages %s", d.Client.Name(), strings.Join(names, ", "))
+	if v.Default != "" {
+		check.Message += fmt.Sprintf("; the default is %s", v.Default)
+	}
+	return check
+}
+
+// serverChecks probes the servers of each service. Services that use
+// DHCP are skipped, as the backend does not report their servers.
+func (d *Doctor) serverChecks(ctx context.Context, v view) []Check {
+	var checks []Check
+	for _, s := range v.Services {
+		check := Check{Name: "servers on " + s.Name}
+		if s.Err != nil {
+			check.Status = Fail
+			check.Message = fmt.Sprintf("failed to read the servers: %v", s.Err)
+			check.Hint = "Check that the backend still manages " + s.Name
+			checks = append(checks, check)
+			continue
+		}
+		if len(s.Servers) == 0 {
+			continue
+		}
+
+		addrs := make([]string, len(s.Servers))
+		for i, server := range s.Servers {
+			addrs[i] = serverAddress(server)
+		}
+		results := d.checker().ProbeAll(ctx, addrs)
+
+		answered := 0
+		for _, result := range results {
+			if result.OK() {
+				answered++
+			}
+		}
+		switch {
+		case answered == len(results):
+			check.Status = Pass
+			check.Message = "all servers answer: " + probe.Summary(results)
+		case answered > 0:
+			check.Status = Warn
+			check.Message = fmt.Sprintf("%d of %d servers answer: %s", answered, len(results), probe.Summary(results))
+			check.Hint = "Queries to the silent servers time out first; remove them from the profile"
+		default:
+			check.Status = Fail
+			check.Message = "no server answers: " + probe.Summary(results)
+			check.Hint = "Check that the servers are reachable from this network, e.g. behind a VPN that is down, or apply another profile"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checker returns the configured Checker, or a Prober built from the
+// settings.
+func (d *Doctor) checker() probe.Checker {
+	if d.Checker != nil {
+		return d.Checker
+	}
+	return probe.Prober{
+		Name:    d.Config.Settings.VerifyProbeName(),
+		Timeout: d.Config.Settings.Timeouts.ProbeTimeout(),
+	}
+}
+
+// path returns a path under the doctor's root.
+func (d *Doctor) path(name string) string {
+	return filepath.Join(d.Root, name)
+}
+
+// serverAddress strips the TLS server name some backends report, as in
+// "1.1.1.1:853#cloudflare-dns.com", leaving an address to compare or
+// probe.
+func serverAddress(server string) string {
+	address, _, _ := strings.Cut(server, "#")
+	return address
+}
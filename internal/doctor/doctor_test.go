@@ -0,0 +1,131 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+)
+
+// downServer never answers probes.
+const downServer = "192.0.2.1"
+
+// namedClient is a MockClient named after the backend it stands in for.
+type namedClient struct {
+	*dns.MockClient
+	name string
+}
+
+// Name returns the backend's name.
+func (c namedClient) Name() string {
+	return c.name
+}
+
+// testDoctor creates a Doctor for a mock backend with the given name,
+// an empty root and a checker that only downServer does not answer.
+func testDoctor(t *testing.T, backend string) (*Doctor, *dns.MockClient) {
+	t.Helper()
+
+	mock := dns.NewMockClient()
+	mock.DefaultName = "Wi-Fi"
+	d := New(namedClient{MockClient: mock, name: backend}, &config.Config{DefaultService: dns.AutoService})
+	d.Root = t.TempDir()
+	d.Checker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+		results := make([]probe.Result, len(servers))
+		for i, server := range servers {
+			results[i] = probe.Result{Server: server, Latency: time.Millisecond}
+			if server == downServer {
+				results[i] = probe.Result{Server: server, Err: errors.New("timed out")}
+			}
+		}
+		return results
+	})
+	return d, mock
+}
+
+// findCheck returns the named check of a report.
+func findCheck(t *testing.T, report Report, name string) Check {
+	t.Helper()
+
+	i := slices.IndexFunc(report.Checks, func(c Check) bool { return c.Name == name })
+	if i < 0 {
+		t.Fatalf("expected a %q check, got %+v", name, report.Checks)
+	}
+	return report.Checks[i]
+}
+
+// TestRun_Servers tests that each service's servers are probed, and
+// services using DHCP are skipped.
+func TestRun_Servers(t *testing.T) {
+	d, mock := testDoctor(t, "mock")
+	mock.Services = []string{"Wi-Fi", "Ethernet", "Thunderbolt"}
+	mock.DNSServers["Wi-Fi"] = []string{"1.1.1.1", downServer}
+	mock.DNSServers["Ethernet"] = []string{downServer}
+	mock.DNSServers["Thunderbolt"] = []string{"9.9.9.9:853#dns.quad9.net"}
+
+	report := d.Run(context.Background())
+
+	if check := findCheck(t, report, "servers on Wi-Fi"); check.Status != Warn || check.Hint == "" {
+		t.Errorf("expected a warning with a hint for Wi-Fi, got %+v", check)
+	}
+	if check := findCheck(t, report, "servers on Ethernet"); check.Status != Fail {
+		t.Errorf("expected Ethernet to fail, got %+v", check)
+	}
+	if check := findCheck(t, report, "servers on Thunderbolt"); check.Status != Pass || check.Message != "all servers answer: 9.9.9.9:853 1ms" {
+		t.Errorf("expected the TLS name to be dropped for the probe, got %+v", check)
+	}
+	if report.Status != Fail {
+		t.Errorf("expected the report to fail, got %s", report.Status)
+	}
+}
+
+// TestRun_DHCP tests that services without servers are not probed.
+func TestRun_DHCP(t *testing.T) {
+	d, _ := testDoctor(t, "mock")
+
+	report := d.Run(context.Background())
+
+	for _, check := range report.Checks {
+		if check.Name == "servers on Wi-Fi" || check.Name == "servers on Ethernet" {
+			t.Errorf("expected no server check for DHCP services, got %+v", check)
+		}
+	}
+}
+
+// TestRun_BackendError tests that a backend that cannot list services is
+// reported as a failing check.
+func TestRun_BackendError(t *testing.T) {
+	d, mock := testDoctor(t, "mock")
+	mock.ListError = errors.New("resolved is not running")
+
+	report := d.Run(context.Background())
+
+	check := findCheck(t, report, "backend")
+	if check.Status != Fail || check.Message != "mock cannot list network services: resolved is not running" {
+		t.Errorf("expected the backend check to fail, got %+v", check)
+	}
+	if report.Status != Fail {
+		t.Errorf("expected the report to fail, got %s", report.Status)
+	}
+}
+
+// TestRun_ReadError tests that a service whose servers cannot be read
+// fails without hiding the others.
+func TestRun_ReadError(t *testing.T) {
+	d, mock := testDoctor(t, "mock")
+	mock.GetError = errors.New("no such interface")
+
+	report := d.Run(context.Background())
+
+	if check := findCheck(t, report, "servers on Ethernet"); check.Status != Fail {
+		t.Errorf("expected Ethernet to fail, got %+v", check)
+	}
+	if check := findCheck(t, report, "backend"); check.Status != Pass || check.Message != "mock manages Wi-Fi, Ethernet; the default is Wi-Fi" {
+		t.Errorf("expected the backend check to pass, got %+v", check)
+	}
+}
@@ -0,0 +1,454 @@
+//go:build linux
+
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/probe"
+)
+
+// Files inspected on Linux, relative to the root.
+const (
+	resolvConfPath   = "/etc/resolv.conf"
+	resolvedRunDir   = "/run/systemd/resolve"
+	resolvedConfPath = "/etc/systemd/resolved.conf"
+	nmConfDir        = "/etc/NetworkManager"
+)
+
+// resolvedStub is the address of systemd-resolved's stub resolver.
+const resolvedStub = "127.0.0.53"
+
+// maxNameservers is the number of nameserver lines the resolver uses.
+const maxNameservers = 3
+
+// linkTargets describes the files /etc/resolv.conf commonly links to.
+var linkTargets = map[string]string{
+	"/run/systemd/resolve/stub-resolv.conf": "systemd-resolved's stub resolver",
+	"/run/systemd/resolve/resolv.conf":      "systemd-resolved's upstream servers",
+	"/run/NetworkManager/resolv.conf":       "NetworkManager",
+	"/run/resolvconf/resolv.conf":           "resolvconf",
+	"/etc/resolvconf/run/resolv.conf":       "resolvconf",
+}
+
+// writer is a tool that marks the resolv.conf it generates with a comment.
+type writer struct {
+	// signature is looked for, case-insensitively, in comment lines.
+	signature string
+	name      string
+
+	// backend is the name of the client that manages DNS through the
+	// tool, or "" if there is none and the tool overrides the backend.
+	backend string
+
+	// hint says how to stop the tool from overriding the backend.
+	hint string
+}
+
+// writers are the known resolv.conf writers, with more specific
+// signatures first.
+var writers = []writer{
+	{signature: "generated by dnsctl", name: "dnsctl", backend: "resolv.conf"},
+	{signature: "systemd-resolved", name: "systemd-resolved", backend: "systemd-resolved"},
+	{signature: "generated by networkmanager", name: "NetworkManager", backend: "NetworkManager"},
+	{signature: "tailscale", name: "Tailscale", hint: `Stop Tailscale from managing DNS with "tailscale set --accept-dns=false"`},
+	{signature: "docker", name: "Docker", hint: "DNS in a container is set by Docker; use --dns when starting it, or the daemon's \"dns\" option"},
+	{signature: "vpnc_generated", name: "vpnc-script", hint: "Disconnect the VPN (OpenConnect or vpnc), or have its script use resolvconf or systemd-resolved"},
+	{signature: "openvpn", name: "OpenVPN", hint: "Disconnect the VPN, or use an up script that goes through resolvconf or systemd-resolved"},
+	{signature: "netconfig", name: "netconfig", hint: `Set NETCONFIG_DNS_POLICY="" in /etc/sysconfig/network/config, or select the backend netconfig feeds`},
+	{signature: "dhcpcd", name: "dhcpcd", hint: "Add nohook resolv.conf to /etc/dhcpcd.conf"},
+	{signature: "resolvconf", name: "resolvconf", backend: "resolvconf"},
+}
+
+// stubBackends are the backends whose servers reach the resolver through
+// systemd-resolved's stub.
+var stubBackends = []string{"systemd-resolved", "systemd-networkd", "NetworkManager"}
+
+// systemChecks checks /etc/resolv.conf and the services that write it.
+func (d *Doctor) systemChecks(ctx context.Context, v view) []Check {
+	check, target := d.checkResolvConfLink()
+	checks := []Check{check}
+
+	content, err := os.ReadFile(d.path(target))
+	if err != nil {
+		// The link check reports a missing file
+		if !errors.Is(err, fs.ErrNotExist) {
+			checks = append(checks, Check{
+				Name:    "resolv.conf",
+				Status:  Fail,
+				Message: fmt.Sprintf("failed to read %s: %v", target, err),
+				Hint:    "Make the file readable; every program resolving names reads it",
+			})
+		}
+		return checks
+	}
+	conf := parseResolvConf(string(content))
+
+	checks = append(checks, d.checkWriter(conf))
+	if conf.usesStub() {
+		checks = append(checks, d.checkStub(ctx))
+	}
+	if check, ok := d.checkNetworkManager(); ok {
+		checks = append(checks, check)
+	}
+	if check, ok := d.checkServers(conf, v); ok {
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkResolvConfLink checks that /etc/resolv.conf exists, and names the
+// file it links to. It also returns the path of the file to read.
+func (d *Doctor) checkResolvConfLink() (Check, string) {
+	check := Check{Name: "resolv.conf"}
+
+	info, err := os.Lstat(d.path(resolvConfPath))
+	if err != nil {
+		check.Status = Fail
+		check.Message = fmt.Sprintf("%s does not exist: %v", resolvConfPath, err)
+		check.Hint = "Link it to systemd-resolved's stub with \"ln -sf ../run/systemd/resolve/stub-resolv.conf /etc/resolv.conf\", or write your servers to it"
+		return check, resolvConfPath
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		check.Status = Pass
+		check.Message = resolvConfPath + " is a regular file"
+		return check, resolvConfPath
+	}
+
+	link, err := os.Readlink(d.path(resolvConfPath))
+	if err != nil {
+		check.Status = Fail
+		check.Message = fmt.Sprintf("failed to read the %s link: %v", resolvConfPath, err)
+		return check, resolvConfPath
+	}
+	target := link
+	if !path.IsAbs(target) {
+		target = path.Join(path.Dir(resolvConfPath), target)
+	}
+
+	if _, err := os.Stat(d.path(target)); err != nil {
+		check.Status = Fail
+		check.Message = fmt.Sprintf("%s links to %s, which does not exist", resolvConfPath, target)
+		check.Hint = fmt.Sprintf("Start the service that writes %s, or point the link at an existing file", target)
+		return check, target
+	}
+
+	check.Status = Pass
+	check.Message = fmt.Sprintf("%s links to %s", resolvConfPath, target)
+	if owner, ok := linkTargets[target]; ok {
+		check.Message += " (" + owner + ")"
+	}
+	return check, target
+}
+
+// checkWriter checks which tool wrote /etc/resolv.conf, and whether it is
+// the one the backend manages DNS through.
+func (d *Doctor) checkWriter(conf resolvConf) Check {
+	check := Check{Name: "resolv.conf owner", Status: Pass}
+	backend := d.Client.Name()
+
+	w, ok := conf.writer()
+	switch {
+	case !ok:
+		check.Message = "no tool has marked " + resolvConfPath + " as generated"
+	case w.backend == "":
+		check.Status = Warn
+		check.Message = fmt.Sprintf("%s was written by %s, which overrides %s", resolvConfPath, w.name, backend)
+		check.Hint = w.hint
+	case w.backend == backend || (w.name == "systemd-resolved" && slices.Contains(stubBackends, backend)):
+		check.Message = fmt.Sprintf("%s is written by %s", resolvConfPath, w.name)
+	default:
+		check.Status = Warn
+		check.Message = fmt.Sprintf("%s is written by %s, not %s", resolvConfPath, w.name, backend)
+		check.Hint = fmt.Sprintf("Select the backend that goes through %s (see \"dnsctl backends\"), or stop it from writing %s", w.name, resolvConfPath)
+	}
+	return check
+}
+
+// checkStub checks that systemd-resolved's stub, which /etc/resolv.conf
+// sends queries to, is running and answers.
+func (d *Doctor) checkStub(ctx context.Context) Check {
+	check := Check{Name: "systemd-resolved stub"}
+
+	if _, err := os.Stat(d.path(resolvedRunDir)); err != nil {
+		check.Status = Fail
+		check.Message = fmt.Sprintf("%s sends queries to %s, but systemd-resolved is not running", resolvConfPath, resolvedStub)
+		check.Hint = "Start it with \"systemctl enable --now systemd-resolved\", or point " + resolvConfPath + " at your DNS servers"
+		return check
+	}
+	if file := d.stubListenerDisabled(); file != "" {
+		check.Status = Fail
+		check.Message = fmt.Sprintf("systemd-resolved's stub listener is disabled (DNSStubListener=no in %s)", file)
+		check.Hint = "Remove DNSStubListener=no, or link " + resolvConfPath + " to /run/systemd/resolve/resolv.conf"
+		return check
+	}
+
+	results := d.checker().ProbeAll(ctx, []string{resolvedStub})
+	if !probe.AnyOK(results) {
+		check.Status = Fail
+		check.Message = "systemd-resolved's stub does not answer: " + probe.Summary(results)
+		check.Hint = `Look for errors with "journalctl -u systemd-resolved" and "resolvectl status"`
+		return check
+	}
+
+	check.Status = Pass
+	check.Message = "systemd-resolved's stub answers: " + probe.Summary(results)
+	return check
+}
+
+// stubListenerDisabled returns the resolved.conf file that sets
+// DNSStubListener=no, or "" if the stub listener is enabled.
+func (d *Doctor) stubListenerDisabled() string {
+	files := []string{resolvedConfPath}
+	for _, dir := range []string{"/usr/lib/systemd/resolved.conf.d", "/run/systemd/resolved.conf.d", "/etc/systemd/resolved.conf.d"} {
+		files = append(files, d.dropIns(dir)...)
+	}
+	files = overrideByName(files)
+
+	file, value := d.lastSetting(files, "Resolve", "DNSStubListener")
+	if slices.Contains([]string{"no", "false", "off", "0"}, strings.ToLower(value)) {
+		return file
+	}
+	return ""
+}
+
+// checkNetworkManager reports NetworkManager's dns= mode, and warns if it
+// keeps the NetworkManager backend's changes from being used. It returns
+// false if NetworkManager is not installed.
+func (d *Doctor) checkNetworkManager() (Check, bool) {
+	if _, err := os.Stat(d.path(nmConfDir)); err != nil {
+		return Check{}, false
+	}
+
+	files := []string{path.Join(nmConfDir, "NetworkManager.conf")}
+	for _, dir := range []string{"/usr/lib/NetworkManager/conf.d", "/run/NetworkManager/conf.d", "/etc/NetworkManager/conf.d"} {
+		files = append(files, d.dropIns(dir)...)
+	}
+	files = overrideByName(files)
+
+	check := Check{Name: "NetworkManager", Status: Pass}
+	dnsFile, mode := d.lastSetting(files, "main", "dns")
+	rcFile, rcManager := d.lastSetting(files, "main", "rc-manager")
+	usesNM := d.Client.Name() == "NetworkManager"
+
+	switch {
+	case mode == "none":
+		check.Message = fmt.Sprintf("NetworkManager leaves DNS alone (dns=none in %s)", dnsFile)
+		if usesNM {
+			check.Status = Warn
+			check.Message += ", so servers set on its connections are not used"
+			check.Hint = "Remove dns=none from " + dnsFile + ", or select another backend"
+		}
+	case rcManager == "unmanaged":
+		check.Message = fmt.Sprintf("NetworkManager does not write %s (rc-manager=unmanaged in %s)", resolvConfPath, rcFile)
+		if usesNM {
+			check.Status = Warn
+			check.Hint = "Remove rc-manager=unmanaged from " + rcFile + ", or link " + resolvConfPath + " to /run/NetworkManager/resolv.conf"
+		}
+	case mode == "":
+		check.Message = "NetworkManager uses dns=default"
+	default:
+		check.Message = fmt.Sprintf("NetworkManager uses dns=%s (in %s)", mode, dnsFile)
+	}
+	return check, true
+}
+
+// checkServers compares the servers the backend reports for the default
+// service with those in /etc/resolv.conf. It returns false if the
+// backend's view is unknown.
+func (d *Doctor) checkServers(conf resolvConf, v view) (Check, bool) {
+	check := Check{Name: "resolv.conf servers"}
+	backend := d.Client.Name()
+	nameservers := conf.nameservers()
+
+	if len(nameservers) == 0 {
+		check.Status = Fail
+		check.Message = resolvConfPath + " lists no nameservers, so queries go to 127.0.0.1"
+		check.Hint = "Apply a profile, or restore " + resolvConfPath
+		return check, true
+	}
+	if conf.usesStub() {
+		if slices.Contains(stubBackends, backend) {
+			check.Status = Pass
+			check.Message = fmt.Sprintf("%s sends queries to systemd-resolved, which uses %s's servers", resolvConfPath, backend)
+		} else {
+			check.Status = Warn
+			check.Message = fmt.Sprintf("%s sends queries to systemd-resolved, which %s does not configure", resolvConfPath, backend)
+			check.Hint = "Select the resolved backend (settings.backend: resolved)"
+		}
+		return check, true
+	}
+
+	state, ok := v.service(v.Default)
+	if !ok || state.Err != nil {
+		return Check{}, false
+	}
+	listed := strings.Join(nameservers, ", ")
+	if len(state.Servers) == 0 {
+		check.Status = Pass
+		check.Message = fmt.Sprintf("%s uses servers from DHCP; %s lists %s", state.Name, resolvConfPath, listed)
+		return check, true
+	}
+
+	// The resolver only uses the first few nameservers
+	expected := state.Servers
+	if len(expected) > maxNameservers {
+		expected = expected[:maxNameservers]
+	}
+	var missing []string
+	for _, server := range expected {
+		if !slices.ContainsFunc(nameservers, func(ns string) bool { return sameAddress(ns, server) }) {
+			missing = append(missing, server)
+		}
+	}
+
+	switch {
+	case len(missing) == 0:
+		check.Status = Pass
+		check.Message = fmt.Sprintf("%s lists the servers of %s", resolvConfPath, state.Name)
+	case len(missing) < len(expected):
+		check.Status = Warn
+		check.Message = fmt.Sprintf("%s lists %s, without %s of %s", resolvConfPath, listed, strings.Join(missing, ", "), state.Name)
+		check.Hint = "Another tool may have changed " + resolvConfPath + "; apply the profile again"
+	default:
+		check.Status = Fail
+		check.Message = fmt.Sprintf("%s has %s, but %s lists %s", state.Name, strings.Join(state.Servers, ", "), resolvConfPath, listed)
+		check.Hint = fmt.Sprintf("Another tool, or a service %s does not configure, writes %s; see the resolv.conf owner check", backend, resolvConfPath)
+	}
+	return check, true
+}
+
+// dropIns returns the .conf files in a directory under the root.
+func (d *Doctor) dropIns(dir string) []string {
+	entries, err := os.ReadDir(d.path(dir))
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".conf") {
+			files = append(files, path.Join(dir, entry.Name()))
+		}
+	}
+	return files
+}
+
+// lastSetting returns the last value of key in section across files, in
+// order, and the file that sets it.
+func (d *Doctor) lastSetting(files []string, section, key string) (string, string) {
+	var file, value string
+	for _, name := range files {
+		f, err := os.Open(d.path(name))
+		if err != nil {
+			continue
+		}
+
+		current := ""
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+				current = line[1 : len(line)-1]
+				continue
+			}
+			k, v, ok := strings.Cut(line, "=")
+			if ok && current == section && strings.TrimSpace(k) == key {
+				file, value = name, strings.TrimSpace(v)
+			}
+		}
+		f.Close()
+	}
+	return file, value
+}
+
+// overrideByName keeps the main file first, followed by the drop-ins
+// sorted by name, where a drop-in overrides those of the same name in
+// earlier directories.
+func overrideByName(files []string) []string {
+	byName := make(map[string]string)
+	for _, file := range files[1:] {
+		byName[path.Base(file)] = file
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []string{files[0]}
+	for _, name := range names {
+		result = append(result, byName[name])
+	}
+	return result
+}
+
+// sameAddress reports whether a nameserver and a backend's server are the
+// same address. Backend servers may carry a port or TLS server name.
+func sameAddress(nameserver, server string) bool {
+	a, err := netip.ParseAddr(nameserver)
+	if err != nil {
+		return nameserver == server
+	}
+
+	address := serverAddress(server)
+	if addrPort, err := netip.ParseAddrPort(address); err == nil {
+		return addrPort.Addr().WithZone("") == a.WithZone("")
+	}
+	b, err := netip.ParseAddr(address)
+	return err == nil && b.WithZone("") == a.WithZone("")
+}
+
+// resolvConf is the content of /etc/resolv.conf.
+type resolvConf struct {
+	lines []string
+}
+
+// parseResolvConf splits resolv.conf content into lines.
+func parseResolvConf(content string) resolvConf {
+	return resolvConf{lines: strings.Split(strings.TrimSuffix(content, "\n"), "\n")}
+}
+
+// nameservers returns the servers of all nameserver lines.
+func (r resolvConf) nameservers() []string {
+	var servers []string
+	for _, line := range r.lines {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+// usesStub reports whether queries go to systemd-resolved's stub.
+func (r resolvConf) usesStub() bool {
+	return slices.Contains(r.nameservers(), resolvedStub)
+}
+
+// writer returns the tool that marked the file as generated.
+func (r resolvConf) writer() (writer, bool) {
+	var comments []string
+	for _, line := range r.lines {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			comments = append(comments, strings.ToLower(line))
+		}
+	}
+	text := strings.Join(comments, "\n")
+
+	for _, w := range writers {
+		if strings.Contains(text, w.signature) {
+			return w, true
+		}
+	}
+	return writer{}, false
+}
@@ -0,0 +1,248 @@
+//go:build linux
+
+package doctor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubResolvConf is the file systemd-resolved links /etc/resolv.conf to.
+const stubResolvConf = `# This is /run/systemd/resolve/stub-resolv.conf managed by man:systemd-resolved(8).
+# Do not edit.
+nameserver 127.0.0.53
+options edns0 trust-ad
+search .
+`
+
+// writeFile writes a file under the doctor's root, creating its directory.
+func writeFile(t *testing.T, d *Doctor, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(d.Root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+}
+
+// linkResolvConf makes /etc/resolv.conf under the doctor's root a link.
+func linkResolvConf(t *testing.T, d *Doctor, target string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(d.Root, "etc"), 0755); err != nil {
+		t.Fatalf("failed to create etc: %v", err)
+	}
+	if err := os.Symlink(target, filepath.Join(d.Root, resolvConfPath)); err != nil {
+		t.Fatalf("failed to link resolv.conf: %v", err)
+	}
+}
+
+// TestRun_ResolvedStub tests a host where resolv.conf links to
+// systemd-resolved's stub.
+func TestRun_ResolvedStub(t *testing.T) {
+	d, _ := testDoctor(t, "systemd-resolved")
+	writeFile(t, d, "run/systemd/resolve/stub-resolv.conf", stubResolvConf)
+	linkResolvConf(t, d, "../run/systemd/resolve/stub-resolv.conf")
+
+	report := d.Run(context.Background())
+
+	if check := findCheck(t, report, "resolv.conf"); check.Message != "/etc/resolv.conf links to /run/systemd/resolve/stub-resolv.conf (systemd-resolved's stub resolver)" {
+		t.Errorf("unexpected link check: %+v", check)
+	}
+	if check := findCheck(t, report, "systemd-resolved stub"); check.Status != Pass || check.Message != "systemd-resolved's stub answers: 127.0.0.53 1ms" {
+		t.Errorf("expected the stub to answer, got %+v", check)
+	}
+	if report.Status != Pass {
+		t.Errorf("expected every check to pass, got %+v", report.Checks)
+	}
+}
+
+// TestRun_DanglingLink tests that a link to a missing file fails.
+func TestRun_DanglingLink(t *testing.T) {
+	d, _ := testDoctor(t, "systemd-resolved")
+	linkResolvConf(t, d, "/run/systemd/resolve/stub-resolv.conf")
+
+	report := d.Run(context.Background())
+
+	check := findCheck(t, report, "resolv.conf")
+	if check.Status != Fail || !strings.Contains(check.Message, "which does not exist") || check.Hint == "" {
+		t.Errorf("expected a failure with a hint, got %+v", check)
+	}
+}
+
+// TestRun_Missing tests that a missing resolv.conf fails.
+func TestRun_Missing(t *testing.T) {
+	d, _ := testDoctor(t, "resolv.conf")
+
+	report := d.Run(context.Background())
+
+	if check := findCheck(t, report, "resolv.conf"); check.Status != Fail {
+		t.Errorf("expected a failure, got %+v", check)
+	}
+}
+
+// TestRun_StubNotRunning tests that a resolv.conf pointing at the stub
+// fails if systemd-resolved is not running.
+func TestRun_StubNotRunning(t *testing.T) {
+	d, _ := testDoctor(t, "systemd-resolved")
+	writeFile(t, d, resolvConfPath, stubResolvConf)
+
+	report := d.Run(context.Background())
+
+	check := findCheck(t, report, "systemd-resolved stub")
+	if check.Status != Fail || !strings.Contains(check.Message, "systemd-resolved is not running") {
+		t.Errorf("expected a failure, got %+v", check)
+	}
+}
+
+// TestRun_StubListenerDisabled tests that a drop-in disabling the stub
+// listener is found.
+func TestRun_StubListenerDisabled(t *testing.T) {
+	d, _ := testDoctor(t, "systemd-resolved")
+	writeFile(t, d, resolvConfPath, stubResolvConf)
+	writeFile(t, d, "run/systemd/resolve/resolv.conf", "nameserver 1.1.1.1\n")
+	writeFile(t, d, "etc/systemd/resolved.conf", "[Resolve]\nDNSStubListener=yes\n")
+	writeFile(t, d, "etc/systemd/resolved.conf.d/dnsmasq.conf", "[Resolve]\nDNSStubListener=no\n")
+
+	report := d.Run(context.Background())
+
+	check := findCheck(t, report, "systemd-resolved stub")
+	if check.Status != Fail || !strings.Contains(check.Message, "/etc/systemd/resolved.conf.d/dnsmasq.conf") {
+		t.Errorf("expected a failure naming the drop-in, got %+v", check)
+	}
+}
+
+// TestRun_Writer tests detecting which tool wrote resolv.conf.
+func TestRun_Writer(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		content string
+		status  Status
+		message string
+	}{
+		{
+			name:    "tailscale",
+			backend: "NetworkManager",
+			content: "# resolv.conf(5) file generated by tailscale\nnameserver 100.100.100.100\n",
+			status:  Warn,
+			message: "/etc/resolv.conf was written by Tailscale, which overrides NetworkManager",
+		},
+		{
+			name:    "other manager",
+			backend: "systemd-resolved",
+			content: "# Generated by NetworkManager\nnameserver 192.168.1.1\n",
+			status:  Warn,
+			message: "/etc/resolv.conf is written by NetworkManager, not systemd-resolved",
+		},
+		{
+			name:    "backend",
+			backend: "resolvconf",
+			content: "# Generated by resolvconf\nnameserver 192.168.1.1\n",
+			status:  Pass,
+			message: "/etc/resolv.conf is written by resolvconf",
+		},
+		{
+			name:    "unmarked",
+			backend: "resolv.conf",
+			content: "nameserver 192.168.1.1\n",
+			status:  Pass,
+			message: "no tool has marked /etc/resolv.conf as generated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := testDoctor(t, tt.backend)
+			writeFile(t, d, resolvConfPath, tt.content)
+
+			check := findCheck(t, d.Run(context.Background()), "resolv.conf owner")
+
+			if check.Status != tt.status || check.Message != tt.message {
+				t.Errorf("expected %s %q, got %+v", tt.status, tt.message, check)
+			}
+			if tt.status == Warn && check.Hint == "" {
+				t.Error("expected a hint")
+			}
+		})
+	}
+}
+
+// TestRun_NetworkManagerDNSNone tests that dns=none warns when the
+// NetworkManager backend is used, and that later drop-ins win.
+func TestRun_NetworkManagerDNSNone(t *testing.T) {
+	d, _ := testDoctor(t, "NetworkManager")
+	writeFile(t, d, resolvConfPath, "nameserver 192.168.1.1\n")
+	writeFile(t, d, "etc/NetworkManager/NetworkManager.conf", "[main]\nplugins=keyfile\ndns=default\n")
+	writeFile(t, d, "usr/lib/NetworkManager/conf.d/10-dns.conf", "[main]\ndns=systemd-resolved\n")
+	writeFile(t, d, "etc/NetworkManager/conf.d/90-dns.conf", "[main]\ndns=none\n")
+
+	report := d.Run(context.Background())
+
+	check := findCheck(t, report, "NetworkManager")
+	if check.Status != Warn || !strings.Contains(check.Message, "dns=none in /etc/NetworkManager/conf.d/90-dns.conf") {
+		t.Errorf("expected a warning naming the drop-in, got %+v", check)
+	}
+}
+
+// TestRun_NetworkManagerMode tests that other dns= modes pass.
+func TestRun_NetworkManagerMode(t *testing.T) {
+	d, _ := testDoctor(t, "systemd-resolved")
+	writeFile(t, d, resolvConfPath, "nameserver 192.168.1.1\n")
+	writeFile(t, d, "etc/NetworkManager/NetworkManager.conf", "[main]\ndns=systemd-resolved\n")
+
+	check := findCheck(t, d.Run(context.Background()), "NetworkManager")
+
+	if check.Status != Pass || check.Message != "NetworkManager uses dns=systemd-resolved (in /etc/NetworkManager/NetworkManager.conf)" {
+		t.Errorf("unexpected check: %+v", check)
+	}
+}
+
+// TestRun_ResolvConfServers tests comparing the default service's servers
+// with those in resolv.conf.
+func TestRun_ResolvConfServers(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []string
+		status  Status
+	}{
+		{name: "match", servers: []string{"1.1.1.1", "1.0.0.1:53"}, status: Pass},
+		{name: "beyond the limit", servers: []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111", "9.9.9.9"}, status: Pass},
+		{name: "partial", servers: []string{"1.1.1.1", "9.9.9.9"}, status: Warn},
+		{name: "none", servers: []string{"9.9.9.9"}, status: Fail},
+		{name: "dhcp", servers: nil, status: Pass},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, mock := testDoctor(t, "resolv.conf")
+			mock.DNSServers["Wi-Fi"] = tt.servers
+			writeFile(t, d, resolvConfPath, "nameserver 1.1.1.1\nnameserver 1.0.0.1\nnameserver 2606:4700:4700::1111\n")
+
+			check := findCheck(t, d.Run(context.Background()), "resolv.conf servers")
+
+			if check.Status != tt.status {
+				t.Errorf("expected %s, got %+v", tt.status, check)
+			}
+		})
+	}
+}
+
+// TestRun_StubBackend tests that a stub resolv.conf warns when the
+// backend does not configure systemd-resolved.
+func TestRun_StubBackend(t *testing.T) {
+	d, _ := testDoctor(t, "resolvconf")
+	writeFile(t, d, resolvConfPath, stubResolvConf)
+
+	check := findCheck(t, d.Run(context.Background()), "resolv.conf servers")
+
+	if check.Status != Warn || check.Hint == "" {
+		t.Errorf("expected a warning with a hint, got %+v", check)
+	}
+}
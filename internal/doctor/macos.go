@@ -0,0 +1,12 @@
+//go:build darwin
+
+package doctor
+
+import "context"
+
+// systemChecks returns no checks on macOS. Its resolver takes servers
+// from the system configuration, which networksetup changes directly, and
+// /etc/resolv.conf is only generated from it for other programs.
+func (d *Doctor) systemChecks(ctx context.Context, v view) []Check {
+	return nil
+}
@@ -0,0 +1,247 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// Client is a dns.Client that reads through the user's own backend and
+// makes every change in the privileged helper.
+type Client struct {
+	reader  dns.Client
+	runner  cmdexec.Runner
+	command []string
+
+	// persistence is sent with every change, if set.
+	persistence dns.Persistence
+
+	// backend is sent with every request, so the helper uses the same
+	// backend as reader.
+	backend string
+}
+
+// DomainClient is a Client for backends that also manage domains.
+type DomainClient struct {
+	*Client
+}
+
+// CombinedClient is a DomainClient for backends that can also set
+// servers and domains in one change.
+type CombinedClient struct {
+	DomainClient
+}
+
+// Compile-time interface checks.
+var (
+	_ dns.ContextClient          = (*Client)(nil)
+	_ dns.DefaultServiceDetector = (*Client)(nil)
+	_ dns.PersistenceSwitcher    = (*Client)(nil)
+	_ dns.ManualDetector         = (*Client)(nil)
+	_ dns.DomainClient           = DomainClient{}
+	_ dns.CombinedClient         = CombinedClient{}
+)
+
+// New creates a Client that only calls the read-only methods of reader
+// and runs changes in "dnsctl helper", started as mode (config.ElevateSudo
+// or config.ElevatePkexec) through runner. backend names the backend of
+// reader, as in settings.backend, for the helper to use too; empty lets
+// the helper detect it. The result is a DomainClient if reader manages
+// domains, and a CombinedClient if it also sets both at once.
+func New(reader dns.Client, backend, mode string, runner cmdexec.Runner) (dns.Client, error) {
+	command, err := Command(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return (&Client{reader: reader, runner: runner, command: command, backend: backend}).wrap(), nil
+}
+
+// wrap returns c as a DomainClient if its reader manages domains, and
+// as a CombinedClient if it also sets both at once.
+func (c *Client) wrap() dns.Client {
+	if _, ok := c.reader.(dns.CombinedClient); ok {
+		return CombinedClient{DomainClient{c}}
+	}
+	if _, ok := c.reader.(dns.DomainClient); ok {
+		return DomainClient{c}
+	}
+	return c
+}
+
+// Command returns the command line that starts the helper as mode, without
+// the request. sudo is run with -n, as a password prompt would corrupt the
+// TUI; configure sudo to allow "dnsctl helper" without one.
+func Command(mode string) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate dnsctl: %w", err)
+	}
+
+	switch mode {
+	case config.ElevateSudo:
+		return []string{"sudo", "-n", exe, "helper"}, nil
+	case config.ElevatePkexec:
+		return []string{"pkexec", exe, "helper"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported elevate mode %q", mode)
+	}
+}
+
+// Name returns the backend name.
+func (c *Client) Name() string {
+	return c.reader.Name()
+}
+
+// Persistence returns how long the backend's changes last.
+func (c *Client) Persistence() dns.Persistence {
+	if c.persistence != "" {
+		return c.persistence
+	}
+	return dns.PersistenceOf(c.reader)
+}
+
+// WithPersistence returns a Client that reads through the backend's
+// client for p and has the helper make changes with p.
+func (c *Client) WithPersistence(p dns.Persistence) (dns.Client, error) {
+	reader, err := dns.WithPersistence(c.reader, p)
+	if err != nil {
+		return nil, err
+	}
+	return (&Client{reader: reader, runner: c.runner, command: c.command, persistence: p, backend: c.backend}).wrap(), nil
+}
+
+// ListNetworkServices returns the backend's services.
+func (c *Client) ListNetworkServices() ([]string, error) {
+	return c.reader.ListNetworkServices()
+}
+
+// ListNetworkServicesContext returns the backend's services.
+func (c *Client) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	return dns.WithContext(c.reader).ListNetworkServicesContext(ctx)
+}
+
+// DefaultService returns the service holding the default route, if the
+// backend can detect it.
+func (c *Client) DefaultService() (string, error) {
+	if detector, ok := c.reader.(dns.DefaultServiceDetector); ok {
+		return detector.DefaultService()
+	}
+	return "", nil
+}
+
+// GetDNSServers returns the DNS servers of a service.
+func (c *Client) GetDNSServers(service string) ([]string, error) {
+	return c.reader.GetDNSServers(service)
+}
+
+// GetDNSServersContext returns the DNS servers of a service.
+func (c *Client) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	return dns.WithContext(c.reader).GetDNSServersContext(ctx, service)
+}
+
+// HasManualDNS reports whether the servers of a service were set
+// manually, as the backend tells.
+func (c *Client) HasManualDNS(ctx context.Context, service string) (bool, error) {
+	servers, err := c.GetDNSServersContext(ctx, service)
+	if err != nil {
+		return false, err
+	}
+	return dns.HasManualDNS(ctx, c.reader, service, servers)
+}
+
+// SetDNSServers sets the DNS servers of a service through the helper.
+func (c *Client) SetDNSServers(service string, servers []string) error {
+	return c.SetDNSServersContext(context.Background(), service, servers)
+}
+
+// SetDNSServersContext sets the DNS servers of a service through the helper.
+func (c *Client) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	return c.run(ctx, "set DNS servers", Request{Op: OpSetServers, Service: service, Servers: servers})
+}
+
+// ClearDNSServers clears the DNS servers of a service through the helper.
+func (c *Client) ClearDNSServers(service string) error {
+	return c.ClearDNSServersContext(context.Background(), service)
+}
+
+// ClearDNSServersContext clears the DNS servers of a service through the helper.
+func (c *Client) ClearDNSServersContext(ctx context.Context, service string) error {
+	return c.run(ctx, "clear DNS servers", Request{Op: OpClearServers, Service: service})
+}
+
+// FlushCache flushes the DNS cache through the helper.
+func (c *Client) FlushCache() error {
+	return c.FlushCacheContext(context.Background())
+}
+
+// FlushCacheContext flushes the DNS cache through the helper.
+func (c *Client) FlushCacheContext(ctx context.Context) error {
+	return c.run(ctx, "flush DNS cache", Request{Op: OpFlush})
+}
+
+// GetDomains returns the domains of a service.
+func (c DomainClient) GetDomains(ctx context.Context, service string) (dns.Domains, error) {
+	return c.reader.(dns.DomainClient).GetDomains(ctx, service)
+}
+
+// SetDomains replaces the domains of a service through the helper.
+func (c DomainClient) SetDomains(ctx context.Context, service string, domains dns.Domains) error {
+	return c.run(ctx, "set domains", Request{
+		Op:               OpSetDomains,
+		Service:          service,
+		Domains:          domains.Search,
+		RouteOnlyDomains: domains.RouteOnly,
+	})
+}
+
+// SetDNSAndDomains replaces the servers and domains of a service through
+// the helper, in a single request.
+func (c CombinedClient) SetDNSAndDomains(ctx context.Context, service string, servers []string, domains dns.Domains) error {
+	return c.run(ctx, "set DNS servers and domains", Request{
+		Op:               OpSetAll,
+		Service:          service,
+		Servers:          servers,
+		Domains:          domains.Search,
+		RouteOnlyDomains: domains.RouteOnly,
+	})
+}
+
+// run starts the helper with a request. The helper reports failures
+// like any other dnsctl command, as "Error: ..." on stderr. The time left
+// until ctx's deadline is sent along, so the helper gives up when the
+// caller does rather than after the default apply timeout.
+func (c *Client) run(ctx context.Context, action string, req Request) error {
+	req.Version = Version
+	req.Backend = c.backend
+	if req.Op != OpFlush {
+		req.Persistence = string(c.persistence)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Timeout = max(time.Until(deadline), 0)
+	}
+	if err := req.Validate(); err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	data, err := req.Encode()
+	if err != nil {
+		return err
+	}
+
+	args := append(slices.Clone(c.command[1:]), data)
+	result, err := c.runner.Run(ctx, c.command[0], args...)
+	if err != nil {
+		if output := strings.TrimPrefix(result.Output(), "Error: "); output != "" {
+			return fmt.Errorf("failed to %s: %s: %w", action, output, err)
+		}
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return nil
+}
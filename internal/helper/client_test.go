@@ -0,0 +1,249 @@
+package helper
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// helperCommand returns the command line that starts the helper with sudo.
+func helperCommand(t *testing.T, request string) string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to locate test binary: %v", err)
+	}
+	return "sudo -n " + exe + " helper " + request
+}
+
+// TestClient_ChangesThroughHelper tests that reads go to the backend and
+// changes start the helper.
+func TestClient_ChangesThroughHelper(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.1"}
+	runner := cmdexectest.NewRunner().
+		Expect(helperCommand(t, `{"version":1,"op":"set_servers","service":"Wi-Fi","servers":["1.1.1.1"]}`), "").
+		Expect(helperCommand(t, `{"version":1,"op":"set_domains","service":"Wi-Fi","route_only_domains":["."]}`), "").
+		Expect(helperCommand(t, `{"version":1,"op":"flush"}`), "")
+
+	client, err := New(mock, "", config.ElevateSudo, runner)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	servers, err := client.GetDNSServers("Wi-Fi")
+	if err != nil || len(servers) != 1 || servers[0] != "192.168.1.1" {
+		t.Errorf("expected servers from the backend, got %v (%v)", servers, err)
+	}
+	if err := client.SetDNSServers("Wi-Fi", []string{"1.1.1.1"}); err != nil {
+		t.Errorf("failed to set servers: %v", err)
+	}
+	domainClient, ok := client.(dns.DomainClient)
+	if !ok {
+		t.Fatalf("expected a DomainClient for a backend with domains")
+	}
+	if err := domainClient.SetDomains(context.Background(), "Wi-Fi", dns.Domains{RouteOnly: []string{"."}}); err != nil {
+		t.Errorf("failed to set domains: %v", err)
+	}
+	if err := client.FlushCache(); err != nil {
+		t.Errorf("failed to flush: %v", err)
+	}
+
+	if len(runner.Calls) != 3 {
+		t.Errorf("expected 3 helper runs, got %v", runner.Commands())
+	}
+	if len(mock.SetCalls) != 0 || mock.FlushCalls != 0 {
+		t.Errorf("expected no direct changes, got %v and %d flushes", mock.SetCalls, mock.FlushCalls)
+	}
+}
+
+// TestClient_HelperError tests that the helper's message is reported
+// without its "Error: " prefix.
+func TestClient_HelperError(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Fail(helperCommand(t, `{"version":1,"op":"clear_servers","service":"tun0"}`), 1, "Error: unknown network service \"tun0\"\n")
+	client, err := New(dns.NewMockClient(), "", config.ElevateSudo, runner)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	err = client.ClearDNSServers("tun0")
+
+	want := `failed to clear DNS servers: unknown network service "tun0": sudo exited with status 1`
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got: %v", want, err)
+	}
+}
+
+// TestClient_InvalidRequest tests that invalid changes fail before
+// starting the helper.
+func TestClient_InvalidRequest(t *testing.T) {
+	runner := cmdexectest.NewRunner()
+	client, err := New(dns.NewMockClient(), "", config.ElevateSudo, runner)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	err = client.SetDNSServers("Wi-Fi", []string{"not-an-address"})
+
+	if err == nil || !strings.Contains(err.Error(), "invalid server address") {
+		t.Errorf("expected invalid server error, got: %v", err)
+	}
+	if len(runner.Calls) != 0 {
+		t.Errorf("expected the helper not to run, got %v", runner.Commands())
+	}
+}
+
+// TestNew_WithoutDomains tests that backends without domain support do not
+// gain it through the helper.
+func TestNew_WithoutDomains(t *testing.T) {
+	reader := struct{ dns.Client }{dns.NewMockClient()}
+
+	client, err := New(reader, "", config.ElevatePkexec, cmdexectest.NewRunner())
+
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, ok := client.(dns.DomainClient); ok {
+		t.Errorf("expected no DomainClient for a backend without domains")
+	}
+}
+
+// TestCommand tests the command line for each way of elevating.
+func TestCommand(t *testing.T) {
+	exe, _ := os.Executable()
+
+	sudo, _ := Command(config.ElevateSudo)
+	pkexec, _ := Command(config.ElevatePkexec)
+	_, err := Command("doas")
+
+	if strings.Join(sudo, " ") != "sudo -n "+exe+" helper" {
+		t.Errorf("unexpected sudo command: %v", sudo)
+	}
+	if strings.Join(pkexec, " ") != "pkexec "+exe+" helper" {
+		t.Errorf("unexpected pkexec command: %v", pkexec)
+	}
+	if err == nil {
+		t.Errorf("expected an error for an unsupported mode")
+	}
+}
+
+// TestClient_WithPersistence tests that the chosen persistence is sent to
+// the helper and reads go to the backend's client for it.
+func TestClient_WithPersistence(t *testing.T) {
+	persistent := dns.NewMockClient()
+	persistent.PersistenceMode = dns.Persistent
+	persistent.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	mock := dns.NewMockClient()
+	mock.PersistenceMode = dns.Runtime
+	mock.Alternate = persistent
+	runner := cmdexectest.NewRunner().
+		Expect(helperCommand(t, `{"version":1,"op":"clear_servers","service":"Wi-Fi","persistence":"persistent"}`), "")
+	client, err := New(mock, "", config.ElevateSudo, runner)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	scoped, err := dns.WithPersistence(client, dns.Persistent)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := dns.PersistenceOf(scoped); got != dns.Persistent {
+		t.Errorf("expected persistent, got %q", got)
+	}
+	if servers, err := scoped.GetDNSServers("Wi-Fi"); err != nil || len(servers) != 1 || servers[0] != "9.9.9.9" {
+		t.Errorf("expected servers from the persistent client, got %v (%v)", servers, err)
+	}
+	if err := scoped.ClearDNSServers("Wi-Fi"); err != nil {
+		t.Errorf("failed to clear servers: %v", err)
+	}
+	if _, ok := scoped.(dns.DomainClient); !ok {
+		t.Error("expected a DomainClient for a backend with domains")
+	}
+}
+
+// TestClient_Backend tests that the backend is sent with every request.
+func TestClient_Backend(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Expect(helperCommand(t, `{"version":1,"op":"set_servers","service":"wlan0","servers":["1.1.1.1"],"backend":"networkmanager"}`), "").
+		Expect(helperCommand(t, `{"version":1,"op":"flush","backend":"networkmanager"}`), "")
+	client, err := New(dns.NewMockClient(), "networkmanager", config.ElevateSudo, runner)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	setErr := client.SetDNSServers("wlan0", []string{"1.1.1.1"})
+	flushErr := client.FlushCache()
+
+	if setErr != nil || flushErr != nil {
+		t.Errorf("expected no errors, got: %v, %v", setErr, flushErr)
+	}
+	if len(runner.Calls) != 2 {
+		t.Errorf("expected 2 helper runs, got %v", runner.Commands())
+	}
+}
+
+// TestClient_Timeout tests that the time left until the caller's deadline
+// is sent to the helper.
+func TestClient_Timeout(t *testing.T) {
+	runner := cmdexectest.NewRunner()
+	client, err := New(dns.NewMockClient(), "", config.ElevateSudo, runner)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// The fake runner has no result for the request, whose timeout varies
+	_ = dns.WithContext(client).ClearDNSServersContext(ctx, "Wi-Fi")
+
+	if len(runner.Calls) != 1 {
+		t.Fatalf("expected 1 helper run, got %v", runner.Commands())
+	}
+	args := runner.Calls[0].Args
+	req, err := ParseRequest(args[len(args)-1])
+	if err != nil {
+		t.Fatalf("expected a valid request, got: %v", err)
+	}
+	if req.Timeout <= 0 || req.Timeout > time.Minute {
+		t.Errorf("expected a timeout of at most 1m, got %s", req.Timeout)
+	}
+}
+
+// TestClient_HasManualDNS tests that servers the backend learned from
+// DHCP are captured as such through the helper.
+func TestClient_HasManualDNS(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.1"}
+	mock.DNSServers["Ethernet"] = []string{"9.9.9.9"}
+	mock.Automatic = map[string]bool{"Wi-Fi": true}
+	client, err := New(mock, "", config.ElevateSudo, cmdexectest.NewRunner())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	applier := apply.New(client, config.Settings{})
+
+	automatic, err := applier.Capture(context.Background(), "Wi-Fi")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	manual, err := applier.Capture(context.Background(), "Ethernet")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !automatic.DHCP {
+		t.Errorf("expected the Wi-Fi servers to come from DHCP, got %+v", automatic)
+	}
+	if manual.DHCP {
+		t.Errorf("expected the Ethernet servers to be manual, got %+v", manual)
+	}
+}
@@ -0,0 +1,72 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// Execute makes the change a validated request asks for. Only services
+// the backend lists are accepted, so a request can never pass an
+// arbitrary argument to a backend command.
+func Execute(ctx context.Context, backend dns.Client, req Request) error {
+	plain, err := dns.WithPersistence(backend, dns.Persistence(req.Persistence))
+	if err != nil {
+		return err
+	}
+	client := dns.WithContext(plain)
+
+	if req.Service != "" {
+		services, err := client.ListNetworkServicesContext(ctx)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(services, req.Service) {
+			return fmt.Errorf("unknown network service %q", req.Service)
+		}
+	}
+
+	switch req.Op {
+	case OpSetServers:
+		return client.SetDNSServersContext(ctx, req.Service, req.Servers)
+	case OpClearServers:
+		return client.ClearDNSServersContext(ctx, req.Service)
+	case OpSetDomains:
+		domainClient, ok := plain.(dns.DomainClient)
+		if !ok {
+			return fmt.Errorf("%s does not support search domains", plain.Name())
+		}
+		return domainClient.SetDomains(ctx, req.Service, dns.Domains{Search: req.Domains, RouteOnly: req.RouteOnlyDomains})
+	case OpSetAll:
+		return setAll(ctx, plain, req)
+	case OpFlush:
+		return client.FlushCacheContext(ctx)
+	default:
+		return fmt.Errorf("unknown operation %q", req.Op)
+	}
+}
+
+// setAll replaces the servers and domains of a service, in one change if
+// the backend can make one.
+func setAll(ctx context.Context, plain dns.Client, req Request) error {
+	domains := dns.Domains{Search: req.Domains, RouteOnly: req.RouteOnlyDomains}
+	if combined, ok := plain.(dns.CombinedClient); ok {
+		return combined.SetDNSAndDomains(ctx, req.Service, req.Servers, domains)
+	}
+
+	domainClient, ok := plain.(dns.DomainClient)
+	if !ok {
+		return fmt.Errorf("%s does not support search domains", plain.Name())
+	}
+	client := dns.WithContext(plain)
+	if len(req.Servers) == 0 {
+		if err := client.ClearDNSServersContext(ctx, req.Service); err != nil {
+			return err
+		}
+	} else if err := client.SetDNSServersContext(ctx, req.Service, req.Servers); err != nil {
+		return err
+	}
+	return domainClient.SetDomains(ctx, req.Service, domains)
+}
@@ -0,0 +1,103 @@
+package helper
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// TestExecute tests that each operation reaches the backend.
+func TestExecute(t *testing.T) {
+	mock := dns.NewMockClient()
+	ctx := context.Background()
+
+	requests := []Request{
+		{Version: Version, Op: OpSetServers, Service: "Wi-Fi", Servers: []string{"1.1.1.1"}},
+		{Version: Version, Op: OpSetDomains, Service: "Wi-Fi", Domains: []string{"corp.example.com"}},
+		{Version: Version, Op: OpClearServers, Service: "Ethernet"},
+		{Version: Version, Op: OpFlush},
+	}
+	for _, req := range requests {
+		if err := Execute(ctx, mock, req); err != nil {
+			t.Fatalf("%s: expected no error, got: %v", req.Op, err)
+		}
+	}
+
+	if len(mock.SetCalls) != 1 || !slices.Equal(mock.SetCalls[0].Servers, []string{"1.1.1.1"}) {
+		t.Errorf("unexpected set calls: %v", mock.SetCalls)
+	}
+	if len(mock.SetDomainsCalls) != 1 || !slices.Equal(mock.SetDomainsCalls[0].Domains.Search, []string{"corp.example.com"}) {
+		t.Errorf("unexpected set domains calls: %v", mock.SetDomainsCalls)
+	}
+	if !slices.Equal(mock.ClearCalls, []string{"Ethernet"}) {
+		t.Errorf("unexpected clear calls: %v", mock.ClearCalls)
+	}
+	if mock.FlushCalls != 1 {
+		t.Errorf("expected 1 flush, got %d", mock.FlushCalls)
+	}
+}
+
+// TestExecute_SetAll tests that servers and domains are set one after
+// the other on backends that cannot set them at once.
+func TestExecute_SetAll(t *testing.T) {
+	mock := dns.NewMockClient()
+
+	err := Execute(context.Background(), mock, Request{
+		Version: Version,
+		Op:      OpSetAll,
+		Service: "Wi-Fi",
+		Servers: []string{"10.0.0.53"},
+		Domains: []string{"corp.example.com"},
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(mock.SetCalls) != 1 || !slices.Equal(mock.SetCalls[0].Servers, []string{"10.0.0.53"}) {
+		t.Errorf("unexpected set calls: %v", mock.SetCalls)
+	}
+	if len(mock.SetDomainsCalls) != 1 || !slices.Equal(mock.SetDomainsCalls[0].Domains.Search, []string{"corp.example.com"}) {
+		t.Errorf("unexpected set domains calls: %v", mock.SetDomainsCalls)
+	}
+}
+
+// TestExecute_UnknownService tests that only listed services are changed.
+func TestExecute_UnknownService(t *testing.T) {
+	mock := dns.NewMockClient()
+
+	err := Execute(context.Background(), mock, Request{Version: Version, Op: OpClearServers, Service: "--all"})
+
+	if err == nil || err.Error() != `unknown network service "--all"` {
+		t.Errorf("expected unknown service error, got: %v", err)
+	}
+	if len(mock.ClearCalls) != 0 {
+		t.Errorf("expected no changes, got %v", mock.ClearCalls)
+	}
+}
+
+// TestExecute_Persistence tests that the change is made with the
+// requested persistence.
+func TestExecute_Persistence(t *testing.T) {
+	persistent := dns.NewMockClient()
+	persistent.PersistenceMode = dns.Persistent
+	mock := dns.NewMockClient()
+	mock.PersistenceMode = dns.Runtime
+	mock.Alternate = persistent
+
+	err := Execute(context.Background(), mock, Request{
+		Version:     Version,
+		Op:          OpSetServers,
+		Service:     "Wi-Fi",
+		Servers:     []string{"1.1.1.1"},
+		Persistence: "persistent",
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(persistent.SetCalls) != 1 || len(mock.SetCalls) != 0 {
+		t.Errorf("expected a persistent change, got %v and %v", persistent.SetCalls, mock.SetCalls)
+	}
+}
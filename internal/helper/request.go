@@ -0,0 +1,179 @@
+// Package helper runs DNS changes in a separate privileged process, so
+// the TUI, the commands and config loading stay in the user's context.
+//
+// The unprivileged side sends a single Request, encoded as JSON, as the
+// only argument of "dnsctl helper", started with sudo or pkexec. The
+// helper accepts nothing else: the request is validated strictly and
+// may only name services the backend lists.
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+)
+
+// Version is the request format understood by this build.
+const Version = 1
+
+// MaxRequestSize limits the length of an encoded request.
+const MaxRequestSize = 4096
+
+// MaxTimeout caps the time a request may give the helper, so a caller
+// cannot keep a privileged process around indefinitely.
+const MaxTimeout = 5 * time.Minute
+
+// Operations a request may ask for.
+const (
+	OpSetServers   = "set_servers"
+	OpClearServers = "clear_servers"
+	OpSetDomains   = "set_domains"
+	OpSetAll       = "set_all"
+	OpFlush        = "flush"
+)
+
+// Request is one DNS change for the helper to make.
+type Request struct {
+	Version          int      `json:"version"`
+	Op               string   `json:"op"`
+	Service          string   `json:"service,omitempty"`
+	Servers          []string `json:"servers,omitempty"`
+	Domains          []string `json:"domains,omitempty"`
+	RouteOnlyDomains []string `json:"route_only_domains,omitempty"`
+
+	// Persistence is the dns.Persistence to make the change with, or
+	// empty for the backend's own.
+	Persistence string `json:"persistence,omitempty"`
+
+	// Backend names the backend to make the change through, as in
+	// settings.backend, or is empty to detect it.
+	Backend string `json:"backend,omitempty"`
+
+	// Timeout is how long the caller waits for the change, or zero for
+	// config.DefaultApplyTimeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// Limit returns how long the helper may take: the request's timeout,
+// capped at MaxTimeout, or the default apply timeout if it has none.
+func (r Request) Limit() time.Duration {
+	if r.Timeout <= 0 {
+		return config.DefaultApplyTimeout
+	}
+	return min(r.Timeout, MaxTimeout)
+}
+
+// Encode returns the request as compact JSON.
+func (r Request) Encode() (string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ParseRequest decodes and validates an encoded request. Unknown fields,
+// trailing data and fields the operation does not use are rejected.
+func ParseRequest(data string) (Request, error) {
+	if len(data) > MaxRequestSize {
+		return Request{}, fmt.Errorf("invalid request: longer than %d bytes", MaxRequestSize)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(data)))
+	dec.DisallowUnknownFields()
+
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		return Request{}, fmt.Errorf("invalid request: %w", err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return Request{}, errors.New("invalid request: unexpected data after the request")
+	}
+
+	if err := req.Validate(); err != nil {
+		return Request{}, fmt.Errorf("invalid request: %w", err)
+	}
+	return req, nil
+}
+
+// Validate checks that the request is complete and well-formed.
+// Whether its service exists is only known to the backend, so Execute
+// checks that.
+func (r Request) Validate() error {
+	if r.Version != Version {
+		return fmt.Errorf("unsupported version %d (supported: %d)", r.Version, Version)
+	}
+
+	if config.ValidateBackend(r.Backend) != nil {
+		return fmt.Errorf("unsupported backend %q", r.Backend)
+	}
+
+	if r.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+
+	hasDomains := len(r.Domains) > 0 || len(r.RouteOnlyDomains) > 0
+
+	switch r.Op {
+	case OpSetServers:
+		if len(r.Servers) == 0 {
+			return errors.New("set_servers requires servers")
+		}
+		if hasDomains {
+			return errors.New("set_servers does not take domains")
+		}
+	case OpClearServers:
+		if len(r.Servers) > 0 || hasDomains {
+			return errors.New("clear_servers takes only a service")
+		}
+	case OpSetDomains:
+		if len(r.Servers) > 0 {
+			return errors.New("set_domains does not take servers")
+		}
+	case OpSetAll:
+		// Without servers, the service goes back to DHCP servers
+	case OpFlush:
+		if r.Service != "" || len(r.Servers) > 0 || hasDomains || r.Persistence != "" {
+			return errors.New("flush takes no arguments")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q", r.Op)
+	}
+
+	if r.Service == "" {
+		return fmt.Errorf("%s requires a service", r.Op)
+	}
+
+	switch r.Persistence {
+	case "", config.PersistenceRuntime, config.PersistencePersistent:
+	default:
+		return fmt.Errorf("unsupported persistence %q", r.Persistence)
+	}
+
+	for _, server := range r.Servers {
+		if err := config.ValidateServer(server); err != nil {
+			return err
+		}
+	}
+	for _, domain := range r.Domains {
+		if err := config.ValidateDomain(domain); err != nil {
+			return err
+		}
+	}
+	for _, domain := range r.RouteOnlyDomains {
+		// "." routes every lookup to the service's servers
+		if domain == "." {
+			continue
+		}
+		if err := config.ValidateDomain(domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package helper
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+)
+
+// TestParseRequest tests decoding a valid request.
+func TestParseRequest(t *testing.T) {
+	data := `{"version":1,"op":"set_servers","service":"Wi-Fi","servers":["1.1.1.1","2606:4700:4700::1111"]}`
+
+	req, err := ParseRequest(data)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if req.Op != OpSetServers || req.Service != "Wi-Fi" || !slices.Equal(req.Servers, []string{"1.1.1.1", "2606:4700:4700::1111"}) {
+		t.Errorf("unexpected request: %+v", req)
+	}
+}
+
+// TestParseRequest_RoundTrip tests that encoded requests parse back.
+func TestParseRequest_RoundTrip(t *testing.T) {
+	want := Request{Version: Version, Op: OpSetDomains, Service: "wlan0", Domains: []string{"corp.example.com"}, RouteOnlyDomains: []string{"."}}
+
+	data, err := want.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	got, err := ParseRequest(data)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got.Op != want.Op || got.Service != want.Service || !slices.Equal(got.Domains, want.Domains) || !slices.Equal(got.RouteOnlyDomains, want.RouteOnlyDomains) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+// TestParseRequest_Invalid tests that malformed requests are rejected.
+func TestParseRequest_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"not JSON", `set_servers Wi-Fi 1.1.1.1`, "invalid character"},
+		{"unknown field", `{"version":1,"op":"flush","command":"rm"}`, `unknown field "command"`},
+		{"trailing data", `{"version":1,"op":"flush"} {"version":1,"op":"flush"}`, "unexpected data"},
+		{"missing version", `{"op":"flush"}`, "unsupported version 0"},
+		{"unknown op", `{"version":1,"op":"exec"}`, `unknown operation "exec"`},
+		{"no service", `{"version":1,"op":"clear_servers"}`, "clear_servers requires a service"},
+		{"no servers", `{"version":1,"op":"set_servers","service":"Wi-Fi"}`, "set_servers requires servers"},
+		{"bad server", `{"version":1,"op":"set_servers","service":"Wi-Fi","servers":["1.1.1.1; reboot"]}`, "invalid server address"},
+		{"bad domain", `{"version":1,"op":"set_domains","service":"Wi-Fi","domains":["-x"]}`, "invalid domain"},
+		{"servers on clear", `{"version":1,"op":"clear_servers","service":"Wi-Fi","servers":["1.1.1.1"]}`, "takes only a service"},
+		{"service on flush", `{"version":1,"op":"flush","service":"Wi-Fi"}`, "flush takes no arguments"},
+		{"bad persistence", `{"version":1,"op":"clear_servers","service":"Wi-Fi","persistence":"forever"}`, `unsupported persistence "forever"`},
+		{"bad backend", `{"version":1,"op":"flush","backend":"/bin/sh"}`, `unsupported backend "/bin/sh"`},
+		{"negative timeout", `{"version":1,"op":"flush","timeout":-1}`, "timeout must not be negative"},
+		{"too long", `{"version":1,"op":"flush","service":"` + strings.Repeat("x", MaxRequestSize) + `"}`, "longer than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRequest(tt.data)
+
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestRequest_Limit tests that the request's timeout is used up to
+// MaxTimeout, and the default apply timeout without one.
+func TestRequest_Limit(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{0, config.DefaultApplyTimeout},
+		{5 * time.Second, 5 * time.Second},
+		{time.Hour, MaxTimeout},
+	}
+
+	for _, tt := range tests {
+		if got := (Request{Timeout: tt.timeout}).Limit(); got != tt.want {
+			t.Errorf("expected %s for a timeout of %s, got %s", tt.want, tt.timeout, got)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package network
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// procNetARP is the kernel's ARP cache.
+const procNetARP = "/proc/net/arp"
+
+// gateway returns the IPv4 default gateway and, if it is in the ARP
+// cache, its hardware address.
+func (d *Detector) gateway(ctx context.Context) (netip.Addr, string) {
+	data, err := d.ReadFile(dns.ProcNetRoute)
+	if err != nil {
+		return netip.Addr{}, ""
+	}
+	gateway := parseDefaultGateway(string(data))
+	if !gateway.IsValid() {
+		return netip.Addr{}, ""
+	}
+
+	data, err = d.ReadFile(procNetARP)
+	if err != nil {
+		return gateway, ""
+	}
+	return gateway, parseARPTable(string(data), gateway)
+}
+
+// parseDefaultGateway parses /proc/net/route content and returns the
+// gateway of the lowest-metric default route that has one.
+func parseDefaultGateway(output string) netip.Addr {
+	routes, err := dns.ParseDefaultRoutes(strings.NewReader(output))
+	if err != nil {
+		return netip.Addr{}
+	}
+	for _, route := range routes {
+		if route.Gateway.IsValid() {
+			return route.Gateway
+		}
+	}
+	return netip.Addr{}
+}
+
+// parseARPTable parses /proc/net/arp content and returns the hardware
+// address of ip, or "" if it has no complete entry.
+func parseARPTable(output string, ip netip.Addr) string {
+	for i, line := range strings.Split(output, "\n") {
+		if i == 0 {
+			continue
+		}
+
+		// Columns: IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[0] != ip.String() {
+			continue
+		}
+		// Flags 0x0 marks an incomplete entry
+		if fields[2] == "0x0" {
+			continue
+		}
+		if mac := normalizeMAC(fields[3]); mac != "" && mac != "00:00:00:00:00:00" {
+			return mac
+		}
+	}
+	return ""
+}
+
+// ssid returns the connected Wi-Fi network from NetworkManager, falling
+// back to iwgetid, or "" if neither reports one.
+func (d *Detector) ssid(ctx context.Context) string {
+	result, err := d.Runner.Run(ctx, "nmcli", "-t", "-f", "active,ssid", "device", "wifi", "list", "--rescan", "no")
+	if err == nil {
+		if ssid := parseNmcliSSID(string(result.Stdout)); ssid != "" {
+			return ssid
+		}
+	}
+
+	result, err = d.Runner.Run(ctx, "iwgetid", "-r")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(result.Stdout))
+}
+
+// parseNmcliSSID finds the active network in terse "ACTIVE:SSID" nmcli
+// output. Colons in names are escaped as "\:".
+func parseNmcliSSID(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		ssid, ok := strings.CutPrefix(line, "yes:")
+		if !ok {
+			continue
+		}
+		return dns.UnescapeNmcli(ssid)
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package network
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// TestParseDefaultGateway tests picking the default route with the lowest metric.
+func TestParseDefaultGateway(t *testing.T) {
+	gateway := parseDefaultGateway(fixture(t, "proc_net_route.txt"))
+
+	if gateway != netip.MustParseAddr("10.10.0.1") {
+		t.Errorf("expected 10.10.0.1, got %v", gateway)
+	}
+}
+
+// TestParseARPTable tests that only complete entries are returned.
+func TestParseARPTable(t *testing.T) {
+	table := fixture(t, "proc_net_arp.txt")
+
+	if got := parseARPTable(table, netip.MustParseAddr("10.10.0.1")); got != "00:1b:2c:3d:4e:5f" {
+		t.Errorf("expected 00:1b:2c:3d:4e:5f, got %q", got)
+	}
+	if got := parseARPTable(table, netip.MustParseAddr("10.10.0.77")); got != "" {
+		t.Errorf("expected no address for an incomplete entry, got %q", got)
+	}
+}
+
+// TestParseNmcliSSID tests finding the active network with escaped colons.
+func TestParseNmcliSSID(t *testing.T) {
+	if got := parseNmcliSSID(fixture(t, "nmcli_wifi.txt")); got != "Home:5GHz" {
+		t.Errorf("expected Home:5GHz, got %q", got)
+	}
+}
+
+// TestDetect tests gathering network facts from kernel tables and nmcli.
+func TestDetect(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Expect("nmcli -t -f active,ssid device wifi list --rescan no", fixture(t, "nmcli_wifi.txt"))
+	detector := &Detector{
+		Runner: runner,
+		ReadFile: func(name string) ([]byte, error) {
+			switch name {
+			case dns.ProcNetRoute:
+				return []byte(fixture(t, "proc_net_route.txt")), nil
+			case procNetARP:
+				return []byte(fixture(t, "proc_net_arp.txt")), nil
+			}
+			return nil, os.ErrNotExist
+		},
+		Interfaces: func() ([]Interface, error) {
+			return []Interface{{Name: "enp0s31f6"}}, nil
+		},
+	}
+
+	info, err := detector.Detect(context.Background())
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if info.SSID != "Home:5GHz" {
+		t.Errorf("expected Home:5GHz, got %q", info.SSID)
+	}
+	if info.Gateway != netip.MustParseAddr("10.10.0.1") || info.GatewayMAC != "00:1b:2c:3d:4e:5f" {
+		t.Errorf("unexpected gateway: %v %s", info.Gateway, info.GatewayMAC)
+	}
+	if len(info.Interfaces) != 1 {
+		t.Errorf("expected 1 interface, got %v", info.Interfaces)
+	}
+}
+
+// TestDetect_Wired tests falling back to iwgetid and tolerating no Wi-Fi.
+func TestDetect_Wired(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Fail("nmcli -t -f active,ssid device wifi list --rescan no", 8, "Error: NetworkManager is not running.").
+		Fail("iwgetid -r", 255, "")
+	detector := &Detector{
+		Runner: runner,
+		ReadFile: func(name string) ([]byte, error) {
+			return nil, os.ErrNotExist
+		},
+		Interfaces: func() ([]Interface, error) {
+			return nil, nil
+		},
+	}
+
+	info, err := detector.Detect(context.Background())
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if info.SSID != "" || info.Gateway.IsValid() {
+		t.Errorf("expected no SSID or gateway, got %+v", info)
+	}
+}
+
+// TestDetect_InterfaceError tests that failing to list interfaces is an error.
+func TestDetect_InterfaceError(t *testing.T) {
+	detector := &Detector{
+		Runner: cmdexectest.NewRunner(),
+		Interfaces: func() ([]Interface, error) {
+			return nil, errors.New("netlink unavailable")
+		},
+	}
+
+	_, err := detector.Detect(context.Background())
+
+	if err == nil || err.Error() != "netlink unavailable" {
+		t.Errorf("expected interface error, got: %v", err)
+	}
+}
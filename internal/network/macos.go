@@ -0,0 +1,105 @@
+//go:build darwin
+
+package network
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+)
+
+// gateway returns the default gateway from the routing table and, if it
+// is in the ARP cache, its hardware address.
+func (d *Detector) gateway(ctx context.Context) (netip.Addr, string) {
+	result, err := d.Runner.Run(ctx, "route", "-n", "get", "default")
+	if err != nil {
+		return netip.Addr{}, ""
+	}
+	gateway := parseRouteGateway(string(result.Stdout))
+	if !gateway.IsValid() {
+		return netip.Addr{}, ""
+	}
+
+	result, err = d.Runner.Run(ctx, "arp", "-n", gateway.String())
+	if err != nil {
+		return gateway, ""
+	}
+	return gateway, parseARPEntry(string(result.Stdout))
+}
+
+// parseRouteGateway parses "route -n get default" output and returns
+// the gateway address.
+func parseRouteGateway(output string) netip.Addr {
+	for _, line := range strings.Split(output, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "gateway:")
+		if !ok {
+			continue
+		}
+		addr, err := netip.ParseAddr(strings.TrimSpace(value))
+		if err != nil {
+			return netip.Addr{}
+		}
+		return addr
+	}
+	return netip.Addr{}
+}
+
+// parseARPEntry parses "arp -n <ip>" output such as
+// "? (192.168.1.1) at a0:b1:c2:d3:e4:f5 on en0 ifscope [ethernet]"
+// and returns the hardware address.
+func parseARPEntry(output string) string {
+	fields := strings.Fields(output)
+	for i, field := range fields {
+		if field == "at" && i+1 < len(fields) {
+			return normalizeMAC(fields[i+1])
+		}
+	}
+	return ""
+}
+
+// ssid returns the connected Wi-Fi network, or "" if there is none.
+func (d *Detector) ssid(ctx context.Context) string {
+	result, err := d.Runner.Run(ctx, "networksetup", "-listallhardwareports")
+	if err != nil {
+		return ""
+	}
+	device := parseWiFiDevice(string(result.Stdout))
+	if device == "" {
+		return ""
+	}
+
+	result, err = d.Runner.Run(ctx, "networksetup", "-getairportnetwork", device)
+	if err != nil {
+		return ""
+	}
+	return parseAirportNetwork(string(result.Stdout))
+}
+
+// parseWiFiDevice finds the device of the Wi-Fi hardware port in
+// "networksetup -listallhardwareports" output.
+func parseWiFiDevice(output string) string {
+	wifi := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if port, ok := strings.CutPrefix(line, "Hardware Port:"); ok {
+			port = strings.TrimSpace(port)
+			wifi = port == "Wi-Fi" || port == "AirPort"
+			continue
+		}
+		if device, ok := strings.CutPrefix(line, "Device:"); ok && wifi {
+			return strings.TrimSpace(device)
+		}
+	}
+	return ""
+}
+
+// parseAirportNetwork parses "networksetup -getairportnetwork" output.
+// It reports "You are not associated with an AirPort network." when
+// disconnected.
+func parseAirportNetwork(output string) string {
+	ssid, ok := strings.CutPrefix(strings.TrimSpace(output), "Current Wi-Fi Network:")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(ssid)
+}
@@ -0,0 +1,67 @@
+//go:build darwin
+
+package network
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec/cmdexectest"
+)
+
+// TestParseRouteGateway tests reading the gateway from "route get default".
+func TestParseRouteGateway(t *testing.T) {
+	gateway := parseRouteGateway(fixture(t, "route_get_default.txt"))
+
+	if gateway != netip.MustParseAddr("192.168.1.1") {
+		t.Errorf("expected 192.168.1.1, got %v", gateway)
+	}
+}
+
+// TestParseARPEntry tests that short octets are padded.
+func TestParseARPEntry(t *testing.T) {
+	if got := parseARPEntry(fixture(t, "arp_gateway.txt")); got != "00:1b:2c:3d:4e:5f" {
+		t.Errorf("expected 00:1b:2c:3d:4e:5f, got %q", got)
+	}
+	if got := parseARPEntry("? (192.168.1.1) -- no entry"); got != "" {
+		t.Errorf("expected no address, got %q", got)
+	}
+}
+
+// TestParseAirportNetwork tests the connected and disconnected outputs.
+func TestParseAirportNetwork(t *testing.T) {
+	if got := parseAirportNetwork(fixture(t, "networksetup_airport.txt")); got != "HomeNet" {
+		t.Errorf("expected HomeNet, got %q", got)
+	}
+	if got := parseAirportNetwork("You are not associated with an AirPort network."); got != "" {
+		t.Errorf("expected no SSID, got %q", got)
+	}
+}
+
+// TestDetect tests gathering network facts with route, arp and networksetup.
+func TestDetect(t *testing.T) {
+	runner := cmdexectest.NewRunner().
+		Expect("route -n get default", fixture(t, "route_get_default.txt")).
+		Expect("arp -n 192.168.1.1", fixture(t, "arp_gateway.txt")).
+		Expect("networksetup -listallhardwareports", fixture(t, "networksetup_hardwareports.txt")).
+		Expect("networksetup -getairportnetwork en0", fixture(t, "networksetup_airport.txt"))
+	detector := &Detector{
+		Runner: runner,
+		Interfaces: func() ([]Interface, error) {
+			return nil, nil
+		},
+	}
+
+	info, err := detector.Detect(context.Background())
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if info.SSID != "HomeNet" {
+		t.Errorf("expected HomeNet, got %q", info.SSID)
+	}
+	if info.Gateway != netip.MustParseAddr("192.168.1.1") || info.GatewayMAC != "00:1b:2c:3d:4e:5f" {
+		t.Errorf("unexpected gateway: %v %s", info.Gateway, info.GatewayMAC)
+	}
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"net/netip"
+	"path"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+)
+
+// Matches reports whether every condition set on the rule holds for the
+// network. A rule without conditions always matches.
+func (i Info) Matches(rule config.Rule) bool {
+	if rule.SSID != "" && rule.SSID != i.SSID {
+		return false
+	}
+
+	if rule.Gateway != "" {
+		want, err := netip.ParseAddr(rule.Gateway)
+		if err != nil || !i.Gateway.IsValid() || want.Unmap() != i.Gateway.Unmap() {
+			return false
+		}
+	}
+
+	if rule.GatewayMAC != "" {
+		if i.GatewayMAC == "" || normalizeMAC(rule.GatewayMAC) != i.GatewayMAC {
+			return false
+		}
+	}
+
+	if rule.Interface != "" && !i.hasInterface(rule.Interface) {
+		return false
+	}
+
+	if rule.Subnet != "" {
+		subnet, err := netip.ParsePrefix(rule.Subnet)
+		if err != nil || !i.inSubnet(subnet) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasInterface reports whether a connected interface matches the glob.
+func (i Info) hasInterface(pattern string) bool {
+	for _, iface := range i.Interfaces {
+		if ok, _ := path.Match(pattern, iface.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inSubnet reports whether a connected interface has an address in subnet.
+func (i Info) inSubnet(subnet netip.Prefix) bool {
+	for _, iface := range i.Interfaces {
+		for _, prefix := range iface.Prefixes {
+			if subnet.Contains(prefix.Addr().Unmap()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchRule returns the index of the first rule matching the network,
+// or -1 if none does.
+func (i Info) MatchRule(rules []config.Rule) int {
+	for idx, rule := range rules {
+		if i.Matches(rule) {
+			return idx
+		}
+	}
+	return -1
+}
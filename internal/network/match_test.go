@@ -0,0 +1,76 @@
+package network
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+)
+
+// homeNetwork is a Wi-Fi network with a USB Ethernet dock attached.
+var homeNetwork = Info{
+	SSID:       "HomeNet",
+	Gateway:    netip.MustParseAddr("192.168.1.1"),
+	GatewayMAC: "a0:b1:c2:d3:e4:f5",
+	Interfaces: []Interface{
+		{Name: "wlan0", Prefixes: []netip.Prefix{netip.MustParsePrefix("192.168.1.23/24")}},
+		{Name: "enx00e04c680001", Prefixes: []netip.Prefix{netip.MustParsePrefix("10.20.4.7/16")}},
+	},
+}
+
+// TestMatches tests each condition on its own.
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.Rule
+		want bool
+	}{
+		{"no conditions", config.Rule{}, true},
+		{"ssid", config.Rule{SSID: "HomeNet"}, true},
+		{"other ssid", config.Rule{SSID: "Airport"}, false},
+		{"gateway", config.Rule{Gateway: "192.168.1.1"}, true},
+		{"other gateway", config.Rule{Gateway: "10.0.0.1"}, false},
+		{"gateway mac", config.Rule{GatewayMAC: "A0:B1:C2:D3:E4:F5"}, true},
+		{"other gateway mac", config.Rule{GatewayMAC: "a0:b1:c2:d3:e4:00"}, false},
+		{"interface glob", config.Rule{Interface: "enx*"}, true},
+		{"missing interface", config.Rule{Interface: "eth0"}, false},
+		{"subnet", config.Rule{Subnet: "10.20.0.0/16"}, true},
+		{"other subnet", config.Rule{Subnet: "172.16.0.0/12"}, false},
+		{"all conditions", config.Rule{SSID: "HomeNet", Interface: "wlan*", Subnet: "192.168.1.0/24"}, true},
+		{"one condition fails", config.Rule{SSID: "HomeNet", Subnet: "172.16.0.0/12"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := homeNetwork.Matches(tt.rule); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestMatches_UnknownGateway tests that gateway rules fail without a default route.
+func TestMatches_UnknownGateway(t *testing.T) {
+	info := Info{SSID: "HomeNet"}
+
+	if info.Matches(config.Rule{Gateway: "192.168.1.1"}) {
+		t.Error("expected gateway rule not to match")
+	}
+	if info.Matches(config.Rule{GatewayMAC: "a0:b1:c2:d3:e4:f5"}) {
+		t.Error("expected gateway MAC rule not to match")
+	}
+}
+
+// TestMatchRule tests that the first matching rule wins.
+func TestMatchRule(t *testing.T) {
+	rules := []config.Rule{
+		{Profile: "office", Subnet: "172.16.0.0/12"},
+		{Profile: "home", SSID: "HomeNet"},
+		{Profile: "traveling"},
+	}
+
+	if got := homeNetwork.MatchRule(rules); got != 1 {
+		t.Errorf("expected rule 1, got %d", got)
+	}
+	if got := (Info{}).MatchRule(rules[:2]); got != -1 {
+		t.Errorf("expected no match, got %d", got)
+	}
+}
@@ -0,0 +1,118 @@
+// Package network describes the network the machine is connected to, so
+// that rules can pick a DNS profile for it.
+package network
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/nycjv321/dnsctl/internal/cmdexec"
+)
+
+// Info describes the current network.
+type Info struct {
+	// SSID is the name of the connected Wi-Fi network, if any.
+	SSID string
+	// Gateway is the default gateway; it is invalid without a default route.
+	Gateway netip.Addr
+	// GatewayMAC is the gateway's hardware address in lower-case,
+	// colon-separated form, if known.
+	GatewayMAC string
+	// Interfaces are the connected interfaces.
+	Interfaces []Interface
+}
+
+// Interface is a connected network interface and its addresses.
+type Interface struct {
+	Name     string
+	Prefixes []netip.Prefix
+}
+
+// Detector gathers Info about the current network.
+// Its fields are exposed so tests can replace the system sources.
+type Detector struct {
+	// Runner executes tools such as nmcli or networksetup.
+	Runner cmdexec.Runner
+	// ReadFile reads kernel tables such as /proc/net/route.
+	ReadFile func(name string) ([]byte, error)
+	// Interfaces lists the connected interfaces.
+	Interfaces func() ([]Interface, error)
+}
+
+// NewDetector creates a Detector that inspects the running system.
+func NewDetector() *Detector {
+	return &Detector{
+		Runner:     cmdexec.Exec(),
+		ReadFile:   os.ReadFile,
+		Interfaces: SystemInterfaces,
+	}
+}
+
+// Detect describes the current network.
+// Facts that cannot be determined, such as the SSID on a wired network or
+// the gateway without a default route, are left empty; only failing to
+// list the interfaces is an error.
+func (d *Detector) Detect(ctx context.Context) (Info, error) {
+	interfaces, err := d.Interfaces()
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{Interfaces: interfaces}
+	info.Gateway, info.GatewayMAC = d.gateway(ctx)
+	info.SSID = d.ssid(ctx)
+	return info, nil
+}
+
+// SystemInterfaces returns the interfaces that are up, are not loopback
+// and have at least one address.
+func SystemInterfaces() ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []Interface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		var prefixes []netip.Prefix
+		for _, addr := range addrs {
+			if prefix, err := netip.ParsePrefix(addr.String()); err == nil {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		if len(prefixes) > 0 {
+			interfaces = append(interfaces, Interface{Name: iface.Name, Prefixes: prefixes})
+		}
+	}
+	return interfaces, nil
+}
+
+// normalizeMAC returns a hardware address in lower-case, colon-separated
+// form with two digits per byte, or "" if it cannot be parsed.
+// macOS prints addresses without leading zeros, e.g. "0:1b:2c:3d:4e:5f".
+func normalizeMAC(mac string) string {
+	parts := strings.Split(mac, ":")
+	for i, part := range parts {
+		if len(part) == 1 {
+			parts[i] = "0" + part
+		}
+	}
+
+	hw, err := net.ParseMAC(strings.Join(parts, ":"))
+	if err != nil {
+		return ""
+	}
+	return hw.String()
+}
@@ -0,0 +1,34 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixture reads a file from testdata.
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+	return string(data)
+}
+
+// TestNormalizeMAC tests that hardware addresses compare regardless of format.
+func TestNormalizeMAC(t *testing.T) {
+	tests := map[string]string{
+		"A0:B1:C2:D3:E4:F5": "a0:b1:c2:d3:e4:f5",
+		"0:1b:2c:3d:4e:5f":  "00:1b:2c:3d:4e:5f",
+		"a0-b1-c2-d3-e4-f5": "a0:b1:c2:d3:e4:f5",
+		"(incomplete)":      "",
+	}
+
+	for input, want := range tests {
+		if got := normalizeMAC(input); got != want {
+			t.Errorf("normalizeMAC(%q): expected %q, got %q", input, want, got)
+		}
+	}
+}
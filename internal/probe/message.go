@@ -0,0 +1,108 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DNS message constants used by the probe.
+const (
+	headerLen = 12
+
+	typeA   = 1
+	classIN = 1
+
+	flagQR = 1 << 15 // response
+	flagTC = 1 << 9  // truncated
+	flagRD = 1 << 8  // recursion desired
+
+	rcodeMask = 0x000f
+)
+
+// Response codes a probe may see.
+const (
+	RcodeSuccess  = 0
+	RcodeFormErr  = 1
+	RcodeServFail = 2
+	RcodeNXDomain = 3
+	RcodeNotImp   = 4
+	RcodeRefused  = 5
+)
+
+var rcodeNames = map[int]string{
+	RcodeSuccess:  "NOERROR",
+	RcodeFormErr:  "FORMERR",
+	RcodeServFail: "SERVFAIL",
+	RcodeNXDomain: "NXDOMAIN",
+	RcodeNotImp:   "NOTIMP",
+	RcodeRefused:  "REFUSED",
+}
+
+// RcodeError is returned when a server answers with a failure response code.
+type RcodeError struct {
+	Rcode int
+}
+
+// Error implements the error interface.
+func (e *RcodeError) Error() string {
+	if name, ok := rcodeNames[e.Rcode]; ok {
+		return "server returned " + name
+	}
+	return fmt.Sprintf("server returned rcode %d", e.Rcode)
+}
+
+// errTruncated is returned for UDP responses with the TC bit set.
+var errTruncated = errors.New("response truncated")
+
+// buildQuery encodes a recursive A query for name with the given ID.
+func buildQuery(id uint16, name string) ([]byte, error) {
+	msg := make([]byte, headerLen, headerLen+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], flagRD)
+	binary.BigEndian.PutUint16(msg[4:], 1) // QDCOUNT
+
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if label == "" || len(label) > 63 {
+				return nil, fmt.Errorf("invalid probe name %q", name)
+			}
+			msg = append(msg, byte(len(label)))
+			msg = append(msg, label...)
+		}
+	}
+	msg = append(msg, 0)
+
+	msg = binary.BigEndian.AppendUint16(msg, typeA)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	return msg, nil
+}
+
+// checkResponse validates a response to the query with the given ID.
+// NXDOMAIN counts as an answer: the server resolved the name, it just
+// doesn't exist.
+func checkResponse(id uint16, msg []byte) error {
+	if len(msg) < headerLen {
+		return errors.New("short response")
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return errors.New("response ID mismatch")
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&flagQR == 0 {
+		return errors.New("not a response")
+	}
+	if flags&flagTC != 0 {
+		return errTruncated
+	}
+
+	switch rcode := int(flags & rcodeMask); rcode {
+	case RcodeSuccess, RcodeNXDomain:
+		return nil
+	default:
+		return &RcodeError{Rcode: rcode}
+	}
+}
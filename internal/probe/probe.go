@@ -0,0 +1,252 @@
+// Package probe checks that DNS servers answer queries.
+//
+// It sends a single recursive A query directly to each server, bypassing
+// the system resolver, so it can verify servers before or right after
+// they are configured.
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults used when a Prober field is unset.
+const (
+	DefaultName    = "example.com"
+	DefaultTimeout = 2 * time.Second
+	DefaultPort    = 53
+)
+
+// Result is the outcome of probing one server.
+type Result struct {
+	Server  string
+	Latency time.Duration
+	Err     error
+}
+
+// OK returns true if the server answered.
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+// String formats the result as "1.1.1.1 12ms" or "1.1.1.1 timed out".
+func (r Result) String() string {
+	if r.OK() {
+		return fmt.Sprintf("%s %s", r.Server, r.Latency.Round(time.Millisecond))
+	}
+	var netErr net.Error
+	if errors.As(r.Err, &netErr) && netErr.Timeout() {
+		return r.Server + " timed out"
+	}
+	if errors.Is(r.Err, context.DeadlineExceeded) {
+		return r.Server + " timed out"
+	}
+	return fmt.Sprintf("%s failed: %v", r.Server, r.Err)
+}
+
+// Checker probes a set of servers. Prober is the real implementation;
+// tests substitute a CheckerFunc.
+type Checker interface {
+	ProbeAll(ctx context.Context, servers []string) []Result
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context, servers []string) []Result
+
+// ProbeAll calls f(ctx, servers).
+func (f CheckerFunc) ProbeAll(ctx context.Context, servers []string) []Result {
+	return f(ctx, servers)
+}
+
+// Prober sends probe queries to DNS servers.
+type Prober struct {
+	// Name is the domain queried for an A record.
+	Name string
+	// Timeout bounds each query, including a TCP retry after truncation.
+	Timeout time.Duration
+	// Network is "udp" (the default, retrying over TCP when the answer is
+	// truncated) or "tcp".
+	Network string
+}
+
+// Probe queries a single server. Servers may be written as accepted in
+// profiles: "1.1.1.1", "1.1.1.1:53", "2606:4700:4700::1111",
+// "[2606:4700:4700::1111]:53" or "fe80::1%eth0".
+func (p Prober) Probe(ctx context.Context, server string) Result {
+	result := Result{Server: server}
+
+	addr, err := serverAddr(server)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	query, id, err := p.query()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	start := time.Now()
+	network := p.Network
+	if network == "" {
+		network = "udp"
+	}
+	err = exchange(ctx, network, addr, id, query)
+	if errors.Is(err, errTruncated) && network == "udp" {
+		err = exchange(ctx, "tcp", addr, id, query)
+	}
+	result.Latency = time.Since(start)
+	result.Err = err
+	return result
+}
+
+// ProbeAll queries every server concurrently and returns the results in
+// the order of servers.
+func (p Prober) ProbeAll(ctx context.Context, servers []string) []Result {
+	results := make([]Result, len(servers))
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = p.Probe(ctx, server)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// AnyOK returns true if at least one server answered.
+func AnyOK(results []Result) bool {
+	for _, r := range results {
+		if r.OK() {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary joins the results into one line, e.g.
+// "1.1.1.1 12ms, 1.0.0.1 timed out".
+func Summary(results []Result) string {
+	parts := make([]string, len(results))
+	for i, r := range results {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// query builds the probe query with a random ID.
+func (p Prober) query() ([]byte, uint16, error) {
+	name := p.Name
+	if name == "" {
+		name = DefaultName
+	}
+	id := uint16(rand.N(1 << 16))
+	msg, err := buildQuery(id, name)
+	return msg, id, err
+}
+
+// timeout returns the per-query timeout, or its default if unset.
+func (p Prober) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return DefaultTimeout
+}
+
+// serverAddr turns a profile server into a host:port to dial.
+func serverAddr(server string) (string, error) {
+	if addrPort, err := netip.ParseAddrPort(server); err == nil {
+		return addrPort.String(), nil
+	}
+	if addr, err := netip.ParseAddr(server); err == nil {
+		return netip.AddrPortFrom(addr, DefaultPort).String(), nil
+	}
+	return "", fmt.Errorf("invalid server address %q", server)
+}
+
+// exchange sends query to addr over network and waits for a valid answer.
+func exchange(ctx context.Context, network, addr string, id uint16, query []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock reads when ctx is cancelled before its deadline
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		return exchangeTCP(ctx, conn, id, query)
+	}
+	return exchangeUDP(ctx, conn, id, query)
+}
+
+// exchangeUDP sends a datagram query, ignoring stray datagrams that don't
+// answer it.
+func exchangeUDP(ctx context.Context, conn net.Conn, id uint16, query []byte) error {
+	if _, err := conn.Write(query); err != nil {
+		return contextErr(ctx, err)
+	}
+
+	buf := make([]byte, 512)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return contextErr(ctx, err)
+		}
+		if n >= 2 && binary.BigEndian.Uint16(buf) != id {
+			continue
+		}
+		return checkResponse(id, buf[:n])
+	}
+}
+
+// exchangeTCP sends a length-prefixed query over a stream.
+func exchangeTCP(ctx context.Context, conn net.Conn, id uint16, query []byte) error {
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	msg = append(msg, query...)
+	if _, err := conn.Write(msg); err != nil {
+		return contextErr(ctx, err)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return contextErr(ctx, err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return contextErr(ctx, err)
+	}
+	return checkResponse(id, resp)
+}
+
+// contextErr prefers the context's error over the I/O error it caused.
+func contextErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
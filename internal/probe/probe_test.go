@@ -0,0 +1,177 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/probe/probetest"
+)
+
+// TestBuildQuery tests the encoding of the probe question.
+func TestBuildQuery(t *testing.T) {
+	msg, err := buildQuery(0x1234, "example.com.")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := []byte{
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0x00, 0x01, 0x00, 0x01,
+	}
+	if string(msg) != string(want) {
+		t.Errorf("expected %x, got %x", want, msg)
+	}
+}
+
+// TestBuildQuery_InvalidName tests that empty labels are rejected.
+func TestBuildQuery_InvalidName(t *testing.T) {
+	if _, err := buildQuery(1, "example..com"); err == nil {
+		t.Error("expected error for empty label")
+	}
+}
+
+// TestProbe_UDP tests a successful UDP probe.
+func TestProbe_UDP(t *testing.T) {
+	stub := probetest.Start(t, probetest.Options{})
+	prober := Prober{Name: "probe.example.com", Timeout: time.Second}
+
+	result := prober.Probe(context.Background(), stub.Addr)
+
+	if !result.OK() {
+		t.Fatalf("expected success, got: %v", result.Err)
+	}
+	if result.Latency <= 0 {
+		t.Errorf("expected positive latency, got %s", result.Latency)
+	}
+	query := <-stub.UDPQueries
+	if !strings.Contains(string(query), "\x05probe\x07example\x03com\x00") {
+		t.Errorf("expected query for probe name, got %x", query)
+	}
+}
+
+// TestProbe_TCP tests probing over TCP only.
+func TestProbe_TCP(t *testing.T) {
+	stub := probetest.Start(t, probetest.Options{})
+	prober := Prober{Timeout: time.Second, Network: "tcp"}
+
+	result := prober.Probe(context.Background(), stub.Addr)
+
+	if !result.OK() {
+		t.Fatalf("expected success, got: %v", result.Err)
+	}
+	if len(stub.UDPQueries) != 0 {
+		t.Errorf("expected no UDP queries, got %d", len(stub.UDPQueries))
+	}
+}
+
+// TestProbe_TruncatedFallsBackToTCP tests retrying over TCP after a truncated answer.
+func TestProbe_TruncatedFallsBackToTCP(t *testing.T) {
+	stub := probetest.Start(t, probetest.Options{TruncateUDP: true})
+	prober := Prober{Timeout: time.Second}
+
+	result := prober.Probe(context.Background(), stub.Addr)
+
+	if !result.OK() {
+		t.Fatalf("expected success, got: %v", result.Err)
+	}
+	if len(stub.TCPQueries) != 1 {
+		t.Errorf("expected 1 TCP query, got %d", len(stub.TCPQueries))
+	}
+}
+
+// TestProbe_NXDomain tests that NXDOMAIN still counts as an answer.
+func TestProbe_NXDomain(t *testing.T) {
+	stub := probetest.Start(t, probetest.Options{Rcode: RcodeNXDomain})
+
+	result := Prober{Timeout: time.Second}.Probe(context.Background(), stub.Addr)
+
+	if !result.OK() {
+		t.Errorf("expected success, got: %v", result.Err)
+	}
+}
+
+// TestProbe_Refused tests that refusing servers are reported as failures.
+func TestProbe_Refused(t *testing.T) {
+	stub := probetest.Start(t, probetest.Options{Rcode: RcodeRefused})
+
+	result := Prober{Timeout: time.Second}.Probe(context.Background(), stub.Addr)
+
+	var rcodeErr *RcodeError
+	if !errors.As(result.Err, &rcodeErr) || rcodeErr.Rcode != RcodeRefused {
+		t.Fatalf("expected REFUSED error, got: %v", result.Err)
+	}
+	if result.String() != stub.Addr+" failed: server returned REFUSED" {
+		t.Errorf("unexpected string: %s", result.String())
+	}
+}
+
+// TestProbe_Timeout tests that silent servers time out.
+func TestProbe_Timeout(t *testing.T) {
+	stub := probetest.Start(t, probetest.Options{Silent: true})
+
+	result := Prober{Timeout: 50 * time.Millisecond}.Probe(context.Background(), stub.Addr)
+
+	if result.OK() {
+		t.Fatal("expected failure")
+	}
+	if result.String() != stub.Addr+" timed out" {
+		t.Errorf("expected timeout, got: %s", result.String())
+	}
+}
+
+// TestProbe_InvalidServer tests that malformed addresses are rejected without dialing.
+func TestProbe_InvalidServer(t *testing.T) {
+	result := Prober{}.Probe(context.Background(), "not-an-ip")
+
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "invalid server address") {
+		t.Errorf("expected invalid address error, got: %v", result.Err)
+	}
+}
+
+// TestProbeAll tests probing several servers keeps their order.
+func TestProbeAll(t *testing.T) {
+	good := probetest.Start(t, probetest.Options{})
+	bad := probetest.Start(t, probetest.Options{Silent: true})
+	prober := Prober{Timeout: 100 * time.Millisecond}
+
+	results := prober.ProbeAll(context.Background(), []string{bad.Addr, good.Addr})
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Server != bad.Addr || results[0].OK() {
+		t.Errorf("expected first result to fail, got %+v", results[0])
+	}
+	if results[1].Server != good.Addr || !results[1].OK() {
+		t.Errorf("expected second result to succeed, got %+v", results[1])
+	}
+	if !AnyOK(results) {
+		t.Error("expected AnyOK to be true")
+	}
+}
+
+// TestServerAddr tests adding the default port to bare addresses.
+func TestServerAddr(t *testing.T) {
+	tests := map[string]string{
+		"1.1.1.1":                   "1.1.1.1:53",
+		"1.1.1.1:5353":              "1.1.1.1:5353",
+		"2606:4700:4700::1111":      "[2606:4700:4700::1111]:53",
+		"[2606:4700:4700::1111]:53": "[2606:4700:4700::1111]:53",
+		"fe80::1%eth0":              "[fe80::1%eth0]:53",
+	}
+
+	for server, want := range tests {
+		got, err := serverAddr(server)
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %v", server, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: expected %s, got %s", server, want, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package probetest provides an in-process DNS server for tests.
+package probetest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// Options controls how a Server answers.
+type Options struct {
+	// Rcode is returned in every answer.
+	Rcode int
+	// TruncateUDP sets the TC bit on UDP answers.
+	TruncateUDP bool
+	// Silent drops UDP queries without answering.
+	Silent bool
+}
+
+// Server is a DNS server answering on UDP and TCP on the same loopback
+// port. It echoes each query back as a response without answer records.
+type Server struct {
+	// Addr is the "127.0.0.1:port" address to query.
+	Addr string
+
+	// UDPQueries and TCPQueries receive every query the server reads.
+	UDPQueries chan []byte
+	TCPQueries chan []byte
+
+	opts Options
+}
+
+// Start starts a server on a random loopback port. It is stopped when
+// the test finishes.
+func Start(t testing.TB, opts Options) *Server {
+	t.Helper()
+
+	s := &Server{
+		UDPQueries: make(chan []byte, 16),
+		TCPQueries: make(chan []byte, 16),
+		opts:       opts,
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen on UDP: %v", err)
+	}
+	s.Addr = pc.LocalAddr().String()
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		pc.Close()
+		t.Skipf("failed to listen on TCP %s: %v", s.Addr, err)
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		ln.Close()
+	})
+
+	go s.serveUDP(pc)
+	go s.serveTCP(ln)
+	return s
+}
+
+// answer turns a query into a response header.
+func (s *Server) answer(query []byte, truncate bool) []byte {
+	resp := append([]byte(nil), query...)
+	if len(resp) < 4 {
+		return resp
+	}
+	flags := uint16(1<<15|1<<8) | uint16(s.opts.Rcode&0xf) // QR, RD
+	if truncate {
+		flags |= 1 << 9 // TC
+	}
+	binary.BigEndian.PutUint16(resp[2:], flags)
+	return resp
+}
+
+// record passes a query to ch without blocking the server.
+func record(ch chan []byte, query []byte) {
+	select {
+	case ch <- query:
+	default:
+	}
+}
+
+func (s *Server) serveUDP(pc net.PacketConn) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := append([]byte(nil), buf[:n]...)
+		record(s.UDPQueries, query)
+		if s.opts.Silent {
+			continue
+		}
+		pc.WriteTo(s.answer(query, s.opts.TruncateUDP), addr)
+	}
+}
+
+func (s *Server) serveTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, query); err != nil {
+				return
+			}
+			record(s.TCPQueries, query)
+			resp := s.answer(query, false)
+			conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+		}()
+	}
+}
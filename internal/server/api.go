@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Profile is a configured profile as listed by GET /v1/profiles.
+type Profile struct {
+	Name             string   `json:"name"`
+	Description      string   `json:"description,omitempty"`
+	Servers          []string `json:"servers,omitempty"`
+	DHCP             bool     `json:"dhcp"`
+	Domains          []string `json:"domains,omitempty"`
+	RouteOnlyDomains []string `json:"route_only_domains,omitempty"`
+	Services         []string `json:"services,omitempty"`
+	// Persistence is what the profile is applied with, if set.
+	Persistence string `json:"persistence,omitempty"`
+}
+
+// ProfilesResponse is the body of GET /v1/profiles.
+type ProfilesResponse struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+// ApplyRequest is the body of POST /v1/apply.
+type ApplyRequest struct {
+	Profile string `json:"profile"`
+	// Services are names, globs or "all". When empty, the profile's
+	// services are used, then the default service.
+	Services []string `json:"services,omitempty"`
+	// Verify reports probe results even when rollback is disabled.
+	// When unset, settings.verify applies.
+	Verify *bool `json:"verify,omitempty"`
+}
+
+// ClearRequest is the body of POST /v1/clear.
+type ClearRequest struct {
+	// Services are names, globs or "all". When empty, the default
+	// service is cleared.
+	Services []string `json:"services,omitempty"`
+}
+
+// ServiceResult is the outcome of changing one service.
+type ServiceResult struct {
+	Service string   `json:"service"`
+	Probes  []string `json:"probes,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// ChangeResponse is the body returned by POST /v1/apply and /v1/clear.
+type ChangeResponse struct {
+	Results []ServiceResult `json:"results"`
+}
+
+// ServicesResponse is the body of GET /v1/services.
+type ServicesResponse struct {
+	Backend  string   `json:"backend"`
+	Services []string `json:"services"`
+	// Default is the service holding the default route, if known.
+	Default string `json:"default,omitempty"`
+	// Persistence tells whether the backend's changes survive a reboot
+	// ("runtime" or "persistent"), if known.
+	Persistence string `json:"persistence,omitempty"`
+}
+
+// ServiceDNS is the DNS configuration of one service, returned by
+// GET /v1/services/{service}.
+type ServiceDNS struct {
+	Servers          []string `json:"servers"`
+	Domains          []string `json:"domains,omitempty"`
+	RouteOnlyDomains []string `json:"route_only_domains,omitempty"`
+}
+
+// HistoryResponse is the body of GET /v1/history, oldest change first.
+type HistoryResponse struct {
+	History []Change `json:"history"`
+}
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Actions recorded in the history.
+const (
+	ActionApply = "apply"
+	ActionClear = "clear"
+	ActionFlush = "flush"
+)
+
+// Change is one DNS change made through the server.
+type Change struct {
+	Time time.Time `json:"time"`
+	// User is the local user that requested the change, if known.
+	User    string   `json:"user,omitempty"`
+	Action  string   `json:"action"`
+	Profile string   `json:"profile,omitempty"`
+	Service string   `json:"service,omitempty"`
+	Servers []string `json:"servers,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// String formats the change for the server's log.
+func (c Change) String() string {
+	var b strings.Builder
+	if c.User != "" {
+		fmt.Fprintf(&b, "%s: ", c.User)
+	}
+	b.WriteString(c.Action)
+	if c.Profile != "" {
+		fmt.Fprintf(&b, " %s", c.Profile)
+	}
+	if c.Service != "" {
+		fmt.Fprintf(&b, " on %s", c.Service)
+	}
+	if len(c.Servers) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(c.Servers, ", "))
+	}
+	if len(c.Domains) > 0 {
+		fmt.Fprintf(&b, " (domains %s)", strings.Join(c.Domains, ", "))
+	}
+	if c.Error != "" {
+		fmt.Fprintf(&b, " failed: %s", c.Error)
+	}
+	return b.String()
+}
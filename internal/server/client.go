@@ -0,0 +1,284 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// ErrChangeByProfile is returned by the dns.Writer methods of Client that
+// would change a single setting: the server only changes DNS by applying
+// or clearing with its own profiles, through Apply and Clear.
+var ErrChangeByProfile = errors.New("dnsctl serve only changes DNS by applying its profiles")
+
+// Client reads DNS through a server as a dns.Client and changes it with
+// the server's profiles, so its caller needs no privileges of its own.
+// The server verifies and rolls back each change itself.
+type Client struct {
+	http    *http.Client
+	backend string
+
+	// backendPersistence is how long the backend's own changes last.
+	backendPersistence dns.Persistence
+	// persistence is requested with every call, if set.
+	persistence dns.Persistence
+}
+
+// Compile-time interface checks.
+var (
+	_ dns.ContextClient          = (*Client)(nil)
+	_ dns.DomainClient           = (*Client)(nil)
+	_ dns.DefaultServiceDetector = (*Client)(nil)
+	_ dns.PersistenceSwitcher    = (*Client)(nil)
+)
+
+// Connect connects to the server listening on socket and returns a
+// Client for its backend.
+func Connect(ctx context.Context, socket string) (*Client, error) {
+	c := &Client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+
+	var resp ServicesResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/services", nil, &resp); err != nil {
+		return nil, fmt.Errorf("connecting to dnsctl serve at %s: %w", socket, err)
+	}
+	c.backend = resp.Backend
+	c.backendPersistence = dns.Persistence(resp.Persistence)
+	return c, nil
+}
+
+// Name returns the server's backend name.
+func (c *Client) Name() string {
+	return c.backend
+}
+
+// Persistence returns how long the changes of the Client last.
+func (c *Client) Persistence() dns.Persistence {
+	if c.persistence != "" {
+		return c.persistence
+	}
+	return c.backendPersistence
+}
+
+// WithPersistence returns a Client that asks the server for changes with
+// persistence p. Whether the backend supports it is checked by the server.
+func (c *Client) WithPersistence(p dns.Persistence) (dns.Client, error) {
+	scoped := *c
+	scoped.persistence = p
+	return &scoped, nil
+}
+
+// ListNetworkServices returns the services the server's backend lists.
+func (c *Client) ListNetworkServices() ([]string, error) {
+	return c.ListNetworkServicesContext(context.Background())
+}
+
+// ListNetworkServicesContext returns the services the server's backend lists.
+func (c *Client) ListNetworkServicesContext(ctx context.Context) ([]string, error) {
+	var resp ServicesResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/services", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Services, nil
+}
+
+// DefaultService returns the service holding the default route, as
+// detected by the server.
+func (c *Client) DefaultService() (string, error) {
+	var resp ServicesResponse
+	if err := c.do(context.Background(), http.MethodGet, "/v1/services", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Default, nil
+}
+
+// GetDNSServers returns the DNS servers of a service.
+func (c *Client) GetDNSServers(service string) ([]string, error) {
+	return c.GetDNSServersContext(context.Background(), service)
+}
+
+// GetDNSServersContext returns the DNS servers of a service.
+func (c *Client) GetDNSServersContext(ctx context.Context, service string) ([]string, error) {
+	var resp ServiceDNS
+	if err := c.do(ctx, http.MethodGet, c.servicePath(service), nil, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Servers) == 0 {
+		return nil, nil
+	}
+	return resp.Servers, nil
+}
+
+// SetDNSServers returns ErrChangeByProfile; use Apply.
+func (c *Client) SetDNSServers(service string, servers []string) error {
+	return ErrChangeByProfile
+}
+
+// SetDNSServersContext returns ErrChangeByProfile; use Apply.
+func (c *Client) SetDNSServersContext(ctx context.Context, service string, servers []string) error {
+	return ErrChangeByProfile
+}
+
+// ClearDNSServers returns ErrChangeByProfile; use Clear.
+func (c *Client) ClearDNSServers(service string) error {
+	return ErrChangeByProfile
+}
+
+// ClearDNSServersContext returns ErrChangeByProfile; use Clear.
+func (c *Client) ClearDNSServersContext(ctx context.Context, service string) error {
+	return ErrChangeByProfile
+}
+
+// FlushCache flushes the DNS cache.
+func (c *Client) FlushCache() error {
+	return c.FlushCacheContext(context.Background())
+}
+
+// FlushCacheContext flushes the DNS cache.
+func (c *Client) FlushCacheContext(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/v1/flush", nil, nil)
+}
+
+// GetDomains returns the domains of a service.
+func (c *Client) GetDomains(ctx context.Context, service string) (dns.Domains, error) {
+	var resp ServiceDNS
+	if err := c.do(ctx, http.MethodGet, c.servicePath(service), nil, &resp); err != nil {
+		return dns.Domains{}, err
+	}
+	return dns.Domains{Search: resp.Domains, RouteOnly: resp.RouteOnlyDomains}, nil
+}
+
+// SetDomains returns ErrChangeByProfile; use Apply.
+func (c *Client) SetDomains(ctx context.Context, service string, domains dns.Domains) error {
+	return ErrChangeByProfile
+}
+
+// Profiles returns the server's profiles by name, as it applies them.
+func (c *Client) Profiles(ctx context.Context) (map[string]config.Profile, error) {
+	var resp ProfilesResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/profiles", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	profiles := make(map[string]config.Profile, len(resp.Profiles))
+	for _, p := range resp.Profiles {
+		profiles[p.Name] = config.Profile{
+			Description:      p.Description,
+			Servers:          p.Servers,
+			DHCP:             p.DHCP,
+			Domains:          p.Domains,
+			RouteOnlyDomains: p.RouteOnlyDomains,
+			Services:         p.Services,
+			Persistence:      p.Persistence,
+		}
+	}
+	return profiles, nil
+}
+
+// Apply has the server apply one of its profiles to services, or to the
+// profile's own services, then the default service, if there are none.
+// It returns one result per service; the error is only set if the
+// request as a whole failed.
+func (c *Client) Apply(ctx context.Context, profile string, services []string) ([]apply.ServiceResult, error) {
+	return c.change(ctx, "/v1/apply", ApplyRequest{Profile: profile, Services: services})
+}
+
+// Clear has the server clear the DNS of services, or of the default
+// service if there are none, to use DHCP.
+func (c *Client) Clear(ctx context.Context, services []string) ([]apply.ServiceResult, error) {
+	return c.change(ctx, "/v1/clear", ClearRequest{Services: services})
+}
+
+// change sends an apply or clear request. The server reports each
+// service's outcome even when some failed.
+func (c *Client) change(ctx context.Context, path string, body any) ([]apply.ServiceResult, error) {
+	var resp ChangeResponse
+	if err := c.do(ctx, http.MethodPost, path, body, &resp); err != nil && len(resp.Results) == 0 {
+		return nil, err
+	}
+
+	results := make([]apply.ServiceResult, len(resp.Results))
+	for i, r := range resp.Results {
+		results[i].Service = r.Service
+		if r.Error != "" {
+			results[i].Err = errors.New(r.Error)
+		}
+	}
+	return results, nil
+}
+
+// servicePath returns the API path of a service, with the requested
+// persistence. Service names may contain spaces and slashes, e.g.
+// "USB 10/100/1000 LAN".
+func (c *Client) servicePath(service string) string {
+	path := "/v1/services/" + url.PathEscape(service)
+	if c.persistence != "" {
+		path += "?persistence=" + url.QueryEscape(string(c.persistence))
+	}
+	return path
+}
+
+// do sends a request with body encoded as JSON, if not nil, and decodes
+// the response into out, if not nil. Failed requests return the error
+// the server reported; if it reported none, the response is still
+// decoded into out.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// The host is ignored; requests always go to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://dnsctl"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		var e ErrorResponse
+		if err := json.Unmarshal(data, &e); err == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		if out != nil {
+			_ = json.Unmarshal(data, out)
+		}
+		return fmt.Errorf("dnsctl serve: %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
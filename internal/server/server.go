@@ -0,0 +1,420 @@
+// Package server exposes DNS management over a local Unix socket as a
+// JSON API, so one privileged process makes every change while status
+// bars, scripts and the TUI run unprivileged.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/user"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/status"
+)
+
+// HistorySize is how many changes GET /v1/history returns at most.
+const HistorySize = 100
+
+// Server serves the control API.
+type Server struct {
+	Config  *config.Config
+	Applier *apply.Applier
+	Log     *log.Logger
+
+	// changing serializes DNS changes, so one client's rollback never
+	// races another client's change.
+	changing sync.Mutex
+
+	mu      sync.Mutex
+	history []Change
+}
+
+// New creates a Server for a config, changing DNS through applier.
+func New(cfg *config.Config, applier *apply.Applier, logger *log.Logger) *Server {
+	return &Server{
+		Config:  cfg,
+		Applier: applier,
+		Log:     logger,
+	}
+}
+
+// Handler returns the HTTP handler of the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/profiles", s.handleProfiles)
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	mux.HandleFunc("POST /v1/apply", s.handleApply)
+	mux.HandleFunc("POST /v1/clear", s.handleClear)
+	mux.HandleFunc("POST /v1/flush", s.handleFlush)
+	mux.HandleFunc("GET /v1/history", s.handleHistory)
+
+	// Reads, used by Client to stand in for a dns.Client. Changes are
+	// only made through apply and clear, so clients are limited to the
+	// server's own profiles.
+	// They take an optional ?persistence=runtime|persistent.
+	mux.HandleFunc("GET /v1/services", s.handleServices)
+	mux.HandleFunc("GET /v1/services/{service}", s.handleGetService)
+	return mux
+}
+
+// Serve answers API requests on l until ctx is done. Requests that are
+// in progress then are finished first, bounded by the apply timeout, so
+// shutdown never leaves DNS half-changed.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler: s.Handler(),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, c)
+		},
+	}
+
+	s.Log.Printf("listening on %s with %s backend", l.Addr(), s.Applier.Client.Name())
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.Log.Print("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.Config.Settings.Timeouts.ApplyTimeout())
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// History returns the recorded changes, oldest first.
+func (s *Server) History() []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.history)
+}
+
+// record adds a change to the history and logs it.
+func (s *Server) record(r *http.Request, change Change) {
+	change.Time = time.Now()
+	change.User = peerUser(r)
+	s.Log.Print(change)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = append(s.history, change)
+	if len(s.history) > HistorySize {
+		s.history = slices.Delete(s.history, 0, len(s.history)-HistorySize)
+	}
+}
+
+// handleProfiles implements GET /v1/profiles.
+func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	resp := ProfilesResponse{Profiles: []Profile{}}
+	for _, name := range s.Config.ProfileNames() {
+		p := s.Config.Profiles[name]
+		resp.Profiles = append(resp.Profiles, Profile{
+			Name:             name,
+			Description:      p.Description,
+			Servers:          p.Servers,
+			DHCP:             p.IsDHCP(),
+			Domains:          p.Domains,
+			RouteOnlyDomains: p.RouteOnlyDomains,
+			Services:         p.Services,
+			Persistence:      s.Config.Settings.PersistenceFor(p),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleStatus implements GET /v1/status.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	report, err := status.Collect(r.Context(), s.Applier.Client, s.Config)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleApply implements POST /v1/apply.
+func (s *Server) handleApply(w http.ResponseWriter, r *http.Request) {
+	var req ApplyRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	profile, ok := s.Config.GetProfile(req.Profile)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown profile %q", req.Profile))
+		return
+	}
+
+	patterns := req.Services
+	if len(patterns) == 0 {
+		patterns = profile.Services
+	}
+
+	applier := *s.Applier
+	if req.Verify != nil {
+		applier.Settings.Verify = *req.Verify
+	}
+
+	s.change(w, r, &applier, patterns, profile, Change{Action: ActionApply, Profile: req.Profile})
+}
+
+// handleClear implements POST /v1/clear.
+func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
+	var req ClearRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	s.change(w, r, s.Applier, req.Services, config.Profile{DHCP: true}, Change{Action: ActionClear})
+}
+
+// change applies a profile to the services matching patterns, or to the
+// default service, and records the outcome for each. The response is 200
+// only if every service was changed.
+func (s *Server) change(w http.ResponseWriter, r *http.Request, applier *apply.Applier, patterns []string, profile config.Profile, change Change) {
+	if len(patterns) == 0 {
+		patterns = []string{s.Config.DefaultService}
+	}
+	for _, pattern := range patterns {
+		if err := config.ValidateServicePattern(pattern); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	s.changing.Lock()
+	defer s.changing.Unlock()
+
+	targets, err := dns.ResolveServices(applier.Client, patterns)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, dns.ErrNoMatchingService) || errors.Is(err, dns.ErrNoActiveService) {
+			code = http.StatusNotFound
+		}
+		writeError(w, code, err)
+		return
+	}
+
+	code := http.StatusOK
+	resp := ChangeResponse{Results: []ServiceResult{}}
+	for _, result := range applier.ApplyAll(r.Context(), targets, profile) {
+		entry := change
+		entry.Service = result.Service
+		entry.Servers = profile.Servers
+		entry.Domains = profileDomains(profile)
+
+		out := ServiceResult{Service: result.Service}
+		for _, p := range result.Probes {
+			out.Probes = append(out.Probes, p.String())
+		}
+		if result.Err != nil {
+			out.Error = result.Err.Error()
+			entry.Error = out.Error
+			code = http.StatusInternalServerError
+		}
+
+		s.record(r, entry)
+		resp.Results = append(resp.Results, out)
+	}
+
+	writeJSON(w, code, resp)
+}
+
+// profileDomains returns the domains a profile lists, in "~" notation.
+func profileDomains(profile config.Profile) []string {
+	return dns.Domains{Search: profile.Domains, RouteOnly: profile.RouteOnlyDomains}.Entries()
+}
+
+// handleFlush implements POST /v1/flush.
+func (s *Server) handleFlush(w http.ResponseWriter, r *http.Request) {
+	client := dns.WithContext(s.Applier.Client)
+	err := dns.WithTimeout(r.Context(), s.Config.Settings.Timeouts.FlushTimeout(), client.FlushCacheContext)
+	s.finish(w, r, Change{Action: ActionFlush}, err)
+}
+
+// handleHistory implements GET /v1/history.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	history := s.History()
+	if history == nil {
+		history = []Change{}
+	}
+	writeJSON(w, http.StatusOK, HistoryResponse{History: history})
+}
+
+// handleServices implements GET /v1/services.
+func (s *Server) handleServices(w http.ResponseWriter, r *http.Request) {
+	services, err := s.services(r.Context(), s.Applier.Client)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := ServicesResponse{
+		Backend:     s.Applier.Client.Name(),
+		Services:    services,
+		Persistence: string(dns.PersistenceOf(s.Applier.Client)),
+	}
+	if resp.Services == nil {
+		resp.Services = []string{}
+	}
+	if detector, ok := s.Applier.Client.(dns.DefaultServiceDetector); ok {
+		resp.Default, _ = detector.DefaultService()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleGetService implements GET /v1/services/{service}.
+func (s *Server) handleGetService(w http.ResponseWriter, r *http.Request) {
+	client, service, ok := s.service(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := apply.New(client, s.Config.Settings).Capture(r.Context(), service)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := ServiceDNS{
+		Servers:          state.Servers,
+		Domains:          state.Domains.Search,
+		RouteOnlyDomains: state.Domains.RouteOnly,
+	}
+	if resp.Servers == nil {
+		resp.Servers = []string{}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// services lists the network services of a backend client.
+func (s *Server) services(ctx context.Context, plain dns.Client) ([]string, error) {
+	client := dns.WithContext(plain)
+
+	var services []string
+	err := dns.WithTimeout(ctx, s.Config.Settings.Timeouts.ReadTimeout(), func(ctx context.Context) (err error) {
+		services, err = client.ListNetworkServicesContext(ctx)
+		return err
+	})
+	return services, err
+}
+
+// service returns the request's service and the backend client for the
+// requested persistence. Only services the backend lists are accepted,
+// so a request can never pass an arbitrary argument to a backend command.
+func (s *Server) service(w http.ResponseWriter, r *http.Request) (dns.Client, string, bool) {
+	service := r.PathValue("service")
+
+	var client dns.Client
+	switch p := persistence(r); p {
+	case "", config.PersistenceRuntime, config.PersistencePersistent:
+		var err error
+		if client, err = dns.WithPersistence(s.Applier.Client, dns.Persistence(p)); err != nil {
+			writeError(w, http.StatusNotImplemented, err)
+			return nil, "", false
+		}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported persistence %q", p))
+		return nil, "", false
+	}
+
+	services, err := s.services(r.Context(), client)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, "", false
+	}
+	if !slices.Contains(services, service) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown network service %q", service))
+		return nil, "", false
+	}
+	return client, service, true
+}
+
+// persistence returns the persistence a request asks for, if any.
+func persistence(r *http.Request) string {
+	return r.URL.Query().Get("persistence")
+}
+
+// finish records a single change and responds with its outcome.
+func (s *Server) finish(w http.ResponseWriter, r *http.Request, change Change, err error) {
+	if err != nil {
+		change.Error = err.Error()
+	}
+	s.record(r, change)
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// readJSON decodes the request body into v. Unknown fields are rejected
+// so that misspelled options are not silently ignored. It responds with
+// an error and returns false if the body is invalid.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return false
+	}
+	return true
+}
+
+// writeJSON responds with v as JSON.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with an ErrorResponse.
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, ErrorResponse{Error: err.Error()})
+}
+
+// connKey is the context key of a request's connection.
+type connKey struct{}
+
+// peerUser returns the name of the local user on the other end of the
+// request's connection, its uid if the name is unknown, or "" if the
+// socket does not report it.
+func peerUser(r *http.Request) string {
+	conn, ok := r.Context().Value(connKey{}).(*net.UnixConn)
+	if !ok {
+		return ""
+	}
+	uid, err := peerUID(conn)
+	if err != nil {
+		return ""
+	}
+
+	id := strconv.Itoa(uid)
+	if u, err := user.LookupId(id); err == nil {
+		return u.Username
+	}
+	return id
+}
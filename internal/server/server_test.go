@@ -0,0 +1,465 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+	"github.com/nycjv321/dnsctl/internal/status"
+)
+
+// testConfig has a plain profile and one bound to its own services.
+func testConfig() *config.Config {
+	return &config.Config{
+		DefaultService: "Wi-Fi",
+		Profiles: map[string]config.Profile{
+			"home":   {Description: "Home", Servers: []string{"192.168.1.100"}},
+			"office": {Servers: []string{"10.0.0.53"}, Services: []string{"Ether*"}},
+		},
+	}
+}
+
+// testServer creates a Server on a mock client whose servers all answer.
+func testServer(cfg *config.Config) (*Server, *dns.MockClient) {
+	mock := dns.NewMockClient()
+	applier := apply.New(mock, cfg.Settings)
+	applier.Checker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+		results := make([]probe.Result, len(servers))
+		for i, server := range servers {
+			results[i] = probe.Result{Server: server, Latency: time.Millisecond}
+		}
+		return results
+	})
+	return New(cfg, applier, log.New(io.Discard, "", 0)), mock
+}
+
+// serve runs s on a socket in a temporary directory until the test ends
+// and returns the socket's path.
+func serve(t *testing.T, s *Server) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "dnsctl.sock")
+	l, err := Listen(socket, "")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("expected clean shutdown, got: %v", err)
+		}
+	})
+	return socket
+}
+
+// request sends a request straight to the server's handler and returns
+// the status code and body.
+func request(t *testing.T, s *Server, method, path, body string) (int, []byte) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec.Code, rec.Body.Bytes()
+}
+
+// decode unmarshals a response body.
+func decode[T any](t *testing.T, body []byte) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("invalid response %s: %v", body, err)
+	}
+	return v
+}
+
+// TestProfiles tests that profiles are listed in name order.
+func TestProfiles(t *testing.T) {
+	s, _ := testServer(testConfig())
+
+	code, body := request(t, s, "GET", "/v1/profiles", "")
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	resp := decode[ProfilesResponse](t, body)
+	if len(resp.Profiles) != 2 || resp.Profiles[0].Name != "home" || resp.Profiles[1].Name != "office" {
+		t.Fatalf("unexpected profiles: %+v", resp.Profiles)
+	}
+	if resp.Profiles[0].Description != "Home" || !slices.Equal(resp.Profiles[0].Servers, []string{"192.168.1.100"}) {
+		t.Errorf("unexpected home profile: %+v", resp.Profiles[0])
+	}
+}
+
+// TestStatus tests that the status report matches "dnsctl status".
+func TestStatus(t *testing.T) {
+	s, mock := testServer(testConfig())
+	mock.DNSServers["Wi-Fi"] = []string{"192.168.1.100"}
+
+	code, body := request(t, s, "GET", "/v1/status", "")
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	report := decode[status.Report](t, body)
+	if len(report.Services) != 2 {
+		t.Fatalf("expected 2 services, got %+v", report.Services)
+	}
+	wifi := report.Services[0]
+	if wifi.Name != "Wi-Fi" || !wifi.Default || wifi.Profile != "home" {
+		t.Errorf("unexpected Wi-Fi status: %+v", wifi)
+	}
+	if !report.Services[1].DHCP {
+		t.Errorf("expected Ethernet to use DHCP, got %+v", report.Services[1])
+	}
+}
+
+// TestApply tests applying a profile to the default service.
+func TestApply(t *testing.T) {
+	s, mock := testServer(testConfig())
+
+	code, body := request(t, s, "POST", "/v1/apply", `{"profile": "home", "verify": true}`)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	resp := decode[ChangeResponse](t, body)
+	if len(resp.Results) != 1 || resp.Results[0].Service != "Wi-Fi" || resp.Results[0].Error != "" {
+		t.Fatalf("unexpected results: %+v", resp.Results)
+	}
+	if !slices.Equal(resp.Results[0].Probes, []string{"192.168.1.100 1ms"}) {
+		t.Errorf("expected probe results, got %v", resp.Results[0].Probes)
+	}
+	if !slices.Equal(mock.DNSServers["Wi-Fi"], []string{"192.168.1.100"}) {
+		t.Errorf("expected Wi-Fi to use home, got %v", mock.DNSServers["Wi-Fi"])
+	}
+
+	history := s.History()
+	if len(history) != 1 || history[0].Action != ActionApply || history[0].Profile != "home" || history[0].Service != "Wi-Fi" {
+		t.Errorf("unexpected history: %+v", history)
+	}
+}
+
+// TestApply_ProfileServices tests that a profile's own services are used
+// when the request names none.
+func TestApply_ProfileServices(t *testing.T) {
+	s, mock := testServer(testConfig())
+
+	code, body := request(t, s, "POST", "/v1/apply", `{"profile": "office"}`)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	if len(mock.SetCalls) != 1 || mock.SetCalls[0].Service != "Ethernet" {
+		t.Errorf("expected Ethernet to be set, got %v", mock.SetCalls)
+	}
+}
+
+// TestApply_PartialFailure tests that every service is attempted and the
+// failures are reported with a 500.
+func TestApply_PartialFailure(t *testing.T) {
+	s, mock := testServer(testConfig())
+	mock.ServiceErrors = map[string]error{"Ethernet": errors.New("unmanaged")}
+
+	code, body := request(t, s, "POST", "/v1/apply", `{"profile": "home", "services": ["all"]}`)
+
+	if code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", code, body)
+	}
+	resp := decode[ChangeResponse](t, body)
+	if len(resp.Results) != 2 || resp.Results[0].Error != "" || !strings.Contains(resp.Results[1].Error, "unmanaged") {
+		t.Errorf("unexpected results: %+v", resp.Results)
+	}
+	if history := s.History(); len(history) != 2 || history[1].Error == "" {
+		t.Errorf("expected the failure in the history, got %+v", history)
+	}
+}
+
+// TestApply_InvalidRequests tests the errors for bad apply requests.
+func TestApply_InvalidRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+		want string
+	}{
+		{"unknown profile", `{"profile": "missing"}`, http.StatusNotFound, `unknown profile "missing"`},
+		{"unknown field", `{"profile": "home", "servce": "Wi-Fi"}`, http.StatusBadRequest, "invalid request"},
+		{"no matching service", `{"profile": "home", "services": ["tun*"]}`, http.StatusNotFound, "no network service matches: tun*"},
+		{"invalid pattern", `{"profile": "home", "services": ["en["]}`, http.StatusBadRequest, "invalid service pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := testServer(testConfig())
+
+			code, body := request(t, s, "POST", "/v1/apply", tt.body)
+
+			if code != tt.code {
+				t.Errorf("expected %d, got %d: %s", tt.code, code, body)
+			}
+			if resp := decode[ErrorResponse](t, body); !strings.Contains(resp.Error, tt.want) {
+				t.Errorf("expected error containing %q, got %q", tt.want, resp.Error)
+			}
+			if len(mock.SetCalls) != 0 {
+				t.Errorf("expected no changes, got %v", mock.SetCalls)
+			}
+		})
+	}
+}
+
+// TestClear tests clearing the listed services.
+func TestClear(t *testing.T) {
+	s, mock := testServer(testConfig())
+
+	code, body := request(t, s, "POST", "/v1/clear", `{"services": ["Ethernet"]}`)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	if !slices.Equal(mock.ClearCalls, []string{"Ethernet"}) {
+		t.Errorf("expected Ethernet to be cleared, got %v", mock.ClearCalls)
+	}
+	if history := s.History(); len(history) != 1 || history[0].Action != ActionClear {
+		t.Errorf("unexpected history: %+v", history)
+	}
+}
+
+// TestGetService_UnknownService tests that only listed services can be
+// read, so no request can pass arbitrary arguments to a backend.
+func TestGetService_UnknownService(t *testing.T) {
+	s, _ := testServer(testConfig())
+
+	code, body := request(t, s, "GET", "/v1/services/--help", "")
+
+	if code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d: %s", code, body)
+	}
+}
+
+// TestServices_NoSingleChanges tests that services cannot be changed
+// directly, only by applying the server's profiles.
+func TestServices_NoSingleChanges(t *testing.T) {
+	for _, req := range []struct{ method, path, body string }{
+		{"PUT", "/v1/services/Wi-Fi/servers", `{"servers": ["1.1.1.1"]}`},
+		{"DELETE", "/v1/services/Wi-Fi/servers", ""},
+		{"PUT", "/v1/services/Wi-Fi/domains", `{"domains": ["corp.example.com"]}`},
+		{"PUT", "/v1/services/Wi-Fi", `{"servers": ["1.1.1.1"]}`},
+	} {
+		s, mock := testServer(testConfig())
+
+		code, body := request(t, s, req.method, req.path, req.body)
+
+		if code != http.StatusNotFound && code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected 404 or 405, got %d: %s", req.method, req.path, code, body)
+		}
+		if len(mock.SetCalls) != 0 || len(mock.ClearCalls) != 0 || len(mock.SetDomainsCalls) != 0 {
+			t.Errorf("%s %s: expected no changes", req.method, req.path)
+		}
+	}
+}
+
+// TestHistory_Limit tests that only the latest changes are kept.
+func TestHistory_Limit(t *testing.T) {
+	s, _ := testServer(testConfig())
+	for range HistorySize + 5 {
+		request(t, s, "POST", "/v1/flush", "")
+	}
+
+	code, body := request(t, s, "GET", "/v1/history", "")
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	if resp := decode[HistoryResponse](t, body); len(resp.History) != HistorySize {
+		t.Errorf("expected %d changes, got %d", HistorySize, len(resp.History))
+	}
+}
+
+// TestClient tests that a Client reads DNS and applies profiles through
+// the server, and that changes are recorded with the requesting user.
+func TestClient(t *testing.T) {
+	s, mock := testServer(testConfig())
+	mock.Services = append(mock.Services, "USB 10/100/1000 LAN")
+	mock.DefaultName = "Ethernet"
+	mock.Domains = map[string]dns.Domains{"Wi-Fi": {Search: []string{"corp.example.com"}, RouteOnly: []string{"internal"}}}
+	socket := serve(t, s)
+	ctx := context.Background()
+
+	client, err := Connect(ctx, socket)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	if client.Name() != "mock" {
+		t.Errorf("expected the server's backend name, got %q", client.Name())
+	}
+	if services, err := client.ListNetworkServices(); err != nil || len(services) != 3 {
+		t.Errorf("expected 3 services, got %v (%v)", services, err)
+	}
+	if name, err := client.DefaultService(); err != nil || name != "Ethernet" {
+		t.Errorf("expected default service Ethernet, got %q (%v)", name, err)
+	}
+	if got, err := client.GetDomains(ctx, "Wi-Fi"); err != nil || !got.Equal(mock.Domains["Wi-Fi"]) {
+		t.Errorf("expected %v, got %v (%v)", mock.Domains["Wi-Fi"], got, err)
+	}
+	if profiles, err := client.Profiles(ctx); err != nil || len(profiles) != 2 || profiles["office"].Services[0] != "Ether*" {
+		t.Errorf("expected the server's profiles, got %+v (%v)", profiles, err)
+	}
+
+	results, err := client.Apply(ctx, "home", []string{"USB 10/100/1000 LAN"})
+	if err != nil || len(results) != 1 || results[0].Service != "USB 10/100/1000 LAN" || results[0].Err != nil {
+		t.Fatalf("failed to apply: %+v (%v)", results, err)
+	}
+	if servers, err := client.GetDNSServers("USB 10/100/1000 LAN"); err != nil || !slices.Equal(servers, []string{"192.168.1.100"}) {
+		t.Errorf("expected 192.168.1.100, got %v (%v)", servers, err)
+	}
+
+	if _, err := client.Clear(ctx, []string{"USB 10/100/1000 LAN"}); err != nil {
+		t.Fatalf("failed to clear: %v", err)
+	}
+	if servers, err := client.GetDNSServers("USB 10/100/1000 LAN"); err != nil || servers != nil {
+		t.Errorf("expected DHCP, got %v (%v)", servers, err)
+	}
+
+	if err := client.FlushCache(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	history := s.History()
+	if len(history) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", history)
+	}
+	for _, change := range history {
+		if change.User == "" {
+			t.Errorf("expected the requesting user, got %+v", change)
+		}
+	}
+}
+
+// TestClient_SingleChanges tests that a Client refuses to change single
+// settings, which the server does not allow.
+func TestClient_SingleChanges(t *testing.T) {
+	s, mock := testServer(testConfig())
+	client, err := Connect(context.Background(), serve(t, s))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	err = client.SetDNSServers("Wi-Fi", []string{"1.1.1.1"})
+
+	if !errors.Is(err, ErrChangeByProfile) {
+		t.Errorf("expected ErrChangeByProfile, got: %v", err)
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no changes, got %v", mock.SetCalls)
+	}
+}
+
+// TestClient_Errors tests that the server's errors are returned as is,
+// and that each service's failure is reported.
+func TestClient_Errors(t *testing.T) {
+	s, mock := testServer(testConfig())
+	mock.ServiceErrors = map[string]error{"Ethernet": errors.New("unmanaged")}
+	client, err := Connect(context.Background(), serve(t, s))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	_, err = client.Apply(context.Background(), "missing", nil)
+	if err == nil || err.Error() != `unknown profile "missing"` {
+		t.Errorf("expected unknown profile error, got: %v", err)
+	}
+
+	results, err := client.Apply(context.Background(), "home", []string{"all"})
+	if err != nil {
+		t.Fatalf("expected per-service results, got: %v", err)
+	}
+	if len(results) != 2 || results[0].Err != nil || results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "unmanaged") {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+// TestClient_Persistence tests that a Client reads through the backend
+// client for its persistence, and that unsupported ones are refused.
+func TestClient_Persistence(t *testing.T) {
+	s, mock := testServer(testConfig())
+	mock.PersistenceMode = dns.Runtime
+	persistent := dns.NewMockClient()
+	persistent.PersistenceMode = dns.Persistent
+	persistent.DNSServers["Wi-Fi"] = []string{"1.1.1.1"}
+	mock.Alternate = persistent
+	client, err := Connect(context.Background(), serve(t, s))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if got := dns.PersistenceOf(client); got != dns.Runtime {
+		t.Errorf("expected the backend's persistence, got %q", got)
+	}
+
+	scoped, err := dns.WithPersistence(client, dns.Persistent)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	servers, err := scoped.GetDNSServers("Wi-Fi")
+
+	if err != nil || !slices.Equal(servers, []string{"1.1.1.1"}) {
+		t.Errorf("expected the persistent servers, got %v (%v)", servers, err)
+	}
+
+	mock.Alternate = nil
+	_, err = scoped.GetDNSServers("Wi-Fi")
+	if err == nil || !strings.Contains(err.Error(), "cannot make persistent changes") {
+		t.Errorf("expected unsupported persistence error, got: %v", err)
+	}
+}
+
+// TestGetService_InvalidPersistence tests that unknown persistence modes
+// are rejected.
+func TestGetService_InvalidPersistence(t *testing.T) {
+	s, _ := testServer(testConfig())
+
+	code, body := request(t, s, "GET", "/v1/services/Wi-Fi?persistence=forever", "")
+
+	if code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d: %s", code, body)
+	}
+}
+
+// TestConnect_NoServer tests the error when nothing listens on the socket.
+func TestConnect_NoServer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "dnsctl.sock")
+
+	_, err := Connect(context.Background(), socket)
+
+	if err == nil || !strings.Contains(err.Error(), "connecting to dnsctl serve at "+socket) {
+		t.Errorf("expected connection error, got: %v", err)
+	}
+}
+
+// TestChange_String tests the log format of changes.
+func TestChange_String(t *testing.T) {
+	change := Change{User: "alice", Action: ActionApply, Profile: "home", Service: "Wi-Fi", Servers: []string{"1.1.1.1"}, Error: "timed out"}
+
+	got := change.String()
+
+	if want := "alice: apply home on Wi-Fi (1.1.1.1) failed: timed out"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build linux || darwin
+
+package server
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestListen_OwnerOnly tests that without a group only the owner may connect.
+func TestListen_OwnerOnly(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "run", "dnsctl.sock")
+
+	l, err := Listen(socket, "")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("expected the socket to exist: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected mode 0600, got %o", perm)
+	}
+}
+
+// TestListen_Group tests that the socket is opened to the configured group.
+func TestListen_Group(t *testing.T) {
+	group, err := user.LookupGroupId(strconv.Itoa(os.Getgid()))
+	if err != nil {
+		t.Skipf("current group has no name: %v", err)
+	}
+	socket := filepath.Join(t.TempDir(), "dnsctl.sock")
+
+	l, err := Listen(socket, group.Name)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("expected the socket to exist: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o660 {
+		t.Errorf("expected mode 0660, got %o", perm)
+	}
+	if gid := info.Sys().(*syscall.Stat_t).Gid; strconv.Itoa(int(gid)) != group.Gid {
+		t.Errorf("expected group %s, got %d", group.Gid, gid)
+	}
+}
+
+// TestListen_UnknownGroup tests that a missing group is reported.
+func TestListen_UnknownGroup(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "dnsctl.sock")
+
+	_, err := Listen(socket, "no-such-dnsctl-group")
+
+	if err == nil || !strings.Contains(err.Error(), `unknown group "no-such-dnsctl-group"`) {
+		t.Errorf("expected unknown group error, got: %v", err)
+	}
+}
+
+// TestListen_ReplacesStaleSocket tests that a socket nobody listens on
+// is replaced.
+func TestListen_ReplacesStaleSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "dnsctl.sock")
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	// Keep the file, as a crashed server would
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := Listen(socket, "")
+
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got: %v", err)
+	}
+	l.Close()
+}
+
+// TestListen_RefusesLiveSocket tests that a running server is not replaced.
+func TestListen_RefusesLiveSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "dnsctl.sock")
+	live, err := Listen(socket, "")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer live.Close()
+
+	_, err = Listen(socket, "")
+
+	if err == nil || !strings.Contains(err.Error(), "already listening") {
+		t.Errorf("expected already listening error, got: %v", err)
+	}
+}
+
+// TestListen_RefusesOtherFiles tests that a file that is not a socket is
+// never removed.
+func TestListen_RefusesOtherFiles(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "dnsctl.sock")
+	if err := os.WriteFile(socket, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Listen(socket, "")
+
+	if err == nil || !strings.Contains(err.Error(), "is not a socket") {
+		t.Errorf("expected not a socket error, got: %v", err)
+	}
+	if data, _ := os.ReadFile(socket); string(data) != "keep" {
+		t.Errorf("expected the file to be kept, got %q", data)
+	}
+}
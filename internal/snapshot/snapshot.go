@@ -0,0 +1,241 @@
+// Package snapshot saves the DNS state of every network service to disk
+// and restores it later.
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// Snapshot names used by dnsctl itself.
+const (
+	// DefaultName is used when "dnsctl snapshot" is given no name.
+	DefaultName = "default"
+	// PreviousName holds the state from before the last TUI change.
+	PreviousName = "previous"
+)
+
+// ErrNotFound is returned when a named snapshot does not exist.
+var ErrNotFound = errors.New("snapshot not found")
+
+// Snapshot is the DNS state of every network service at one point in time.
+type Snapshot struct {
+	Name     string    `yaml:"name"`
+	Created  time.Time `yaml:"created"`
+	Backend  string    `yaml:"backend"`
+	Services []Service `yaml:"services"`
+}
+
+// Service is the saved DNS state of one network service.
+type Service struct {
+	Name             string   `yaml:"name"`
+	DHCP             bool     `yaml:"dhcp,omitempty"`
+	Servers          []string `yaml:"servers,omitempty"`
+	Domains          []string `yaml:"domains,omitempty"`
+	RouteOnlyDomains []string `yaml:"route_only_domains,omitempty"`
+}
+
+// State converts the saved service into an apply.State.
+func (s Service) State() apply.State {
+	return apply.State{
+		Servers: s.Servers,
+		DHCP:    s.DHCP,
+		Domains: dns.Domains{Search: s.Domains, RouteOnly: s.RouteOnlyDomains},
+	}
+}
+
+// Take captures the state of every service the client lists.
+func Take(ctx context.Context, applier *apply.Applier, name string) (*Snapshot, error) {
+	client := dns.WithContext(applier.Client)
+
+	var services []string
+	err := dns.WithTimeout(ctx, applier.Settings.Timeouts.ReadTimeout(), func(ctx context.Context) (err error) {
+		services, err = client.ListNetworkServicesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		Name:    name,
+		Created: time.Now(),
+		Backend: client.Name(),
+	}
+	for _, service := range services {
+		state, err := applier.Capture(ctx, service)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", service, err)
+		}
+		snap.Services = append(snap.Services, Service{
+			Name:             service,
+			DHCP:             state.IsDHCP(),
+			Servers:          state.Servers,
+			Domains:          state.Domains.Search,
+			RouteOnlyDomains: state.Domains.RouteOnly,
+		})
+	}
+
+	return snap, nil
+}
+
+// Result is the outcome of restoring one service.
+type Result struct {
+	Service string
+	// Changed is false if the service already matched the snapshot.
+	Changed bool
+	Err     error
+}
+
+// Restore puts every service in the snapshot back into its saved state.
+// Services that already match are left alone, so restoring does not
+// needlessly reconnect them. Every service is attempted even if an
+// earlier one fails.
+func Restore(ctx context.Context, applier *apply.Applier, snap *Snapshot) []Result {
+	results := make([]Result, 0, len(snap.Services))
+
+	for _, service := range snap.Services {
+		result := Result{Service: service.Name}
+		want := service.State()
+
+		current, err := applier.Capture(ctx, service.Name)
+		if err != nil {
+			result.Err = err
+		} else if !sameState(current, want) {
+			result.Changed = true
+			result.Err = applier.Restore(ctx, service.Name, want)
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// sameState reports whether two states configure the same DNS. Servers
+// learned from DHCP may change from one network to the next, so two DHCP
+// states always have the same servers.
+func sameState(a, b apply.State) bool {
+	if a.IsDHCP() != b.IsDHCP() || !a.Domains.Equal(b.Domains) {
+		return false
+	}
+	return a.IsDHCP() || slices.Equal(a.Servers, b.Servers)
+}
+
+// Store keeps snapshots as YAML files in a directory.
+type Store struct {
+	Dir string
+}
+
+// NewStore returns the store kept next to a config file, in a
+// "snapshots" directory.
+func NewStore(configPath string) *Store {
+	return &Store{Dir: filepath.Join(filepath.Dir(configPath), "snapshots")}
+}
+
+// namePattern limits snapshot names to safe file names.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateName checks that a snapshot name can be used as a file name.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// path returns the file holding the named snapshot.
+func (s *Store) path(name string) string {
+	return filepath.Join(s.Dir, name+".yaml")
+}
+
+// Save writes a snapshot, replacing any snapshot with the same name.
+func (s *Store) Save(snap *Snapshot) error {
+	if err := ValidateName(snap.Name); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn snapshot
+	tmp, err := os.CreateTemp(s.Dir, "."+snap.Name+"-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(snap.Name)); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// Load reads the named snapshot.
+func (s *Store) Load(name string) (*Snapshot, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap Snapshot
+	if err := yaml.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", name, err)
+	}
+	snap.Name = name
+	return &snap, nil
+}
+
+// List returns the names of all saved snapshots, sorted.
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".yaml")
+		if !ok || entry.IsDir() || ValidateName(name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,214 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nycjv321/dnsctl/internal/apply"
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// TestTake tests capturing servers and domains of every service.
+func TestTake(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"1.1.1.1"}
+	mock.Domains = map[string]dns.Domains{"Wi-Fi": {Search: []string{"home.arpa"}}}
+	applier := apply.New(mock, config.Settings{})
+
+	snap, err := Take(context.Background(), applier, "before-vpn")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if snap.Name != "before-vpn" || snap.Backend != "mock" {
+		t.Errorf("unexpected snapshot header: %+v", snap)
+	}
+	if len(snap.Services) != 2 {
+		t.Fatalf("expected 2 services, got %d", len(snap.Services))
+	}
+	wifi, ethernet := snap.Services[0], snap.Services[1]
+	if wifi.Name != "Wi-Fi" || wifi.DHCP || wifi.Servers[0] != "1.1.1.1" || wifi.Domains[0] != "home.arpa" {
+		t.Errorf("unexpected Wi-Fi state: %+v", wifi)
+	}
+	if ethernet.Name != "Ethernet" || !ethernet.DHCP {
+		t.Errorf("expected Ethernet to use DHCP, got %+v", ethernet)
+	}
+}
+
+// TestTake_ListError tests that listing failures are returned.
+func TestTake_ListError(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.ListError = errors.New("network unavailable")
+
+	_, err := Take(context.Background(), apply.New(mock, config.Settings{}), DefaultName)
+
+	if err == nil || err.Error() != "network unavailable" {
+		t.Errorf("expected list error, got: %v", err)
+	}
+}
+
+// TestRestore tests that only changed services are restored.
+func TestRestore(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	applier := apply.New(mock, config.Settings{})
+	snap := &Snapshot{Services: []Service{
+		{Name: "Wi-Fi", Servers: []string{"1.1.1.1"}, Domains: []string{"home.arpa"}},
+		{Name: "Ethernet", DHCP: true},
+	}}
+
+	results := Restore(context.Background(), applier, snap)
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if !results[0].Changed || results[0].Err != nil {
+		t.Errorf("expected Wi-Fi to be restored, got %+v", results[0])
+	}
+	if results[1].Changed || results[1].Err != nil {
+		t.Errorf("expected Ethernet to be unchanged, got %+v", results[1])
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 1 || got[0] != "1.1.1.1" {
+		t.Errorf("expected 1.1.1.1, got %v", got)
+	}
+	if got := mock.Domains["Wi-Fi"].Search; len(got) != 1 || got[0] != "home.arpa" {
+		t.Errorf("expected home.arpa, got %v", got)
+	}
+	if len(mock.ClearCalls) != 0 {
+		t.Errorf("expected no clear calls, got %v", mock.ClearCalls)
+	}
+}
+
+// TestRestore_ContinuesAfterError tests that a failing service doesn't stop the rest.
+func TestRestore_ContinuesAfterError(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"9.9.9.9"}
+	mock.DNSServers["Ethernet"] = []string{"9.9.9.9"}
+	mock.SetError = errors.New("permission denied")
+	applier := apply.New(mock, config.Settings{})
+	snap := &Snapshot{Services: []Service{
+		{Name: "Wi-Fi", Servers: []string{"1.1.1.1"}},
+		{Name: "Ethernet", DHCP: true},
+	}}
+
+	results := Restore(context.Background(), applier, snap)
+
+	if results[0].Err == nil {
+		t.Error("expected Wi-Fi to fail")
+	}
+	if !results[1].Changed || results[1].Err != nil {
+		t.Errorf("expected Ethernet to be cleared, got %+v", results[1])
+	}
+}
+
+// dhcpClient is a MockClient whose servers were all learned from DHCP.
+type dhcpClient struct {
+	*dns.MockClient
+}
+
+func (c dhcpClient) HasManualDNS(ctx context.Context, service string) (bool, error) {
+	return false, nil
+}
+
+// TestRestore_DHCPServers tests that a service still on DHCP is left
+// alone, even if DHCP now gives other servers.
+func TestRestore_DHCPServers(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.DNSServers["Wi-Fi"] = []string{"10.0.0.1"}
+	applier := apply.New(dhcpClient{mock}, config.Settings{})
+	snap := &Snapshot{Services: []Service{
+		{Name: "Wi-Fi", DHCP: true, Servers: []string{"192.168.1.1"}},
+	}}
+
+	results := Restore(context.Background(), applier, snap)
+
+	if results[0].Changed || results[0].Err != nil {
+		t.Errorf("expected Wi-Fi to be unchanged, got %+v", results[0])
+	}
+	if len(mock.ClearCalls) != 0 || len(mock.SetCalls) != 0 {
+		t.Errorf("expected no changes, got %v and %v", mock.ClearCalls, mock.SetCalls)
+	}
+}
+
+// TestStore_SaveLoad tests that snapshots round-trip through disk.
+func TestStore_SaveLoad(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "config.yaml"))
+	snap := &Snapshot{
+		Name:    "home",
+		Backend: "mock",
+		Services: []Service{
+			{Name: "Wi-Fi", Servers: []string{"1.1.1.1"}, RouteOnlyDomains: []string{"corp.example.com"}},
+		},
+	}
+
+	if err := store.Save(snap); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	loaded, err := store.Load("home")
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if loaded.Backend != "mock" || len(loaded.Services) != 1 {
+		t.Fatalf("unexpected snapshot: %+v", loaded)
+	}
+	if loaded.Services[0].RouteOnlyDomains[0] != "corp.example.com" {
+		t.Errorf("expected routing domain, got %+v", loaded.Services[0])
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir, "home.yaml")); err != nil {
+		t.Errorf("expected snapshot file: %v", err)
+	}
+}
+
+// TestStore_LoadMissing tests the error for unknown snapshots.
+func TestStore_LoadMissing(t *testing.T) {
+	store := &Store{Dir: t.TempDir()}
+
+	_, err := store.Load("nope")
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+}
+
+// TestStore_List tests listing saved snapshots by name.
+func TestStore_List(t *testing.T) {
+	store := &Store{Dir: filepath.Join(t.TempDir(), "snapshots")}
+
+	names, err := store.List()
+	if err != nil || len(names) != 0 {
+		t.Fatalf("expected no snapshots, got %v, %v", names, err)
+	}
+
+	for _, name := range []string{"work", "home"} {
+		if err := store.Save(&Snapshot{Name: name}); err != nil {
+			t.Fatalf("failed to save %s: %v", name, err)
+		}
+	}
+	names, err = store.List()
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(names) != 2 || names[0] != "home" || names[1] != "work" {
+		t.Errorf("expected [home work], got %v", names)
+	}
+}
+
+// TestValidateName tests that names cannot escape the snapshot directory.
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"default", "before-vpn", "2024.01_a"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("expected %q to be valid, got: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../etc", "a/b", ".hidden"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
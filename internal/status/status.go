@@ -0,0 +1,102 @@
+// Package status reports the DNS state of every network service, for
+// "dnsctl status" and the control API.
+package status
+
+import (
+	"context"
+
+	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
+)
+
+// Report is the DNS state of every network service.
+type Report struct {
+	Backend  string    `json:"backend" yaml:"backend"`
+	Services []Service `json:"services" yaml:"services"`
+}
+
+// Service describes the DNS state of a single network service.
+type Service struct {
+	Name    string   `json:"name" yaml:"name"`
+	Default bool     `json:"default" yaml:"default"`
+	DHCP    bool     `json:"dhcp" yaml:"dhcp"`
+	Servers []string `json:"servers" yaml:"servers"`
+	Profile string   `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Error   string   `json:"error,omitempty" yaml:"error,omitempty"`
+
+	Domains          []string `json:"domains,omitempty" yaml:"domains,omitempty"`
+	RouteOnlyDomains []string `json:"route_only_domains,omitempty" yaml:"route_only_domains,omitempty"`
+}
+
+// Collect reads the DNS state of every service the client lists and
+// finds the profile each one matches. Each read is bounded by the read
+// timeout. A service that cannot be read is reported with its error
+// instead of failing the whole report.
+func Collect(ctx context.Context, plain dns.Client, cfg *config.Config) (Report, error) {
+	client := dns.WithContext(plain)
+	timeout := cfg.Settings.Timeouts.ReadTimeout()
+
+	var services []string
+	err := dns.WithTimeout(ctx, timeout, func(ctx context.Context) (err error) {
+		services, err = client.ListNetworkServicesContext(ctx)
+		return err
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	// An unresolvable default service just means none is marked as default
+	defaultService, _ := dns.ResolveService(client, cfg.DefaultService)
+
+	report := Report{
+		Backend:  client.Name(),
+		Services: make([]Service, 0, len(services)),
+	}
+	for _, service := range services {
+		status := Service{
+			Name:    service,
+			Default: service == defaultService,
+			Servers: []string{},
+		}
+
+		var servers []string
+		var manual bool
+		err := dns.WithTimeout(ctx, timeout, func(ctx context.Context) (err error) {
+			servers, err = client.GetDNSServersContext(ctx, service)
+			if err != nil {
+				return err
+			}
+			manual, err = dns.HasManualDNS(ctx, plain, service, servers)
+			return err
+		})
+		if err != nil {
+			status.Error = err.Error()
+		} else {
+			status.DHCP = !manual
+			if servers != nil {
+				status.Servers = servers
+			}
+			if matches := cfg.MatchProfiles(servers, status.DHCP, cfg.Settings.MatchOptions()); len(matches) > 0 {
+				status.Profile = matches[0]
+			}
+		}
+
+		if domainClient, ok := plain.(dns.DomainClient); ok && status.Error == "" {
+			var domains dns.Domains
+			err := dns.WithTimeout(ctx, timeout, func(ctx context.Context) (err error) {
+				domains, err = domainClient.GetDomains(ctx, service)
+				return err
+			})
+			if err != nil {
+				status.Error = err.Error()
+			} else {
+				status.Domains = domains.Search
+				status.RouteOnlyDomains = domains.RouteOnly
+			}
+		}
+
+		report.Services = append(report.Services, status)
+	}
+
+	return report, nil
+}
@@ -1,13 +1,20 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nycjv321/dnsctl/internal/apply"
 	"github.com/nycjv321/dnsctl/internal/config"
 	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+	"github.com/nycjv321/dnsctl/internal/snapshot"
 )
 
 // Model represents the application state.
@@ -18,12 +25,34 @@ type Model struct {
 	currentView    View
 	currentService string
 	currentDNS     []string
+	currentDomains dns.Domains
 	services       []string
+	targetServices []string
 	selectedIndex  int
 	statusMsg      string
 	statusIsError  bool
 	width          int
 	height         int
+
+	// cancelApply aborts the in-flight DNS change, if any.
+	cancelApply context.CancelFunc
+
+	// checker probes servers after a change; nil uses real DNS queries.
+	checker probe.Checker
+
+	// snapshots stores the automatic snapshot taken before each change.
+	// Without a store, "restore previous" is unavailable.
+	snapshots *snapshot.Store
+
+	// remote makes every change, if set, instead of dnsClient.
+	remote Remote
+}
+
+// Remote applies and clears DNS by profile name, for a TUI connected to
+// "dnsctl serve". The server verifies and rolls back each change itself.
+type Remote interface {
+	Apply(ctx context.Context, profile string, services []string) ([]apply.ServiceResult, error)
+	Clear(ctx context.Context, services []string) ([]apply.ServiceResult, error)
 }
 
 // NewModel creates a new TUI model.
@@ -38,6 +67,20 @@ func NewModel(cfg *config.Config, dnsClient dns.Client) Model {
 	}
 }
 
+// WithSnapshots returns a copy of the model that saves a snapshot to
+// store before each change, so it can be undone with "restore previous".
+func (m Model) WithSnapshots(store *snapshot.Store) Model {
+	m.snapshots = store
+	return m
+}
+
+// WithRemote returns a copy of the model that makes every change through
+// remote, applying profiles by name.
+func (m Model) WithRemote(remote Remote) Model {
+	m.remote = remote
+	return m
+}
+
 // Init initializes the model.
 func (m Model) Init() tea.Cmd {
 	return m.refreshStatus
@@ -45,28 +88,58 @@ func (m Model) Init() tea.Cmd {
 
 // refreshStatus fetches the current DNS status.
 func (m Model) refreshStatus() tea.Msg {
-	// Get network services
-	services, err := m.dnsClient.ListNetworkServices()
-	if err != nil {
-		return statusMsg{err: err}
-	}
+	client := dns.WithContext(m.dnsClient)
+
+	var msg statusMsg
+	err := dns.WithTimeout(context.Background(), m.config.Settings.Timeouts.ReadTimeout(), func(ctx context.Context) error {
+		// Get network services
+		services, err := client.ListNetworkServicesContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Resolve the "auto" placeholder into a real service
+		service, err := dns.ResolveService(m.dnsClient, m.currentService)
+		if err != nil {
+			return err
+		}
+
+		// Get current DNS servers
+		dnsServers, err := client.GetDNSServersContext(ctx, service)
+		if err != nil {
+			return err
+		}
+
+		// Get current domains, if the backend manages them
+		var domains dns.Domains
+		if domainClient, ok := m.dnsClient.(dns.DomainClient); ok {
+			domains, err = domainClient.GetDomains(ctx, service)
+			if err != nil {
+				return err
+			}
+		}
 
-	// Get current DNS servers
-	dnsServers, err := m.dnsClient.GetDNSServers(m.currentService)
+		msg = statusMsg{
+			service:    service,
+			services:   services,
+			dnsServers: dnsServers,
+			domains:    domains,
+		}
+		return nil
+	})
 	if err != nil {
 		return statusMsg{err: err}
 	}
 
-	return statusMsg{
-		services:   services,
-		dnsServers: dnsServers,
-	}
+	return msg
 }
 
 // statusMsg is a message containing the current status.
 type statusMsg struct {
+	service    string
 	services   []string
 	dnsServers []string
+	domains    dns.Domains
 	err        error
 }
 
@@ -92,12 +165,25 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.statusMsg = fmt.Sprintf("Error: %v", msg.err)
 			m.statusIsError = true
 		} else {
+			if msg.service != "" {
+				m.currentService = msg.service
+			}
 			m.services = msg.services
+			m.targetServices = slices.DeleteFunc(slices.Clone(m.targetServices), func(s string) bool {
+				return !slices.Contains(msg.services, s)
+			})
 			m.currentDNS = msg.dnsServers
+			m.currentDomains = msg.domains
 		}
 		return m, nil
 
 	case dnsChangedMsg:
+		// The change has finished; release its context
+		if m.cancelApply != nil {
+			m.cancelApply()
+			m.cancelApply = nil
+		}
+
 		if msg.success {
 			m.statusMsg = msg.message
 			m.statusIsError = false
@@ -127,6 +213,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // handleKeyPress handles key press events.
 func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.cancelApply != nil {
+		switch {
+		case key.Matches(msg, m.keys.Back):
+			// Esc aborts the in-flight change instead of navigating
+			m.cancelApply()
+			m.statusMsg = "Cancelling..."
+			m.statusIsError = false
+			return m, nil
+
+		case key.Matches(msg, m.keys.Quit):
+			m.cancelApply()
+		}
+	}
+
 	switch m.currentView {
 	case ViewMain:
 		return m.handleMainKeys(msg)
@@ -151,7 +251,28 @@ func (m Model) handleMainKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case key.Matches(msg, m.keys.ClearDNS):
-		return m, m.clearDNS
+		if m.cancelApply != nil {
+			return m, nil
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancelApply = cancel
+		m.statusMsg = "Clearing DNS... (esc to cancel)"
+		m.statusIsError = false
+		return m, func() tea.Msg {
+			return m.clearDNSContext(ctx)
+		}
+
+	case key.Matches(msg, m.keys.Restore):
+		if m.cancelApply != nil {
+			return m, nil
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancelApply = cancel
+		m.statusMsg = "Restoring previous DNS... (esc to cancel)"
+		m.statusIsError = false
+		return m, func() tea.Msg {
+			return m.restorePrevious(ctx)
+		}
 
 	case key.Matches(msg, m.keys.ChangeService):
 		m.currentView = ViewServices
@@ -200,8 +321,12 @@ func (m Model) handleProfileKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case key.Matches(msg, m.keys.Select):
 		name, profile, ok := m.getSelectedProfile()
-		if ok {
-			return m, m.applyProfile(name, profile)
+		if ok && m.cancelApply == nil {
+			ctx, cancel := context.WithCancel(context.Background())
+			m.cancelApply = cancel
+			m.statusMsg = fmt.Sprintf("Applying profile: %s... (esc to cancel)", name)
+			m.statusIsError = false
+			return m, m.applyProfileContext(ctx, name, profile)
 		}
 		return m, nil
 	}
@@ -234,9 +359,19 @@ func (m Model) handleServiceKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case key.Matches(msg, m.keys.Toggle):
+		if service, ok := m.getSelectedService(); ok {
+			m.targetServices = m.toggleTarget(service)
+		}
+		return m, nil
+
 	case key.Matches(msg, m.keys.Select):
 		service, ok := m.getSelectedService()
 		if ok {
+			// With services marked, show one of them rather than the cursor
+			if len(m.targetServices) > 0 && !slices.Contains(m.targetServices, service) {
+				service = m.targetServices[0]
+			}
 			m.currentService = service
 			m.currentView = ViewMain
 			return m, m.refreshStatus
@@ -249,17 +384,38 @@ func (m Model) handleServiceKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 // applyProfile applies a DNS profile.
 func (m Model) applyProfile(name string, profile config.Profile) tea.Cmd {
+	return m.applyProfileContext(context.Background(), name, profile)
+}
+
+// applyProfileContext applies a DNS profile until ctx is cancelled.
+func (m Model) applyProfileContext(ctx context.Context, name string, profile config.Profile) tea.Cmd {
 	return func() tea.Msg {
-		var err error
+		services, err := m.targets(profile)
+		if err != nil {
+			return dnsChangedMsg{
+				success: false,
+				message: fmt.Sprintf("Failed to apply profile: %v", err),
+			}
+		}
 
-		if profile.IsDHCP() {
-			// Clear DNS to use DHCP
-			err = m.dnsClient.ClearDNSServers(m.currentService)
-		} else {
-			// Set specific DNS servers
-			err = m.dnsClient.SetDNSServers(m.currentService, profile.Servers)
+		results, err := m.change(ctx, name, profile, services)
+		if cancelled(results, err) {
+			return dnsChangedMsg{
+				success: false,
+				message: fmt.Sprintf("Cancelled applying profile: %s", name),
+			}
+		}
+		if err != nil {
+			return dnsChangedMsg{
+				success: false,
+				message: fmt.Sprintf("Failed to apply profile: %v", err),
+			}
+		}
+		if len(results) > 1 {
+			return m.reportResults(fmt.Sprintf("Applied profile: %s", name), fmt.Sprintf("Failed to apply profile: %s", name), results)
 		}
 
+		result, err := results[0].Result, results[0].Err
 		if err != nil {
 			return dnsChangedMsg{
 				success: false,
@@ -267,21 +423,127 @@ func (m Model) applyProfile(name string, profile config.Profile) tea.Cmd {
 			}
 		}
 
-		// Flush cache if configured
-		if m.config.Settings.FlushCache {
-			_ = m.dnsClient.FlushCache()
+		if !m.config.Settings.Verify || len(result.Probes) == 0 {
+			return dnsChangedMsg{
+				success: true,
+				message: fmt.Sprintf("Applied profile: %s", name),
+			}
 		}
 
+		// Report how each of the new servers answered
+		if !probe.AnyOK(result.Probes) {
+			return dnsChangedMsg{
+				success: false,
+				message: fmt.Sprintf("Applied profile: %s, but no server answered (%s)", name, probe.Summary(result.Probes)),
+			}
+		}
 		return dnsChangedMsg{
 			success: true,
-			message: fmt.Sprintf("Applied profile: %s", name),
+			message: fmt.Sprintf("Applied profile: %s (%s)", name, probe.Summary(result.Probes)),
 		}
 	}
 }
 
+// targets returns the services a change applies to: the services marked
+// in the service list, else the profile's own services, else the current
+// service.
+func (m Model) targets(profile config.Profile) ([]string, error) {
+	if len(m.targetServices) > 0 {
+		return m.targetServices, nil
+	}
+	if len(profile.Services) > 0 {
+		return dns.ResolveServices(m.dnsClient, profile.Services)
+	}
+	return []string{m.currentService}, nil
+}
+
+// toggleTarget marks or unmarks a service as a target, keeping the
+// targets in service list order.
+func (m Model) toggleTarget(service string) []string {
+	marked := slices.Contains(m.targetServices, service)
+
+	var targets []string
+	for _, s := range m.services {
+		if s == service {
+			if !marked {
+				targets = append(targets, s)
+			}
+		} else if slices.Contains(m.targetServices, s) {
+			targets = append(targets, s)
+		}
+	}
+	return targets
+}
+
+// cancelled returns true if the change as a whole, or any service's
+// change, was cancelled.
+func cancelled(results []apply.ServiceResult, err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	for _, r := range results {
+		if errors.Is(r.Err, context.Canceled) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportResults describes a change to several services, naming the
+// services that changed and each one that failed with its error.
+func (m Model) reportResults(done, failure string, results []apply.ServiceResult) dnsChangedMsg {
+	var changed, failed []string
+	var probes []probe.Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", r.Service, r.Err))
+			continue
+		}
+		changed = append(changed, r.Service)
+		if probes == nil {
+			probes = r.Probes
+		}
+	}
+
+	switch {
+	case len(changed) == 0:
+		return dnsChangedMsg{
+			success: false,
+			message: fmt.Sprintf("%s (%s)", failure, strings.Join(failed, "; ")),
+		}
+	case len(failed) > 0:
+		return dnsChangedMsg{
+			success: false,
+			message: fmt.Sprintf("%s on %s, but failed on %s", done, strings.Join(changed, ", "), strings.Join(failed, "; ")),
+		}
+	}
+
+	message := fmt.Sprintf("%s on %s", done, strings.Join(changed, ", "))
+	if !m.config.Settings.Verify || len(probes) == 0 {
+		return dnsChangedMsg{success: true, message: message}
+	}
+	// Every service got the same servers, so one service's probes stand for all
+	return dnsChangedMsg{
+		success: probe.AnyOK(probes),
+		message: fmt.Sprintf("%s (%s)", message, probe.Summary(probes)),
+	}
+}
+
+// newApplier creates an Applier for the model's client and settings.
+func (m Model) newApplier() *apply.Applier {
+	applier := apply.New(m.dnsClient, m.config.Settings)
+	applier.Checker = m.checker
+	return applier
+}
+
 // clearDNS clears the DNS servers to use DHCP defaults.
 func (m Model) clearDNS() tea.Msg {
-	err := m.dnsClient.ClearDNSServers(m.currentService)
+	return m.clearDNSContext(context.Background())
+}
+
+// clearDNSContext clears the DNS servers until ctx is cancelled.
+func (m Model) clearDNSContext(ctx context.Context) tea.Msg {
+	services, err := m.targets(config.Profile{})
 	if err != nil {
 		return dnsChangedMsg{
 			success: false,
@@ -289,9 +551,28 @@ func (m Model) clearDNS() tea.Msg {
 		}
 	}
 
-	// Flush cache if configured
-	if m.config.Settings.FlushCache {
-		_ = m.dnsClient.FlushCache()
+	results, err := m.change(ctx, "", config.Profile{DHCP: true}, services)
+	if cancelled(results, err) {
+		return dnsChangedMsg{
+			success: false,
+			message: "Cancelled clearing DNS",
+		}
+	}
+	if err != nil {
+		return dnsChangedMsg{
+			success: false,
+			message: fmt.Sprintf("Failed to clear DNS: %v", err),
+		}
+	}
+	if len(results) > 1 {
+		return m.reportResults("Cleared DNS", "Failed to clear DNS", results)
+	}
+
+	if err := results[0].Err; err != nil {
+		return dnsChangedMsg{
+			success: false,
+			message: fmt.Sprintf("Failed to clear DNS: %v", err),
+		}
 	}
 
 	return dnsChangedMsg{
@@ -300,6 +581,81 @@ func (m Model) clearDNS() tea.Msg {
 	}
 }
 
+// change applies the profile called name to services, or clears them if
+// name is empty. Through a remote, the server applies its own profile of
+// that name; otherwise the previous snapshot is saved first.
+func (m Model) change(ctx context.Context, name string, profile config.Profile, services []string) ([]apply.ServiceResult, error) {
+	if m.remote != nil {
+		if name == "" {
+			return m.remote.Clear(ctx, services)
+		}
+		return m.remote.Apply(ctx, name, services)
+	}
+
+	applier := m.newApplier()
+	if err := m.savePrevious(ctx, applier); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return applier.ApplyAll(ctx, services, profile), nil
+}
+
+// savePrevious saves the current DNS of every service as the "previous"
+// snapshot, if the model has a snapshot store.
+func (m Model) savePrevious(ctx context.Context, applier *apply.Applier) error {
+	if m.snapshots == nil {
+		return nil
+	}
+
+	snap, err := snapshot.Take(ctx, applier, snapshot.PreviousName)
+	if err != nil {
+		return err
+	}
+	return m.snapshots.Save(snap)
+}
+
+// restorePrevious restores the snapshot taken before the last change.
+func (m Model) restorePrevious(ctx context.Context) tea.Msg {
+	if m.snapshots == nil {
+		return dnsChangedMsg{success: false, message: "No previous DNS to restore"}
+	}
+
+	snap, err := m.snapshots.Load(snapshot.PreviousName)
+	if errors.Is(err, snapshot.ErrNotFound) {
+		return dnsChangedMsg{success: false, message: "No previous DNS to restore"}
+	}
+	if err != nil {
+		return dnsChangedMsg{success: false, message: fmt.Sprintf("Failed to restore previous DNS: %v", err)}
+	}
+
+	var restored, failed []string
+	for _, r := range snapshot.Restore(ctx, m.newApplier(), snap) {
+		switch {
+		case r.Err != nil:
+			if errors.Is(r.Err, context.Canceled) {
+				return dnsChangedMsg{success: false, message: "Cancelled restoring previous DNS"}
+			}
+			failed = append(failed, fmt.Sprintf("%s: %v", r.Service, r.Err))
+		case r.Changed:
+			restored = append(restored, r.Service)
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		return dnsChangedMsg{
+			success: false,
+			message: fmt.Sprintf("Failed to restore previous DNS: %s", strings.Join(failed, "; ")),
+		}
+	case len(restored) == 0:
+		return dnsChangedMsg{success: true, message: "Previous DNS already in place"}
+	default:
+		return dnsChangedMsg{
+			success: true,
+			message: fmt.Sprintf("Restored previous DNS: %s", strings.Join(restored, ", ")),
+		}
+	}
+}
+
 // View renders the current view.
 func (m Model) View() string {
 	switch m.currentView {
@@ -1,12 +1,19 @@
 package tui
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nycjv321/dnsctl/internal/apply"
 	"github.com/nycjv321/dnsctl/internal/config"
 	"github.com/nycjv321/dnsctl/internal/dns"
+	"github.com/nycjv321/dnsctl/internal/probe"
+	"github.com/nycjv321/dnsctl/internal/probe/probetest"
+	"github.com/nycjv321/dnsctl/internal/snapshot"
 )
 
 // testConfig returns a test configuration with known profiles.
@@ -42,9 +49,19 @@ func testModel() (Model, *dns.MockClient) {
 	model := NewModel(cfg, mock)
 	model.services = mock.Services
 	model.currentDNS = mock.DNSServers["Wi-Fi"]
+	model.checker = answeringChecker
 	return model, mock
 }
 
+// answeringChecker reports every server as answering without sending queries.
+var answeringChecker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+	results := make([]probe.Result, len(servers))
+	for i, server := range servers {
+		results[i] = probe.Result{Server: server, Latency: time.Millisecond}
+	}
+	return results
+})
+
 // TestNewModel_InitializesCorrectly tests that NewModel sets up the model properly.
 func TestNewModel_InitializesCorrectly(t *testing.T) {
 	mock := dns.NewMockClient()
@@ -474,6 +491,38 @@ func TestServicesView_SelectService(t *testing.T) {
 	}
 }
 
+// TestServicesView_ToggleTargets tests marking several services with space.
+func TestServicesView_ToggleTargets(t *testing.T) {
+	model, _ := testModel()
+	model.currentView = ViewServices
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+
+	newModel, _ := model.Update(space)
+	m := newModel.(Model)
+	m.selectedIndex = 1
+	newModel, _ = m.Update(space)
+	m = newModel.(Model)
+
+	if strings.Join(m.targetServices, ",") != "Wi-Fi,Ethernet" {
+		t.Fatalf("expected both services marked, got %v", m.targetServices)
+	}
+
+	m.selectedIndex = 0
+	newModel, _ = m.Update(space)
+	m = newModel.(Model)
+
+	if strings.Join(m.targetServices, ",") != "Ethernet" {
+		t.Errorf("expected only Ethernet marked, got %v", m.targetServices)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+
+	if m.currentService != "Ethernet" {
+		t.Errorf("expected the marked service to become current, got %s", m.currentService)
+	}
+}
+
 // TestServicesView_Back tests going back from services view.
 func TestServicesView_Back(t *testing.T) {
 	model, _ := testModel()
@@ -518,6 +567,205 @@ func TestApplyProfile_SetsDNS(t *testing.T) {
 	}
 }
 
+// fakeRemote records the changes a model makes through a server.
+type fakeRemote struct {
+	changes []string
+	err     error
+}
+
+func (r *fakeRemote) Apply(ctx context.Context, profile string, services []string) ([]apply.ServiceResult, error) {
+	return r.change("apply "+profile, services)
+}
+
+func (r *fakeRemote) Clear(ctx context.Context, services []string) ([]apply.ServiceResult, error) {
+	return r.change("clear", services)
+}
+
+func (r *fakeRemote) change(action string, services []string) ([]apply.ServiceResult, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.changes = append(r.changes, action+" on "+strings.Join(services, ","))
+	results := make([]apply.ServiceResult, len(services))
+	for i, service := range services {
+		results[i].Service = service
+	}
+	return results, nil
+}
+
+// TestApplyProfile_Remote tests that a remote applies the profile by name
+// instead of the model's client.
+func TestApplyProfile_Remote(t *testing.T) {
+	model, mock := testModel()
+	remote := &fakeRemote{}
+	model = model.WithRemote(remote)
+
+	result := model.applyProfile("cloudflare", model.config.Profiles["cloudflare"])()
+
+	dnsMsg := result.(dnsChangedMsg)
+	if !dnsMsg.success || dnsMsg.message != "Applied profile: cloudflare" {
+		t.Errorf("expected success, got: %s", dnsMsg.message)
+	}
+	if strings.Join(remote.changes, "; ") != "apply cloudflare on Wi-Fi" {
+		t.Errorf("expected the profile to be applied remotely, got %v", remote.changes)
+	}
+	if len(mock.SetCalls) != 0 {
+		t.Errorf("expected no local changes, got %v", mock.SetCalls)
+	}
+}
+
+// TestClearDNS_Remote tests that a remote clears DNS, and that its
+// errors are reported.
+func TestClearDNS_Remote(t *testing.T) {
+	model, mock := testModel()
+	remote := &fakeRemote{}
+	model = model.WithRemote(remote)
+
+	result := model.clearDNS().(dnsChangedMsg)
+
+	if !result.success {
+		t.Errorf("expected success, got: %s", result.message)
+	}
+	if strings.Join(remote.changes, "; ") != "clear on Wi-Fi" {
+		t.Errorf("expected DNS to be cleared remotely, got %v", remote.changes)
+	}
+	if len(mock.ClearCalls) != 0 {
+		t.Errorf("expected no local changes, got %v", mock.ClearCalls)
+	}
+
+	remote.err = errors.New("dnsctl serve: 503 Service Unavailable")
+	result = model.clearDNS().(dnsChangedMsg)
+	if result.success || result.message != "Failed to clear DNS: dnsctl serve: 503 Service Unavailable" {
+		t.Errorf("expected the remote's error, got: %s", result.message)
+	}
+}
+
+// TestApplyProfile_MultipleServices tests applying to every marked service.
+func TestApplyProfile_MultipleServices(t *testing.T) {
+	model, mock := testModel()
+	model.targetServices = []string{"Wi-Fi", "Ethernet"}
+
+	result := model.applyProfile("test", config.Profile{Servers: []string{"9.9.9.9"}})()
+
+	dnsMsg := result.(dnsChangedMsg)
+	if !dnsMsg.success {
+		t.Errorf("expected success, got: %s", dnsMsg.message)
+	}
+	if dnsMsg.message != "Applied profile: test on Wi-Fi, Ethernet" {
+		t.Errorf("unexpected message: %s", dnsMsg.message)
+	}
+	if len(mock.SetCalls) != 2 {
+		t.Errorf("expected 2 set calls, got %v", mock.SetCalls)
+	}
+}
+
+// TestApplyProfile_PartialFailure tests that a failing service is named
+// alongside the ones that changed.
+func TestApplyProfile_PartialFailure(t *testing.T) {
+	model, mock := testModel()
+	model.targetServices = []string{"Wi-Fi", "Ethernet"}
+	mock.ServiceErrors = map[string]error{"Ethernet": errors.New("device not managed")}
+
+	result := model.applyProfile("test", config.Profile{Servers: []string{"9.9.9.9"}})()
+
+	dnsMsg := result.(dnsChangedMsg)
+	if dnsMsg.success {
+		t.Error("expected failure")
+	}
+	if dnsMsg.message != "Applied profile: test on Wi-Fi, but failed on Ethernet: device not managed" {
+		t.Errorf("unexpected message: %s", dnsMsg.message)
+	}
+}
+
+// TestApplyProfile_ProfileServices tests that a profile's own services are
+// used when none are marked.
+func TestApplyProfile_ProfileServices(t *testing.T) {
+	model, mock := testModel()
+
+	result := model.applyProfile("test", config.Profile{Servers: []string{"9.9.9.9"}, Services: []string{"Eth*"}})()
+
+	dnsMsg := result.(dnsChangedMsg)
+	if !dnsMsg.success {
+		t.Errorf("expected success, got: %s", dnsMsg.message)
+	}
+	if len(mock.SetCalls) != 1 || mock.SetCalls[0].Service != "Ethernet" {
+		t.Errorf("expected set call for Ethernet, got %v", mock.SetCalls)
+	}
+}
+
+// TestApplyProfile_Verify tests that verification results are shown in the status line.
+func TestApplyProfile_Verify(t *testing.T) {
+	model, _ := testModel()
+	model.checker = nil
+	model.config.Settings.Verify = true
+	model.config.Settings.Timeouts.Probe = 100 * time.Millisecond
+	good := probetest.Start(t, probetest.Options{})
+	bad := probetest.Start(t, probetest.Options{Silent: true})
+
+	result := model.applyProfile("local", config.Profile{Servers: []string{good.Addr, bad.Addr}})()
+
+	dnsMsg, ok := result.(dnsChangedMsg)
+	if !ok {
+		t.Fatal("expected dnsChangedMsg")
+	}
+	if !dnsMsg.success {
+		t.Errorf("expected success, got: %s", dnsMsg.message)
+	}
+	if !strings.HasPrefix(dnsMsg.message, "Applied profile: local ("+good.Addr+" ") {
+		t.Errorf("expected latency in message, got: %s", dnsMsg.message)
+	}
+	if !strings.HasSuffix(dnsMsg.message, bad.Addr+" timed out)") {
+		t.Errorf("expected timeout in message, got: %s", dnsMsg.message)
+	}
+}
+
+// TestApplyProfile_RollsBack tests that unreachable servers are rolled back.
+func TestApplyProfile_RollsBack(t *testing.T) {
+	model, mock := testModel()
+	model.checker = probe.CheckerFunc(func(ctx context.Context, servers []string) []probe.Result {
+		return []probe.Result{{Server: servers[0], Err: context.DeadlineExceeded}}
+	})
+
+	result := model.applyProfile("broken", config.Profile{Servers: []string{"192.0.2.1"}})()
+
+	dnsMsg, ok := result.(dnsChangedMsg)
+	if !ok {
+		t.Fatal("expected dnsChangedMsg")
+	}
+	if dnsMsg.success {
+		t.Error("expected failure")
+	}
+	if dnsMsg.message != "Failed to apply profile: no server answered (192.0.2.1 timed out); rolled back to previous DNS" {
+		t.Errorf("unexpected message: %s", dnsMsg.message)
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 2 || got[0] != "8.8.8.8" {
+		t.Errorf("expected previous servers to be restored, got %v", got)
+	}
+}
+
+// TestApplyProfile_VerifyNoAnswer tests that unreachable servers are reported when rollback is off.
+func TestApplyProfile_VerifyNoAnswer(t *testing.T) {
+	model, _ := testModel()
+	model.checker = nil
+	model.config.Settings.Verify = true
+	model.config.Settings.Timeouts.Probe = 50 * time.Millisecond
+	bad := probetest.Start(t, probetest.Options{Silent: true})
+	noRollback := false
+
+	result := model.applyProfile("local", config.Profile{Servers: []string{bad.Addr}, Rollback: &noRollback})()
+
+	dnsMsg, ok := result.(dnsChangedMsg)
+	if !ok {
+		t.Fatal("expected dnsChangedMsg")
+	}
+	if dnsMsg.success {
+		t.Error("expected failure")
+	}
+	if dnsMsg.message != "Applied profile: local, but no server answered ("+bad.Addr+" timed out)" {
+		t.Errorf("unexpected message: %s", dnsMsg.message)
+	}
+}
+
 // TestApplyProfile_DHCP_ClearsDNS tests that DHCP profiles clear DNS.
 func TestApplyProfile_DHCP_ClearsDNS(t *testing.T) {
 	model, mock := testModel()
@@ -569,6 +817,7 @@ func TestApplyProfile_NoFlushWhenDisabled(t *testing.T) {
 	cfg := testConfig()
 	cfg.Settings.FlushCache = false
 	model := NewModel(cfg, mock)
+	model.checker = answeringChecker
 
 	profile := config.Profile{
 		Description: "Test",
@@ -648,6 +897,25 @@ func TestClearDNS_Error(t *testing.T) {
 	}
 }
 
+// TestClearDNS_MultipleServices tests clearing every marked service.
+func TestClearDNS_MultipleServices(t *testing.T) {
+	model, mock := testModel()
+	model.targetServices = []string{"Wi-Fi", "Ethernet"}
+	mock.ClearError = errors.New("permission denied")
+
+	dnsMsg := model.clearDNS().(dnsChangedMsg)
+
+	if dnsMsg.success {
+		t.Error("expected failure")
+	}
+	if dnsMsg.message != "Failed to clear DNS (Wi-Fi: permission denied; Ethernet: permission denied)" {
+		t.Errorf("unexpected message: %s", dnsMsg.message)
+	}
+	if len(mock.ClearCalls) != 2 {
+		t.Errorf("expected 2 clear calls, got %v", mock.ClearCalls)
+	}
+}
+
 // TestRefreshStatus_Success tests successful status refresh.
 func TestRefreshStatus_Success(t *testing.T) {
 	model, mock := testModel()
@@ -670,6 +938,24 @@ func TestRefreshStatus_Success(t *testing.T) {
 	}
 }
 
+// TestRefreshStatus_Domains tests that current domains are fetched and shown.
+func TestRefreshStatus_Domains(t *testing.T) {
+	model, mock := testModel()
+	mock.Domains = map[string]dns.Domains{
+		"Wi-Fi": {Search: []string{"corp.example.com"}, RouteOnly: []string{"internal.example.com"}},
+	}
+
+	newModel, _ := model.Update(model.refreshStatus())
+	m := newModel.(Model)
+
+	if len(m.currentDomains.Search) != 1 || m.currentDomains.Search[0] != "corp.example.com" {
+		t.Errorf("expected search domain corp.example.com, got %v", m.currentDomains.Search)
+	}
+	if !strings.Contains(m.View(), "Domains: corp.example.com, ~internal.example.com") {
+		t.Errorf("expected domains in view, got:\n%s", m.View())
+	}
+}
+
 // TestRefreshStatus_ListError tests error handling when listing services fails.
 func TestRefreshStatus_ListError(t *testing.T) {
 	model, mock := testModel()
@@ -707,3 +993,146 @@ func TestRefreshStatus_GetError(t *testing.T) {
 		t.Errorf("unexpected error: %v", statusResult.err)
 	}
 }
+
+// TestRefreshStatus_ResolvesAutoService tests that "auto" is resolved to a real service.
+func TestRefreshStatus_ResolvesAutoService(t *testing.T) {
+	model, mock := testModel()
+	model.currentService = dns.AutoService
+	mock.DefaultName = "Ethernet"
+	mock.DNSServers["Ethernet"] = []string{"9.9.9.9"}
+
+	result := model.refreshStatus()
+
+	statusResult, ok := result.(statusMsg)
+	if !ok {
+		t.Fatal("expected statusMsg")
+	}
+	if statusResult.err != nil {
+		t.Fatalf("expected no error, got: %v", statusResult.err)
+	}
+	if statusResult.service != "Ethernet" {
+		t.Errorf("expected Ethernet, got %s", statusResult.service)
+	}
+
+	newModel, _ := model.Update(statusResult)
+	m := newModel.(Model)
+	if m.currentService != "Ethernet" {
+		t.Errorf("expected currentService to be Ethernet, got %s", m.currentService)
+	}
+	if len(m.currentDNS) != 1 || m.currentDNS[0] != "9.9.9.9" {
+		t.Errorf("expected [9.9.9.9], got %v", m.currentDNS)
+	}
+}
+
+// TestProfilesView_EscCancelsApply tests that Esc aborts an in-flight apply.
+func TestProfilesView_EscCancelsApply(t *testing.T) {
+	model, mock := testModel()
+	mock.Delay = time.Hour
+	model.currentView = ViewProfiles
+	model.selectedIndex = 0
+
+	newModel, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m := newModel.(Model)
+	if cmd == nil {
+		t.Fatal("expected command to apply profile")
+	}
+	if m.cancelApply == nil {
+		t.Fatal("expected apply to be cancellable")
+	}
+
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+	if m.currentView != ViewProfiles {
+		t.Errorf("expected to stay in ViewProfiles while cancelling, got %v", m.currentView)
+	}
+
+	var result tea.Msg
+	select {
+	case result = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("apply was not cancelled")
+	}
+
+	dnsMsg, ok := result.(dnsChangedMsg)
+	if !ok {
+		t.Fatal("expected dnsChangedMsg")
+	}
+	if dnsMsg.success {
+		t.Error("expected failure")
+	}
+	if dnsMsg.message != "Cancelled applying profile: cloudflare" {
+		t.Errorf("unexpected message: %s", dnsMsg.message)
+	}
+
+	newModel, _ = m.Update(dnsMsg)
+	m = newModel.(Model)
+	if m.cancelApply != nil {
+		t.Error("expected cancel func to be released")
+	}
+}
+
+// TestApplyProfile_Timeout tests that timeouts are shown in the status line.
+func TestApplyProfile_Timeout(t *testing.T) {
+	model, mock := testModel()
+	mock.Delay = time.Hour
+	model.config.Settings.Timeouts.Apply = 20 * time.Millisecond
+
+	result := model.applyProfile("test", config.Profile{Servers: []string{"9.9.9.9"}})()
+
+	dnsMsg, ok := result.(dnsChangedMsg)
+	if !ok {
+		t.Fatal("expected dnsChangedMsg")
+	}
+	// The hung backend cannot restore the previous DNS either
+	if dnsMsg.message != "Failed to apply profile: timed out after 20ms; restoring the previous DNS failed: timed out after 20ms" {
+		t.Errorf("unexpected message: %s", dnsMsg.message)
+	}
+}
+
+// TestRestorePrevious_UndoesApply tests that "u" restores the DNS from before the last apply.
+func TestRestorePrevious_UndoesApply(t *testing.T) {
+	model, mock := testModel()
+	model = model.WithSnapshots(&snapshot.Store{Dir: t.TempDir()})
+
+	model.applyProfile("cloudflare", config.Profile{Servers: []string{"1.1.1.1"}})()
+	if got := mock.DNSServers["Wi-Fi"]; got[0] != "1.1.1.1" {
+		t.Fatalf("expected profile to be applied, got %v", got)
+	}
+
+	newModel, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m := newModel.(Model)
+	if m.statusMsg != "Restoring previous DNS... (esc to cancel)" {
+		t.Errorf("unexpected status: %s", m.statusMsg)
+	}
+	result := cmd()
+
+	dnsMsg, ok := result.(dnsChangedMsg)
+	if !ok {
+		t.Fatal("expected dnsChangedMsg")
+	}
+	if !dnsMsg.success || dnsMsg.message != "Restored previous DNS: Wi-Fi" {
+		t.Errorf("unexpected result: %+v", dnsMsg)
+	}
+	if got := mock.DNSServers["Wi-Fi"]; len(got) != 2 || got[0] != "8.8.8.8" {
+		t.Errorf("expected 8.8.8.8 to be restored, got %v", got)
+	}
+}
+
+// TestRestorePrevious_NoSnapshot tests restoring before any change was made.
+func TestRestorePrevious_NoSnapshot(t *testing.T) {
+	model, _ := testModel()
+	model = model.WithSnapshots(&snapshot.Store{Dir: t.TempDir()})
+
+	result := model.restorePrevious(context.Background())
+
+	dnsMsg, ok := result.(dnsChangedMsg)
+	if !ok {
+		t.Fatal("expected dnsChangedMsg")
+	}
+	if dnsMsg.success || dnsMsg.message != "No previous DNS to restore" {
+		t.Errorf("unexpected result: %+v", dnsMsg)
+	}
+}
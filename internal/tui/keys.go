@@ -7,12 +7,14 @@ type KeyMap struct {
 	Up            key.Binding
 	Down          key.Binding
 	Select        key.Binding
+	Toggle        key.Binding
 	Back          key.Binding
 	Quit          key.Binding
 	SwitchProfile key.Binding
 	ClearDNS      key.Binding
 	ChangeService key.Binding
 	Refresh       key.Binding
+	Restore       key.Binding
 }
 
 // DefaultKeyMap returns the default keybindings.
@@ -27,9 +29,13 @@ func DefaultKeyMap() KeyMap {
 			key.WithHelp("↓/j", "down"),
 		),
 		Select: key.NewBinding(
-			key.WithKeys("enter", " "),
+			key.WithKeys("enter"),
 			key.WithHelp("enter", "select"),
 		),
+		Toggle: key.NewBinding(
+			key.WithKeys(" ", "x"),
+			key.WithHelp("space", "toggle"),
+		),
 		Back: key.NewBinding(
 			key.WithKeys("esc", "backspace"),
 			key.WithHelp("esc", "back"),
@@ -54,5 +60,9 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("r"),
 			key.WithHelp("r", "refresh"),
 		),
+		Restore: key.NewBinding(
+			key.WithKeys("u"),
+			key.WithHelp("u", "restore previous"),
+		),
 	}
 }
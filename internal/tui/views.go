@@ -2,9 +2,11 @@ package tui
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/nycjv321/dnsctl/internal/config"
+	"github.com/nycjv321/dnsctl/internal/dns"
 )
 
 // View represents the current view state.
@@ -27,6 +29,11 @@ func (m Model) renderMainView() string {
 	// Current service
 	b.WriteString(fmt.Sprintf("Service: %s\n", selectedStyle.Render(m.currentService)))
 
+	// Services marked in the service list, if more than the current one
+	if len(m.targetServices) > 1 {
+		b.WriteString(fmt.Sprintf("Targets: %s\n", normalStyle.Render(strings.Join(m.targetServices, ", "))))
+	}
+
 	// Current DNS servers
 	b.WriteString("DNS:     ")
 	if len(m.currentDNS) == 0 {
@@ -36,6 +43,33 @@ func (m Model) renderMainView() string {
 	}
 	b.WriteString("\n")
 
+	// Current domains
+	if !m.currentDomains.IsEmpty() {
+		b.WriteString("Domains: ")
+		b.WriteString(normalStyle.Render(strings.Join(m.currentDomains.Entries(), ", ")))
+		b.WriteString("\n")
+	}
+
+	// Matching profile
+	b.WriteString("Profile: ")
+	if active := m.activeProfiles(); len(active) == 0 && len(m.currentDNS) == 0 {
+		// Without a DHCP profile, nothing matches but no servers are set
+		b.WriteString(dimStyle.Render("DHCP (automatic)"))
+	} else if len(active) == 0 {
+		b.WriteString(dimStyle.Render("none (custom servers)"))
+	} else {
+		b.WriteString(normalStyle.Render(strings.Join(active, ", ")))
+	}
+	b.WriteString("\n")
+
+	// Whether the active profile survives a reboot
+	switch m.activePersistence() {
+	case dns.Persistent:
+		b.WriteString("Reboot:  " + normalStyle.Render("kept (persistent)") + "\n")
+	case dns.Runtime:
+		b.WriteString("Reboot:  " + warningStyle.Render("lost (runtime only)") + "\n")
+	}
+
 	// Status message
 	if m.statusMsg != "" {
 		b.WriteString("\n")
@@ -57,9 +91,10 @@ func (m Model) renderMainView() string {
 // renderMainHelp renders the help text for the main view.
 func (m Model) renderMainHelp() string {
 	return fmt.Sprintf(
-		"%s switch profile  %s clear DNS  %s change service  %s refresh  %s quit",
+		"%s switch profile  %s clear DNS  %s restore previous  %s change service  %s refresh  %s quit",
 		keyStyle.Render("[p]"),
 		keyStyle.Render("[c]"),
+		keyStyle.Render("[u]"),
 		keyStyle.Render("[s]"),
 		keyStyle.Render("[r]"),
 		keyStyle.Render("[q]"),
@@ -86,8 +121,15 @@ func (m Model) renderProfilesView() string {
 			style = selectedStyle
 		}
 
+		// Mark active profile
+		suffix := ""
+		if m.isActiveProfile(name) {
+			suffix = dimStyle.Render(" (active)")
+		}
+
 		b.WriteString(cursor)
 		b.WriteString(style.Render(name))
+		b.WriteString(suffix)
 		b.WriteString("\n")
 
 		// Show description and servers for selected item
@@ -100,6 +142,10 @@ func (m Model) renderProfilesView() string {
 			} else {
 				b.WriteString(fmt.Sprintf("    %s\n", dimStyle.Render("Servers: "+strings.Join(profile.Servers, ", "))))
 			}
+			if profile.HasDomains() {
+				domains := dns.Domains{Search: profile.Domains, RouteOnly: profile.RouteOnlyDomains}
+				b.WriteString(fmt.Sprintf("    %s\n", dimStyle.Render("Domains: "+strings.Join(domains.Entries(), ", "))))
+			}
 		}
 	}
 
@@ -138,6 +184,12 @@ func (m Model) renderServicesView() string {
 			style = selectedStyle
 		}
 
+		// Mark services targeted by the next change
+		check := "[ ] "
+		if slices.Contains(m.targetServices, service) {
+			check = "[x] "
+		}
+
 		// Mark current service
 		suffix := ""
 		if service == m.currentService {
@@ -145,6 +197,7 @@ func (m Model) renderServicesView() string {
 		}
 
 		b.WriteString(cursor)
+		b.WriteString(check)
 		b.WriteString(style.Render(service))
 		b.WriteString(suffix)
 		b.WriteString("\n")
@@ -162,11 +215,23 @@ func (m Model) renderServicesView() string {
 
 	// Help
 	b.WriteString("\n")
-	b.WriteString(helpStyle.Render(m.renderListHelp()))
+	b.WriteString(helpStyle.Render(m.renderServicesHelp()))
 
 	return b.String()
 }
 
+// renderServicesHelp renders the help text for the service list.
+func (m Model) renderServicesHelp() string {
+	return fmt.Sprintf(
+		"%s navigate  %s toggle  %s select  %s back  %s quit",
+		keyStyle.Render("[↑/↓]"),
+		keyStyle.Render("[space]"),
+		keyStyle.Render("[enter]"),
+		keyStyle.Render("[esc]"),
+		keyStyle.Render("[q]"),
+	)
+}
+
 // renderListHelp renders the help text for list views.
 func (m Model) renderListHelp() string {
 	return fmt.Sprintf(
@@ -196,3 +261,30 @@ func (m Model) getSelectedService() (string, bool) {
 	}
 	return "", false
 }
+
+// activeProfiles returns the names of profiles matching the current DNS servers.
+func (m Model) activeProfiles() []string {
+	return m.config.MatchProfiles(m.currentDNS, len(m.currentDNS) == 0, m.config.Settings.MatchOptions())
+}
+
+// activePersistence returns how long the active profile's servers last:
+// the persistence it is applied with, or else the backend's own. It is
+// empty without servers from a profile, or if the backend cannot tell.
+func (m Model) activePersistence() dns.Persistence {
+	active := m.activeProfiles()
+	if len(m.currentDNS) == 0 || len(active) == 0 {
+		return ""
+	}
+
+	profile, _ := m.config.GetProfile(active[0])
+	if persistence := m.config.Settings.PersistenceFor(profile); persistence != "" {
+		return dns.Persistence(persistence)
+	}
+	return dns.PersistenceOf(m.dnsClient)
+}
+
+// isActiveProfile returns true if the named profile matches the current DNS servers.
+func (m Model) isActiveProfile(name string) bool {
+	profile, ok := m.config.GetProfile(name)
+	return ok && profile.Matches(m.currentDNS, m.config.Settings.MatchOptions())
+}
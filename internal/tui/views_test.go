@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/nycjv321/dnsctl/internal/config"
 	"github.com/nycjv321/dnsctl/internal/dns"
 )
 
@@ -260,6 +261,25 @@ func TestRenderServicesView_ShowsCurrentMarker(t *testing.T) {
 	}
 }
 
+// TestRenderServicesView_ShowsTargets tests that marked services are checked.
+func TestRenderServicesView_ShowsTargets(t *testing.T) {
+	mock := dns.NewMockClient()
+	cfg := testConfig()
+	model := NewModel(cfg, mock)
+	model.currentView = ViewServices
+	model.services = []string{"Wi-Fi", "Ethernet"}
+	model.targetServices = []string{"Ethernet"}
+
+	output := model.renderServicesView()
+
+	if !strings.Contains(output, "[x] Ethernet") {
+		t.Errorf("expected Ethernet to be checked, got:\n%s", output)
+	}
+	if !strings.Contains(output, "[ ] Wi-Fi") {
+		t.Errorf("expected Wi-Fi to be unchecked, got:\n%s", output)
+	}
+}
+
 // TestRenderServicesView_ShowsTitle tests that services view shows title.
 func TestRenderServicesView_ShowsTitle(t *testing.T) {
 	mock := dns.NewMockClient()
@@ -460,3 +480,108 @@ func TestRenderMainHelp(t *testing.T) {
 		t.Error("expected help to contain 'quit'")
 	}
 }
+
+// TestRenderMainView_ShowsActiveProfile tests that the matching profile is shown.
+func TestRenderMainView_ShowsActiveProfile(t *testing.T) {
+	mock := dns.NewMockClient()
+	cfg := testConfig()
+	model := NewModel(cfg, mock)
+	model.currentDNS = []string{"1.0.0.1", "1.1.1.1"}
+
+	output := model.renderMainView()
+
+	if !strings.Contains(output, "Profile: cloudflare") {
+		t.Errorf("expected output to show cloudflare as active, got:\n%s", output)
+	}
+}
+
+// TestRenderMainView_ShowsNoActiveProfile tests custom servers without a profile.
+func TestRenderMainView_ShowsNoActiveProfile(t *testing.T) {
+	mock := dns.NewMockClient()
+	cfg := testConfig()
+	model := NewModel(cfg, mock)
+	model.currentDNS = []string{"9.9.9.9"}
+
+	output := model.renderMainView()
+
+	if !strings.Contains(output, "none (custom servers)") {
+		t.Errorf("expected output to show no active profile, got:\n%s", output)
+	}
+}
+
+// TestRenderMainView_ShowsDHCPWithoutProfile tests that no servers are
+// shown as DHCP even if no DHCP profile exists.
+func TestRenderMainView_ShowsDHCPWithoutProfile(t *testing.T) {
+	mock := dns.NewMockClient()
+	cfg := testConfig()
+	delete(cfg.Profiles, "dhcp")
+	model := NewModel(cfg, mock)
+	model.currentDNS = nil
+
+	output := model.renderMainView()
+
+	if !strings.Contains(output, "Profile: DHCP (automatic)") {
+		t.Errorf("expected output to show DHCP, got:\n%s", output)
+	}
+	if strings.Contains(output, "custom servers") {
+		t.Errorf("expected no custom servers, got:\n%s", output)
+	}
+}
+
+// TestRenderProfilesView_MarksActiveProfile tests that the active profile is marked.
+func TestRenderProfilesView_MarksActiveProfile(t *testing.T) {
+	mock := dns.NewMockClient()
+	cfg := testConfig()
+	model := NewModel(cfg, mock)
+	model.currentView = ViewProfiles
+	model.currentDNS = []string{"8.8.4.4", "8.8.8.8"}
+
+	output := model.renderProfilesView()
+
+	if !strings.Contains(output, "google (active)") {
+		t.Errorf("expected google to be marked active, got:\n%s", output)
+	}
+	if strings.Contains(output, "cloudflare (active)") {
+		t.Error("expected cloudflare not to be marked active")
+	}
+}
+
+// TestRenderMainView_ShowsPersistence tests that the main view tells
+// whether the active profile survives a reboot.
+func TestRenderMainView_ShowsPersistence(t *testing.T) {
+	mock := dns.NewMockClient()
+	mock.PersistenceMode = dns.Runtime
+	cfg := testConfig()
+	model := NewModel(cfg, mock)
+	model.currentDNS = []string{"1.0.0.1", "1.1.1.1"}
+
+	output := model.renderMainView()
+
+	if !strings.Contains(output, "Reboot:  lost (runtime only)") {
+		t.Errorf("expected runtime persistence, got:\n%s", output)
+	}
+
+	cloudflare := cfg.Profiles["cloudflare"]
+	cloudflare.Persistence = config.PersistencePersistent
+	cfg.Profiles["cloudflare"] = cloudflare
+
+	output = model.renderMainView()
+
+	if !strings.Contains(output, "Reboot:  kept (persistent)") {
+		t.Errorf("expected the profile's persistence, got:\n%s", output)
+	}
+}
+
+// TestRenderMainView_HidesUnknownPersistence tests that nothing is shown
+// for custom servers or backends that cannot tell.
+func TestRenderMainView_HidesUnknownPersistence(t *testing.T) {
+	mock := dns.NewMockClient()
+	model := NewModel(testConfig(), mock)
+	model.currentDNS = []string{"1.0.0.1", "1.1.1.1"}
+
+	output := model.renderMainView()
+
+	if strings.Contains(output, "Reboot:") {
+		t.Errorf("expected no persistence, got:\n%s", output)
+	}
+}